@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterPodSetSpec defines the desired state of ClusterPodSet
+type ClusterPodSetSpec struct {
+	// Replicas is the number of desired pods in every target namespace.
+	Replicas *int32 `json:"replicas,omitempty" protobuf:"varint,1,opt,name=replicas"`
+
+	// NamespaceSelector selects the target namespaces by their labels.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty" protobuf:"bytes,2,opt,name=namespaceSelector"`
+
+	// Namespaces lists target namespaces explicitly, in addition to the ones
+	// selected by NamespaceSelector.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty" protobuf:"bytes,3,rep,name=namespaces"`
+
+	// Selector is a label query over pods that should match the pods count.
+	Selector *metav1.LabelSelector `json:"selector" protobuf:"bytes,4,opt,name=selector"`
+
+	// Template describes the pods that will be created.
+	Template v1.PodTemplateSpec `json:"template" protobuf:"bytes,5,opt,name=template"`
+}
+
+// NamespaceReplicaStatus is the replica count of a ClusterPodSet in one namespace.
+type NamespaceReplicaStatus struct {
+	Namespace string `json:"namespace" protobuf:"bytes,1,opt,name=namespace"`
+	// +optional
+	Replicas int32 `json:"replicas,omitempty" protobuf:"varint,2,opt,name=replicas"`
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty" protobuf:"varint,3,opt,name=readyReplicas"`
+	// +optional
+	AvailableReplicas int32 `json:"availableReplicas,omitempty" protobuf:"varint,4,opt,name=availableReplicas"`
+}
+
+// ClusterPodSetStatus defines the observed state of ClusterPodSet
+type ClusterPodSetStatus struct {
+	// ObservedGeneration reflects the generation of the most recently observed ClusterPodSet.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty" protobuf:"varint,1,opt,name=observedGeneration"`
+
+	// Total number of non-terminated pods across all target namespaces.
+	// +optional
+	Replicas int32 `json:"replicas,omitempty" protobuf:"varint,2,opt,name=replicas"`
+
+	// Total number of ready pods across all target namespaces.
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty" protobuf:"varint,3,opt,name=readyReplicas"`
+
+	// Total number of available pods across all target namespaces.
+	// +optional
+	AvailableReplicas int32 `json:"availableReplicas,omitempty" protobuf:"varint,4,opt,name=availableReplicas"`
+
+	// Namespaces reports the replicas per target namespace.
+	// +optional
+	Namespaces []NamespaceReplicaStatus `json:"namespaces,omitempty" protobuf:"bytes,5,rep,name=namespaces"`
+
+	// Represents the latest available observations of the cluster pod set's current state.
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	Conditions []PodSetCondition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,6,rep,name=conditions"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster,shortName=cps
+//+kubebuilder:printcolumn:name="REPLICAS",type=integer,JSONPath=`.status.replicas`
+//+kubebuilder:printcolumn:name="READY",type=integer,JSONPath=`.status.readyReplicas`
+//+kubebuilder:printcolumn:name="AVAILABLE",type=integer,JSONPath=`.status.availableReplicas`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ClusterPodSet is the Schema for the clusterpodsets API. It maintains a
+// PodSet in each of its target namespaces.
+type ClusterPodSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterPodSetSpec   `json:"spec,omitempty"`
+	Status ClusterPodSetStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterPodSetList contains a list of ClusterPodSet
+type ClusterPodSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterPodSet `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterPodSet{}, &ClusterPodSetList{})
+}
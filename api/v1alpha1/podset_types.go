@@ -18,7 +18,10 @@ package v1alpha1
 
 import (
 	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // PodSetSpec defines the desired state of PodSet
@@ -27,14 +30,842 @@ type PodSetSpec struct {
 	Replicas *int32 `json:"replicas,omitempty" protobuf:"varint,1,opt,name=replicas"`
 
 	// Selector is a label query over pods that should match the pods count.
+	// It must match the template labels, and is immutable once the PodSet is
+	// created.
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="selector is immutable"
 	Selector *metav1.LabelSelector `json:"selector" protobuf:"bytes,2,opt,name=selector"`
 
 	// Template describes the pods that will be created.
 	Template v1.PodTemplateSpec `json:"template" protobuf:"bytes,3,opt,name=template"`
 
-	// Indicates that the PodSet is paused.
+	// Indicates that the PodSet is paused: its pods are neither created nor
+	// deleted while it is set, but its status is still refreshed.
 	// +optional
 	Paused bool `json:"paused,omitempty" protobuf:"varint,7,opt,name=paused"`
+
+	// ScalePolicy limits how fast pods are created and deleted.
+	// +optional
+	ScalePolicy *ScalePolicy `json:"scalePolicy,omitempty" protobuf:"bytes,8,opt,name=scalePolicy"`
+
+	// ReplicaSource computes the desired replicas from an external source instead
+	// of spec.replicas, which is only used until the source could be evaluated.
+	// +optional
+	ReplicaSource *ReplicaSource `json:"replicaSource,omitempty" protobuf:"bytes,9,opt,name=replicaSource"`
+
+	// Parameters are user supplied values available to the pod template as
+	// {{ .Parameters.<key> }}, next to {{ .Index }}, {{ .Name }}, {{ .Namespace }}
+	// and {{ .RevisionHash }}. The template is expanded when a pod is created.
+	// +optional
+	Parameters map[string]string `json:"parameters,omitempty" protobuf:"bytes,10,rep,name=parameters"`
+
+	// OwnerReferencePolicy controls the owner references set on the pods and
+	// auxiliary objects of the PodSet.
+	// +optional
+	OwnerReferencePolicy *OwnerReferencePolicy `json:"ownerReferencePolicy,omitempty" protobuf:"bytes,11,opt,name=ownerReferencePolicy"`
+
+	// ClassName is the name of the PodSetClass providing the defaults of the
+	// fields left unset in this spec.
+	// +optional
+	ClassName string `json:"className,omitempty" protobuf:"bytes,12,opt,name=className"`
+
+	// ImageResolution controls whether the image tags of the template are
+	// resolved to digests when the template changes, so that all the pods of a
+	// revision run the same image even if a tag is pushed again. Defaults to Tag.
+	// +optional
+	ImageResolution ImageResolutionPolicy `json:"imageResolution,omitempty" protobuf:"bytes,13,opt,name=imageResolution,casttype=ImageResolutionPolicy"`
+
+	// PrePull, when set, pulls the images of a new template on the nodes
+	// running the pods of the PodSet as soon as the template changes, so that
+	// replacing the pods isn't slowed down by image pulls.
+	// +optional
+	PrePull *PrePullPolicy `json:"prePull,omitempty" protobuf:"bytes,14,opt,name=prePull"`
+
+	// ImageVerification, when set, only creates pods once the cosign signatures
+	// of every image of the template are verified.
+	// +optional
+	ImageVerification *ImageVerification `json:"imageVerification,omitempty" protobuf:"bytes,15,opt,name=imageVerification"`
+
+	// ImagePullSecrets are added to the image pull secrets of the pods.
+	// +optional
+	ImagePullSecrets []ImagePullSecret `json:"imagePullSecrets,omitempty" protobuf:"bytes,16,rep,name=imagePullSecrets"`
+
+	// ReconcileTimeout overrides the deadline of the reconciles of the PodSet
+	// the operator is configured with, e.g. for a PodSet with many pods.
+	// +optional
+	ReconcileTimeout *metav1.Duration `json:"reconcileTimeout,omitempty" protobuf:"bytes,17,opt,name=reconcileTimeout"`
+
+	// MinReadySeconds is the minimum number of seconds a newly created pod
+	// must be ready, without any of its containers crashing, to be counted as
+	// available. Defaults to 0, pods are available as soon as they are ready.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MinReadySeconds int32 `json:"minReadySeconds,omitempty" protobuf:"varint,18,opt,name=minReadySeconds"`
+
+	// UpdateStrategy is how the pods of a previous template are replaced when
+	// the template changes. Defaults to RollingUpdate.
+	// +optional
+	UpdateStrategy *PodSetUpdateStrategy `json:"updateStrategy,omitempty" protobuf:"bytes,19,opt,name=updateStrategy"`
+
+	// RevisionHistoryLimit is the number of previous templates kept as
+	// ControllerRevisions, to roll back to. Defaults to 10.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty" protobuf:"varint,20,opt,name=revisionHistoryLimit"`
+
+	// PodManagementPolicy is how pods are created and deleted. Defaults to
+	// Parallel.
+	// +optional
+	PodManagementPolicy PodManagementPolicyType `json:"podManagementPolicy,omitempty" protobuf:"bytes,21,opt,name=podManagementPolicy,casttype=PodManagementPolicyType"`
+
+	// IdentityPolicy is how the pods are named. Defaults to Random.
+	// +optional
+	IdentityPolicy PodIdentityPolicyType `json:"identityPolicy,omitempty" protobuf:"bytes,22,opt,name=identityPolicy,casttype=PodIdentityPolicyType"`
+
+	// ServiceName is the name of the headless Service the controller manages
+	// for the pods. The pods are put in its subdomain with the hostname
+	// <podset>-<index>, giving each replica a stable DNS record.
+	// +optional
+	ServiceName string `json:"serviceName,omitempty" protobuf:"bytes,23,opt,name=serviceName"`
+
+	// DeletionPolicy is what happens to the pods and the auxiliary objects
+	// when the podset is deleted. Defaults to Delete.
+	// +optional
+	DeletionPolicy DeletionPolicyType `json:"deletionPolicy,omitempty" protobuf:"bytes,24,opt,name=deletionPolicy,casttype=DeletionPolicyType"`
+
+	// ProgressDeadlineSeconds is how long the podset may make no progress
+	// towards its updated and available replicas before its Progressing
+	// condition is set to False with the ProgressDeadlineExceeded reason.
+	// Defaults to 600.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	ProgressDeadlineSeconds *int32 `json:"progressDeadlineSeconds,omitempty" protobuf:"varint,25,opt,name=progressDeadlineSeconds"`
+
+	// ScaleDownPolicy is which pods are deleted first when scaling down.
+	// Defaults to LeastReady.
+	// +optional
+	ScaleDownPolicy ScaleDownPolicyType `json:"scaleDownPolicy,omitempty" protobuf:"bytes,26,opt,name=scaleDownPolicy,casttype=ScaleDownPolicyType"`
+
+	// Scheduling is merged into the pods of the template, steering the whole
+	// podset without editing the template.
+	// +optional
+	Scheduling *PodSetScheduling `json:"scheduling,omitempty" protobuf:"bytes,27,opt,name=scheduling"`
+
+	// SpreadPolicy, when set, spreads the pods across the zones and the nodes
+	// with topology spread constraints, unless the template already constrains
+	// the spread over the same topology key. The skew is reported in
+	// status.spread.
+	// +optional
+	SpreadPolicy *SpreadPolicy `json:"spreadPolicy,omitempty" protobuf:"bytes,28,opt,name=spreadPolicy"`
+
+	// PriorityClassName is the PriorityClass of the pods, overriding the one
+	// of the template. The podset isn't scaled up while it doesn't exist.
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty" protobuf:"bytes,29,opt,name=priorityClassName"`
+
+	// SchedulingGates are added to the pods when they are created, holding
+	// their scheduling until the gates are removed. A gate is lifted from the
+	// pods, and no longer added to new ones, once it is removed from this
+	// list or listed in the pixiu.pixiu.io/lift-scheduling-gates annotation.
+	// Requires Kubernetes 1.27 or later.
+	// +optional
+	// +listType=set
+	SchedulingGates []string `json:"schedulingGates,omitempty" protobuf:"bytes,30,rep,name=schedulingGates"`
+
+	// Suspend deletes all the active pods of the PodSet and creates none
+	// while it is set, like for Jobs. The pods are created again once it is
+	// unset.
+	// +optional
+	Suspend bool `json:"suspend,omitempty" protobuf:"varint,31,opt,name=suspend"`
+
+	// TerminationGracePeriodSeconds overrides the termination grace period of
+	// the template, and is the grace period the pods are deleted with when
+	// the podset is scaled down, updated or deleted. 0 kills the pods
+	// immediately.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	TerminationGracePeriodSeconds *int64 `json:"terminationGracePeriodSeconds,omitempty" protobuf:"varint,32,opt,name=terminationGracePeriodSeconds"`
+
+	// PodDeletionPropagation is the propagation policy the pods are deleted
+	// with, Orphan to keep the objects they own. Defaults to the policy of
+	// the API server, Background for pods.
+	// +optional
+	// +kubebuilder:validation:Enum=Orphan;Background;Foreground
+	PodDeletionPropagation *metav1.DeletionPropagation `json:"podDeletionPropagation,omitempty" protobuf:"bytes,53,opt,name=podDeletionPropagation,casttype=k8s.io/apimachinery/pkg/apis/meta/v1.DeletionPropagation"`
+
+	// EnvOverrides are merged into the environment of all the containers and
+	// init containers of the pods.
+	// +optional
+	EnvOverrides *EnvOverrides `json:"envOverrides,omitempty" protobuf:"bytes,33,opt,name=envOverrides"`
+
+	// Sidecars are containers added to the pods, e.g. logging or metrics
+	// agents. A sidecar named like a container of the template isn't added.
+	// +optional
+	Sidecars []Sidecar `json:"sidecars,omitempty" protobuf:"bytes,34,rep,name=sidecars"`
+
+	// PodNamePolicy customizes the names of the pods.
+	// +optional
+	PodNamePolicy *PodNamePolicy `json:"podNamePolicy,omitempty" protobuf:"bytes,35,opt,name=podNamePolicy"`
+
+	// Schedules replace spec.replicas while they are active, the first active
+	// one wins. The active schedule is reported in status.activeSchedule.
+	// +optional
+	Schedules []ReplicaSchedule `json:"schedules,omitempty" protobuf:"bytes,36,rep,name=schedules"`
+
+	// ActivationReplicas is the number of replicas a podset scaled below it
+	// is scaled up to when the pixiu.pixiu.io/activate annotation is set,
+	// waking up a podset scaled to zero. Defaults to 1.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	ActivationReplicas *int32 `json:"activationReplicas,omitempty" protobuf:"varint,37,opt,name=activationReplicas"`
+
+	// ZoneDistribution distributes the replicas over topology zones. The pods
+	// are created with a node selector on their zone, and deleted from the
+	// zones above their share first.
+	// +optional
+	ZoneDistribution []ZoneReplicas `json:"zoneDistribution,omitempty" protobuf:"bytes,38,rep,name=zoneDistribution"`
+
+	// SpreadAcrossNodes adds a pod anti-affinity on the selector of the
+	// podset to the pods, so that no two of them run on the same node.
+	// Defaults to Disabled.
+	// +optional
+	SpreadAcrossNodes NodeSpreadType `json:"spreadAcrossNodes,omitempty" protobuf:"bytes,39,opt,name=spreadAcrossNodes,casttype=NodeSpreadType"`
+
+	// PropagateMetadata copies labels and annotations of the PodSet onto its
+	// pods, e.g. cost allocation or ownership labels.
+	// +optional
+	PropagateMetadata *PropagateMetadata `json:"propagateMetadata,omitempty" protobuf:"bytes,40,opt,name=propagateMetadata"`
+
+	// Overrides customize the pods of some indexes, e.g. giving more memory
+	// to the primary replica 0. A pod whose override changes is replaced like
+	// on a template update. Requires the Ordinal identity policy.
+	// +optional
+	Overrides []ReplicaOverride `json:"overrides,omitempty" protobuf:"bytes,41,rep,name=overrides"`
+
+	// LifecycleDefaults are the lifecycle hooks of the containers of the pods
+	// that don't declare their own.
+	// +optional
+	LifecycleDefaults *LifecycleDefaults `json:"lifecycleDefaults,omitempty" protobuf:"bytes,42,opt,name=lifecycleDefaults"`
+
+	// ReadinessGates are added to the readiness gates of the pods. The pods
+	// waiting on every gate are reported in status.readinessGates.
+	// +optional
+	ReadinessGates []v1.PodReadinessGate `json:"readinessGates,omitempty" protobuf:"bytes,43,rep,name=readinessGates"`
+
+	// MinReplicas is the fewest replicas the podset runs, whatever spec.replicas,
+	// its schedules or its replica source ask for.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MinReplicas *int32 `json:"minReplicas,omitempty" protobuf:"varint,44,opt,name=minReplicas"`
+
+	// MaxReplicas is the most replicas the podset runs, whatever spec.replicas,
+	// its schedules or its replica source ask for.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxReplicas *int32 `json:"maxReplicas,omitempty" protobuf:"varint,45,opt,name=maxReplicas"`
+
+	// FinishedPodTTLSeconds is how long the pods of the podset that
+	// succeeded or failed are kept before they are deleted. They don't count
+	// as replicas, and are kept until they are deleted otherwise when unset.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	FinishedPodTTLSeconds *int32 `json:"finishedPodTTLSeconds,omitempty" protobuf:"varint,46,opt,name=finishedPodTTLSeconds"`
+
+	// PodFailurePolicy is what happens to the pods of the podset that failed,
+	// e.g. evicted or killed by their node. The failed pods are replaced and
+	// kept when unset.
+	// +optional
+	PodFailurePolicy *PodFailurePolicy `json:"podFailurePolicy,omitempty" protobuf:"bytes,47,opt,name=podFailurePolicy"`
+
+	// CrashLoopPolicy is what happens to the pods of the podset whose
+	// containers keep restarting, e.g. on a node with a broken runtime.
+	// They are left to the kubelet when unset.
+	// +optional
+	CrashLoopPolicy *CrashLoopPolicy `json:"crashLoopPolicy,omitempty" protobuf:"bytes,54,opt,name=crashLoopPolicy"`
+
+	// StandbyReplicas is the number of pods created above the replicas and
+	// held un-ready by a readiness gate. They are scheduled and pull their
+	// images, and are promoted to replicas when the podset is scaled up.
+	// The random identity policy is required.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	StandbyReplicas *int32 `json:"standbyReplicas,omitempty" protobuf:"varint,48,opt,name=standbyReplicas"`
+
+	// Hibernation scales the podset to zero during its windows, and back to
+	// its replicas outside of them.
+	// +optional
+	Hibernation *Hibernation `json:"hibernation,omitempty" protobuf:"bytes,49,opt,name=hibernation"`
+
+	// PreDeleteHook is called when the podset is deleted, before its pods
+	// are drained, e.g. to deregister it or take a backup.
+	// +optional
+	PreDeleteHook *PreDeleteHook `json:"preDeleteHook,omitempty" protobuf:"bytes,50,opt,name=preDeleteHook"`
+
+	// UnreachablePodPolicy is what happens to the pods of the podset on nodes
+	// that aren't ready, which are only evicted after 5 minutes and can't
+	// finish terminating until their node is back. They are left to the node
+	// lifecycle controller when unset.
+	// +optional
+	UnreachablePodPolicy *UnreachablePodPolicy `json:"unreachablePodPolicy,omitempty" protobuf:"bytes,51,opt,name=unreachablePodPolicy"`
+
+	// BurstReplicas is the maximum number of pods created or deleted in a
+	// single reconcile, and overrides the one the operator is configured with.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	BurstReplicas *int32 `json:"burstReplicas,omitempty" protobuf:"varint,52,opt,name=burstReplicas"`
+}
+
+// LifecycleDefaults is the default lifecycle hooks of the containers of a
+// PodSet.
+type LifecycleDefaults struct {
+	// PreStop is the preStop hook of the containers without one, commonly a
+	// sleep letting the endpoints of the pod be removed before it stops.
+	// +optional
+	PreStop *v1.LifecycleHandler `json:"preStop,omitempty" protobuf:"bytes,1,opt,name=preStop"`
+}
+
+// ReplicaOverride customizes the pod of an index of a PodSet.
+type ReplicaOverride struct {
+	// Index is the index of the pod.
+	// +kubebuilder:validation:Minimum=0
+	Index int32 `json:"index" protobuf:"varint,1,opt,name=index"`
+
+	// Containers override the containers of the template with the same name.
+	// +optional
+	Containers []ContainerOverride `json:"containers,omitempty" protobuf:"bytes,2,rep,name=containers"`
+
+	// NodeSelector is merged into the node selector of the pod, overriding
+	// the template for the same keys.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty" protobuf:"bytes,3,rep,name=nodeSelector"`
+}
+
+// ContainerOverride customizes a container of the pod of an index.
+type ContainerOverride struct {
+	// Name is the name of the container of the template.
+	Name string `json:"name" protobuf:"bytes,1,opt,name=name"`
+
+	// Resources replace the resources of the container.
+	// +optional
+	Resources *v1.ResourceRequirements `json:"resources,omitempty" protobuf:"bytes,2,opt,name=resources"`
+
+	// Env variables replace the variables of the container with the same
+	// name, and are appended otherwise.
+	// +optional
+	Env []v1.EnvVar `json:"env,omitempty" protobuf:"bytes,3,rep,name=env"`
+}
+
+// PropagateMetadata is the keys of the labels and annotations of a PodSet set
+// on its pods. The pods are updated when the values change, but the keys
+// removed from the PodSet are left on them, and the template wins for the
+// keys it sets.
+type PropagateMetadata struct {
+	// Labels are the keys of the labels to propagate.
+	// +optional
+	Labels []string `json:"labels,omitempty" protobuf:"bytes,1,rep,name=labels"`
+
+	// Annotations are the keys of the annotations to propagate.
+	// +optional
+	Annotations []string `json:"annotations,omitempty" protobuf:"bytes,2,rep,name=annotations"`
+}
+
+// NodeSpreadType is how strictly the pods of a PodSet avoid sharing a node.
+// +kubebuilder:validation:Enum=Required;Preferred;Disabled
+type NodeSpreadType string
+
+const (
+	// RequiredNodeSpread doesn't schedule a pod on a node running another
+	// pod of the PodSet.
+	RequiredNodeSpread NodeSpreadType = "Required"
+	// PreferredNodeSpread prefers the nodes not running another pod of the
+	// PodSet.
+	PreferredNodeSpread NodeSpreadType = "Preferred"
+	// DisabledNodeSpread adds no anti-affinity.
+	DisabledNodeSpread NodeSpreadType = "Disabled"
+)
+
+// PodFailurePolicy is what happens to the failed pods of a PodSet.
+type PodFailurePolicy struct {
+	// Type is Replace, Backoff or Leave. Defaults to Replace.
+	// +optional
+	Type PodFailurePolicyType `json:"type,omitempty" protobuf:"bytes,1,opt,name=type,casttype=PodFailurePolicyType"`
+
+	// BackoffSeconds is how long a failed pod is kept before it is replaced
+	// with the Backoff policy. It doubles with every other failed pod of the
+	// podset, up to 5 minutes. Defaults to 10.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	BackoffSeconds *int32 `json:"backoffSeconds,omitempty" protobuf:"varint,2,opt,name=backoffSeconds"`
+}
+
+// CrashLoopPolicy is what happens to the crash looping pods of a PodSet.
+type CrashLoopPolicy struct {
+	// Action is Replace or Report. Defaults to Report.
+	// +optional
+	Action CrashLoopAction `json:"action,omitempty" protobuf:"bytes,1,opt,name=action,casttype=CrashLoopAction"`
+
+	// MaxRestarts is the number of restarts of a container of a pod within
+	// the window from which the pod is crash looping.
+	// +kubebuilder:validation:Minimum=1
+	MaxRestarts int32 `json:"maxRestarts" protobuf:"varint,2,opt,name=maxRestarts"`
+
+	// WindowSeconds is the window the restarts are counted in. Defaults to
+	// 600.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	WindowSeconds *int32 `json:"windowSeconds,omitempty" protobuf:"varint,3,opt,name=windowSeconds"`
+
+	// BackoffSeconds is how long the controller waits after replacing a crash
+	// looping pod before it replaces another one of the podset with the
+	// Replace action. It doubles with every replacement within the window,
+	// up to 5 minutes. Defaults to 10.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	BackoffSeconds *int32 `json:"backoffSeconds,omitempty" protobuf:"varint,4,opt,name=backoffSeconds"`
+}
+
+// CrashLoopAction is what happens to the crash looping pods of a PodSet.
+// +kubebuilder:validation:Enum=Replace;Report
+type CrashLoopAction string
+
+const (
+	// ReplaceCrashLoopAction deletes the crash looping pods, so that their
+	// replacements are scheduled again, possibly on other nodes.
+	ReplaceCrashLoopAction CrashLoopAction = "Replace"
+	// ReportCrashLoopAction only reports the crash looping pods with the
+	// PodsCrashLooping condition.
+	ReportCrashLoopAction CrashLoopAction = "Report"
+)
+
+// UnreachablePodPolicy is what happens to the pods of a PodSet on nodes that
+// aren't ready.
+type UnreachablePodPolicy struct {
+	// ReplaceImmediately stops counting the pods on a node that isn't ready as
+	// replicas, so that they are replaced right away. The pods in excess are
+	// deleted once the node is back. The pods with fixed names are only
+	// replaced once force deleted.
+	// +optional
+	ReplaceImmediately bool `json:"replaceImmediately,omitempty" protobuf:"varint,1,opt,name=replaceImmediately"`
+
+	// ForceDeleteAfterSeconds is how long the node of a pod terminating, or
+	// whose phase is Unknown, hasn't been ready before the pod is deleted
+	// without grace period. The pods are never force deleted when unset.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	ForceDeleteAfterSeconds *int32 `json:"forceDeleteAfterSeconds,omitempty" protobuf:"varint,2,opt,name=forceDeleteAfterSeconds"`
+}
+
+// PodFailurePolicyType is what happens to the failed pods of a PodSet.
+// +kubebuilder:validation:Enum=Replace;Backoff;Leave
+type PodFailurePolicyType string
+
+const (
+	// ReplacePodFailurePolicy deletes the failed pods, and replaces them.
+	ReplacePodFailurePolicy PodFailurePolicyType = "Replace"
+	// BackoffPodFailurePolicy keeps the failed pods as replicas for a backoff,
+	// then deletes and replaces them.
+	BackoffPodFailurePolicy PodFailurePolicyType = "Backoff"
+	// LeavePodFailurePolicy keeps the failed pods as replicas for inspection,
+	// and neither deletes nor replaces them.
+	LeavePodFailurePolicy PodFailurePolicyType = "Leave"
+)
+
+// ZoneReplicas is the share of the replicas of a PodSet in a zone. Zones with
+// a number of replicas get them first, the remaining replicas are spread over
+// the other zones in proportion to their weight. Without a weight to share
+// them by, they are spread over the zones in turn, in their order.
+type ZoneReplicas struct {
+	// Zone is the topology.kubernetes.io/zone label of the nodes of the zone.
+	Zone string `json:"zone" protobuf:"bytes,1,opt,name=zone"`
+
+	// Replicas is the number of replicas of the zone.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Replicas *int32 `json:"replicas,omitempty" protobuf:"varint,2,opt,name=replicas"`
+
+	// Weight is the share of the remaining replicas of the zone, when
+	// replicas isn't set. Defaults to 1.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Weight *int32 `json:"weight,omitempty" protobuf:"varint,3,opt,name=weight"`
+}
+
+// ReplicaSchedule is a number of replicas for the minutes matching a cron
+// expression.
+type ReplicaSchedule struct {
+	// Name identifies the schedule in status.
+	Name string `json:"name" protobuf:"bytes,1,opt,name=name"`
+
+	// Schedule is the cron expression of the minutes the schedule is active,
+	// e.g. "* 9-17 * * 1-5" for weekdays from 9:00 to 18:00.
+	Schedule string `json:"schedule" protobuf:"bytes,2,opt,name=schedule"`
+
+	// Replicas is the number of desired pods while the schedule is active.
+	// +kubebuilder:validation:Minimum=0
+	Replicas int32 `json:"replicas" protobuf:"varint,3,opt,name=replicas"`
+
+	// TimeZone is the IANA time zone the schedule is evaluated in. Defaults
+	// to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty" protobuf:"bytes,4,opt,name=timeZone"`
+}
+
+// PreDeleteHook is an HTTP endpoint called before the pods of a deleted
+// PodSet are drained.
+type PreDeleteHook struct {
+	// URL receives a POST of the PodSet in JSON. The pods are drained once it
+	// responds with a 2xx status, the call is retried with a backoff
+	// otherwise. Its host must be allowed by the controller, and can't be a
+	// loopback or link-local address.
+	URL string `json:"url" protobuf:"bytes,1,opt,name=url"`
+
+	// TimeoutSeconds bounds each call. Defaults to 10.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty" protobuf:"varint,2,opt,name=timeoutSeconds"`
+}
+
+// Hibernation is when a PodSet is scaled to zero.
+type Hibernation struct {
+	// Windows are the cron expressions of the minutes the podset hibernates,
+	// e.g. "* 0-7,19-23 * * 1-5" for weekday nights.
+	Windows []string `json:"windows" protobuf:"bytes,1,rep,name=windows"`
+
+	// TimeZone is the IANA time zone the windows are evaluated in. Defaults
+	// to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty" protobuf:"bytes,2,opt,name=timeZone"`
+}
+
+// PodNamePolicy is how the pods of a PodSet are named.
+type PodNamePolicy struct {
+	// Prefix is the prefix of the names of the pods, followed by a random
+	// suffix. Defaults to "<podset>-".
+	// +optional
+	Prefix string `json:"prefix,omitempty" protobuf:"bytes,1,opt,name=prefix"`
+
+	// Template is the name of the pods, with the {podset}, {namespace} and
+	// {index} placeholders, e.g. "{podset}-{index}". It must contain {index}.
+	// The pods then have fixed names, as with the Ordinal identity policy,
+	// and Prefix is ignored.
+	// +optional
+	Template string `json:"template,omitempty" protobuf:"bytes,2,opt,name=template"`
+}
+
+// SidecarPosition is where a sidecar is added among the containers of a pod.
+// +kubebuilder:validation:Enum=Before;After
+type SidecarPosition string
+
+const (
+	// BeforeSidecarPosition adds the sidecar before the containers of the
+	// template, so that it is started first.
+	BeforeSidecarPosition SidecarPosition = "Before"
+
+	// AfterSidecarPosition adds the sidecar after the containers of the
+	// template.
+	AfterSidecarPosition SidecarPosition = "After"
+)
+
+// Sidecar is a container added to the pods of a PodSet.
+type Sidecar struct {
+	// Container is the sidecar container.
+	Container v1.Container `json:"container" protobuf:"bytes,1,opt,name=container"`
+
+	// Position is where the sidecar is added among the containers of the
+	// template. The sidecars of a position keep their order. Defaults to
+	// After.
+	// +optional
+	Position SidecarPosition `json:"position,omitempty" protobuf:"bytes,2,opt,name=position,casttype=SidecarPosition"`
+}
+
+// EnvOverrides is the environment added to the containers of a PodSet.
+type EnvOverrides struct {
+	// Env variables replace the variables of the containers with the same
+	// name, and are appended otherwise.
+	// +optional
+	Env []v1.EnvVar `json:"env,omitempty" protobuf:"bytes,1,rep,name=env"`
+
+	// EnvFrom sources are appended to the sources of the containers. As
+	// usual, the variables of env take precedence over them.
+	// +optional
+	EnvFrom []v1.EnvFromSource `json:"envFrom,omitempty" protobuf:"bytes,2,rep,name=envFrom"`
+}
+
+// SpreadPolicy is how the pods of a PodSet are spread across topology domains.
+type SpreadPolicy struct {
+	// MaxSkew is the largest difference allowed between the number of pods of
+	// two domains. Defaults to 1.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=1
+	MaxSkew int32 `json:"maxSkew,omitempty" protobuf:"varint,1,opt,name=maxSkew"`
+
+	// WhenUnsatisfiable is what the scheduler does with a pod that can't
+	// satisfy the skew, DoNotSchedule or ScheduleAnyway. Defaults to
+	// ScheduleAnyway.
+	// +optional
+	// +kubebuilder:validation:Enum=DoNotSchedule;ScheduleAnyway
+	WhenUnsatisfiable v1.UnsatisfiableConstraintAction `json:"whenUnsatisfiable,omitempty" protobuf:"bytes,2,opt,name=whenUnsatisfiable,casttype=k8s.io/api/core/v1.UnsatisfiableConstraintAction"`
+}
+
+// PodSetScheduling is the scheduling constraints added to the pods of a PodSet.
+type PodSetScheduling struct {
+	// NodeSelector is merged into the node selector of the pods, overriding
+	// the template for the same keys.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty" protobuf:"bytes,1,rep,name=nodeSelector"`
+
+	// Tolerations are added to the tolerations of the pods.
+	// +optional
+	Tolerations []v1.Toleration `json:"tolerations,omitempty" protobuf:"bytes,2,rep,name=tolerations"`
+}
+
+// ScaleDownPolicyType is which pods of a PodSet are deleted first when it is
+// scaled down.
+// +kubebuilder:validation:Enum=Newest;Oldest;Random;LeastReady
+type ScaleDownPolicyType string
+
+const (
+	// NewestScaleDownPolicy deletes the most recently created pods first.
+	NewestScaleDownPolicy ScaleDownPolicyType = "Newest"
+	// OldestScaleDownPolicy deletes the least recently created pods first.
+	OldestScaleDownPolicy ScaleDownPolicyType = "Oldest"
+	// RandomScaleDownPolicy deletes random pods.
+	RandomScaleDownPolicy ScaleDownPolicyType = "Random"
+	// LeastReadyScaleDownPolicy deletes the pods like ReplicaSets: the
+	// unscheduled, pending and not ready pods first, then the pods sharing a
+	// node with more pods of the PodSet, then the pods ready for the shortest
+	// time, restarted most often or newest.
+	LeastReadyScaleDownPolicy ScaleDownPolicyType = "LeastReady"
+)
+
+// DeletionPolicyType is what happens to the pods of a deleted PodSet.
+// +kubebuilder:validation:Enum=Delete;Orphan
+type DeletionPolicyType string
+
+const (
+	// DeletePodsPolicy deletes the pods and the auxiliary objects before the
+	// PodSet is removed.
+	DeletePodsPolicy DeletionPolicyType = "Delete"
+	// OrphanPodsPolicy removes the references to the PodSet from its pods and
+	// auxiliary objects, which are kept after it is removed.
+	OrphanPodsPolicy DeletionPolicyType = "Orphan"
+)
+
+// PodIdentityPolicyType is how the pods of a PodSet are named.
+// +kubebuilder:validation:Enum=Random;Ordinal
+type PodIdentityPolicyType string
+
+const (
+	// RandomPodIdentity names the pods after the PodSet with a random suffix.
+	RandomPodIdentity PodIdentityPolicyType = "Random"
+	// OrdinalPodIdentity names the pods <podset>-<index>, from 0 to replicas-1,
+	// and recreates a missing pod with the same name. A rolling update then
+	// deletes each pod before recreating it, maxSurge is ignored.
+	OrdinalPodIdentity PodIdentityPolicyType = "Ordinal"
+)
+
+// PodManagementPolicyType is how the pods of a PodSet are created and deleted.
+// +kubebuilder:validation:Enum=OrderedReady;Parallel
+type PodManagementPolicyType string
+
+const (
+	// OrderedReadyPodManagement creates pods one at a time by increasing index,
+	// and deletes them one at a time by decreasing index, each step waiting
+	// for all the pods to be ready.
+	OrderedReadyPodManagement PodManagementPolicyType = "OrderedReady"
+	// ParallelPodManagement creates and deletes the pods in bursts, without
+	// waiting for them to be ready.
+	ParallelPodManagement PodManagementPolicyType = "Parallel"
+)
+
+// PodSetUpdateStrategyType is how the pods of a PodSet are updated.
+// +kubebuilder:validation:Enum=RollingUpdate;OnDelete
+type PodSetUpdateStrategyType string
+
+const (
+	// RollingUpdatePodSetStrategyType replaces the pods of previous templates
+	// progressively, within the bounds of the rollingUpdate settings.
+	RollingUpdatePodSetStrategyType PodSetUpdateStrategyType = "RollingUpdate"
+	// OnDeletePodSetStrategyType keeps the pods of previous templates until
+	// they are deleted, their replacements are created from the new template.
+	OnDeletePodSetStrategyType PodSetUpdateStrategyType = "OnDelete"
+)
+
+// PodSetUpdateStrategy is how the pods of a PodSet are updated.
+type PodSetUpdateStrategy struct {
+	// Type of the update. Defaults to RollingUpdate.
+	// +optional
+	Type PodSetUpdateStrategyType `json:"type,omitempty" protobuf:"bytes,1,opt,name=type,casttype=PodSetUpdateStrategyType"`
+
+	// RollingUpdate bounds a RollingUpdate.
+	// +optional
+	RollingUpdate *RollingUpdatePodSet `json:"rollingUpdate,omitempty" protobuf:"bytes,2,opt,name=rollingUpdate"`
+}
+
+// RollingUpdatePodSet bounds the replacement of the pods of a PodSet.
+type RollingUpdatePodSet struct {
+	// MaxSurge is the number of pods that can be created above the desired
+	// replicas during the update, an absolute number or a percentage of the
+	// desired replicas rounded up. Defaults to 25%.
+	// +optional
+	// +kubebuilder:validation:XIntOrString
+	MaxSurge *intstr.IntOrString `json:"maxSurge,omitempty" protobuf:"bytes,1,opt,name=maxSurge"`
+
+	// MaxUnavailable is the number of desired replicas that can be unavailable
+	// during the update, an absolute number or a percentage of the desired
+	// replicas rounded down. It can't be 0 when maxSurge is 0. Defaults to 25%.
+	// +optional
+	// +kubebuilder:validation:XIntOrString
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty" protobuf:"bytes,2,opt,name=maxUnavailable"`
+
+	// Partition is the lowest pod index updated: the pods with a lower index
+	// keep their template, for staged rollouts. Deleted pods are recreated
+	// from the current template whatever their index. Defaults to 0.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Partition *int32 `json:"partition,omitempty" protobuf:"varint,3,opt,name=partition"`
+}
+
+// ImagePullSecret references a registry Secret the pods pull their images with.
+type ImagePullSecret struct {
+	// Name is the name of the Secret.
+	Name string `json:"name" protobuf:"bytes,1,opt,name=name"`
+
+	// SourceNamespace, when set, copies the Secret from this namespace into
+	// the namespace of the PodSet as <podset name>-<name>, and the pods
+	// reference the copy. The source Secret must be of type
+	// kubernetes.io/dockerconfigjson or kubernetes.io/dockercfg, and share
+	// itself with the namespace with the pixiu.pixiu.io/share-to-namespaces
+	// annotation. The copy is refreshed when the PodSet is reconciled.
+	// +optional
+	SourceNamespace string `json:"sourceNamespace,omitempty" protobuf:"bytes,2,opt,name=sourceNamespace"`
+}
+
+// ImageVerification accepts the images signed with one of the public keys, or
+// signed keyless by one of the identities.
+type ImageVerification struct {
+	// PublicKeys are the PEM encoded public keys images may be signed with.
+	// +optional
+	PublicKeys []string `json:"publicKeys,omitempty" protobuf:"bytes,1,rep,name=publicKeys"`
+
+	// Identities are the signers of keyless signatures, verified against the
+	// Fulcio and Rekor roots the operator is configured with.
+	// +optional
+	Identities []SignerIdentity `json:"identities,omitempty" protobuf:"bytes,2,rep,name=identities"`
+}
+
+// SignerIdentity is the identity a keyless signing certificate is issued to.
+type SignerIdentity struct {
+	// Issuer is the OIDC issuer of the identity, e.g. https://token.actions.githubusercontent.com.
+	Issuer string `json:"issuer" protobuf:"bytes,1,opt,name=issuer"`
+
+	// Subject is the email or URI of the identity.
+	Subject string `json:"subject" protobuf:"bytes,2,opt,name=subject"`
+}
+
+// PrePullPolicy configures the pre-pull of the images of a new template.
+type PrePullPolicy struct {
+	// Timeout bounds how long the pre-pull of a template may take, slow nodes
+	// are given up on once it expires. Defaults to 5m.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty" protobuf:"bytes,1,opt,name=timeout"`
+}
+
+// ImageResolutionPolicy is how the images of a PodSet template are referenced by its pods.
+// +kubebuilder:validation:Enum=Tag;Digest
+type ImageResolutionPolicy string
+
+const (
+	// TagImageResolution creates pods with the images of the template as is.
+	TagImageResolution ImageResolutionPolicy = "Tag"
+	// DigestImageResolution pins the images of the pods to the digests their
+	// tags pointed to when the template changed.
+	DigestImageResolution ImageResolutionPolicy = "Digest"
+)
+
+// OwnerReferencePolicy controls how the PodSet is referenced as the owner of
+// the objects it creates. Some GitOps and backup tools fail on blockOwnerDeletion
+// or on controller references they did not set.
+type OwnerReferencePolicy struct {
+	// BlockOwnerDeletion sets blockOwnerDeletion on the owner references, so
+	// foreground deletion of the PodSet waits for the objects. Defaults to true.
+	// +optional
+	BlockOwnerDeletion *bool `json:"blockOwnerDeletion,omitempty" protobuf:"varint,1,opt,name=blockOwnerDeletion"`
+
+	// Controller marks the PodSet as the managing controller of the objects
+	// instead of a plain owner. Defaults to true.
+	// +optional
+	Controller *bool `json:"controller,omitempty" protobuf:"varint,2,opt,name=controller"`
+
+	// SkipAdoptedPods leaves the owner references of pods the PodSet adopts
+	// untouched; only the pods it creates reference it. The orphan pods
+	// matching the selector are then counted without being adopted.
+	// +optional
+	SkipAdoptedPods bool `json:"skipAdoptedPods,omitempty" protobuf:"varint,3,opt,name=skipAdoptedPods"`
+}
+
+// ReplicaSource describes where the desired replicas of a PodSet come from.
+// Exactly one source must be set.
+type ReplicaSource struct {
+	// Prometheus computes the replicas from the value of a Prometheus query.
+	// +optional
+	Prometheus *PrometheusReplicaSource `json:"prometheus,omitempty" protobuf:"bytes,1,opt,name=prometheus"`
+
+	// ConfigMapKeyRef reads the replicas from a key of a ConfigMap in the namespace
+	// of the PodSet, so scale can be changed without permissions on the PodSet.
+	// +optional
+	ConfigMapKeyRef *v1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty" protobuf:"bytes,2,opt,name=configMapKeyRef"`
+}
+
+// PrometheusScalingFunction maps a metric value to a number of replicas.
+// +kubebuilder:validation:Enum=Proportional;Direct
+type PrometheusScalingFunction string
+
+const (
+	// ProportionalScaling runs one replica per targetValue of the metric: ceil(value / targetValue).
+	ProportionalScaling PrometheusScalingFunction = "Proportional"
+	// DirectScaling uses the metric value as the number of replicas: ceil(value).
+	DirectScaling PrometheusScalingFunction = "Direct"
+)
+
+// PrometheusReplicaSource computes the replicas from a Prometheus query.
+type PrometheusReplicaSource struct {
+	// Address is the URL of the Prometheus server. Defaults to the address the operator is configured with.
+	// +optional
+	Address string `json:"address,omitempty" protobuf:"bytes,1,opt,name=address"`
+	// Query must evaluate to a scalar or to a vector with a single sample.
+	Query string `json:"query" protobuf:"bytes,2,opt,name=query"`
+	// Interval is how often the query is evaluated. Defaults to 30s.
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty" protobuf:"bytes,3,opt,name=interval"`
+	// MinReplicas is the lower bound of the computed replicas. Defaults to 1.
+	// +optional
+	MinReplicas *int32 `json:"minReplicas,omitempty" protobuf:"varint,4,opt,name=minReplicas"`
+	// MaxReplicas is the upper bound of the computed replicas.
+	// +kubebuilder:validation:Minimum=1
+	MaxReplicas int32 `json:"maxReplicas" protobuf:"varint,5,opt,name=maxReplicas"`
+	// ScalingFunction maps the metric value to replicas. Defaults to Proportional.
+	// +optional
+	ScalingFunction PrometheusScalingFunction `json:"scalingFunction,omitempty" protobuf:"bytes,6,opt,name=scalingFunction,casttype=PrometheusScalingFunction"`
+	// TargetValue is the metric value handled by a single replica, required by the Proportional function.
+	// +optional
+	TargetValue *resource.Quantity `json:"targetValue,omitempty" protobuf:"bytes,7,opt,name=targetValue"`
+}
+
+// ScalePolicy spreads large replica changes out over time, on top of the
+// per reconcile burst limit.
+type ScalePolicy struct {
+	// MaxCreatePerMinute is the maximum number of pods created within any minute.
+	// Pod creation is not rate limited when unset.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxCreatePerMinute *int32 `json:"maxCreatePerMinute,omitempty" protobuf:"varint,1,opt,name=maxCreatePerMinute"`
+
+	// MaxDeletePerMinute is the maximum number of pods deleted within any minute.
+	// Pod deletion is not rate limited when unset.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxDeletePerMinute *int32 `json:"maxDeletePerMinute,omitempty" protobuf:"varint,2,opt,name=maxDeletePerMinute"`
 }
 
 // PodSetStatus defines the observed state of PodSet
@@ -67,7 +898,235 @@ type PodSetStatus struct {
 	// Represents the latest available observations of a deployment's current state.
 	// +patchMergeKey=type
 	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
 	Conditions []PodSetCondition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,6,rep,name=conditions"`
+
+	// SnapshotGeneration is the generation of the most recent spec written to the snapshot store.
+	// +optional
+	SnapshotGeneration int64 `json:"snapshotGeneration,omitempty" protobuf:"varint,8,opt,name=snapshotGeneration"`
+
+	// Inventory lists the auxiliary objects (services, disruption budgets, ...) the
+	// controller manages for this PodSet. Objects dropped from the spec are pruned.
+	// +optional
+	Inventory []ResourceRef `json:"inventory,omitempty" protobuf:"bytes,9,rep,name=inventory"`
+
+	// ScaleDown reports the progress of a scale down rate limited by spec.scalePolicy.
+	// +optional
+	ScaleDown *ScaleDownStatus `json:"scaleDown,omitempty" protobuf:"bytes,10,opt,name=scaleDown"`
+
+	// FailureBackoff is set while reconciles of this PodSet keep failing and
+	// explains when the controller retries next.
+	// +optional
+	FailureBackoff *FailureBackoff `json:"failureBackoff,omitempty" protobuf:"bytes,11,opt,name=failureBackoff"`
+
+	// DesiredReplicas is the number of replicas the controller converges to, which
+	// differs from spec.replicas when the replicas come from spec.replicaSource.
+	// +optional
+	DesiredReplicas int32 `json:"desiredReplicas,omitempty" protobuf:"varint,12,opt,name=desiredReplicas"`
+
+	// ReplicaSource is the last evaluation of spec.replicaSource.
+	// +optional
+	ReplicaSource *ReplicaSourceStatus `json:"replicaSource,omitempty" protobuf:"bytes,13,opt,name=replicaSource"`
+
+	// ImageResolution records the digests the images of the current template
+	// resolved to, when spec.imageResolution is Digest.
+	// +optional
+	ImageResolution *ImageResolutionStatus `json:"imageResolution,omitempty" protobuf:"bytes,14,opt,name=imageResolution"`
+
+	// PrePull is the progress of the pre-pull of the images of the current
+	// template, when spec.prePull is set.
+	// +optional
+	PrePull *PrePullStatus `json:"prePull,omitempty" protobuf:"bytes,15,opt,name=prePull"`
+
+	// UpdateRevision is the hash of the current template, the pods created
+	// from it carry it in their pixiu.pixiu.io/pod-template-hash label.
+	// +optional
+	UpdateRevision string `json:"updateRevision,omitempty" protobuf:"bytes,17,opt,name=updateRevision"`
+
+	// Revision is the number of the ControllerRevision recording the current
+	// spec.template.
+	// +optional
+	Revision int64 `json:"revision,omitempty" protobuf:"varint,18,opt,name=revision"`
+
+	// Rollback is the progress of the rollback requested with the
+	// pixiu.pixiu.io/rollback-to annotation, until its pods are available.
+	// +optional
+	Rollback *RollbackStatus `json:"rollback,omitempty" protobuf:"bytes,19,opt,name=rollback"`
+
+	// Spread is the skew of the pods over the topology keys of the spread
+	// policy.
+	// +optional
+	Spread []TopologySpreadStatus `json:"spread,omitempty" protobuf:"bytes,20,rep,name=spread"`
+
+	// ActiveSchedule is the name of the schedule of spec.schedules the
+	// replicas currently follow.
+	// +optional
+	ActiveSchedule string `json:"activeSchedule,omitempty" protobuf:"bytes,21,opt,name=activeSchedule"`
+
+	// ScaledToZeroSince is when the podset was scaled to zero and its last
+	// pod was gone, unset while it has desired replicas or pods.
+	// +optional
+	ScaledToZeroSince *metav1.Time `json:"scaledToZeroSince,omitempty" protobuf:"bytes,22,opt,name=scaledToZeroSince"`
+
+	// ReadinessGates are the readiness gates of the template and the number
+	// of pods whose condition of the gate isn't True.
+	// +optional
+	ReadinessGates []ReadinessGateStatus `json:"readinessGates,omitempty" protobuf:"bytes,23,rep,name=readinessGates"`
+
+	// StandbyReplicas is the number of standby pods.
+	// +optional
+	StandbyReplicas int32 `json:"standbyReplicas,omitempty" protobuf:"varint,24,opt,name=standbyReplicas"`
+
+	// Hibernation is set while the podset hibernates.
+	// +optional
+	Hibernation *HibernationStatus `json:"hibernation,omitempty" protobuf:"bytes,25,opt,name=hibernation"`
+
+	// FullyLabeledReplicas is the number of pods of the podset with the
+	// labels of its template, as for ReplicaSets. A pod whose labels were
+	// edited since it was created isn't counted.
+	// +optional
+	FullyLabeledReplicas int32 `json:"fullyLabeledReplicas,omitempty" protobuf:"varint,27,opt,name=fullyLabeledReplicas"`
+
+	// TerminatingReplicas is the number of pods of the podset being deleted.
+	// They don't count as replicas, but still hold the resources of their
+	// node until they stop.
+	// +optional
+	TerminatingReplicas int32 `json:"terminatingReplicas,omitempty" protobuf:"varint,26,opt,name=terminatingReplicas"`
+
+	// Resources are the resources of the desired replicas.
+	// +optional
+	Resources *ResourceTotals `json:"resources,omitempty" protobuf:"bytes,16,opt,name=resources"`
+}
+
+// ResourceTotals sums the resources of the containers of all the desired
+// replicas, the way the scheduler and resource quotas count them.
+type ResourceTotals struct {
+	// Requests are the total requested resources.
+	// +optional
+	Requests v1.ResourceList `json:"requests,omitempty" protobuf:"bytes,1,rep,name=requests,casttype=ResourceList,castkey=ResourceName"`
+
+	// Limits are the total resource limits.
+	// +optional
+	Limits v1.ResourceList `json:"limits,omitempty" protobuf:"bytes,2,rep,name=limits,casttype=ResourceList,castkey=ResourceName"`
+
+	// EstimatedHourlyCost is the hourly cost of the requests at the resource
+	// prices the operator is configured with, e.g. "1.2500". It is unset when
+	// no prices are configured.
+	// +optional
+	EstimatedHourlyCost string `json:"estimatedHourlyCost,omitempty" protobuf:"bytes,3,opt,name=estimatedHourlyCost"`
+}
+
+// RollbackStatus is the progress of a rollback.
+type RollbackStatus struct {
+	// Revision is the number of the revision rolled back to, when it was
+	// requested.
+	Revision int64 `json:"revision" protobuf:"varint,1,opt,name=revision"`
+	// TemplateHash is the hash of the template restored from the revision.
+	TemplateHash string `json:"templateHash" protobuf:"bytes,2,opt,name=templateHash"`
+	// StartTime is when the template was restored.
+	StartTime metav1.Time `json:"startTime" protobuf:"bytes,3,opt,name=startTime"`
+	// UpdatedReplicas is the number of replicas rolled back so far.
+	UpdatedReplicas int32 `json:"updatedReplicas,omitempty" protobuf:"varint,4,opt,name=updatedReplicas"`
+}
+
+// HibernationStatus is the hibernation of a PodSet.
+type HibernationStatus struct {
+	// Since is when the podset started hibernating.
+	Since metav1.Time `json:"since" protobuf:"bytes,1,opt,name=since"`
+
+	// Replicas is the number of desired replicas before the podset started
+	// hibernating.
+	Replicas int32 `json:"replicas" protobuf:"varint,2,opt,name=replicas"`
+}
+
+// ReadinessGateStatus is the number of pods blocked on a readiness gate.
+type ReadinessGateStatus struct {
+	// ConditionType is the pod condition of the gate.
+	ConditionType v1.PodConditionType `json:"conditionType" protobuf:"bytes,1,opt,name=conditionType,casttype=k8s.io/api/core/v1.PodConditionType"`
+	// BlockedReplicas is the number of pods whose condition isn't True.
+	BlockedReplicas int32 `json:"blockedReplicas" protobuf:"varint,2,opt,name=blockedReplicas"`
+}
+
+// TopologySpreadStatus is how the pods are spread over the domains of a
+// topology key.
+type TopologySpreadStatus struct {
+	// TopologyKey is the node label the domains are defined by.
+	TopologyKey string `json:"topologyKey" protobuf:"bytes,1,opt,name=topologyKey"`
+	// Domains is the number of domains the pods may run in.
+	Domains int32 `json:"domains" protobuf:"varint,2,opt,name=domains"`
+	// Skew is the difference between the number of scheduled pods of the most
+	// and the least populated domains.
+	Skew int32 `json:"skew" protobuf:"varint,3,opt,name=skew"`
+}
+
+// PrePullStatus is the progress of the pre-pull of a revision of the template.
+type PrePullStatus struct {
+	// RevisionHash is the hash of the template whose images are pulled.
+	RevisionHash string `json:"revisionHash" protobuf:"bytes,1,opt,name=revisionHash"`
+	// StartTime is when the pre-pull started.
+	StartTime metav1.Time `json:"startTime" protobuf:"bytes,2,opt,name=startTime"`
+	// Nodes is the number of nodes the images are pulled on.
+	Nodes int32 `json:"nodes" protobuf:"varint,3,opt,name=nodes"`
+	// PulledNodes is the number of nodes that pulled all the images.
+	PulledNodes int32 `json:"pulledNodes" protobuf:"varint,4,opt,name=pulledNodes"`
+	// Completed is set once all the nodes pulled the images or the timeout expired.
+	Completed bool `json:"completed,omitempty" protobuf:"varint,5,opt,name=completed"`
+}
+
+// ImageResolutionStatus pins the images of a revision of the template.
+type ImageResolutionStatus struct {
+	// RevisionHash is the hash of the template the images were resolved for.
+	RevisionHash string `json:"revisionHash" protobuf:"bytes,1,opt,name=revisionHash"`
+	// Images maps the images of the template to the same images pinned to a digest.
+	Images map[string]string `json:"images,omitempty" protobuf:"bytes,2,rep,name=images"`
+}
+
+// ReplicaSourceStatus is the result of evaluating the replica source of a PodSet.
+type ReplicaSourceStatus struct {
+	// Value is the last value read from the source.
+	// +optional
+	Value string `json:"value,omitempty" protobuf:"bytes,1,opt,name=value"`
+	// Replicas is the number of replicas computed from Value.
+	Replicas int32 `json:"replicas" protobuf:"varint,2,opt,name=replicas"`
+	// LastEvaluationTime is when the source was last read.
+	LastEvaluationTime metav1.Time `json:"lastEvaluationTime" protobuf:"bytes,3,opt,name=lastEvaluationTime"`
+	// Error is the error of the last evaluation, the previous replicas are kept meanwhile.
+	// +optional
+	Error string `json:"error,omitempty" protobuf:"bytes,4,opt,name=error"`
+}
+
+// FailureBackoff describes the exponential backoff applied to a PodSet whose
+// reconciles keep failing.
+type FailureBackoff struct {
+	// Failures is the number of consecutive failed reconciles.
+	Failures int32 `json:"failures" protobuf:"varint,1,opt,name=failures"`
+	// Backoff is the current delay between retries.
+	Backoff metav1.Duration `json:"backoff" protobuf:"bytes,2,opt,name=backoff"`
+	// NextRetryTime is when the controller retries next.
+	NextRetryTime metav1.Time `json:"nextRetryTime" protobuf:"bytes,3,opt,name=nextRetryTime"`
+	// LastError is the error of the last failed reconcile.
+	// +optional
+	LastError string `json:"lastError,omitempty" protobuf:"bytes,4,opt,name=lastError"`
+}
+
+// ScaleDownStatus is the state of a rate limited scale down.
+type ScaleDownStatus struct {
+	// WindowStart is when the current rate limit window started.
+	WindowStart metav1.Time `json:"windowStart" protobuf:"bytes,1,opt,name=windowStart"`
+	// DeletedInWindow is the number of pods deleted since WindowStart.
+	DeletedInWindow int32 `json:"deletedInWindow" protobuf:"varint,2,opt,name=deletedInWindow"`
+	// PendingDeletions is the number of pods still to be deleted to reach the desired replicas.
+	PendingDeletions int32 `json:"pendingDeletions" protobuf:"varint,3,opt,name=pendingDeletions"`
+}
+
+// ResourceRef identifies an object in the namespace of the PodSet.
+type ResourceRef struct {
+	APIVersion string `json:"apiVersion" protobuf:"bytes,1,opt,name=apiVersion"`
+	Kind       string `json:"kind" protobuf:"bytes,2,opt,name=kind"`
+	Name       string `json:"name" protobuf:"bytes,3,opt,name=name"`
+	// +optional
+	UID types.UID `json:"uid,omitempty" protobuf:"bytes,4,opt,name=uid,casttype=k8s.io/apimachinery/pkg/types.UID"`
 }
 
 // PodSetCondition describes the state of a podset at a certain point.
@@ -86,8 +1145,114 @@ type PodSetCondition struct {
 	Message string `json:"message,omitempty" protobuf:"bytes,5,opt,name=message"`
 }
 
+// These are valid conditions of a podset.
+const (
+	// PodSetDriftDetected means the live spec no longer matches the content hash declared
+	// by its GitOps source, i.e. the PodSet was edited outside of GitOps.
+	PodSetDriftDetected = "DriftDetected"
+
+	// PodSetReconciling follows the kstatus conventions and is true while the controller
+	// is working towards the desired state, e.g. creating, deleting or waiting for pods.
+	PodSetReconciling = "Reconciling"
+	// PodSetStalled follows the kstatus conventions and is true when the controller
+	// encountered an error and cannot make progress without intervention.
+	PodSetStalled = "Stalled"
+
+	// PodSetCreateCircuitOpen is added to a podset when pod creation is suspended
+	// after too many consecutive failures. It is removed once creation resumes.
+	PodSetCreateCircuitOpen = "CreateCircuitOpen"
+
+	// PodSetQuotaDenied is added to a podset when a PodSetQuota of its namespace
+	// refuses scaling it up to the desired replicas. It is removed once the
+	// quota allows them.
+	PodSetQuotaDenied = "QuotaDenied"
+
+	// PodSetUnschedulable is added to a podset when no node can run a pod of
+	// its template, its reason is the one excluding the most nodes. The podset
+	// isn't scaled up while it is set.
+	PodSetUnschedulable = "Unschedulable"
+
+	// PodSetTemplateInvalid is added to a podset when the API server refuses a
+	// server-side dry run create of a pod of its template. The podset isn't
+	// scaled up while it is set.
+	PodSetTemplateInvalid = "TemplateInvalid"
+
+	// PodSetImageResolutionFailed is added to a podset when the images of a new
+	// template can't be resolved to digests. The podset isn't scaled up while
+	// it is set.
+	PodSetImageResolutionFailed = "ImageResolutionFailed"
+
+	// PodSetImageVerificationFailed is added to a podset when the signatures
+	// of the images of its template can't be verified. The podset isn't scaled
+	// up while it is set.
+	PodSetImageVerificationFailed = "ImageVerificationFailed"
+
+	// PodSetAvailable tells whether the podset has its minimum availability,
+	// at most maxUnavailable of its desired replicas being unavailable, as
+	// for Deployments.
+	PodSetAvailable = "Available"
+
+	// PodSetProgressing tells whether the podset is making progress towards
+	// its updated and available replicas. It is False once no progress was
+	// made within spec.progressDeadlineSeconds.
+	PodSetProgressing = "Progressing"
+
+	// PodSetPaused is added to a podset whose spec.paused is set, its pods are
+	// neither created nor deleted.
+	PodSetPaused = "Paused"
+
+	// PodSetSuspended is added to a podset whose spec.suspend is set, its pods
+	// are deleted.
+	PodSetSuspended = "Suspended"
+
+	// PodSetHibernating is added to a podset within one of its hibernation
+	// windows, its pods are deleted.
+	PodSetHibernating = "Hibernating"
+
+	// PodSetReplicasClamped is added to a podset whose requested replicas are
+	// outside of spec.minReplicas and spec.maxReplicas, its message tells the
+	// replicas requested.
+	PodSetReplicasClamped = "ReplicasClamped"
+
+	// PodSetPodsFailed is added to a podset keeping failed pods as replicas,
+	// following its spec.podFailurePolicy.
+	PodSetPodsFailed = "PodsFailed"
+
+	// PodSetPodsCrashLooping is added to a podset with pods restarting more
+	// than its spec.crashLoopPolicy allows.
+	PodSetPodsCrashLooping = "PodsCrashLooping"
+
+	// PodSetReplicaFailure is added to a podset when some of its pods fail
+	// to be created or deleted, its reason is the reason of the API error.
+	PodSetReplicaFailure = "ReplicaFailure"
+
+	// PodSetInvalidSpec is added to a podset whose selector is invalid or
+	// doesn't match the labels of its template. Its pods are left alone, and
+	// the podset isn't reconciled again, until the spec is fixed.
+	PodSetInvalidSpec = "InvalidSpec"
+
+	// PodSetInvalidPriorityClass is added to a podset when the PriorityClass of
+	// spec.priorityClassName doesn't exist. The podset isn't scaled up while
+	// it is set.
+	PodSetInvalidPriorityClass = "InvalidPriorityClass"
+)
+
+const (
+	// PodSetInvalidClass is the reason of the Stalled condition when the
+	// PodSetClass of a podset doesn't exist.
+	PodSetInvalidClass = "InvalidClass"
+)
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=ps
+//+kubebuilder:printcolumn:name="READY",type=integer,JSONPath=`.status.readyReplicas`
+//+kubebuilder:printcolumn:name="UP-TO-DATE",type=integer,JSONPath=`.status.updatedReplicas`
+//+kubebuilder:printcolumn:name="AVAILABLE",type=integer,JSONPath=`.status.availableReplicas`
+//+kubebuilder:printcolumn:name="CPU",type=string,JSONPath=`.status.resources.requests.cpu`,priority=1
+//+kubebuilder:printcolumn:name="MEMORY",type=string,JSONPath=`.status.resources.requests.memory`,priority=1
+//+kubebuilder:printcolumn:name="HOURLY-COST",type=string,JSONPath=`.status.resources.estimatedHourlyCost`,priority=1
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // PodSet is the Schema for the podsets API
 type PodSet struct {
@@ -0,0 +1,86 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PodSetClassSpec defines the defaults applied to the PodSets of the class.
+// A field set in a PodSet always takes precedence over its class.
+type PodSetClassSpec struct {
+	// ScalePolicy is the default spec.scalePolicy.
+	// +optional
+	ScalePolicy *ScalePolicy `json:"scalePolicy,omitempty" protobuf:"bytes,1,opt,name=scalePolicy"`
+
+	// OwnerReferencePolicy is the default spec.ownerReferencePolicy.
+	// +optional
+	OwnerReferencePolicy *OwnerReferencePolicy `json:"ownerReferencePolicy,omitempty" protobuf:"bytes,2,opt,name=ownerReferencePolicy"`
+
+	// PriorityClassName is the default priority class of the pods.
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty" protobuf:"bytes,3,opt,name=priorityClassName"`
+
+	// SecurityContext is the default security context of the pods.
+	// +optional
+	SecurityContext *v1.PodSecurityContext `json:"securityContext,omitempty" protobuf:"bytes,4,opt,name=securityContext"`
+
+	// ContainerSecurityContext is the security context of the containers and
+	// init containers that don't declare one.
+	// +optional
+	ContainerSecurityContext *v1.SecurityContext `json:"containerSecurityContext,omitempty" protobuf:"bytes,5,opt,name=containerSecurityContext"`
+
+	// ImageVerification is the default spec.imageVerification.
+	// +optional
+	ImageVerification *ImageVerification `json:"imageVerification,omitempty" protobuf:"bytes,6,opt,name=imageVerification"`
+
+	// ImagePullSecrets is the default spec.imagePullSecrets.
+	// +optional
+	ImagePullSecrets []ImagePullSecret `json:"imagePullSecrets,omitempty" protobuf:"bytes,7,rep,name=imagePullSecrets"`
+
+	// UpdateStrategy is the default spec.updateStrategy.
+	// +optional
+	UpdateStrategy *PodSetUpdateStrategy `json:"updateStrategy,omitempty" protobuf:"bytes,8,opt,name=updateStrategy"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster,shortName=psc
+//+kubebuilder:printcolumn:name="PRIORITY-CLASS",type=string,JSONPath=`.spec.priorityClassName`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// PodSetClass is the Schema for the podsetclasses API. Platform teams define
+// the behavior of PodSets once in a class, which PodSets pick with spec.className.
+type PodSetClass struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PodSetClassSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PodSetClassList contains a list of PodSetClass
+type PodSetClassList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PodSetClass `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PodSetClass{}, &PodSetClassList{})
+}
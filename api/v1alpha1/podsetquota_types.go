@@ -0,0 +1,80 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PodSetQuotaSpec defines the limits of the PodSets of a namespace
+type PodSetQuotaSpec struct {
+	// MaxReplicas caps the total replicas of all PodSets in the namespace.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxReplicas *int32 `json:"maxReplicas,omitempty" protobuf:"varint,1,opt,name=maxReplicas"`
+
+	// Hard caps the total resources requested by the pods of all PodSets in the
+	// namespace, e.g. requests.cpu or memory, which means requests.memory.
+	// +optional
+	Hard v1.ResourceList `json:"hard,omitempty" protobuf:"bytes,2,rep,name=hard,casttype=ResourceList,castkey=ResourceName"`
+}
+
+// PodSetQuotaStatus defines the observed usage of a PodSetQuota
+type PodSetQuotaStatus struct {
+	// ObservedGeneration reflects the generation of the most recently observed PodSetQuota.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty" protobuf:"varint,1,opt,name=observedGeneration"`
+
+	// UsedReplicas is the total of the replicas of the PodSets in the namespace.
+	// +optional
+	UsedReplicas int32 `json:"usedReplicas,omitempty" protobuf:"varint,2,opt,name=usedReplicas"`
+
+	// Used is the total of the resources requested by the pods of the PodSets
+	// in the namespace, for the resources listed in spec.hard.
+	// +optional
+	Used v1.ResourceList `json:"used,omitempty" protobuf:"bytes,3,rep,name=used,casttype=ResourceList,castkey=ResourceName"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=psq
+//+kubebuilder:printcolumn:name="MAX-REPLICAS",type=integer,JSONPath=`.spec.maxReplicas`
+//+kubebuilder:printcolumn:name="USED-REPLICAS",type=integer,JSONPath=`.status.usedReplicas`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// PodSetQuota is the Schema for the podsetquotas API. It caps the replicas and
+// requested resources of all the PodSets in its namespace.
+type PodSetQuota struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PodSetQuotaSpec   `json:"spec,omitempty"`
+	Status PodSetQuotaStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PodSetQuotaList contains a list of PodSetQuota
+type PodSetQuotaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PodSetQuota `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PodSetQuota{}, &PodSetQuotaList{})
+}
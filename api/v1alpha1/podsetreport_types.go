@@ -0,0 +1,106 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PodSetReportName is the name of the PodSetReport of every namespace.
+const PodSetReportName = "podsets"
+
+// PodSetReportStatus summarizes the PodSets of a namespace
+type PodSetReportStatus struct {
+	// PodSets is the number of PodSets in the namespace.
+	// +optional
+	PodSets int32 `json:"podSets,omitempty" protobuf:"varint,1,opt,name=podSets"`
+
+	// Healthy is the number of PodSets with all their desired replicas ready.
+	// +optional
+	Healthy int32 `json:"healthy,omitempty" protobuf:"varint,2,opt,name=healthy"`
+
+	// Progressing is the number of PodSets scaling or rolling out a new spec.
+	// +optional
+	Progressing int32 `json:"progressing,omitempty" protobuf:"varint,3,opt,name=progressing"`
+
+	// Degraded is the number of PodSets with pods that aren't ready, or that
+	// are kept from scaling up, e.g. by a quota or an invalid template.
+	// +optional
+	Degraded int32 `json:"degraded,omitempty" protobuf:"varint,4,opt,name=degraded"`
+
+	// Stalled is the number of PodSets the controller can't make progress on.
+	// +optional
+	Stalled int32 `json:"stalled,omitempty" protobuf:"varint,5,opt,name=stalled"`
+
+	// DegradedPodSets are the names of the degraded and stalled PodSets, at
+	// most MaxReportedPodSets of them.
+	// +optional
+	DegradedPodSets []string `json:"degradedPodSets,omitempty" protobuf:"bytes,6,rep,name=degradedPodSets"`
+
+	// ProgressingPodSets are the names of the progressing PodSets, at most
+	// MaxReportedPodSets of them.
+	// +optional
+	ProgressingPodSets []string `json:"progressingPodSets,omitempty" protobuf:"bytes,7,rep,name=progressingPodSets"`
+
+	// Replicas, DesiredReplicas, ReadyReplicas and AvailableReplicas total
+	// the replicas of the PodSets.
+	// +optional
+	Replicas int32 `json:"replicas,omitempty" protobuf:"varint,8,opt,name=replicas"`
+	// +optional
+	DesiredReplicas int32 `json:"desiredReplicas,omitempty" protobuf:"varint,9,opt,name=desiredReplicas"`
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty" protobuf:"varint,10,opt,name=readyReplicas"`
+	// +optional
+	AvailableReplicas int32 `json:"availableReplicas,omitempty" protobuf:"varint,11,opt,name=availableReplicas"`
+}
+
+// MaxReportedPodSets bounds the PodSets named in a PodSetReport, keeping the
+// report small in namespaces with many PodSets.
+const MaxReportedPodSets = 100
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=psr
+//+kubebuilder:printcolumn:name="PODSETS",type=integer,JSONPath=`.status.podSets`
+//+kubebuilder:printcolumn:name="HEALTHY",type=integer,JSONPath=`.status.healthy`
+//+kubebuilder:printcolumn:name="PROGRESSING",type=integer,JSONPath=`.status.progressing`
+//+kubebuilder:printcolumn:name="DEGRADED",type=integer,JSONPath=`.status.degraded`
+//+kubebuilder:printcolumn:name="STALLED",type=integer,JSONPath=`.status.stalled`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// PodSetReport is the Schema for the podsetreports API. The controller keeps
+// one report named podsets in every namespace with PodSets, so that namespace
+// owners can watch a single object instead of every PodSet.
+type PodSetReport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status PodSetReportStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PodSetReportList contains a list of PodSetReport
+type PodSetReportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PodSetReport `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PodSetReport{}, &PodSetReportList{})
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"github.com/caoyingjunz/podset-operator/pkg/admissionpolicy"
+)
+
+func runAdmissionPolicy(args []string) error {
+	fs := flag.NewFlagSet("admission-policy", flag.ContinueOnError)
+	name := fs.String("name", "podset-validation", "The name of the policy and of its binding.")
+	apiVersion := fs.String("api-version", admissionpolicy.DefaultAPIVersion,
+		"The admissionregistration API version, admissionregistration.k8s.io/v1beta1 before Kubernetes 1.30.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	policy, binding := admissionpolicy.Generate(*name, *apiVersion)
+	for i, obj := range []interface{}{policy, binding} {
+		data, err := sigsyaml.Marshal(obj)
+		if err != nil {
+			return fmt.Errorf("admission-policy: %v", err)
+		}
+		if i != 0 {
+			fmt.Fprintln(os.Stdout, "---")
+		}
+		os.Stdout.Write(data)
+	}
+	return nil
+}
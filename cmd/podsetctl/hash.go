@@ -0,0 +1,55 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	sigsyaml "sigs.k8s.io/yaml"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/util"
+)
+
+// runHash prints the spec hash of every PodSet in the given manifests, the
+// value of the source-spec-hash annotation drift detection compares with.
+func runHash(files []string) error {
+	if len(files) == 0 {
+		return errors.New("hash: no files given")
+	}
+
+	for _, file := range files {
+		err := forEachPodSet(file, func(doc []byte) error {
+			podSet := &pixiuv1alpha1.PodSet{}
+			if err := sigsyaml.UnmarshalStrict(doc, podSet); err != nil {
+				return fmt.Errorf("%s: PodSet %s: %v", file, nameOf(doc), err)
+			}
+			hash, err := util.ComputeSpecHash(&podSet.Spec)
+			if err != nil {
+				return fmt.Errorf("%s: PodSet %s: %v", file, podSet.Name, err)
+			}
+			fmt.Fprintf(os.Stdout, "%s  %s\n", hash, podSet.Name)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
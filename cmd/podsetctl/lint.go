@@ -0,0 +1,74 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/validation"
+)
+
+func runLint(files []string) error {
+	if len(files) == 0 {
+		return errors.New("lint: no files given")
+	}
+
+	invalid := 0
+	for _, file := range files {
+		n, err := lintFile(file)
+		if err != nil {
+			return err
+		}
+		invalid += n
+	}
+	if invalid != 0 {
+		return fmt.Errorf("lint: found %d invalid PodSet(s)", invalid)
+	}
+	return nil
+}
+
+// lintFile validates every PodSet document of the file and returns the number
+// of invalid ones.
+func lintFile(file string) (int, error) {
+	invalid := 0
+	err := forEachPodSet(file, func(doc []byte) error {
+		podSet := &pixiuv1alpha1.PodSet{}
+		if err := sigsyaml.UnmarshalStrict(doc, podSet); err != nil {
+			fmt.Fprintf(os.Stdout, "%s: PodSet %s: %v\n", file, nameOf(doc), err)
+			invalid++
+			return nil
+		}
+		if len(podSet.Namespace) == 0 {
+			// Manifests usually leave the namespace to the apply context.
+			podSet.Namespace = metav1.NamespaceDefault
+		}
+		if errs := validation.ValidatePodSet(podSet); len(errs) != 0 {
+			for _, e := range errs {
+				fmt.Fprintf(os.Stdout, "%s: PodSet %s: %v\n", file, podSet.Name, e)
+			}
+			invalid++
+		}
+		return nil
+	})
+	return invalid, err
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `podsetctl works with PodSets.
+
+Usage:
+  podsetctl <command> [arguments]
+
+Commands:
+  lint FILE...        Validate the PodSets in the given manifests, "-" reads from stdin.
+  hash FILE...        Print the spec hash of the PodSets in the given manifests, the
+                      value of their pixiu.pixiu.io/source-spec-hash annotation.
+  admission-policy    Print a ValidatingAdmissionPolicy and its binding enforcing the
+                      lint rules at admission time, as an alternative to a webhook.
+  restore NAME        Restore a PodSet from its snapshot, creating it again if it was
+                      deleted.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "lint":
+		err = runLint(os.Args[2:])
+	case "hash":
+		err = runHash(os.Args[2:])
+	case "admission-policy":
+		err = runAdmissionPolicy(os.Args[2:])
+	case "restore":
+		err = runRestore(os.Args[2:])
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/types"
+)
+
+// forEachPodSet calls fn with every PodSet document of the file, "-" reads
+// from stdin. Documents of other kinds are skipped.
+func forEachPodSet(file string, fn func(doc []byte) error) error {
+	var r io.Reader
+	if file == "-" {
+		r = os.Stdin
+	} else {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	reader := yaml.NewYAMLReader(bufio.NewReader(r))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+
+		typeMeta := &metav1.TypeMeta{}
+		if err = sigsyaml.Unmarshal(doc, typeMeta); err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+		if typeMeta.Kind != types.PodSetKind || typeMeta.GroupVersionKind().Group != pixiuv1alpha1.GroupVersion.Group {
+			continue
+		}
+		if err = fn(doc); err != nil {
+			return err
+		}
+	}
+}
+
+func nameOf(doc []byte) string {
+	obj := &metav1.PartialObjectMetadata{}
+	if err := sigsyaml.Unmarshal(doc, obj); err != nil || len(obj.Name) == 0 {
+		return "<unknown>"
+	}
+	return obj.Name
+}
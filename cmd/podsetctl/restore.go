@@ -0,0 +1,102 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/snapshot"
+)
+
+// runRestore restores a PodSet from its snapshot without going through the
+// restore annotation, so that a deleted PodSet can be created again.
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	kubeconfig := fs.String("kubeconfig", "", "The kubeconfig file, defaults to the usual kubectl lookup.")
+	namespace := fs.String("namespace", "", "The namespace of the PodSet, defaults to the namespace of the kubeconfig context.")
+	generation := fs.Int64("generation", 0, "The generation to restore, 0 restores the latest revision.")
+	store := fs.String("snapshot-store", "configmap", "Where the snapshots are kept, one of configmap or s3, as configured for the operator.")
+	snapshotNamespace := fs.String("snapshot-namespace", "", "The namespace snapshot ConfigMaps are written to. Defaults to the namespace of the PodSet.")
+	s3Endpoint := fs.String("snapshot-s3-endpoint", "", "The URL of the S3-compatible snapshot service.")
+	s3Bucket := fs.String("snapshot-s3-bucket", "", "The bucket snapshots are written to.")
+	s3Region := fs.String("snapshot-s3-region", "us-east-1", "The region of the snapshot bucket.")
+	s3Prefix := fs.String("snapshot-s3-prefix", "podsets", "The key prefix of snapshot objects.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("restore: expected exactly one PodSet name")
+	}
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = *kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{})
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return fmt.Errorf("restore: %v", err)
+	}
+	if len(*namespace) == 0 {
+		if *namespace, _, err = clientConfig.Namespace(); err != nil {
+			return fmt.Errorf("restore: %v", err)
+		}
+	}
+
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(pixiuv1alpha1.AddToScheme(scheme))
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		return fmt.Errorf("restore: %v", err)
+	}
+
+	var s snapshot.Store
+	switch *store {
+	case "configmap":
+		s = &snapshot.ConfigMapStore{Client: c, Namespace: *snapshotNamespace}
+	case "s3":
+		s = &snapshot.S3Store{
+			Endpoint:        *s3Endpoint,
+			Bucket:          *s3Bucket,
+			Region:          *s3Region,
+			Prefix:          *s3Prefix,
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		}
+	default:
+		return fmt.Errorf("restore: unknown snapshot store %q", *store)
+	}
+
+	key := types.NamespacedName{Namespace: *namespace, Name: fs.Arg(0)}
+	podSet, err := snapshot.Restore(context.Background(), c, s, key, *generation)
+	if err != nil {
+		return fmt.Errorf("restore: %s: %v", key, err)
+	}
+	fmt.Fprintf(os.Stdout, "podset %s/%s restored\n", podSet.Namespace, podSet.Name)
+	return nil
+}
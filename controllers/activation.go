@@ -0,0 +1,77 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/types"
+	"github.com/caoyingjunz/podset-operator/pkg/util"
+)
+
+// activate handles the activate annotation of the podSet, scaling it up to
+// spec.activationReplicas, and reports whether the PodSet was updated.
+func (r *PodSetReconciler) activate(ctx context.Context, podSet *pixiuv1alpha1.PodSet) (bool, error) {
+	if _, ok := podSet.Annotations[types.ActivateAnnotation]; !ok {
+		return false, nil
+	}
+
+	floor := int32(1)
+	if podSet.Spec.ActivationReplicas != nil {
+		floor = *podSet.Spec.ActivationReplicas
+	}
+	replicas := int32(1)
+	if podSet.Spec.Replicas != nil {
+		replicas = *podSet.Spec.Replicas
+	}
+	updated := podSet.DeepCopy()
+	delete(updated.Annotations, types.ActivateAnnotation)
+	activated := replicas < floor
+	if activated {
+		updated.Spec.Replicas = &floor
+		if err := util.SetControllerSpecHash(updated); err != nil {
+			return false, err
+		}
+	}
+	if err := r.Update(ctx, updated); err != nil {
+		return false, err
+	}
+	if activated {
+		r.Log.Info("Activated pod set", "podSet", klog.KObj(podSet), "replicas", floor)
+		r.Recorder.Eventf(podSet, corev1.EventTypeNormal, "Activated", "Scaled up from %d to %d replicas", replicas, floor)
+	}
+	return true, nil
+}
+
+// scaledToZeroSince returns when the podSet was scaled to zero, keeping the
+// time already recorded.
+func scaledToZeroSince(podSet *pixiuv1alpha1.PodSet, newStatus *pixiuv1alpha1.PodSetStatus, now time.Time) *metav1.Time {
+	if newStatus.DesiredReplicas != 0 || newStatus.Replicas != 0 {
+		return nil
+	}
+	if podSet.Status.ScaledToZeroSince != nil {
+		return podSet.Status.ScaledToZeroSince
+	}
+	since := metav1.NewTime(now)
+	return &since
+}
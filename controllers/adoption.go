@@ -0,0 +1,199 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/types"
+	"github.com/caoyingjunz/podset-operator/pkg/util"
+)
+
+// podOwnerKey indexes pods by the UID of the PodSets owning them.
+const podOwnerKey = ".metadata.ownerReferences.uid"
+
+// podOwnerIndex returns the UIDs of the PodSets owning the pod.
+func podOwnerIndex(obj client.Object) []string {
+	var uids []string
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.Kind == types.PodSetKind {
+			uids = append(uids, string(ref.UID))
+		}
+	}
+	return uids
+}
+
+// listClaimCandidates lists the pods claimPods picks from: the pods the podSet
+// owns, found through the owner index, and the pods matching its selector,
+// instead of every pod of the namespace. When only the metadata of pods is
+// cached, the pods matching the selector are listed live, and the owned ones
+// no longer matching it are picked from the metadata cache and read one by one.
+func (r *PodSetReconciler) listClaimCandidates(ctx context.Context, podSet *pixiuv1alpha1.PodSet, selector labels.Selector) ([]corev1.Pod, error) {
+	matching := &corev1.PodList{}
+	if err := r.listPods(ctx, matching, client.InNamespace(podSet.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+	pods := matching.Items
+	seen := make(map[k8stypes.UID]bool, len(pods))
+	for i := range pods {
+		seen[pods[i].UID] = true
+	}
+
+	if r.PodMetadataOnly {
+		metas := &metav1.PartialObjectMetadataList{}
+		metas.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("PodList"))
+		if err := r.List(ctx, metas, client.InNamespace(podSet.Namespace)); err != nil {
+			return nil, err
+		}
+		for i := range metas.Items {
+			meta := &metas.Items[i]
+			if seen[meta.UID] || !util.IsOwnedBy(meta, podSet.UID) || selector.Matches(labels.Set(meta.Labels)) {
+				continue
+			}
+			pod := &corev1.Pod{}
+			if err := r.APIReader.Get(ctx, client.ObjectKeyFromObject(meta), pod); err != nil {
+				if apierrors.IsNotFound(err) {
+					continue
+				}
+				return nil, err
+			}
+			if !seen[pod.UID] {
+				seen[pod.UID] = true
+				pods = append(pods, *pod)
+			}
+		}
+		return pods, nil
+	}
+
+	owned := &corev1.PodList{}
+	if err := r.List(ctx, owned, client.InNamespace(podSet.Namespace), client.MatchingFields{podOwnerKey: string(podSet.UID)}); err != nil {
+		return nil, err
+	}
+	for i := range owned.Items {
+		if !seen[owned.Items[i].UID] {
+			pods = append(pods, owned.Items[i])
+		}
+	}
+	return pods, nil
+}
+
+// claimPods returns the pods of the podSet among the candidate pods,
+// like the ControllerRefManager of kube-controller-manager: the pods it owns
+// that match its selector, and the orphans matching it, which are adopted.
+// The pods it owns that no longer match are released.
+func (r *PodSetReconciler) claimPods(ctx context.Context, podSet *pixiuv1alpha1.PodSet, selector labels.Selector, pods []corev1.Pod) ([]corev1.Pod, error) {
+	var claimed []corev1.Pod
+	var errs []error
+	var adoptErr error
+	checked := false
+	for i := range pods {
+		pod := &pods[i]
+		owned := util.IsOwnedBy(pod, podSet.UID)
+		matches := selector.Matches(labels.Set(pod.Labels))
+		switch {
+		case owned && matches:
+			claimed = append(claimed, *pod)
+		case owned:
+			// The pre-pull pods never match the selector.
+			if _, ok := pod.Labels[types.PrePullLabel]; ok {
+				continue
+			}
+			if err := r.orphanPod(ctx, pod, podSet); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			r.Log.Info("Released pod no longer matching the selector", "podSet", klog.KObj(podSet), "pod", pod.Name)
+		case matches && isOrphan(pod) && pod.DeletionTimestamp == nil:
+			if !checked {
+				adoptErr, checked = r.canAdopt(ctx, podSet), true
+			}
+			if adoptErr != nil {
+				continue
+			}
+			if policy := podSet.Spec.OwnerReferencePolicy; policy == nil || !policy.SkipAdoptedPods {
+				if err := r.adoptPod(ctx, pod, podSet); err != nil {
+					if !apierrors.IsNotFound(err) {
+						errs = append(errs, err)
+					}
+					continue
+				}
+				r.Log.Info("Adopted orphan pod", "podSet", klog.KObj(podSet), "pod", pod.Name)
+			}
+			claimed = append(claimed, *pod)
+		}
+	}
+	if adoptErr != nil {
+		r.Log.V(2).Info("Not adopting orphan pods", "podSet", klog.KObj(podSet), "reason", adoptErr.Error())
+	}
+	return claimed, utilerrors.NewAggregate(errs)
+}
+
+// isOrphan reports whether the pod has neither a controller nor a PodSet
+// owner.
+func isOrphan(pod *corev1.Pod) bool {
+	return metav1.GetControllerOf(pod) == nil && util.GetOwnerByKind(pod, types.PodSetKind) == nil
+}
+
+// canAdopt re-reads the podSet through the uncached reader: a podSet being
+// deleted, or replaced by another one of the same name, must not adopt pods
+// the garbage collector would then miss.
+func (r *PodSetReconciler) canAdopt(ctx context.Context, podSet *pixiuv1alpha1.PodSet) error {
+	var reader client.Reader = r.Client
+	if r.APIReader != nil {
+		reader = r.APIReader
+	}
+	fresh := &pixiuv1alpha1.PodSet{}
+	if err := reader.Get(ctx, client.ObjectKeyFromObject(podSet), fresh); err != nil {
+		return err
+	}
+	if fresh.UID != podSet.UID {
+		return fmt.Errorf("original pod set %v is gone: got uid %v, wanted %v", klog.KObj(podSet), fresh.UID, podSet.UID)
+	}
+	if fresh.DeletionTimestamp != nil {
+		return fmt.Errorf("pod set %v has just been deleted at %v", klog.KObj(podSet), fresh.DeletionTimestamp)
+	}
+	return nil
+}
+
+// adoptPod sets the owner reference of the podSet on the pod. The patch holds
+// the resource version, so that a pod another controller adopted meanwhile
+// isn't taken over.
+func (r *PodSetReconciler) adoptPod(ctx context.Context, pod *corev1.Pod, podSet *pixiuv1alpha1.PodSet) error {
+	adopted := pod.DeepCopy()
+	if err := util.SetOwnerReference(adopted, *podSetOwnerReference(podSet)); err != nil {
+		return err
+	}
+	if err := r.Patch(ctx, adopted, client.MergeFromWithOptions(pod, client.MergeFromWithOptimisticLock{})); err != nil {
+		if apierrors.IsNotFound(err) {
+			return err
+		}
+		return fmt.Errorf("failed to adopt pod %s: %v", pod.Name, err)
+	}
+	*pod = *adopted
+	return nil
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/inventory"
+)
+
+// auxiliaryFunc declares the auxiliary objects of a podset through the tracker.
+type auxiliaryFunc func(ctx context.Context, podSet *pixiuv1alpha1.PodSet, tracker *inventory.Tracker) error
+
+// auxiliaryFuncs returns the declarations of every auxiliary object kind the
+// controller manages besides pods.
+func (r *PodSetReconciler) auxiliaryFuncs() []auxiliaryFunc {
+	return []auxiliaryFunc{
+		r.applyImagePullSecretCopies,
+		r.applyHeadlessService,
+	}
+}
+
+// syncAuxiliaryResources applies the auxiliary objects declared for the podSet,
+// records them in status and prunes the ones that are no longer declared.
+func (r *PodSetReconciler) syncAuxiliaryResources(ctx context.Context, podSet *pixiuv1alpha1.PodSet, newStatus *pixiuv1alpha1.PodSetStatus) error {
+	tracker := inventory.NewTracker(r.Client, r.Scheme, podSet, *podSetOwnerReference(podSet))
+
+	var errs []error
+	for _, fn := range r.auxiliaryFuncs() {
+		if err := fn(ctx, podSet, tracker); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) != 0 {
+		// Don't prune on partial failures, objects that failed to apply are still declared.
+		return utilerrors.NewAggregate(errs)
+	}
+
+	if err := tracker.Prune(ctx, podSet.Status.Inventory); err != nil {
+		return err
+	}
+	newStatus.Inventory = tracker.Inventory()
+	return nil
+}
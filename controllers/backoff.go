@@ -0,0 +1,70 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+const (
+	DefaultFailureBackoffBase = 5 * time.Second
+	DefaultFailureBackoffMax  = 5 * time.Minute
+)
+
+// failureBackoff returns the backoff to record in status after a failed
+// reconcile. The number of consecutive failures is kept in status, so the
+// backoff survives controller restarts. Failures happening before the current
+// retry time, e.g. reconciles triggered by pod events, keep the backoff as is
+// so that writing it does not trigger yet another reconcile.
+func (r *PodSetReconciler) failureBackoff(podSet *pixiuv1alpha1.PodSet, reconcileErr error, now time.Time) *pixiuv1alpha1.FailureBackoff {
+	current := podSet.Status.FailureBackoff
+	if current != nil && now.Before(current.NextRetryTime.Time) {
+		return current.DeepCopy()
+	}
+
+	settings := r.settings()
+	base, max := settings.FailureBackoffBase, settings.FailureBackoffMax
+	if base <= 0 {
+		base = DefaultFailureBackoffBase
+	}
+	if max <= 0 {
+		max = DefaultFailureBackoffMax
+	}
+
+	failures := int32(1)
+	if current != nil {
+		failures = current.Failures + 1
+	}
+	backoff := base
+	for i := int32(1); i < failures && backoff < max; i++ {
+		backoff *= 2
+	}
+	if backoff > max {
+		backoff = max
+	}
+
+	return &pixiuv1alpha1.FailureBackoff{
+		Failures:      failures,
+		Backoff:       metav1.Duration{Duration: backoff},
+		NextRetryTime: metav1.NewTime(now.Add(backoff)),
+		LastError:     reconcileErr.Error(),
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+const (
+	DefaultCreateFailureThreshold = 5
+	DefaultCreateCircuitCooldown  = 5 * time.Minute
+)
+
+// circuitBreaker stops pod creation for a PodSet after too many consecutive
+// failed create attempts. Once the cooldown expires the next attempt is let
+// through: a success closes the circuit, a failure opens it again.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu     sync.Mutex
+	states map[types.NamespacedName]*breakerState
+}
+
+type breakerState struct {
+	failures int
+	openedAt time.Time
+	lastErr  error
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	b := &circuitBreaker{states: make(map[types.NamespacedName]*breakerState)}
+	b.SetLimits(threshold, cooldown)
+	return b
+}
+
+// SetLimits changes the threshold and cooldown, zero values restore the
+// defaults. Open circuits keep their failures and use the new cooldown.
+func (b *circuitBreaker) SetLimits(threshold int, cooldown time.Duration) {
+	if threshold <= 0 {
+		threshold = DefaultCreateFailureThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultCreateCircuitCooldown
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.threshold = threshold
+	b.cooldown = cooldown
+}
+
+// Allow reports whether pods may be created, and otherwise how long the
+// circuit stays open.
+func (b *circuitBreaker) Allow(key types.NamespacedName, now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.states[key]
+	if !ok || s.openedAt.IsZero() {
+		return true, 0
+	}
+	if remaining := s.openedAt.Add(b.cooldown).Sub(now); remaining > 0 {
+		return false, remaining
+	}
+	return true, 0
+}
+
+// Record accounts the outcome of a batch of create attempts, and reports
+// whether the circuit has just been opened.
+func (b *circuitBreaker) Record(key types.NamespacedName, succeeded, failed int, lastErr error, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if failed == 0 || succeeded > 0 {
+		delete(b.states, key)
+		if failed == 0 {
+			return false
+		}
+	}
+
+	s, ok := b.states[key]
+	if !ok {
+		s = &breakerState{}
+		b.states[key] = s
+	}
+	s.failures += failed
+	s.lastErr = lastErr
+	if s.failures < b.threshold {
+		return false
+	}
+
+	wasOpen := !s.openedAt.IsZero()
+	s.openedAt = now
+	return !wasOpen
+}
+
+// Open returns the last error and until when the circuit stays open if it is open.
+func (b *circuitBreaker) Open(key types.NamespacedName) (bool, int, time.Time, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.states[key]
+	if !ok || s.openedAt.IsZero() {
+		return false, 0, time.Time{}, nil
+	}
+	return true, s.failures, s.openedAt.Add(b.cooldown), s.lastErr
+}
+
+// Forget drops the state of a deleted PodSet.
+func (b *circuitBreaker) Forget(key types.NamespacedName) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.states, key)
+}
+
+// setCreateCircuitCondition reflects the circuit breaker state of the podSet
+// in the CreateCircuitOpen condition.
+func (r *PodSetReconciler) setCreateCircuitCondition(podSet *pixiuv1alpha1.PodSet, newStatus *pixiuv1alpha1.PodSetStatus) {
+	open, failures, until, lastErr := r.createBreaker.Open(client.ObjectKeyFromObject(podSet))
+	if !open {
+		RemovePodSetCondition(newStatus, pixiuv1alpha1.PodSetCreateCircuitOpen)
+		return
+	}
+
+	msg := fmt.Sprintf("Pod creation suspended until %s after %d consecutive failures: %v",
+		until.Format(time.RFC3339), failures, lastErr)
+	SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetCreateCircuitOpen, corev1.ConditionTrue, "ConsecutiveCreateFailures", msg))
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	key := types.NamespacedName{Namespace: "default", Name: "test"}
+	errQuota := errors.New("exceeded quota")
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	type record struct {
+		succeeded, failed int
+		// opened is whether Record reports the circuit as just opened.
+		opened bool
+	}
+	tests := []struct {
+		name    string
+		records []record
+		// after is how long after the last record Allow is called.
+		after     time.Duration
+		wantAllow bool
+		wantOpen  bool
+	}{
+		{
+			name:      "no failures",
+			wantAllow: true,
+		},
+		{
+			name:      "failures below the threshold",
+			records:   []record{{failed: 2}, {failed: 2}},
+			wantAllow: true,
+		},
+		{
+			name:      "failures reaching the threshold",
+			records:   []record{{failed: 2}, {failed: 3, opened: true}},
+			wantAllow: false,
+			wantOpen:  true,
+		},
+		{
+			name:      "a success resets the failures",
+			records:   []record{{failed: 4}, {succeeded: 1}, {failed: 4}},
+			wantAllow: true,
+		},
+		{
+			name:      "a partial success resets the failures",
+			records:   []record{{failed: 4}, {succeeded: 1, failed: 1}},
+			wantAllow: true,
+		},
+		{
+			name:      "cooldown expired",
+			records:   []record{{failed: 5, opened: true}},
+			after:     time.Minute + time.Second,
+			wantAllow: true,
+			wantOpen:  true,
+		},
+		{
+			name:      "failure after the cooldown opens the circuit again",
+			records:   []record{{failed: 5, opened: true}, {failed: 1}},
+			wantAllow: false,
+			wantOpen:  true,
+		},
+		{
+			name:      "success after the cooldown closes the circuit",
+			records:   []record{{failed: 5, opened: true}, {succeeded: 1}},
+			wantAllow: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newCircuitBreaker(5, time.Minute)
+			now := start
+			for i, r := range tt.records {
+				now = now.Add(2 * time.Minute)
+				if opened := b.Record(key, r.succeeded, r.failed, errQuota, now); opened != r.opened {
+					t.Errorf("Record() #%d = %v, want %v", i, opened, r.opened)
+				}
+			}
+			allow, remaining := b.Allow(key, now.Add(tt.after))
+			if allow != tt.wantAllow {
+				t.Errorf("Allow() = %v, want %v", allow, tt.wantAllow)
+			}
+			if !allow && remaining <= 0 {
+				t.Errorf("Allow() remaining = %v, want a positive duration", remaining)
+			}
+			open, _, _, lastErr := b.Open(key)
+			if open != tt.wantOpen {
+				t.Errorf("Open() = %v, want %v", open, tt.wantOpen)
+			}
+			if open && lastErr != errQuota {
+				t.Errorf("Open() error = %v, want %v", lastErr, errQuota)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerSetLimits(t *testing.T) {
+	b := newCircuitBreaker(0, 0)
+	if b.threshold != DefaultCreateFailureThreshold || b.cooldown != DefaultCreateCircuitCooldown {
+		t.Errorf("limits = %d, %v, want the defaults %d, %v", b.threshold, b.cooldown, DefaultCreateFailureThreshold, DefaultCreateCircuitCooldown)
+	}
+	b.SetLimits(3, time.Second)
+	if b.threshold != 3 || b.cooldown != time.Second {
+		t.Errorf("limits = %d, %v, want 3, 1s", b.threshold, b.cooldown)
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+// classNameKey indexes PodSets by the name of their PodSetClass.
+const classNameKey = ".spec.className"
+
+// applyPodSetClass returns the podSet with the defaults of its PodSetClass
+// filled in. The result is only used to reconcile, it is never written back,
+// so drift detection and snapshots keep seeing the declared spec.
+func (r *PodSetReconciler) applyPodSetClass(ctx context.Context, podSet *pixiuv1alpha1.PodSet) (*pixiuv1alpha1.PodSet, error) {
+	if len(podSet.Spec.ClassName) == 0 {
+		return podSet, nil
+	}
+
+	class := &pixiuv1alpha1.PodSetClass{}
+	if err := r.Get(ctx, types.NamespacedName{Name: podSet.Spec.ClassName}, class); err != nil {
+		return nil, fmt.Errorf("failed to get pod set class %s: %v", podSet.Spec.ClassName, err)
+	}
+
+	effective := podSet.DeepCopy()
+	spec := &effective.Spec
+	if spec.ScalePolicy == nil && class.Spec.ScalePolicy != nil {
+		spec.ScalePolicy = class.Spec.ScalePolicy.DeepCopy()
+	}
+	if spec.OwnerReferencePolicy == nil && class.Spec.OwnerReferencePolicy != nil {
+		spec.OwnerReferencePolicy = class.Spec.OwnerReferencePolicy.DeepCopy()
+	}
+	if spec.ImageVerification == nil && class.Spec.ImageVerification != nil {
+		spec.ImageVerification = class.Spec.ImageVerification.DeepCopy()
+	}
+	if spec.UpdateStrategy == nil && class.Spec.UpdateStrategy != nil {
+		spec.UpdateStrategy = class.Spec.UpdateStrategy.DeepCopy()
+	}
+	if len(spec.ImagePullSecrets) == 0 && len(class.Spec.ImagePullSecrets) != 0 {
+		spec.ImagePullSecrets = append([]pixiuv1alpha1.ImagePullSecret(nil), class.Spec.ImagePullSecrets...)
+	}
+
+	podSpec := &spec.Template.Spec
+	if len(podSpec.PriorityClassName) == 0 {
+		podSpec.PriorityClassName = class.Spec.PriorityClassName
+	}
+	if podSpec.SecurityContext == nil && class.Spec.SecurityContext != nil {
+		podSpec.SecurityContext = class.Spec.SecurityContext.DeepCopy()
+	}
+	if class.Spec.ContainerSecurityContext != nil {
+		for _, containers := range [][]corev1.Container{podSpec.InitContainers, podSpec.Containers} {
+			for i := range containers {
+				if containers[i].SecurityContext == nil {
+					containers[i].SecurityContext = class.Spec.ContainerSecurityContext.DeepCopy()
+				}
+			}
+		}
+	}
+	return effective, nil
+}
+
+// mapClassToPodSets enqueues the PodSets of a PodSetClass.
+func (r *PodSetReconciler) mapClassToPodSets(obj client.Object) (requests []reconcile.Request) {
+	podSets := &pixiuv1alpha1.PodSetList{}
+	if err := r.List(context.TODO(), podSets, client.MatchingFields{classNameKey: obj.GetName()}); err != nil {
+		return
+	}
+	for _, podSet := range podSets.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: podSet.Namespace, Name: podSet.Name},
+		})
+	}
+	return
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	pixiutypes "github.com/caoyingjunz/podset-operator/pkg/types"
+)
+
+// ClusterPodSetReconciler reconciles a ClusterPodSet object by maintaining a
+// PodSet of the same name in every target namespace.
+type ClusterPodSetReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	Log    logr.Logger
+
+	Recorder record.EventRecorder
+
+	// DrainContext, when set, is cancelled at the end of the drain period of a
+	// graceful shutdown. In-flight reconciles keep running until then.
+	DrainContext context.Context
+}
+
+//+kubebuilder:rbac:groups=pixiu.pixiu.io,resources=clusterpodsets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=pixiu.pixiu.io,resources=clusterpodsets/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=pixiu.pixiu.io,resources=clusterpodsets/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+var _ reconcile.Reconciler = &ClusterPodSetReconciler{}
+
+func (r *ClusterPodSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("request", req)
+	log.V(1).Info("reconciling cluster pod set operator")
+
+	ctx, ok := withDrain(ctx, r.DrainContext)
+	if !ok {
+		log.V(1).Info("skipping cluster pod set, the manager is stopping")
+		return reconcile.Result{}, nil
+	}
+
+	cps := &pixiuv1alpha1.ClusterPodSet{}
+	if err := r.Get(ctx, req.NamespacedName, cps); err != nil {
+		if apierrors.IsNotFound(err) {
+			// The PodSets are garbage collected through their owner references.
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, fmt.Errorf("failed to get cluster pod set: %v", err)
+	}
+	if cps.DeletionTimestamp != nil {
+		return reconcile.Result{}, nil
+	}
+
+	targets, err := r.targetNamespaces(ctx, cps)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to resolve target namespaces: %v", err)
+	}
+
+	var errs []error
+	for _, ns := range targets.List() {
+		if err = r.syncPodSet(ctx, cps, ns); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	children := &pixiuv1alpha1.PodSetList{}
+	if err = r.List(ctx, children, client.MatchingLabels{pixiutypes.ClusterPodSetLabel: cps.Name}); err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to list pod sets: %v", err)
+	}
+
+	var owned []pixiuv1alpha1.PodSet
+	for i := range children.Items {
+		child := &children.Items[i]
+		if !metav1.IsControlledBy(child, cps) {
+			continue
+		}
+		if !targets.Has(child.Namespace) {
+			log.Info("Deleting pod set of untargeted namespace", "namespace", child.Namespace)
+			if err = r.Delete(ctx, child); err != nil && !apierrors.IsNotFound(err) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		owned = append(owned, *child)
+	}
+
+	syncErr := utilerrors.NewAggregate(errs)
+	if err = r.updateStatus(ctx, cps, owned, syncErr); err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to update cluster pod set status: %v", err)
+	}
+	if syncErr != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to sync pod sets: %v", syncErr)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// targetNamespaces returns the active namespaces the ClusterPodSet runs in.
+func (r *ClusterPodSetReconciler) targetNamespaces(ctx context.Context, cps *pixiuv1alpha1.ClusterPodSet) (sets.String, error) {
+	explicit := sets.NewString(cps.Spec.Namespaces...)
+
+	namespaces := &corev1.NamespaceList{}
+	if err := r.List(ctx, namespaces); err != nil {
+		return nil, err
+	}
+
+	selector := labels.Nothing()
+	if cps.Spec.NamespaceSelector != nil {
+		var err error
+		if selector, err = metav1.LabelSelectorAsSelector(cps.Spec.NamespaceSelector); err != nil {
+			return nil, fmt.Errorf("invalid namespace selector: %v", err)
+		}
+	}
+
+	targets := sets.NewString()
+	for _, ns := range namespaces.Items {
+		if ns.Status.Phase == corev1.NamespaceTerminating {
+			continue
+		}
+		if explicit.Has(ns.Name) || selector.Matches(labels.Set(ns.Labels)) {
+			targets.Insert(ns.Name)
+		}
+	}
+	return targets, nil
+}
+
+// syncPodSet creates or updates the PodSet of the ClusterPodSet in namespace.
+func (r *ClusterPodSetReconciler) syncPodSet(ctx context.Context, cps *pixiuv1alpha1.ClusterPodSet, namespace string) error {
+	podSet := &pixiuv1alpha1.PodSet{}
+	podSet.Namespace = namespace
+	podSet.Name = cps.Name
+
+	if err := r.Get(ctx, client.ObjectKeyFromObject(podSet), podSet); err == nil && !metav1.IsControlledBy(podSet, cps) {
+		r.Recorder.Eventf(cps, corev1.EventTypeWarning, "NameConflict", "PodSet %s/%s exists and is not managed by this ClusterPodSet", namespace, cps.Name)
+		return fmt.Errorf("pod set %s/%s is not controlled by cluster pod set %s", namespace, cps.Name, cps.Name)
+	}
+
+	op, err := controllerutil.CreateOrUpdate(ctx, r.Client, podSet, func() error {
+		if podSet.Labels == nil {
+			podSet.Labels = make(map[string]string)
+		}
+		podSet.Labels[pixiutypes.ClusterPodSetLabel] = cps.Name
+		podSet.Spec.Replicas = cps.Spec.Replicas
+		podSet.Spec.Selector = cps.Spec.Selector.DeepCopy()
+		podSet.Spec.Template = *cps.Spec.Template.DeepCopy()
+		return controllerutil.SetControllerReference(cps, podSet, r.Scheme)
+	})
+	if err != nil {
+		return err
+	}
+	if op == controllerutil.OperationResultCreated {
+		r.Recorder.Eventf(cps, corev1.EventTypeNormal, "SuccessfulCreate", "Created pod set in namespace %s", namespace)
+	}
+	return nil
+}
+
+func (r *ClusterPodSetReconciler) updateStatus(ctx context.Context, cps *pixiuv1alpha1.ClusterPodSet, children []pixiuv1alpha1.PodSet, syncErr error) error {
+	newStatus := cps.Status.DeepCopy()
+	newStatus.ObservedGeneration = cps.Generation
+	newStatus.Replicas, newStatus.ReadyReplicas, newStatus.AvailableReplicas = 0, 0, 0
+	newStatus.Namespaces = nil
+
+	sort.Slice(children, func(i, j int) bool { return children[i].Namespace < children[j].Namespace })
+	for _, child := range children {
+		newStatus.Replicas += child.Status.Replicas
+		newStatus.ReadyReplicas += child.Status.ReadyReplicas
+		newStatus.AvailableReplicas += child.Status.AvailableReplicas
+		newStatus.Namespaces = append(newStatus.Namespaces, pixiuv1alpha1.NamespaceReplicaStatus{
+			Namespace:         child.Namespace,
+			Replicas:          child.Status.Replicas,
+			ReadyReplicas:     child.Status.ReadyReplicas,
+			AvailableReplicas: child.Status.AvailableReplicas,
+		})
+	}
+
+	if syncErr != nil {
+		newStatus.Conditions = setCondition(newStatus.Conditions, NewPodSetCondition(pixiuv1alpha1.PodSetStalled, corev1.ConditionTrue, "PodSetSyncFailed", syncErr.Error()))
+	} else {
+		newStatus.Conditions = setCondition(newStatus.Conditions, NewPodSetCondition(pixiuv1alpha1.PodSetStalled, corev1.ConditionFalse, "AsExpected", ""))
+	}
+
+	if reflect.DeepEqual(&cps.Status, newStatus) {
+		return nil
+	}
+	cps = cps.DeepCopy()
+	cps.Status = *newStatus
+	return r.Status().Update(ctx, cps)
+}
+
+// mapNamespaceToClusterPodSets enqueues every ClusterPodSet when a namespace
+// changes, since any of them may select it.
+func (r *ClusterPodSetReconciler) mapNamespaceToClusterPodSets(obj client.Object) (requests []reconcile.Request) {
+	list := &pixiuv1alpha1.ClusterPodSetList{}
+	if err := r.List(context.TODO(), list); err != nil {
+		return
+	}
+	for _, cps := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: cps.Name}})
+	}
+	return
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterPodSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&pixiuv1alpha1.ClusterPodSet{}).
+		Owns(&pixiuv1alpha1.PodSet{}).
+		Watches(&source.Kind{Type: &corev1.Namespace{}}, handler.EnqueueRequestsFromMapFunc(r.mapNamespaceToClusterPodSets)).
+		Complete(recoverPanics("clusterpodset", r.Log, r))
+}
@@ -46,6 +46,12 @@ func GetPodFromTemplate(template *corev1.PodTemplateSpec, parentObject runtime.O
 			Finalizers:   desiredFinalizers,
 		},
 	}
+	if len(template.Name) != 0 {
+		pod.Name = template.Name
+		pod.GenerateName = ""
+	} else if len(template.GenerateName) != 0 {
+		pod.GenerateName = template.GenerateName
+	}
 	if controllerRef != nil {
 		pod.OwnerReferences = append(pod.OwnerReferences, *controllerRef)
 	}
@@ -86,21 +92,21 @@ func getPodsPrefix(controllerName string) string {
 	return prefix
 }
 
-func validateControllerRef(controllerRef *metav1.OwnerReference) error {
-	if controllerRef == nil {
-		return fmt.Errorf("controllerRef is nil")
-	}
-	if len(controllerRef.APIVersion) == 0 {
-		return fmt.Errorf("controllerRef has empty APIVersion")
+// validateOwnerRef checks the owner reference set on created pods. Whether it
+// is a controller reference and blocks owner deletion depends on the
+// ownerReferencePolicy of the PodSet.
+func validateOwnerRef(ownerRef *metav1.OwnerReference) error {
+	if ownerRef == nil {
+		return fmt.Errorf("ownerRef is nil")
 	}
-	if len(controllerRef.Kind) == 0 {
-		return fmt.Errorf("controllerRef has empty Kind")
+	if len(ownerRef.APIVersion) == 0 {
+		return fmt.Errorf("ownerRef has empty APIVersion")
 	}
-	if controllerRef.Controller == nil || !*controllerRef.Controller {
-		return fmt.Errorf("controllerRef.Controller is not set to true")
+	if len(ownerRef.Kind) == 0 {
+		return fmt.Errorf("ownerRef has empty Kind")
 	}
-	if controllerRef.BlockOwnerDeletion == nil || !*controllerRef.BlockOwnerDeletion {
-		return fmt.Errorf("controllerRef.BlockOwnerDeletion is not set")
+	if ownerRef.Controller == nil || ownerRef.BlockOwnerDeletion == nil {
+		return fmt.Errorf("ownerRef.Controller and ownerRef.BlockOwnerDeletion must be set")
 	}
 	return nil
 }
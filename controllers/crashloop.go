@@ -0,0 +1,208 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+const (
+	DefaultCrashLoopWindow     = 10 * time.Minute
+	DefaultCrashLoopBackoff    = 10 * time.Second
+	DefaultCrashLoopBackoffMax = 5 * time.Minute
+)
+
+// crashLoops tracks the container restarts of the pods of each PodSet. The
+// pods only report their total restarts, the restarts within a window are
+// counted from the samples taken when the pods are reconciled, which happens
+// on every restart. The restarts before the controller started aren't
+// counted.
+type crashLoops struct {
+	mu           sync.Mutex
+	samples      map[types.NamespacedName]map[types.UID][]restartSample
+	replacements map[types.NamespacedName]*crashLoopReplacements
+}
+
+type restartSample struct {
+	time     time.Time
+	restarts int32
+}
+
+type crashLoopReplacements struct {
+	// last is when the previous crash looping pod was replaced.
+	last time.Time
+	// count is the number of replacements in a row.
+	count int
+}
+
+func newCrashLoops() *crashLoops {
+	return &crashLoops{
+		samples:      make(map[types.NamespacedName]map[types.UID][]restartSample),
+		replacements: make(map[types.NamespacedName]*crashLoopReplacements),
+	}
+}
+
+// Observe records the restarts of the pods of the PodSet and returns their
+// restarts within the window. The pods not listed are forgotten.
+func (c *crashLoops) Observe(key types.NamespacedName, pods []*corev1.Pod, window time.Duration, now time.Time) map[types.UID]int32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	previous := c.samples[key]
+	current := make(map[types.UID][]restartSample, len(pods))
+	restarts := make(map[types.UID]int32, len(pods))
+	start := now.Add(-window)
+	for _, pod := range pods {
+		count := maxContainerRestarts(pod)
+		samples := previous[pod.UID]
+		// The last sample before the window is the baseline.
+		for len(samples) > 1 && !samples[1].time.After(start) {
+			samples = samples[1:]
+		}
+		if len(samples) == 0 || samples[len(samples)-1].restarts != count {
+			samples = append(samples, restartSample{time: now, restarts: count})
+		}
+		current[pod.UID] = samples
+		restarts[pod.UID] = count - samples[0].restarts
+	}
+	c.samples[key] = current
+	return restarts
+}
+
+// Backoff returns how long the PodSet waits before replacing its next crash
+// looping pod, doubling with every replacement within the window.
+func (c *crashLoops) Backoff(key types.NamespacedName, base, window time.Duration, now time.Time) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r, ok := c.replacements[key]
+	if !ok || now.Sub(r.last) > window {
+		return 0
+	}
+	backoff := base
+	for i := 1; i < r.count && backoff < DefaultCrashLoopBackoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > DefaultCrashLoopBackoffMax {
+		backoff = DefaultCrashLoopBackoffMax
+	}
+	return r.last.Add(backoff).Sub(now)
+}
+
+// Replaced records the replacement of a crash looping pod of the PodSet.
+func (c *crashLoops) Replaced(key types.NamespacedName, window time.Duration, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r, ok := c.replacements[key]
+	if !ok || now.Sub(r.last) > window {
+		r = &crashLoopReplacements{}
+		c.replacements[key] = r
+	}
+	r.last = now
+	r.count++
+}
+
+// Forget drops the samples and replacements of the PodSet.
+func (c *crashLoops) Forget(key types.NamespacedName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.samples, key)
+	delete(c.replacements, key)
+}
+
+// handleCrashLoopingPods applies spec.crashLoopPolicy to the pods of the
+// podSet. With the Replace action, a crash looping pod is deleted once the
+// backoff since the previous replacement has passed. It returns the crash
+// looping pods, the pod replaced if any, and when the next one may be.
+func (r *PodSetReconciler) handleCrashLoopingPods(ctx context.Context, podSet *pixiuv1alpha1.PodSet, pods []*corev1.Pod, now time.Time) ([]*corev1.Pod, *corev1.Pod, time.Duration, error) {
+	key := client.ObjectKeyFromObject(podSet)
+	policy := podSet.Spec.CrashLoopPolicy
+	if policy == nil {
+		r.crashLoops.Forget(key)
+		return nil, nil, 0, nil
+	}
+
+	window := DefaultCrashLoopWindow
+	if policy.WindowSeconds != nil {
+		window = time.Duration(*policy.WindowSeconds) * time.Second
+	}
+	restarts := r.crashLoops.Observe(key, pods, window, now)
+	var crashLooping []*corev1.Pod
+	for _, pod := range pods {
+		if restarts[pod.UID] >= policy.MaxRestarts {
+			crashLooping = append(crashLooping, pod)
+		}
+	}
+	if len(crashLooping) == 0 || policy.Action != pixiuv1alpha1.ReplaceCrashLoopAction {
+		return crashLooping, nil, 0, nil
+	}
+
+	base := DefaultCrashLoopBackoff
+	if policy.BackoffSeconds != nil {
+		base = time.Duration(*policy.BackoffSeconds) * time.Second
+	}
+	if remaining := r.crashLoops.Backoff(key, base, window, now); remaining > 0 {
+		return crashLooping, nil, remaining + time.Second, nil
+	}
+	// The pod restarting the most goes first.
+	sort.SliceStable(crashLooping, func(i, j int) bool { return restarts[crashLooping[i].UID] > restarts[crashLooping[j].UID] })
+	pod := crashLooping[0]
+	if err := r.deletePod(ctx, pod, podDeleteOptions(podSet)...); err != nil {
+		if apierrors.IsNotFound(err) {
+			return crashLooping, nil, 0, nil
+		}
+		r.Recorder.Eventf(podSet, corev1.EventTypeWarning, "FailedDelete", "Error deleting crash looping pod %s: %v", pod.Name, err)
+		return crashLooping, nil, 0, err
+	}
+	r.crashLoops.Replaced(key, window, now)
+	r.Log.Info("Replacing crash looping pod", "podSet", klog.KObj(podSet), "pod", pod.Name, "restarts", restarts[pod.UID])
+	r.Recorder.Eventf(podSet, corev1.EventTypeWarning, "ReplacedCrashLoopingPod", "Replaced pod %s after %d restarts within %v",
+		pod.Name, restarts[pod.UID], window)
+	return crashLooping, pod, 0, nil
+}
+
+func setCrashLoopingCondition(newStatus *pixiuv1alpha1.PodSetStatus, podSet *pixiuv1alpha1.PodSet, crashLooping []*corev1.Pod) {
+	if len(crashLooping) == 0 {
+		RemovePodSetCondition(newStatus, pixiuv1alpha1.PodSetPodsCrashLooping)
+		return
+	}
+	names := make([]string, 0, len(crashLooping))
+	for _, pod := range crashLooping {
+		names = append(names, pod.Name)
+	}
+	sort.Strings(names)
+	reason := "CrashLoopReported"
+	if podSet.Spec.CrashLoopPolicy.Action == pixiuv1alpha1.ReplaceCrashLoopAction {
+		reason = "CrashLoopReplacing"
+	}
+	SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetPodsCrashLooping, corev1.ConditionTrue, reason,
+		fmt.Sprintf("%d pods restarted at least %d times: %s", len(names), podSet.Spec.CrashLoopPolicy.MaxRestarts, strings.Join(names, ", "))))
+}
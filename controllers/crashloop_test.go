@@ -0,0 +1,200 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+func restartingPod(uid types.UID, restarts int32) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: string(uid), UID: uid},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{RestartCount: restarts}},
+		},
+	}
+}
+
+func TestCrashLoopsObserve(t *testing.T) {
+	key := types.NamespacedName{Namespace: "default", Name: "test"}
+	start := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	type sample struct {
+		after    time.Duration
+		restarts int32
+	}
+	tests := []struct {
+		name    string
+		samples []sample
+		window  time.Duration
+		want    int32
+	}{
+		{
+			name:    "first sample is the baseline",
+			samples: []sample{{0, 7}},
+			window:  10 * time.Minute,
+			want:    0,
+		},
+		{
+			name:    "restarts within the window",
+			samples: []sample{{0, 1}, {time.Minute, 2}, {2 * time.Minute, 4}},
+			window:  10 * time.Minute,
+			want:    3,
+		},
+		{
+			name:    "restarts before the window are dropped",
+			samples: []sample{{0, 1}, {time.Minute, 5}, {8 * time.Minute, 6}, {9 * time.Minute, 7}},
+			window:  5 * time.Minute,
+			want:    2,
+		},
+		{
+			name:    "no restarts within the window",
+			samples: []sample{{0, 1}, {time.Minute, 3}, {20 * time.Minute, 3}},
+			window:  5 * time.Minute,
+			want:    0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCrashLoops()
+			var got int32
+			for _, s := range tt.samples {
+				restarts := c.Observe(key, []*corev1.Pod{restartingPod("pod-1", s.restarts)}, tt.window, start.Add(s.after))
+				got = restarts["pod-1"]
+			}
+			if got != tt.want {
+				t.Errorf("Observe() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCrashLoopsObserveForgetsPods(t *testing.T) {
+	key := types.NamespacedName{Namespace: "default", Name: "test"}
+	now := time.Now()
+	c := newCrashLoops()
+	c.Observe(key, []*corev1.Pod{restartingPod("pod-1", 1), restartingPod("pod-2", 1)}, time.Minute, now)
+	c.Observe(key, []*corev1.Pod{restartingPod("pod-2", 2)}, time.Minute, now)
+
+	// A pod coming back starts from a new baseline.
+	if restarts := c.Observe(key, []*corev1.Pod{restartingPod("pod-1", 5)}, time.Minute, now); restarts["pod-1"] != 0 {
+		t.Errorf("Observe() = %d for a forgotten pod, want 0", restarts["pod-1"])
+	}
+}
+
+func TestCrashLoopsBackoff(t *testing.T) {
+	key := types.NamespacedName{Namespace: "default", Name: "test"}
+	start := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	window := time.Hour
+
+	tests := []struct {
+		name         string
+		replacements int
+		// after is how long after the last replacement Backoff is called.
+		after time.Duration
+		want  time.Duration
+	}{
+		{
+			name: "no replacement",
+			want: 0,
+		},
+		{
+			name:         "first replacement",
+			replacements: 1,
+			want:         10 * time.Second,
+		},
+		{
+			name:         "doubling",
+			replacements: 3,
+			after:        5 * time.Second,
+			want:         35 * time.Second,
+		},
+		{
+			name:         "capped",
+			replacements: 20,
+			want:         DefaultCrashLoopBackoffMax,
+		},
+		{
+			name:         "backoff passed",
+			replacements: 1,
+			after:        time.Minute,
+			want:         -50 * time.Second,
+		},
+		{
+			name:         "window passed",
+			replacements: 5,
+			after:        window + time.Second,
+			want:         0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCrashLoops()
+			now := start
+			for i := 0; i < tt.replacements; i++ {
+				now = now.Add(time.Second)
+				c.Replaced(key, window, now)
+			}
+			if got := c.Backoff(key, DefaultCrashLoopBackoff, window, now.Add(tt.after)); got != tt.want {
+				t.Errorf("Backoff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleCrashLoopingPodsReport(t *testing.T) {
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	podSet := &pixiuv1alpha1.PodSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"},
+		Spec: pixiuv1alpha1.PodSetSpec{
+			CrashLoopPolicy: &pixiuv1alpha1.CrashLoopPolicy{
+				Action:      pixiuv1alpha1.ReportCrashLoopAction,
+				MaxRestarts: 3,
+			},
+		},
+	}
+	r := &PodSetReconciler{crashLoops: newCrashLoops()}
+
+	r.handleCrashLoopingPods(context.TODO(), podSet, []*corev1.Pod{restartingPod("stable", 0), restartingPod("crashing", 0)}, now)
+	pods := []*corev1.Pod{restartingPod("stable", 1), restartingPod("crashing", 3)}
+	crashLooping, replaced, requeue, err := r.handleCrashLoopingPods(context.TODO(), podSet, pods, now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("handleCrashLoopingPods() error = %v", err)
+	}
+	if len(crashLooping) != 1 || crashLooping[0].Name != "crashing" {
+		t.Errorf("handleCrashLoopingPods() crash looping = %v, want [crashing]", crashLooping)
+	}
+	if replaced != nil || requeue != 0 {
+		t.Errorf("handleCrashLoopingPods() = %v, %v, want no replacement with the Report action", replaced, requeue)
+	}
+
+	newStatus := &pixiuv1alpha1.PodSetStatus{}
+	setCrashLoopingCondition(newStatus, podSet, crashLooping)
+	if cond := GetPodSetCondition(*newStatus, pixiuv1alpha1.PodSetPodsCrashLooping); cond == nil || cond.Reason != "CrashLoopReported" {
+		t.Errorf("PodsCrashLooping condition = %v, want reason CrashLoopReported", cond)
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/metrics"
+	"github.com/caoyingjunz/podset-operator/pkg/types"
+	"github.com/caoyingjunz/podset-operator/pkg/util"
+)
+
+// checkDrift compares the live spec of the podSet with the spec hash declared
+// by its GitOps source and sets the DriftDetected condition accordingly.
+func (r *PodSetReconciler) checkDrift(podSet *pixiuv1alpha1.PodSet, newStatus *pixiuv1alpha1.PodSetStatus) {
+	declaredHash, ok := podSet.Annotations[types.SourceSpecHashAnnotation]
+	if !ok {
+		RemovePodSetCondition(newStatus, pixiuv1alpha1.PodSetDriftDetected)
+		metrics.DriftDetected.DeleteLabelValues(podSet.Namespace, podSet.Name)
+		return
+	}
+
+	liveHash, err := util.ComputeSpecHash(&podSet.Spec)
+	if err != nil {
+		r.Log.Error(err, "failed to compute spec hash", "podSet", klog.KObj(podSet))
+		return
+	}
+
+	source := podSet.Annotations[types.SourceURLAnnotation]
+	if rev := podSet.Annotations[types.SourceRevisionAnnotation]; len(rev) != 0 {
+		source = fmt.Sprintf("%s@%s", source, rev)
+	}
+
+	if controllerHash, ok := podSet.Annotations[types.ControllerSpecHashAnnotation]; ok && liveHash == controllerHash && liveHash != declaredHash {
+		// The spec was last written by the controller, not changed out of band.
+		SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetDriftDetected, corev1.ConditionFalse, "UpdatedByController",
+			fmt.Sprintf("Spec was updated by the controller since it was applied from source %s", source)))
+		metrics.DriftDetected.WithLabelValues(podSet.Namespace, podSet.Name).Set(0)
+		return
+	}
+
+	if liveHash == declaredHash {
+		SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetDriftDetected, corev1.ConditionFalse, "InSync",
+			fmt.Sprintf("Spec matches the declared source %s", source)))
+		metrics.DriftDetected.WithLabelValues(podSet.Namespace, podSet.Name).Set(0)
+		return
+	}
+
+	msg := fmt.Sprintf("Spec hash %s differs from hash %s declared by source %s", liveHash, declaredHash, source)
+	if cond := GetPodSetCondition(*newStatus, pixiuv1alpha1.PodSetDriftDetected); cond == nil || cond.Status != corev1.ConditionTrue {
+		r.Recorder.Event(podSet, corev1.EventTypeWarning, "DriftDetected", msg)
+	}
+	SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetDriftDetected, corev1.ConditionTrue, "SpecDrifted", msg))
+	metrics.DriftDetected.WithLabelValues(podSet.Namespace, podSet.Name).Set(1)
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/util"
+)
+
+// templateRetryInterval is how long a template refused by the dry run stays
+// refused before it is tried again, admission policies may have changed.
+const templateRetryInterval = time.Minute
+
+// templateChecks remembers the outcome of a check of the current template of
+// every PodSet, e.g. its dry run, so that the check is only run again when the
+// template changes. Failures are checked again after templateRetryInterval.
+type templateChecks struct {
+	mu     sync.Mutex
+	checks map[types.NamespacedName]templateCheck
+}
+
+type templateCheck struct {
+	hash      string
+	err       error
+	checkedAt time.Time
+}
+
+func newTemplateChecks() *templateChecks {
+	return &templateChecks{checks: make(map[types.NamespacedName]templateCheck)}
+}
+
+// get returns the outcome of the last check of the template hash, if it can
+// still be trusted.
+func (c *templateChecks) get(key types.NamespacedName, hash string, now time.Time) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	check, ok := c.checks[key]
+	if !ok || check.hash != hash {
+		return false, nil
+	}
+	if check.err != nil && now.Sub(check.checkedAt) >= templateRetryInterval {
+		return false, nil
+	}
+	return true, check.err
+}
+
+func (c *templateChecks) set(key types.NamespacedName, check templateCheck) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks[key] = check
+}
+
+// Forget drops the checks of a deleted PodSet.
+func (c *templateChecks) Forget(key types.NamespacedName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.checks, key)
+}
+
+// dryRunTemplate creates a pod of the podSet template with a server-side dry
+// run, and returns the error when the API server or an admission controller
+// refuses it. Refusing the template before creating pods keeps a bad change
+// from replacing healthy pods with pods that can't be created. Errors that
+// don't come from the template, e.g. an unavailable API server, are logged
+// and let through.
+func (r *PodSetReconciler) dryRunTemplate(ctx context.Context, podSet *pixiuv1alpha1.PodSet) error {
+	hash, err := util.ComputeTemplateHash(&podSet.Spec.Template)
+	if err != nil {
+		return err
+	}
+	key := client.ObjectKeyFromObject(podSet)
+	now := time.Now()
+	if ok, err := r.templateChecks.get(key, hash, now); ok {
+		return err
+	}
+
+	template, err := podTemplate(podSet, 0, hash)
+	if err != nil {
+		return err
+	}
+	// An ordinal name may be taken by a running pod.
+	template.Name = ""
+	pod, err := newPod(podSet.Namespace, template, podSet, podSetOwnerReference(podSet))
+	if err != nil {
+		return err
+	}
+	err = r.Create(ctx, pod, client.DryRunAll)
+	if err != nil && !isTemplateError(err) {
+		r.Log.Error(err, "error validating pod template with a dry run", "podSet", key)
+		return nil
+	}
+	r.templateChecks.set(key, templateCheck{hash: hash, err: err, checkedAt: now})
+	return err
+}
+
+// isTemplateError reports whether the refusal of a pod is caused by its spec.
+// A ResourceQuota refusal is Forbidden too, but depends on the other pods.
+func isTemplateError(err error) bool {
+	switch {
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err):
+		return true
+	case apierrors.IsForbidden(err):
+		return !strings.Contains(err.Error(), "exceeded quota") && !apierrors.HasStatusCause(err, corev1.NamespaceTerminatingCause)
+	default:
+		return false
+	}
+}
+
+// setTemplateInvalidCondition reports in the podSet status why the dry run
+// of its template failed.
+func setTemplateInvalidCondition(newStatus *pixiuv1alpha1.PodSetStatus, err error) {
+	if err == nil {
+		RemovePodSetCondition(newStatus, pixiuv1alpha1.PodSetTemplateInvalid)
+		return
+	}
+	SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetTemplateInvalid, corev1.ConditionTrue, "DryRunFailed", err.Error()))
+}
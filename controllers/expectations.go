@@ -0,0 +1,177 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+)
+
+// ExpectationsTimeout is how long the pod creations and deletions of a
+// PodSet are waited for before the PodSet is synced again anyway, in case a
+// watch event was lost.
+const ExpectationsTimeout = 5 * time.Minute
+
+// expectations tracks the pod creations and deletions of each PodSet that
+// aren't observed in the cache yet, like the UIDTrackingControllerExpectations
+// of kube-controller-manager. The replicas aren't managed until they are,
+// otherwise a reconcile running on a stale cache would create or delete the
+// same pods again.
+type expectations struct {
+	mu     sync.Mutex
+	states map[types.NamespacedName]*expectation
+}
+
+type expectation struct {
+	// creations is the number of pods created and not observed yet.
+	creations int
+	// deletions are the keys of the pods deleted and not observed yet.
+	deletions sets.String
+	timestamp time.Time
+}
+
+func newExpectations() *expectations {
+	return &expectations{states: make(map[types.NamespacedName]*expectation)}
+}
+
+// Satisfied reports whether the creations and deletions of the PodSet are all
+// observed, or were set longer than ExpectationsTimeout ago.
+func (e *expectations) Satisfied(key types.NamespacedName, now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	s, ok := e.states[key]
+	if !ok {
+		return true
+	}
+	if s.creations <= 0 && s.deletions.Len() == 0 {
+		return true
+	}
+	return now.Sub(s.timestamp) > ExpectationsTimeout
+}
+
+func (e *expectations) state(key types.NamespacedName) *expectation {
+	s, ok := e.states[key]
+	if !ok || (s.creations <= 0 && s.deletions.Len() == 0) {
+		s = &expectation{deletions: sets.NewString()}
+		e.states[key] = s
+	}
+	s.timestamp = time.Now()
+	return s
+}
+
+// ExpectCreations records that count pods of the PodSet are being created.
+func (e *expectations) ExpectCreations(key types.NamespacedName, count int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.state(key).creations += count
+}
+
+// ExpectDeletions records that the pods of the PodSet are being deleted.
+func (e *expectations) ExpectDeletions(key types.NamespacedName, pods []*corev1.Pod) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	s := e.state(key)
+	for _, pod := range pods {
+		s.deletions.Insert(podKey(pod))
+	}
+}
+
+// CreationObserved lowers the creations of the PodSet, when a pod is seen in
+// the cache or failed to be created.
+func (e *expectations) CreationObserved(key types.NamespacedName) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if s, ok := e.states[key]; ok && s.creations > 0 {
+		s.creations--
+	}
+}
+
+// DeletionObserved drops the deletion of the pod, when it is seen deleted or
+// failed to be deleted.
+func (e *expectations) DeletionObserved(key types.NamespacedName, podKey string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if s, ok := e.states[key]; ok {
+		s.deletions.Delete(podKey)
+	}
+}
+
+// Forget drops the expectations of a deleted PodSet.
+func (e *expectations) Forget(key types.NamespacedName) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	delete(e.states, key)
+}
+
+func podKey(pod client.Object) string {
+	return pod.GetNamespace() + "/" + pod.GetName()
+}
+
+// podEventHandler enqueues the PodSet of the pods, and observes the pod
+// creations and deletions it expects.
+func (r *PodSetReconciler) podEventHandler() handler.EventHandler {
+	return handler.Funcs{
+		CreateFunc: func(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+			for _, req := range r.mapToPods(e.Object) {
+				r.expectations.CreationObserved(req.NamespacedName)
+				q.Add(req)
+			}
+		},
+		UpdateFunc: func(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+			// A pod being deleted is as good as deleted, it no longer counts
+			// as a replica.
+			deleted := e.ObjectOld.GetDeletionTimestamp() == nil && e.ObjectNew.GetDeletionTimestamp() != nil
+			requests := append(r.mapToPods(e.ObjectOld), r.mapToPods(e.ObjectNew)...)
+			seen := map[types.NamespacedName]bool{}
+			for _, req := range requests {
+				if seen[req.NamespacedName] {
+					continue
+				}
+				seen[req.NamespacedName] = true
+				if deleted {
+					r.expectations.DeletionObserved(req.NamespacedName, podKey(e.ObjectNew))
+				}
+				q.Add(req)
+			}
+		},
+		DeleteFunc: func(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+			for _, req := range r.mapToPods(e.Object) {
+				r.expectations.DeletionObserved(req.NamespacedName, podKey(e.Object))
+				q.Add(req)
+			}
+		},
+		GenericFunc: func(e event.GenericEvent, q workqueue.RateLimitingInterface) {
+			for _, req := range r.mapToPods(e.Object) {
+				q.Add(req)
+			}
+		},
+	}
+}
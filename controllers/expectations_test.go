@@ -0,0 +1,138 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestExpectations(t *testing.T) {
+	key := types.NamespacedName{Namespace: "default", Name: "test"}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-1"}}
+
+	tests := []struct {
+		name  string
+		setup func(e *expectations)
+		// after is how long after the expectations were set they are checked.
+		after time.Duration
+		want  bool
+	}{
+		{
+			name:  "nothing expected",
+			setup: func(e *expectations) {},
+			want:  true,
+		},
+		{
+			name:  "creations pending",
+			setup: func(e *expectations) { e.ExpectCreations(key, 2) },
+			want:  false,
+		},
+		{
+			name: "some creations observed",
+			setup: func(e *expectations) {
+				e.ExpectCreations(key, 2)
+				e.CreationObserved(key)
+			},
+			want: false,
+		},
+		{
+			name: "all creations observed",
+			setup: func(e *expectations) {
+				e.ExpectCreations(key, 2)
+				e.CreationObserved(key)
+				e.CreationObserved(key)
+			},
+			want: true,
+		},
+		{
+			name: "more creations observed than expected",
+			setup: func(e *expectations) {
+				e.ExpectCreations(key, 1)
+				e.CreationObserved(key)
+				e.CreationObserved(key)
+			},
+			want: true,
+		},
+		{
+			name:  "deletion pending",
+			setup: func(e *expectations) { e.ExpectDeletions(key, []*corev1.Pod{pod}) },
+			want:  false,
+		},
+		{
+			name: "deletion of another pod observed",
+			setup: func(e *expectations) {
+				e.ExpectDeletions(key, []*corev1.Pod{pod})
+				e.DeletionObserved(key, "default/test-2")
+			},
+			want: false,
+		},
+		{
+			name: "deletion observed",
+			setup: func(e *expectations) {
+				e.ExpectDeletions(key, []*corev1.Pod{pod})
+				e.DeletionObserved(key, podKey(pod))
+			},
+			want: true,
+		},
+		{
+			name:  "expired",
+			setup: func(e *expectations) { e.ExpectCreations(key, 1) },
+			after: ExpectationsTimeout + time.Second,
+			want:  true,
+		},
+		{
+			name: "forgotten",
+			setup: func(e *expectations) {
+				e.ExpectCreations(key, 1)
+				e.Forget(key)
+			},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newExpectations()
+			tt.setup(e)
+			if got := e.Satisfied(key, time.Now().Add(tt.after)); got != tt.want {
+				t.Errorf("Satisfied() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpectationsRenewed(t *testing.T) {
+	key := types.NamespacedName{Namespace: "default", Name: "test"}
+	e := newExpectations()
+	e.ExpectCreations(key, 1)
+	e.CreationObserved(key)
+
+	// Satisfied expectations start over instead of accumulating.
+	e.ExpectCreations(key, 1)
+	if e.Satisfied(key, time.Now()) {
+		t.Fatalf("Satisfied() = true with a creation pending")
+	}
+	e.CreationObserved(key)
+	if !e.Satisfied(key, time.Now()) {
+		t.Errorf("Satisfied() = false with every creation observed")
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/util"
+)
+
+const (
+	DefaultFailedPodBackoff    = 10 * time.Second
+	DefaultFailedPodBackoffMax = 5 * time.Minute
+)
+
+// handleFailedPods applies spec.podFailurePolicy to the failed pods of the
+// podSet. It returns the failed pods kept as replicas, so that they aren't
+// replaced yet, and when the next of them is due to be replaced.
+func (r *PodSetReconciler) handleFailedPods(ctx context.Context, podSet *pixiuv1alpha1.PodSet, pods []corev1.Pod, now time.Time) ([]*corev1.Pod, time.Duration, error) {
+	policy := podSet.Spec.PodFailurePolicy
+	if policy == nil {
+		return nil, 0, nil
+	}
+
+	var failedPods []*corev1.Pod
+	for i := range pods {
+		if pod := &pods[i]; pod.Status.Phase == corev1.PodFailed && pod.DeletionTimestamp == nil && util.IsOwnedBy(pod, podSet.UID) {
+			failedPods = append(failedPods, pod)
+		}
+	}
+	if len(failedPods) == 0 || policy.Type == pixiuv1alpha1.LeavePodFailurePolicy {
+		return failedPods, 0, nil
+	}
+
+	backoff := time.Duration(0)
+	if policy.Type == pixiuv1alpha1.BackoffPodFailurePolicy {
+		backoff = failedPodBackoff(policy, len(failedPods))
+	}
+	var heldPods []*corev1.Pod
+	var requeueAfter time.Duration
+	for _, pod := range failedPods {
+		if remaining := podFinishTime(pod).Add(backoff).Sub(now); remaining > 0 {
+			heldPods = append(heldPods, pod)
+			requeueAfter = minRequeue(requeueAfter, remaining+time.Second)
+			continue
+		}
+		if err := r.deletePod(ctx, pod, podDeleteOptions(podSet)...); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			r.Recorder.Eventf(podSet, corev1.EventTypeWarning, "FailedDelete", "Error deleting failed pod %s: %v", pod.Name, err)
+			return heldPods, requeueAfter, err
+		}
+		r.Log.Info("Replacing failed pod", "podSet", klog.KObj(podSet), "pod", pod.Name, "reason", pod.Status.Reason)
+		r.Recorder.Eventf(podSet, corev1.EventTypeNormal, "ReplacingFailedPod", "Deleted failed pod %s to replace it", pod.Name)
+	}
+	return heldPods, requeueAfter, nil
+}
+
+// failedPodBackoff returns how long the failed pods are kept, doubling with
+// every other failed pod.
+func failedPodBackoff(policy *pixiuv1alpha1.PodFailurePolicy, failed int) time.Duration {
+	backoff := DefaultFailedPodBackoff
+	if policy.BackoffSeconds != nil {
+		backoff = time.Duration(*policy.BackoffSeconds) * time.Second
+	}
+	for i := 1; i < failed && backoff < DefaultFailedPodBackoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > DefaultFailedPodBackoffMax {
+		backoff = DefaultFailedPodBackoffMax
+	}
+	return backoff
+}
+
+func setPodsFailedCondition(newStatus *pixiuv1alpha1.PodSetStatus, podSet *pixiuv1alpha1.PodSet, heldPods []*corev1.Pod) {
+	if len(heldPods) == 0 {
+		RemovePodSetCondition(newStatus, pixiuv1alpha1.PodSetPodsFailed)
+		return
+	}
+	reason, msg := "ReplacementBackoff", fmt.Sprintf("%d failed pods are replaced after a backoff", len(heldPods))
+	if podSet.Spec.PodFailurePolicy.Type == pixiuv1alpha1.LeavePodFailurePolicy {
+		reason, msg = "FailedPodsLeft", fmt.Sprintf("%d failed pods are left for inspection", len(heldPods))
+	}
+	SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetPodsFailed, corev1.ConditionTrue, reason, msg))
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/inventory"
+	"github.com/caoyingjunz/podset-operator/pkg/types"
+	"github.com/caoyingjunz/podset-operator/pkg/util"
+)
+
+// ensureFinalizer adds the pod finalizer to the podSet, and reports whether
+// the PodSet was updated.
+func (r *PodSetReconciler) ensureFinalizer(ctx context.Context, podSet *pixiuv1alpha1.PodSet) (bool, error) {
+	if controllerutil.ContainsFinalizer(podSet, types.PodSetFinalizer) {
+		return false, nil
+	}
+	updated := podSet.DeepCopy()
+	controllerutil.AddFinalizer(updated, types.PodSetFinalizer)
+	if err := r.Update(ctx, updated); err != nil {
+		return false, fmt.Errorf("failed to add finalizer: %v", err)
+	}
+	return true, nil
+}
+
+// finalize deletes or orphans the pods of the deleted podSet following its
+// deletionPolicy, then removes the finalizer. The finalizer is kept until the
+// pods are gone, the deletion of the last one triggers another reconcile. The
+// pre-delete hook runs first, and the auxiliary objects are deleted or
+// released last. An OrderedReady podSet is drained one pod at a time, by
+// decreasing index.
+func (r *PodSetReconciler) finalize(ctx context.Context, podSet *pixiuv1alpha1.PodSet) error {
+	if updated, err := r.runPreDeleteHook(ctx, podSet); err != nil {
+		return err
+	} else if updated {
+		// The update triggers another reconcile.
+		return nil
+	}
+
+	labelSelector, err := r.parsePodSelector(podSet)
+	if err != nil {
+		return err
+	}
+	// The owned pods no longer matching the selector are deleted as well.
+	pods, err := r.listClaimCandidates(ctx, podSet, labelSelector)
+	if err != nil {
+		return fmt.Errorf("failed to list pods: %v", err)
+	}
+	var owned []*corev1.Pod
+	for i := range pods {
+		if util.IsOwnedBy(&pods[i], podSet.UID) {
+			owned = append(owned, &pods[i])
+		}
+	}
+
+	if podSet.Spec.DeletionPolicy == pixiuv1alpha1.OrphanPodsPolicy {
+		orphaned := 0
+		for _, pod := range owned {
+			// The pre-pull pods are left to the garbage collector.
+			if _, ok := pod.Labels[types.PrePullLabel]; ok {
+				continue
+			}
+			if err = r.orphanPod(ctx, pod, podSet); err != nil {
+				return err
+			}
+			orphaned++
+		}
+		if orphaned != 0 {
+			r.Recorder.Eventf(podSet, corev1.EventTypeNormal, "OrphanedPods", "Orphaned %d pods", orphaned)
+		}
+	} else {
+		var deleting []*corev1.Pod
+		for _, pod := range owned {
+			if pod.DeletionTimestamp == nil {
+				deleting = append(deleting, pod)
+			}
+		}
+		if isOrderedReady(podSet) && len(deleting) != 0 {
+			if len(deleting) != len(owned) {
+				// Wait for the pod being deleted to be gone.
+				return nil
+			}
+			deleting = highestIndexPods(deleting, 1)
+		}
+		if len(deleting) != 0 {
+			r.Log.Info("Deleting the pods of a deleted pod set", "podSet", klog.KObj(podSet), "deleting", len(deleting))
+			if err = r.deletePods(ctx, podSet, deleting); err != nil {
+				return err
+			}
+		}
+		if len(owned) != 0 {
+			return nil
+		}
+	}
+
+	tracker := inventory.NewTracker(r.Client, r.Scheme, podSet, *podSetOwnerReference(podSet))
+	if podSet.Spec.DeletionPolicy == pixiuv1alpha1.OrphanPodsPolicy {
+		if err = tracker.Release(ctx, podSet.Status.Inventory); err != nil {
+			return fmt.Errorf("failed to release auxiliary resources: %v", err)
+		}
+	} else if err = tracker.Prune(ctx, podSet.Status.Inventory); err != nil {
+		// Nothing is applied, every object of the inventory is deleted.
+		return fmt.Errorf("failed to delete auxiliary resources: %v", err)
+	}
+
+	updated := podSet.DeepCopy()
+	controllerutil.RemoveFinalizer(updated, types.PodSetFinalizer)
+	if err = r.Update(ctx, updated); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to remove finalizer: %v", err)
+	}
+	return nil
+}
+
+// orphanPod removes the references to the podSet from the pod.
+func (r *PodSetReconciler) orphanPod(ctx context.Context, pod *corev1.Pod, podSet *pixiuv1alpha1.PodSet) error {
+	orphaned := pod.DeepCopy()
+	refs := make([]metav1.OwnerReference, 0, len(pod.OwnerReferences))
+	for _, ref := range pod.OwnerReferences {
+		if ref.UID != podSet.UID {
+			refs = append(refs, ref)
+		}
+	}
+	orphaned.OwnerReferences = refs
+	if err := r.Patch(ctx, orphaned, client.MergeFromWithOptions(pod, client.MergeFromWithOptimisticLock{})); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to orphan pod %s: %v", pod.Name, err)
+	}
+	return nil
+}
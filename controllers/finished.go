@@ -0,0 +1,88 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/util"
+)
+
+// deleteFinishedPods deletes the pods of the podSet that succeeded or failed
+// more than spec.finishedPodTTLSeconds ago, and returns when the next
+// finished pod expires.
+func (r *PodSetReconciler) deleteFinishedPods(ctx context.Context, podSet *pixiuv1alpha1.PodSet, pods []corev1.Pod, now time.Time) (time.Duration, error) {
+	if podSet.Spec.FinishedPodTTLSeconds == nil {
+		return 0, nil
+	}
+	ttl := time.Duration(*podSet.Spec.FinishedPodTTLSeconds) * time.Second
+
+	var requeueAfter time.Duration
+	for i := range pods {
+		pod := &pods[i]
+		if !isPodFinished(pod) || pod.DeletionTimestamp != nil || !util.IsOwnedBy(pod, podSet.UID) {
+			continue
+		}
+		if remaining := podFinishTime(pod).Add(ttl).Sub(now); remaining > 0 {
+			requeueAfter = minRequeue(requeueAfter, remaining+time.Second)
+			continue
+		}
+		if err := r.deletePod(ctx, pod, podDeleteOptions(podSet)...); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			r.Recorder.Eventf(podSet, corev1.EventTypeWarning, "FailedDelete", "Error deleting finished pod %s: %v", pod.Name, err)
+			return requeueAfter, err
+		}
+		r.Log.Info("Deleted finished pod", "podSet", klog.KObj(podSet), "pod", pod.Name, "phase", pod.Status.Phase)
+		r.Recorder.Eventf(podSet, corev1.EventTypeNormal, "DeletedFinishedPod", "Deleted %s pod %s after %v", pod.Status.Phase, pod.Name, ttl)
+	}
+	return requeueAfter, nil
+}
+
+func isPodFinished(pod *corev1.Pod) bool {
+	return pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed
+}
+
+// podFinishTime returns when the last container of the pod terminated, or
+// when the pod stopped being ready, otherwise when it started or was created.
+func podFinishTime(pod *corev1.Pod) time.Time {
+	var finished time.Time
+	for _, status := range append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...) {
+		if terminated := status.State.Terminated; terminated != nil && terminated.FinishedAt.After(finished) {
+			finished = terminated.FinishedAt.Time
+		}
+	}
+	if !finished.IsZero() {
+		return finished
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady && condition.Status != corev1.ConditionTrue && !condition.LastTransitionTime.IsZero() {
+			return condition.LastTransitionTime.Time
+		}
+	}
+	if pod.Status.StartTime != nil {
+		return pod.Status.StartTime.Time
+	}
+	return pod.CreationTimestamp.Time
+}
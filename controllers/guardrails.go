@@ -0,0 +1,45 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+// clampReplicas returns the replicas within spec.minReplicas and
+// spec.maxReplicas, and why they were clamped, or an empty message.
+func clampReplicas(podSet *pixiuv1alpha1.PodSet, replicas int32) (int32, string) {
+	if min := podSet.Spec.MinReplicas; min != nil && replicas < *min {
+		return *min, fmt.Sprintf("%d replicas requested, below spec.minReplicas %d", replicas, *min)
+	}
+	if max := podSet.Spec.MaxReplicas; max != nil && replicas > *max {
+		return *max, fmt.Sprintf("%d replicas requested, above spec.maxReplicas %d", replicas, *max)
+	}
+	return replicas, ""
+}
+
+func setReplicasClampedCondition(newStatus *pixiuv1alpha1.PodSetStatus, msg string) {
+	if len(msg) == 0 {
+		RemovePodSetCondition(newStatus, pixiuv1alpha1.PodSetReplicasClamped)
+		return
+	}
+	SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetReplicasClamped, corev1.ConditionTrue, "ReplicasClamped", msg))
+}
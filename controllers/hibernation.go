@@ -0,0 +1,110 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/cron"
+)
+
+// hibernating reports whether now is within a hibernation window of the
+// podSet, and how long until it changes. Like activeSchedule, invalid windows
+// are ignored and returned as an error.
+func hibernating(podSet *pixiuv1alpha1.PodSet, now time.Time) (bool, time.Duration, error) {
+	hibernation := podSet.Spec.Hibernation
+	if hibernation == nil || len(hibernation.Windows) == 0 {
+		return false, 0, nil
+	}
+
+	location := time.UTC
+	if len(hibernation.TimeZone) != 0 {
+		var err error
+		if location, err = time.LoadLocation(hibernation.TimeZone); err != nil {
+			return false, 0, fmt.Errorf("invalid time zone of hibernation: %v", err)
+		}
+	}
+	var errs []error
+	windows := make([]*cron.Schedule, 0, len(hibernation.Windows))
+	for _, window := range hibernation.Windows {
+		parsed, err := cron.Parse(window)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid hibernation window %q: %v", window, err))
+			continue
+		}
+		windows = append(windows, parsed)
+	}
+
+	within := func(t time.Time) bool {
+		for _, window := range windows {
+			if window.Matches(t.In(location)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	current := within(now)
+	requeueAfter := scheduleLookahead
+	for t := now.Truncate(time.Minute).Add(time.Minute); t.Sub(now) < scheduleLookahead; t = t.Add(time.Minute) {
+		if within(t) != current {
+			requeueAfter = t.Sub(now)
+			break
+		}
+	}
+	return current, requeueAfter, utilerrors.NewAggregate(errs)
+}
+
+// trackHibernation returns the hibernation status of the podSet, recording
+// the replicas it had when it started hibernating, and reports the changes
+// with events.
+func (r *PodSetReconciler) trackHibernation(podSet *pixiuv1alpha1.PodSet, hibernate bool, now time.Time) *pixiuv1alpha1.HibernationStatus {
+	current := podSet.Status.Hibernation
+	switch {
+	case hibernate && current != nil:
+		return current
+	case hibernate:
+		r.Log.Info("Pod set hibernating", "podSet", klog.KObj(podSet), "replicas", podSet.Status.DesiredReplicas)
+		r.Recorder.Eventf(podSet, corev1.EventTypeNormal, "Hibernating", "Scaling down from %d replicas to hibernate", podSet.Status.DesiredReplicas)
+		return &pixiuv1alpha1.HibernationStatus{
+			Since:    metav1.NewTime(now),
+			Replicas: podSet.Status.DesiredReplicas,
+		}
+	case current != nil:
+		r.Log.Info("Pod set woke up", "podSet", klog.KObj(podSet), "hibernated", now.Sub(current.Since.Time).Round(time.Second))
+		r.Recorder.Eventf(podSet, corev1.EventTypeNormal, "WokeUp", "Woke up after hibernating for %v", now.Sub(current.Since.Time).Round(time.Second))
+	}
+	return nil
+}
+
+// setHibernatingCondition sets the Hibernating condition of a hibernating
+// podSet.
+func setHibernatingCondition(newStatus *pixiuv1alpha1.PodSetStatus) {
+	if newStatus.Hibernation == nil {
+		RemovePodSetCondition(newStatus, pixiuv1alpha1.PodSetHibernating)
+		return
+	}
+	SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetHibernating, corev1.ConditionTrue, "HibernationWindow",
+		fmt.Sprintf("Pods are deleted during the hibernation windows, %d replicas are restored after them", newStatus.Hibernation.Replicas)))
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+func TestHibernating(t *testing.T) {
+	// A Monday.
+	day := time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		hibernation      *pixiuv1alpha1.Hibernation
+		now              time.Time
+		want             bool
+		wantRequeueAfter time.Duration
+		wantErr          bool
+	}{
+		{
+			name: "no hibernation",
+			now:  day,
+		},
+		{
+			name:             "within a window",
+			hibernation:      &pixiuv1alpha1.Hibernation{Windows: []string{"* 0-7 * * *"}},
+			now:              day.Add(6*time.Hour + 30*time.Minute),
+			want:             true,
+			wantRequeueAfter: 90 * time.Minute,
+		},
+		{
+			name:             "outside of the windows",
+			hibernation:      &pixiuv1alpha1.Hibernation{Windows: []string{"* 0-7 * * *", "* 22-23 * * 1-5"}},
+			now:              day.Add(12 * time.Hour),
+			wantRequeueAfter: 10 * time.Hour,
+		},
+		{
+			name:             "time zone",
+			hibernation:      &pixiuv1alpha1.Hibernation{Windows: []string{"* 0-7 * * *"}, TimeZone: "Asia/Shanghai"},
+			now:              day.Add(20 * time.Hour),
+			want:             true,
+			wantRequeueAfter: 4 * time.Hour,
+		},
+		{
+			name:             "always hibernating",
+			hibernation:      &pixiuv1alpha1.Hibernation{Windows: []string{"* * * * *"}},
+			now:              day,
+			want:             true,
+			wantRequeueAfter: scheduleLookahead,
+		},
+		{
+			name:             "invalid window ignored",
+			hibernation:      &pixiuv1alpha1.Hibernation{Windows: []string{"* 25 * * *", "* 0-7 * * *"}},
+			now:              day.Add(6*time.Hour + 30*time.Minute),
+			want:             true,
+			wantRequeueAfter: 90 * time.Minute,
+			wantErr:          true,
+		},
+		{
+			name:        "invalid time zone",
+			hibernation: &pixiuv1alpha1.Hibernation{Windows: []string{"* * * * *"}, TimeZone: "Mars/Olympus"},
+			now:         day,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			podSet := &pixiuv1alpha1.PodSet{Spec: pixiuv1alpha1.PodSetSpec{Hibernation: tt.hibernation}}
+			got, requeueAfter, err := hibernating(podSet, tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("hibernating() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want || requeueAfter != tt.wantRequeueAfter {
+				t.Errorf("hibernating() = %v, %v, want %v, %v", got, requeueAfter, tt.want, tt.wantRequeueAfter)
+			}
+		})
+	}
+}
+
+func TestTrackHibernation(t *testing.T) {
+	now := time.Date(2021, 1, 4, 6, 0, 0, 0, time.UTC)
+	since := metav1.NewTime(now.Add(-time.Hour))
+
+	tests := []struct {
+		name      string
+		current   *pixiuv1alpha1.HibernationStatus
+		hibernate bool
+		want      *pixiuv1alpha1.HibernationStatus
+		wantEvent bool
+	}{
+		{
+			name: "awake",
+		},
+		{
+			name:      "starts hibernating",
+			hibernate: true,
+			want:      &pixiuv1alpha1.HibernationStatus{Since: metav1.NewTime(now), Replicas: 3},
+			wantEvent: true,
+		},
+		{
+			name:      "keeps hibernating",
+			current:   &pixiuv1alpha1.HibernationStatus{Since: since, Replicas: 5},
+			hibernate: true,
+			want:      &pixiuv1alpha1.HibernationStatus{Since: since, Replicas: 5},
+		},
+		{
+			name:      "wakes up",
+			current:   &pixiuv1alpha1.HibernationStatus{Since: since, Replicas: 5},
+			wantEvent: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(1)
+			r := &PodSetReconciler{Log: logr.Discard(), Recorder: recorder}
+			podSet := &pixiuv1alpha1.PodSet{Status: pixiuv1alpha1.PodSetStatus{DesiredReplicas: 3, Hibernation: tt.current}}
+
+			got := r.trackHibernation(podSet, tt.hibernate, now)
+			switch {
+			case got == nil || tt.want == nil:
+				if got != tt.want {
+					t.Errorf("trackHibernation() = %v, want %v", got, tt.want)
+				}
+			case !got.Since.Equal(&tt.want.Since) || got.Replicas != tt.want.Replicas:
+				t.Errorf("trackHibernation() = %v, want %v", got, tt.want)
+			}
+			if gotEvent := len(recorder.Events) != 0; gotEvent != tt.wantEvent {
+				t.Errorf("trackHibernation() recorded an event: %v, want %v", gotEvent, tt.wantEvent)
+			}
+		})
+	}
+}
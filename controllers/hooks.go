@@ -0,0 +1,157 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/types"
+)
+
+// DefaultPreDeleteHookTimeout bounds the calls of a pre-delete hook without
+// timeoutSeconds.
+const DefaultPreDeleteHookTimeout = 10 * time.Second
+
+// runPreDeleteHook calls the pre-delete hook of the deleted podSet, unless it
+// already succeeded, and records its success with an annotation. It reports
+// whether the PodSet was updated.
+func (r *PodSetReconciler) runPreDeleteHook(ctx context.Context, podSet *pixiuv1alpha1.PodSet) (bool, error) {
+	hook := podSet.Spec.PreDeleteHook
+	if hook == nil {
+		return false, nil
+	}
+	if _, ok := podSet.Annotations[types.PreDeleteHookCompletedAnnotation]; ok {
+		return false, nil
+	}
+
+	timeout := DefaultPreDeleteHookTimeout
+	if hook.TimeoutSeconds != nil {
+		timeout = time.Duration(*hook.TimeoutSeconds) * time.Second
+	}
+	if err := r.callPreDeleteHook(ctx, hook.URL, podSet, timeout); err != nil {
+		r.Recorder.Eventf(podSet, corev1.EventTypeWarning, "PreDeleteHookFailed", "Pre-delete hook failed: %v", err)
+		return false, err
+	}
+	r.Log.Info("Pre-delete hook succeeded", "podSet", klog.KObj(podSet))
+	r.Recorder.Event(podSet, corev1.EventTypeNormal, "PreDeleteHookSucceeded", "Pre-delete hook succeeded, draining the pods")
+
+	updated := podSet.DeepCopy()
+	if updated.Annotations == nil {
+		updated.Annotations = make(map[string]string)
+	}
+	updated.Annotations[types.PreDeleteHookCompletedAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	if err := r.Update(ctx, updated); err != nil {
+		return false, fmt.Errorf("failed to record the pre-delete hook: %v", err)
+	}
+	return true, nil
+}
+
+func (r *PodSetReconciler) callPreDeleteHook(ctx context.Context, hookURL string, podSet *pixiuv1alpha1.PodSet, timeout time.Duration) error {
+	u, err := url.Parse(hookURL)
+	if err != nil {
+		return err
+	}
+	if !hookHostAllowed(u.Hostname(), r.PreDeleteHookHosts) {
+		return fmt.Errorf("host %s is not an allowed pre-delete hook host", u.Hostname())
+	}
+	body, err := json.Marshal(podSet)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.hookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s responded %s: %s", hookURL, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// hookHostAllowed reports whether the host is one of the allowed hosts, or a
+// subdomain of an allowed host with a leading dot.
+func hookHostAllowed(host string, allowedHosts []string) bool {
+	host = strings.ToLower(host)
+	for _, allowed := range allowedHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || (strings.HasPrefix(allowed, ".") && strings.HasSuffix(host, allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// newHookClient returns the client of the pre-delete hooks. It only follows
+// redirects to the allowed hosts, and never connects to loopback, link-local
+// or unspecified addresses, whatever the hosts resolve to: they reach the
+// controller itself or the metadata services of the node.
+func newHookClient(allowedHosts []string) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+				return fmt.Errorf("pre-delete hooks can't connect to %s", host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would connect on behalf of the controller, out of the checks.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			if !hookHostAllowed(req.URL.Hostname(), allowedHosts) {
+				return fmt.Errorf("redirect to host %s is not allowed", req.URL.Hostname())
+			}
+			return nil
+		},
+	}
+}
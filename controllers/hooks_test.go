@@ -0,0 +1,122 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+func TestHookHostAllowed(t *testing.T) {
+	allowedHosts := []string{"hooks.example.com", ".svc.cluster.local"}
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{host: "hooks.example.com", want: true},
+		{host: "Hooks.Example.com", want: true},
+		{host: "backup.default.svc.cluster.local", want: true},
+		{host: "svc.cluster.local"},
+		{host: "example.com"},
+		{host: "hooks.example.com.evil.io"},
+		{host: "169.254.169.254"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := hookHostAllowed(tt.host, allowedHosts); got != tt.want {
+				t.Errorf("hookHostAllowed(%q) = %v, want %v", tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallPreDeleteHook(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		if req.URL.Path == "/fail" {
+			http.Error(w, "backup failed", http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	tests := []struct {
+		name         string
+		url          string
+		allowedHosts []string
+		wantCalls    int
+		wantErr      bool
+	}{
+		{
+			name:         "allowed host",
+			url:          server.URL + "/ok",
+			allowedHosts: []string{u.Hostname()},
+			wantCalls:    1,
+		},
+		{
+			name:         "failing hook",
+			url:          server.URL + "/fail",
+			allowedHosts: []string{u.Hostname()},
+			wantCalls:    1,
+			wantErr:      true,
+		},
+		{
+			name:    "host not allowed",
+			url:     server.URL + "/ok",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			r := &PodSetReconciler{PreDeleteHookHosts: tt.allowedHosts, hookClient: server.Client()}
+			err := r.callPreDeleteHook(context.TODO(), tt.url, &pixiuv1alpha1.PodSet{}, time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("callPreDeleteHook() error = %v, want error %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("callPreDeleteHook() called the hook %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestHookClientLoopback(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) { calls++ }))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	// The host is allowed, but resolves to a loopback address.
+	r := &PodSetReconciler{PreDeleteHookHosts: []string{u.Hostname()}, hookClient: newHookClient([]string{u.Hostname()})}
+	if err := r.callPreDeleteHook(context.TODO(), server.URL, &pixiuv1alpha1.PodSet{}, time.Second); err == nil {
+		t.Errorf("callPreDeleteHook() succeeded on a loopback address")
+	}
+	if calls != 0 {
+		t.Errorf("callPreDeleteHook() called the hook %d times, want 0", calls)
+	}
+}
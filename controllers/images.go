@@ -0,0 +1,126 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/podtemplate"
+	"github.com/caoyingjunz/podset-operator/pkg/registry"
+	"github.com/caoyingjunz/podset-operator/pkg/util"
+)
+
+// imageRetryInterval is how often the images of a template that couldn't be
+// resolved are tried again.
+const imageRetryInterval = time.Minute
+
+// resolveImages returns the digests the images of the podSet template are
+// pinned to. They are resolved once per revision of the template and kept in
+// status, so that the pods created later in the revision, or after a restart
+// of the controller, get the same digests. It returns nil when the images
+// aren't pinned.
+func (r *PodSetReconciler) resolveImages(ctx context.Context, podSet *pixiuv1alpha1.PodSet) (*pixiuv1alpha1.ImageResolutionStatus, error) {
+	if podSet.Spec.ImageResolution != pixiuv1alpha1.DigestImageResolution {
+		return nil, nil
+	}
+	hash, err := util.ComputeTemplateHash(&podSet.Spec.Template)
+	if err != nil {
+		return nil, err
+	}
+	if current := podSet.Status.ImageResolution; current != nil && current.RevisionHash == hash {
+		return current.DeepCopy(), nil
+	}
+
+	keychain, err := r.imagePullKeychain(ctx, podSet)
+	if err != nil {
+		return nil, err
+	}
+	status := &pixiuv1alpha1.ImageResolutionStatus{RevisionHash: hash, Images: map[string]string{}}
+	podSpec := &podSet.Spec.Template.Spec
+	for _, containers := range [][]corev1.Container{podSpec.InitContainers, podSpec.Containers} {
+		for _, c := range containers {
+			// Templated images differ per pod, they are left as is.
+			if _, ok := status.Images[c.Image]; ok || podtemplate.IsTemplated(c.Image) {
+				continue
+			}
+			pinned, err := r.registry.Resolve(ctx, c.Image, keychain)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve image %s of container %s: %v", c.Image, c.Name, err)
+			}
+			status.Images[c.Image] = pinned
+		}
+	}
+	r.Log.Info("Resolved the images of a new template", "podSet", klog.KObj(podSet), "revision", hash, "images", status.Images)
+	return status, nil
+}
+
+// imagePullKeychain reads the registry credentials of the image pull secrets
+// of the podSet template. Secrets are read from the API server, caching every
+// Secret of the cluster for them isn't worth it. Copied secrets are read from
+// their source, their copy may not exist yet.
+func (r *PodSetReconciler) imagePullKeychain(ctx context.Context, podSet *pixiuv1alpha1.PodSet) (registry.Keychain, error) {
+	refs := podSet.Spec.Template.Spec.ImagePullSecrets
+	if r.APIReader == nil || len(refs) == 0 {
+		return registry.Keychain{}, nil
+	}
+	copied := copiedImagePullSecrets(podSet)
+	secrets := make([]corev1.Secret, 0, len(refs))
+	for _, ref := range refs {
+		if source, ok := copied[ref.Name]; ok {
+			secret, err := r.sourceImagePullSecret(ctx, podSet.Namespace, source)
+			if err != nil {
+				return nil, err
+			}
+			secrets = append(secrets, *secret)
+			continue
+		}
+		secret := corev1.Secret{}
+		if err := r.APIReader.Get(ctx, types.NamespacedName{Namespace: podSet.Namespace, Name: ref.Name}, &secret); err != nil {
+			return nil, fmt.Errorf("failed to get image pull secret %s: %v", ref.Name, err)
+		}
+		secrets = append(secrets, secret)
+	}
+	return registry.KeychainFromSecrets(secrets)
+}
+
+// pinImages replaces the images of the template with their pinned digests.
+func pinImages(template *corev1.PodTemplateSpec, images map[string]string) {
+	for _, containers := range [][]corev1.Container{template.Spec.InitContainers, template.Spec.Containers} {
+		for i := range containers {
+			if pinned, ok := images[containers[i].Image]; ok {
+				containers[i].Image = pinned
+			}
+		}
+	}
+}
+
+// setImageResolutionCondition reports in the podSet status why the images of
+// its template couldn't be resolved.
+func setImageResolutionCondition(newStatus *pixiuv1alpha1.PodSetStatus, err error) {
+	if err == nil {
+		RemovePodSetCondition(newStatus, pixiuv1alpha1.PodSetImageResolutionFailed)
+		return
+	}
+	SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetImageResolutionFailed, corev1.ConditionTrue, "ResolveFailed", err.Error()))
+}
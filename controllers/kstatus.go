@@ -0,0 +1,124 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+// setKStatusConditions sets the Reconciling and Stalled conditions following
+// the kstatus conventions, so generic tooling can tell whether the podSet has
+// converged. Both conditions are always present, which lets
+// `kubectl wait --for=condition=Reconciling=false` work as expected. With a
+// RollingUpdate, the podSet is reconciling until every pod from the partition
+// on is updated.
+func setKStatusConditions(newStatus *pixiuv1alpha1.PodSetStatus, replicasErr error, rollingUpdate bool, partition int32) {
+	if replicasErr != nil {
+		SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetStalled, corev1.ConditionTrue, "ReplicaFailure", replicasErr.Error()))
+	} else {
+		SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetStalled, corev1.ConditionFalse, "AsExpected", ""))
+	}
+
+	desired := newStatus.DesiredReplicas
+
+	var reason, msg string
+	switch {
+	case rollingUpdate && newStatus.UpdatedReplicas < newStatus.Replicas-partition:
+		reason = "RollingUpdate"
+		msg = fmt.Sprintf("%d of %d replicas run the current template", newStatus.UpdatedReplicas, newStatus.Replicas)
+	case newStatus.Replicas < desired:
+		reason = "ScalingUp"
+		msg = fmt.Sprintf("Scaling up from %d to %d replicas", newStatus.Replicas, desired)
+	case newStatus.Replicas > desired:
+		reason = "ScalingDown"
+		msg = fmt.Sprintf("Scaling down from %d to %d replicas", newStatus.Replicas, desired)
+	case newStatus.ReadyReplicas < desired:
+		reason = "PodsNotReady"
+		msg = fmt.Sprintf("%d of %d replicas are ready", newStatus.ReadyReplicas, desired)
+	}
+
+	if len(reason) != 0 {
+		SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetReconciling, corev1.ConditionTrue, reason, msg))
+	} else {
+		SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetReconciling, corev1.ConditionFalse, "ReplicasReady",
+			fmt.Sprintf("All %d replicas are ready", desired)))
+	}
+}
+
+// setPausedCondition sets the Paused condition of a paused podSet. It is no
+// longer reconciling, whatever the replicas, as nothing is done until it is
+// resumed.
+func setPausedCondition(newStatus *pixiuv1alpha1.PodSetStatus, paused bool) {
+	if !paused {
+		RemovePodSetCondition(newStatus, pixiuv1alpha1.PodSetPaused)
+		return
+	}
+	SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetPaused, corev1.ConditionTrue, "Paused", "Pods are not created nor deleted while spec.paused is set"))
+	SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetReconciling, corev1.ConditionFalse, "Paused", "Reconciliation is paused"))
+}
+
+// setReplicaFailureCondition sets the ReplicaFailure condition when pods
+// failed to be created or deleted. The reason is the one of the first API
+// error, e.g. Forbidden for a quota.
+func setReplicaFailureCondition(newStatus *pixiuv1alpha1.PodSetStatus, replicasErr error) {
+	if replicasErr == nil {
+		RemovePodSetCondition(newStatus, pixiuv1alpha1.PodSetReplicaFailure)
+		return
+	}
+	reason := "FailedManageReplicas"
+	for _, err := range utilerrors.Flatten(utilerrors.NewAggregate([]error{replicasErr})).Errors() {
+		if r := apierrors.ReasonForError(err); r != metav1.StatusReasonUnknown {
+			reason = string(r)
+			break
+		}
+	}
+	SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetReplicaFailure, corev1.ConditionTrue, reason, replicasErr.Error()))
+}
+
+// setSuspendedCondition sets the Suspended condition of a suspended podSet.
+func setSuspendedCondition(newStatus *pixiuv1alpha1.PodSetStatus, suspended bool) {
+	if !suspended {
+		RemovePodSetCondition(newStatus, pixiuv1alpha1.PodSetSuspended)
+		return
+	}
+	SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetSuspended, corev1.ConditionTrue, "Suspended", "Pods are deleted while spec.suspend is set"))
+}
+
+// setInvalidSpec marks the podSet as stalled by a spec no retry can fix, and
+// emits a Warning event when the condition is first set or its message
+// changes.
+func (r *PodSetReconciler) setInvalidSpec(podSet *pixiuv1alpha1.PodSet, newStatus *pixiuv1alpha1.PodSetStatus, reason string, err error) {
+	msg := err.Error()
+	if cond := GetPodSetCondition(*newStatus, pixiuv1alpha1.PodSetInvalidSpec); cond == nil || cond.Status != corev1.ConditionTrue || cond.Message != msg {
+		r.Recorder.Eventf(podSet, corev1.EventTypeWarning, reason, "Invalid spec: %s", msg)
+	}
+	SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetInvalidSpec, corev1.ConditionTrue, reason, msg))
+	setStalled(newStatus, reason, msg)
+}
+
+// setStalled marks the podSet as stalled because its spec cannot be acted upon.
+func setStalled(newStatus *pixiuv1alpha1.PodSetStatus, reason, msg string) {
+	SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetStalled, corev1.ConditionTrue, reason, msg))
+	SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetReconciling, corev1.ConditionFalse, reason, msg))
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+// propagatedMetadata returns the labels and annotations of the podSet set on
+// its pods, the ones of spec.propagateMetadata the template doesn't set.
+// They aren't part of the template, so that changing them updates the pods in
+// place instead of replacing them.
+func propagatedMetadata(podSet *pixiuv1alpha1.PodSet) (map[string]string, map[string]string) {
+	policy := podSet.Spec.PropagateMetadata
+	if policy == nil {
+		return nil, nil
+	}
+	return pickMetadata(podSet.Labels, podSet.Spec.Template.Labels, policy.Labels),
+		pickMetadata(podSet.Annotations, podSet.Spec.Template.Annotations, policy.Annotations)
+}
+
+func pickMetadata(values, template map[string]string, keys []string) map[string]string {
+	picked := map[string]string{}
+	for _, key := range keys {
+		// The keys of the controller, e.g. the pod index, are not propagated.
+		if _, ok := template[key]; ok || strings.HasPrefix(key, pixiuv1alpha1.GroupVersion.Group+"/") {
+			continue
+		}
+		if value, ok := values[key]; ok {
+			picked[key] = value
+		}
+	}
+	return picked
+}
+
+// setPropagatedMetadata adds the propagated labels and annotations to the
+// metadata of a pod, and reports whether it changed.
+func setPropagatedMetadata(meta *metav1.ObjectMeta, labels, annotations map[string]string) bool {
+	changed := false
+	for key, value := range labels {
+		if current, ok := meta.Labels[key]; !ok || current != value {
+			if meta.Labels == nil {
+				meta.Labels = make(map[string]string)
+			}
+			meta.Labels[key] = value
+			changed = true
+		}
+	}
+	for key, value := range annotations {
+		if current, ok := meta.Annotations[key]; !ok || current != value {
+			if meta.Annotations == nil {
+				meta.Annotations = make(map[string]string)
+			}
+			meta.Annotations[key] = value
+			changed = true
+		}
+	}
+	return changed
+}
+
+// syncPropagatedMetadata updates the propagated labels and annotations of the
+// pods created before they changed.
+func (r *PodSetReconciler) syncPropagatedMetadata(ctx context.Context, podSet *pixiuv1alpha1.PodSet, pods []*corev1.Pod) error {
+	labels, annotations := propagatedMetadata(podSet)
+	if len(labels)+len(annotations) == 0 {
+		return nil
+	}
+	for i, pod := range pods {
+		updated := pod.DeepCopy()
+		if !setPropagatedMetadata(&updated.ObjectMeta, labels, annotations) {
+			continue
+		}
+		if err := r.Patch(ctx, updated, client.MergeFrom(pod)); err != nil {
+			return fmt.Errorf("failed to propagate metadata to pod %s: %v", pod.Name, err)
+		}
+		pods[i] = updated
+	}
+	return nil
+}
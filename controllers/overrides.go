@@ -0,0 +1,89 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/types"
+	"github.com/caoyingjunz/podset-operator/pkg/util"
+)
+
+// replicaOverride returns the override of the pod with the given index, or nil.
+func replicaOverride(podSet *pixiuv1alpha1.PodSet, index int) *pixiuv1alpha1.ReplicaOverride {
+	for i := range podSet.Spec.Overrides {
+		if int(podSet.Spec.Overrides[i].Index) == index {
+			return &podSet.Spec.Overrides[i]
+		}
+	}
+	return nil
+}
+
+// applyReplicaOverride applies the override to the spec of a pod.
+func applyReplicaOverride(podSpec *corev1.PodSpec, override *pixiuv1alpha1.ReplicaOverride) {
+	for _, containerOverride := range override.Containers {
+		for i := range podSpec.Containers {
+			container := &podSpec.Containers[i]
+			if container.Name != containerOverride.Name {
+				continue
+			}
+			if containerOverride.Resources != nil {
+				container.Resources = *containerOverride.Resources.DeepCopy()
+			}
+			container.Env = mergeEnv(container.Env, containerOverride.Env)
+		}
+	}
+	if len(override.NodeSelector) != 0 && podSpec.NodeSelector == nil {
+		podSpec.NodeSelector = make(map[string]string, len(override.NodeSelector))
+	}
+	for key, value := range override.NodeSelector {
+		podSpec.NodeSelector[key] = value
+	}
+}
+
+// replicaRevision returns the revision hash of the pod with the given index:
+// the hash of the template with the override of the index, or the revision
+// hash of the template when it has none.
+func replicaRevision(podSet *pixiuv1alpha1.PodSet, index int, revisionHash string) string {
+	override := replicaOverride(podSet, index)
+	if override == nil {
+		return revisionHash
+	}
+	template := podSet.Spec.Template.DeepCopy()
+	applyReplicaOverride(&template.Spec, override)
+	hash, err := util.ComputeTemplateHash(template)
+	if err != nil {
+		// The template was hashed already, it can be encoded.
+		return revisionHash
+	}
+	return hash
+}
+
+// podRevision returns the revision hash the pod has once it is updated.
+func podRevision(podSet *pixiuv1alpha1.PodSet, pod *corev1.Pod, revisionHash string) string {
+	if len(podSet.Spec.Overrides) == 0 {
+		return revisionHash
+	}
+	index, err := strconv.Atoi(pod.Annotations[types.PodIndexAnnotation])
+	if err != nil {
+		return revisionHash
+	}
+	return replicaRevision(podSet, index, revisionHash)
+}
@@ -0,0 +1,30 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/util"
+)
+
+// podSetOwnerReference returns the reference to the podSet set on the objects
+// it creates, following its ownerReferencePolicy.
+func podSetOwnerReference(podSet *pixiuv1alpha1.PodSet) *metav1.OwnerReference {
+	return util.NewOwnerReference(podSet, pixiuv1alpha1.GroupVersionKind, podSet.Spec.OwnerReferencePolicy)
+}
@@ -0,0 +1,298 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+// priorityClassRetryInterval is how often a podset checks whether its missing
+// PriorityClass was created.
+const priorityClassRetryInterval = 30 * time.Second
+
+// applyScheduling returns the podSet with spec.scheduling merged into the pods
+// of its template. Like applyImagePullSecrets, it returns the podSet itself
+// when there is nothing to merge.
+func applyScheduling(podSet *pixiuv1alpha1.PodSet) *pixiuv1alpha1.PodSet {
+	scheduling := podSet.Spec.Scheduling
+	if scheduling == nil || len(scheduling.NodeSelector)+len(scheduling.Tolerations) == 0 {
+		return podSet
+	}
+
+	effective := podSet.DeepCopy()
+	podSpec := &effective.Spec.Template.Spec
+	if len(scheduling.NodeSelector) != 0 && podSpec.NodeSelector == nil {
+		podSpec.NodeSelector = make(map[string]string, len(scheduling.NodeSelector))
+	}
+	for key, value := range scheduling.NodeSelector {
+		podSpec.NodeSelector[key] = value
+	}
+	for _, toleration := range scheduling.Tolerations {
+		if !hasToleration(podSpec.Tolerations, toleration) {
+			podSpec.Tolerations = append(podSpec.Tolerations, toleration)
+		}
+	}
+	return effective
+}
+
+func hasToleration(tolerations []corev1.Toleration, toleration corev1.Toleration) bool {
+	for _, t := range tolerations {
+		if apiequality.Semantic.DeepEqual(t, toleration) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyPriorityClass returns the podSet with spec.priorityClassName set on the
+// pods of its template. The priority of the template is cleared, the
+// admission of the pods resolves it from the class. Like
+// applyImagePullSecrets, it returns the podSet itself when there is nothing
+// to change.
+func applyPriorityClass(podSet *pixiuv1alpha1.PodSet) *pixiuv1alpha1.PodSet {
+	name := podSet.Spec.PriorityClassName
+	if len(name) == 0 || podSet.Spec.Template.Spec.PriorityClassName == name {
+		return podSet
+	}
+	effective := podSet.DeepCopy()
+	effective.Spec.Template.Spec.PriorityClassName = name
+	effective.Spec.Template.Spec.Priority = nil
+	return effective
+}
+
+// checkPriorityClass returns an error when the PriorityClass of
+// spec.priorityClassName doesn't exist, the pods would be refused.
+func (r *PodSetReconciler) checkPriorityClass(ctx context.Context, podSet *pixiuv1alpha1.PodSet) error {
+	name := podSet.Spec.PriorityClassName
+	if len(name) == 0 {
+		return nil
+	}
+	if err := r.Get(ctx, client.ObjectKey{Name: name}, &schedulingv1.PriorityClass{}); err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("priority class %s not found", name)
+		}
+		return fmt.Errorf("failed to get priority class %s: %v", name, err)
+	}
+	return nil
+}
+
+func setPriorityClassCondition(newStatus *pixiuv1alpha1.PodSetStatus, err error) {
+	if err == nil {
+		RemovePodSetCondition(newStatus, pixiuv1alpha1.PodSetInvalidPriorityClass)
+		return
+	}
+	SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetInvalidPriorityClass, corev1.ConditionTrue, "PriorityClassNotFound", err.Error()))
+}
+
+// applyTerminationGracePeriod returns the podSet with
+// spec.terminationGracePeriodSeconds set on the pods of its template. Like
+// applyImagePullSecrets, it returns the podSet itself when there is nothing
+// to change.
+func applyTerminationGracePeriod(podSet *pixiuv1alpha1.PodSet) *pixiuv1alpha1.PodSet {
+	seconds := podSet.Spec.TerminationGracePeriodSeconds
+	if current := podSet.Spec.Template.Spec.TerminationGracePeriodSeconds; seconds == nil || (current != nil && *current == *seconds) {
+		return podSet
+	}
+	effective := podSet.DeepCopy()
+	gracePeriod := *seconds
+	effective.Spec.Template.Spec.TerminationGracePeriodSeconds = &gracePeriod
+	return effective
+}
+
+// applyEnvOverrides returns the podSet with spec.envOverrides merged into the
+// containers of its template. Like applyImagePullSecrets, it returns the
+// podSet itself when there is nothing to merge.
+func applyEnvOverrides(podSet *pixiuv1alpha1.PodSet) *pixiuv1alpha1.PodSet {
+	overrides := podSet.Spec.EnvOverrides
+	if overrides == nil || len(overrides.Env)+len(overrides.EnvFrom) == 0 {
+		return podSet
+	}
+
+	effective := podSet.DeepCopy()
+	podSpec := &effective.Spec.Template.Spec
+	for _, containers := range [][]corev1.Container{podSpec.InitContainers, podSpec.Containers} {
+		for i := range containers {
+			container := &containers[i]
+			container.Env = mergeEnv(container.Env, overrides.Env)
+			for _, source := range overrides.EnvFrom {
+				if !hasEnvFromSource(container.EnvFrom, source) {
+					container.EnvFrom = append(container.EnvFrom, *source.DeepCopy())
+				}
+			}
+		}
+	}
+	return effective
+}
+
+func mergeEnv(env, overrides []corev1.EnvVar) []corev1.EnvVar {
+	for _, override := range overrides {
+		found := false
+		for i := range env {
+			if env[i].Name == override.Name {
+				env[i] = *override.DeepCopy()
+				found = true
+				break
+			}
+		}
+		if !found {
+			env = append(env, *override.DeepCopy())
+		}
+	}
+	return env
+}
+
+func hasEnvFromSource(sources []corev1.EnvFromSource, source corev1.EnvFromSource) bool {
+	for _, s := range sources {
+		if apiequality.Semantic.DeepEqual(s, source) {
+			return true
+		}
+	}
+	return false
+}
+
+// applySidecars returns the podSet with spec.sidecars added to the containers
+// of its template. Like applyImagePullSecrets, it returns the podSet itself
+// when there is nothing to add.
+func applySidecars(podSet *pixiuv1alpha1.PodSet) *pixiuv1alpha1.PodSet {
+	if len(podSet.Spec.Sidecars) == 0 {
+		return podSet
+	}
+
+	names := map[string]bool{}
+	for _, container := range podSet.Spec.Template.Spec.Containers {
+		names[container.Name] = true
+	}
+	var before, after []corev1.Container
+	for _, sidecar := range podSet.Spec.Sidecars {
+		if names[sidecar.Container.Name] {
+			continue
+		}
+		names[sidecar.Container.Name] = true
+		if sidecar.Position == pixiuv1alpha1.BeforeSidecarPosition {
+			before = append(before, *sidecar.Container.DeepCopy())
+		} else {
+			after = append(after, *sidecar.Container.DeepCopy())
+		}
+	}
+	if len(before)+len(after) == 0 {
+		return podSet
+	}
+
+	effective := podSet.DeepCopy()
+	podSpec := &effective.Spec.Template.Spec
+	podSpec.Containers = append(append(before, podSpec.Containers...), after...)
+	return effective
+}
+
+// applyLifecycleDefaults returns the podSet with the preStop hook of
+// spec.lifecycleDefaults set on the containers of its template without one.
+// Like applyImagePullSecrets, it returns the podSet itself when there is
+// nothing to set.
+func applyLifecycleDefaults(podSet *pixiuv1alpha1.PodSet) *pixiuv1alpha1.PodSet {
+	defaults := podSet.Spec.LifecycleDefaults
+	if defaults == nil || defaults.PreStop == nil {
+		return podSet
+	}
+
+	var effective *pixiuv1alpha1.PodSet
+	for i, container := range podSet.Spec.Template.Spec.Containers {
+		if container.Lifecycle != nil && container.Lifecycle.PreStop != nil {
+			continue
+		}
+		if effective == nil {
+			effective = podSet.DeepCopy()
+		}
+		target := &effective.Spec.Template.Spec.Containers[i]
+		if target.Lifecycle == nil {
+			target.Lifecycle = &corev1.Lifecycle{}
+		}
+		target.Lifecycle.PreStop = defaults.PreStop.DeepCopy()
+	}
+	if effective == nil {
+		return podSet
+	}
+	return effective
+}
+
+// applyReadinessGates returns the podSet with spec.readinessGates added to the
+// pods of its template. Like applyImagePullSecrets, it returns the podSet
+// itself when there is nothing to add.
+func applyReadinessGates(podSet *pixiuv1alpha1.PodSet) *pixiuv1alpha1.PodSet {
+	var effective *pixiuv1alpha1.PodSet
+	for _, gate := range podSet.Spec.ReadinessGates {
+		if hasReadinessGate(podSet.Spec.Template.Spec.ReadinessGates, gate.ConditionType) {
+			continue
+		}
+		if effective == nil {
+			effective = podSet.DeepCopy()
+		}
+		podSpec := &effective.Spec.Template.Spec
+		podSpec.ReadinessGates = append(podSpec.ReadinessGates, gate)
+	}
+	if effective == nil {
+		return podSet
+	}
+	return effective
+}
+
+func hasReadinessGate(gates []corev1.PodReadinessGate, conditionType corev1.PodConditionType) bool {
+	for _, gate := range gates {
+		if gate.ConditionType == conditionType {
+			return true
+		}
+	}
+	return false
+}
+
+// readinessGateStatus counts the pods blocked on every readiness gate of the
+// template.
+func readinessGateStatus(template *corev1.PodTemplateSpec, pods []*corev1.Pod) []pixiuv1alpha1.ReadinessGateStatus {
+	gates := template.Spec.ReadinessGates
+	if len(gates) == 0 {
+		return nil
+	}
+	statuses := make([]pixiuv1alpha1.ReadinessGateStatus, 0, len(gates))
+	for _, gate := range gates {
+		status := pixiuv1alpha1.ReadinessGateStatus{ConditionType: gate.ConditionType}
+		for _, pod := range pods {
+			if hasReadinessGate(pod.Spec.ReadinessGates, gate.ConditionType) && !podConditionTrue(pod, gate.ConditionType) {
+				status.BlockedReplicas++
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+func podConditionTrue(pod *corev1.Pod, conditionType corev1.PodConditionType) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == conditionType {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
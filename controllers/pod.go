@@ -18,6 +18,7 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -28,15 +29,24 @@ import (
 
 	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
 	pixiutypes "github.com/caoyingjunz/podset-operator/pkg/types"
+	"github.com/caoyingjunz/podset-operator/pkg/util"
 )
 
+// mapToPods enqueues the PodSet of a pod: the one its controller reference
+// points to, if any, or the PodSets whose selector matches an orphan pod,
+// which may adopt it.
 func (r *PodSetReconciler) mapToPods(obj client.Object) (requests []reconcile.Request) {
 	if obj == nil {
 		return
 	}
 
-	// If it has a ControllerRef, that's all that matters.
-	if controllerRef := metav1.GetControllerOf(obj); controllerRef != nil {
+	// If it has a ControllerRef, that's all that matters. Otherwise look for a
+	// plain owner reference, set when the ownerReferencePolicy disables controller refs.
+	controllerRef := metav1.GetControllerOf(obj)
+	if controllerRef == nil {
+		controllerRef = util.GetOwnerByKind(obj, pixiutypes.PodSetKind)
+	}
+	if controllerRef != nil {
 		podSet := r.resolveControllerRef(obj.GetNamespace(), controllerRef)
 		if podSet == nil {
 			return
@@ -46,8 +56,47 @@ func (r *PodSetReconciler) mapToPods(obj client.Object) (requests []reconcile.Re
 				Namespace: podSet.Namespace, Name: podSet.Name,
 			},
 		})
+		return
+	}
+	if obj.GetDeletionTimestamp() != nil {
+		// An orphan being deleted can't be adopted.
+		return
 	}
 
+	return r.mapOrphanPod(obj)
+}
+
+// mapOrphanPod enqueues the PodSets whose selector matches the orphan pod.
+func (r *PodSetReconciler) mapOrphanPod(obj client.Object) (requests []reconcile.Request) {
+	podSets := &pixiuv1alpha1.PodSetList{}
+	if err := r.List(context.TODO(), podSets, client.InNamespace(obj.GetNamespace())); err != nil {
+		return
+	}
+	podLabels := labels.Set(obj.GetLabels())
+	for i := range podSets.Items {
+		podSet := &podSets.Items[i]
+		selector, err := r.parsePodSelector(podSet)
+		if err != nil || selector.Empty() || !selector.Matches(podLabels) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: podSet.Namespace, Name: podSet.Name},
+		})
+	}
+	return
+}
+
+// mapConfigMapToPodSets enqueues the PodSets whose replicas are read from the ConfigMap.
+func (r *PodSetReconciler) mapConfigMapToPodSets(obj client.Object) (requests []reconcile.Request) {
+	podSets := &pixiuv1alpha1.PodSetList{}
+	if err := r.List(context.TODO(), podSets, client.InNamespace(obj.GetNamespace()), client.MatchingFields{replicaSourceConfigMapKey: obj.GetName()}); err != nil {
+		return
+	}
+	for _, podSet := range podSets.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: podSet.Namespace, Name: podSet.Name},
+		})
+	}
 	return
 }
 
@@ -55,6 +104,23 @@ func (r *PodSetReconciler) parsePodSelector(ps *pixiuv1alpha1.PodSet) (labels.Se
 	return metav1.LabelSelectorAsSelector(ps.Spec.Selector)
 }
 
+// validatePodSelector parses the selector of the podSet and checks that it
+// matches the labels its pods are created with, otherwise the pods created
+// would never be counted and the podSet would scale up forever.
+func (r *PodSetReconciler) validatePodSelector(ps *pixiuv1alpha1.PodSet) (labels.Selector, error) {
+	selector, err := r.parsePodSelector(ps)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector: %v", err)
+	}
+	if selector.Empty() {
+		return nil, fmt.Errorf("empty selector is invalid")
+	}
+	if !selector.Matches(labels.Set(podLabels(ps))) {
+		return nil, fmt.Errorf("selector %q does not match the labels of the pods", selector.String())
+	}
+	return selector, nil
+}
+
 func (r *PodSetReconciler) resolveControllerRef(namespace string, controllerRef *metav1.OwnerReference) *pixiuv1alpha1.PodSet {
 	if controllerRef.Kind != pixiutypes.PodSetKind {
 		return nil
@@ -80,10 +146,22 @@ func IsPodActive(p *v1.Pod) bool {
 // FilterActivePods returns pods that have not terminated.
 func FilterActivePods(pods []v1.Pod) []*v1.Pod {
 	var result []*v1.Pod
-	for _, p := range pods {
-		if IsPodActive(&p) {
-			result = append(result, &p)
+	for i := range pods {
+		if p := &pods[i]; IsPodActive(p) {
+			result = append(result, p)
 		}
 	}
 	return result
 }
+
+// countTerminatingPods returns the number of pods being deleted that are still
+// running, and holding the resources of their node.
+func countTerminatingPods(pods []v1.Pod) int32 {
+	var count int32
+	for i := range pods {
+		if p := &pods[i]; p.DeletionTimestamp != nil && p.Status.Phase != v1.PodSucceeded && p.Status.Phase != v1.PodFailed {
+			count++
+		}
+	}
+	return count
+}
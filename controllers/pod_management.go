@@ -0,0 +1,66 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sort"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/types"
+)
+
+// isOrderedReady reports whether the pods of the podSet are created and
+// deleted one at a time.
+func isOrderedReady(podSet *pixiuv1alpha1.PodSet) bool {
+	return podSet.Spec.PodManagementPolicy == pixiuv1alpha1.OrderedReadyPodManagement
+}
+
+// allPodsReady reports whether all the pods are ready, which an OrderedReady
+// podSet waits for before each step.
+func allPodsReady(pods []*corev1.Pod) bool {
+	for _, pod := range pods {
+		if !IsPodReady(pod) {
+			return false
+		}
+	}
+	return true
+}
+
+// highestIndexPods returns the count pods with the highest indexes, which an
+// OrderedReady podSet deletes first. Pods without an index come first.
+func highestIndexPods(pods []*corev1.Pod, count int) []*corev1.Pod {
+	sorted := append([]*corev1.Pod{}, pods...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return podIndex(sorted[i]) > podIndex(sorted[j])
+	})
+	if count > len(sorted) {
+		count = len(sorted)
+	}
+	return sorted[:count]
+}
+
+// podIndex returns the index of the pod, or the highest index when it has none.
+func podIndex(pod *corev1.Pod) int {
+	index, err := strconv.Atoi(pod.Annotations[types.PodIndexAnnotation])
+	if err != nil {
+		return int(^uint(0) >> 1)
+	}
+	return index
+}
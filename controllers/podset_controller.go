@@ -18,25 +18,44 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/metrics"
+	"github.com/caoyingjunz/podset-operator/pkg/prometheus"
+	"github.com/caoyingjunz/podset-operator/pkg/registry"
+	"github.com/caoyingjunz/podset-operator/pkg/signature"
+	"github.com/caoyingjunz/podset-operator/pkg/snapshot"
 	"github.com/caoyingjunz/podset-operator/pkg/types"
+	"github.com/caoyingjunz/podset-operator/pkg/util"
+	"github.com/caoyingjunz/podset-operator/pkg/validation"
 )
 
 // PodSetReconciler reconciles a PodSet object
@@ -46,11 +65,71 @@ type PodSetReconciler struct {
 	Log    logr.Logger
 
 	Recorder record.EventRecorder // TODO
+
+	// SnapshotStore, when set, receives a snapshot of every PodSet spec change.
+	SnapshotStore snapshot.Store
+	// SnapshotHistoryLimit is the number of revisions kept per snapshot.
+	SnapshotHistoryLimit int
+
+	// Settings are the tunables of the controller. They are read through
+	// settings() once the controller is running, see UpdateSettings.
+	Settings   Settings
+	settingsMu sync.RWMutex
+
+	// APIReader reads from the API server, bypassing the cache. Large scale
+	// downs are verified through it, see Settings.LiveVerifyDeleteThreshold.
+	APIReader client.Reader
+
+	// PodMetadataOnly watches and caches only the metadata of pods, and lists
+	// the full pods of a PodSet through APIReader when it is reconciled.
+	PodMetadataOnly bool
+
+	// SchedulingPreCheck checks that a pod of the template fits on a node
+	// before scaling up. It needs to list nodes.
+	SchedulingPreCheck bool
+
+	// DrainContext, when set, is cancelled at the end of the drain period of a
+	// graceful shutdown. In-flight reconciles keep running until then.
+	DrainContext context.Context
+
+	// SignatureTrust holds the Fulcio and Rekor roots keyless image signatures
+	// are verified against, see spec.imageVerification.
+	SignatureTrust *signature.TrustRoot
+
+	// PreDeleteHookHosts are the hosts spec.preDeleteHook may call, a leading
+	// dot allowing the subdomains of a domain. The hooks of other hosts fail.
+	PreDeleteHookHosts []string
+
+	// MaxConcurrentReconciles is the number of PodSets reconciled at once.
+	// Defaults to 1.
+	MaxConcurrentReconciles int
+
+	createBreaker      *circuitBreaker
+	expectations       *expectations
+	templateChecks     *templateChecks
+	imageVerifications *templateChecks
+	crashLoops         *crashLoops
+	registry           *registry.Resolver
+	prometheus         *prometheus.Client
+	hookClient         *http.Client
+	// resync enqueues PodSets whose namespace is no longer ignored.
+	resync chan event.GenericEvent
 }
 
 //+kubebuilder:rbac:groups=pixiu.pixiu.io,resources=podsets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=pixiu.pixiu.io,resources=podsets/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=pixiu.pixiu.io,resources=podsets/finalizers,verbs=update
+//+kubebuilder:rbac:groups=pixiu.pixiu.io,resources=podsetquotas,verbs=get;list;watch
+//+kubebuilder:rbac:groups=pixiu.pixiu.io,resources=podsetclasses,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;create;patch;delete
+//+kubebuilder:rbac:groups="",resources=pods/status,verbs=patch
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+//+kubebuilder:rbac:groups=scheduling.k8s.io,resources=priorityclasses,verbs=get;list;watch
+//+kubebuilder:rbac:groups=apps,resources=controllerrevisions,verbs=get;list;watch;create;update;delete
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;create;update;delete
+//+kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update
 
 // Implement reconcile.Reconciler so the controller can reconcile objects
 var _ reconcile.Reconciler = &PodSetReconciler{}
@@ -61,172 +140,768 @@ func (r *PodSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	log := r.Log.WithValues("request", req)
 	log.V(1).Info("reconciling pod set operator")
 
+	ctx, ok := withDrain(ctx, r.DrainContext)
+	if !ok {
+		log.V(1).Info("skipping pod set, the manager is stopping")
+		return reconcile.Result{}, nil
+	}
+
+	if r.ignored(req.Namespace) {
+		log.V(1).Info("skipping pod set of an ignored namespace")
+		return reconcile.Result{}, nil
+	}
+
 	podSet := &pixiuv1alpha1.PodSet{}
 	if err := r.Get(ctx, req.NamespacedName, podSet); err != nil {
 		if apierrors.IsNotFound(err) {
 			// Req object not found, Created objects are automatically garbage collected.
 			// For additional cleanup logic use finalizers.
 			// Return and don't requeue
+			metrics.Forget(req.Namespace, req.Name)
+			r.createBreaker.Forget(req.NamespacedName)
+			r.expectations.Forget(req.NamespacedName)
+			r.templateChecks.Forget(req.NamespacedName)
+			r.imageVerifications.Forget(req.NamespacedName)
+			r.crashLoops.Forget(req.NamespacedName)
 			return reconcile.Result{}, nil
 		} else {
-			log.Error(err, "error requesting pod set operator")
-			// Error reading the object - requeue the request.
-			return reconcile.Result{Requeue: true}, nil
+			return reconcile.Result{}, fmt.Errorf("failed to get pod set: %v", err)
+		}
+	}
+
+	// The deadline bounds the work on the podSet, e.g. a huge pod list or a
+	// hanging API call, so that it can't hold on to a worker.
+	timeout := r.reconcileTimeout(podSet)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	result, err := r.reconcilePodSet(ctx, log, req, podSet)
+	if timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		metrics.ReconcileTimeouts.WithLabelValues("podset").Inc()
+		return reconcile.Result{}, fmt.Errorf("reconcile timed out after %v: %v", timeout, ctx.Err())
+	}
+	return result, err
+}
+
+// reconcilePodSet converges the pods and status of the podSet.
+func (r *PodSetReconciler) reconcilePodSet(ctx context.Context, log logr.Logger, req ctrl.Request, podSet *pixiuv1alpha1.PodSet) (ctrl.Result, error) {
+	if podSet.DeletionTimestamp != nil && controllerutil.ContainsFinalizer(podSet, types.PodSetFinalizer) {
+		if err := r.finalize(ctx, podSet); err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to finalize pod set: %v", err)
+		}
+		return reconcile.Result{}, nil
+	}
+	if podSet.DeletionTimestamp == nil {
+		if added, err := r.ensureFinalizer(ctx, podSet); err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to add pod set finalizer: %v", err)
+		} else if added {
+			// The update triggers another reconcile.
+			return reconcile.Result{}, nil
+		}
+	}
+	if restored, err := r.restoreSnapshot(ctx, podSet); err != nil {
+		log.Error(err, "error restoring pod set from snapshot")
+	} else if restored {
+		// The spec update triggers another reconcile.
+		return reconcile.Result{}, nil
+	}
+	if rolledBack, err := r.rollback(ctx, podSet); err != nil {
+		log.Error(err, "error rolling back pod set")
+	} else if rolledBack {
+		// The spec update triggers another reconcile.
+		return reconcile.Result{}, nil
+	}
+
+	if activated, err := r.activate(ctx, podSet); err != nil {
+		log.Error(err, "error activating pod set")
+	} else if activated {
+		// The spec update triggers another reconcile.
+		return reconcile.Result{}, nil
+	}
+
+	reason := "InvalidSelector"
+	labelSelector, err := r.validatePodSelector(podSet)
+	if err == nil {
+		// The rules of podsetctl lint, which the API server doesn't enforce.
+		if errs := validation.ValidatePodSet(podSet); len(errs) != 0 {
+			reason, err = "InvalidSpec", errs.ToAggregate()
+		}
+	}
+	if err != nil {
+		podSet = podSet.DeepCopy()
+		newStatus := podSet.Status
+		r.setInvalidSpec(podSet, &newStatus, reason, err)
+		if _, err = r.updatePodSetStatus(podSet, newStatus); err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to update pod set status: %v", err)
+		}
+		// No retry fixes the spec, its update triggers another reconcile.
+		return reconcile.Result{}, nil
+	}
+	// effective carries the defaults of the PodSetClass and is used to manage
+	// the pods, while the declared podSet is kept for drift and snapshots.
+	effective, err := r.applyPodSetClass(ctx, podSet)
+	if err != nil {
+		podSet = podSet.DeepCopy()
+		newStatus := podSet.Status
+		setStalled(&newStatus, pixiuv1alpha1.PodSetInvalidClass, err.Error())
+		if _, updateErr := r.updatePodSetStatus(podSet, newStatus); updateErr != nil {
+			log.Error(updateErr, "error updating pod set status")
 		}
+		return reconcile.Result{}, fmt.Errorf("failed to apply pod set class: %v", err)
+	}
+	effective = applyImagePullSecrets(effective)
+	effective = applyServiceSubdomain(effective)
+	effective = applyScheduling(effective)
+	effective = applySpreadPolicy(effective)
+	effective = applyNodeAntiAffinity(effective)
+	effective = applyPriorityClass(effective)
+	effective = applyTerminationGracePeriod(effective)
+	effective = applySidecars(effective)
+	effective = applyEnvOverrides(effective)
+	effective = applyLifecycleDefaults(effective)
+	effective = applyReadinessGates(effective)
+	// The pinned images only apply to the pods, drift and snapshots keep the tags.
+	imageResolution, imageErr := r.resolveImages(ctx, effective)
+	if imageErr != nil {
+		imageResolution = podSet.Status.ImageResolution
+	} else if imageResolution != nil {
+		effective = effective.DeepCopy()
+		pinImages(&effective.Spec.Template, imageResolution.Images)
 	}
 
-	labelSelector, err := r.parsePodSelector(podSet)
+	// The owned pods are listed too, to release the pods that don't match the
+	// selector anymore but still have the owner reference.
+	candidatePods, err := r.listClaimCandidates(ctx, podSet, labelSelector)
 	if err != nil {
-		return reconcile.Result{Requeue: true}, nil
+		return reconcile.Result{}, fmt.Errorf("failed to list pods: %v", err)
 	}
-	allPods := &corev1.PodList{}
-	// list all pods to include the pods that don't match the rs`s selector anymore but has the stale controller ref.
-	if err = r.List(ctx, allPods, &client.ListOptions{Namespace: req.Namespace, LabelSelector: labelSelector}); err != nil {
-		log.Error(err, "error list pods")
-		return reconcile.Result{Requeue: true}, nil
+	// The orphans matching the selector are adopted and the pods no longer
+	// matching it released, the pods claimed are managed.
+	claimedPods, claimErr := r.claimPods(ctx, podSet, labelSelector, candidatePods)
+	if claimErr != nil {
+		log.Error(claimErr, "error claiming pods")
 	}
+	allPods := &corev1.PodList{Items: claimedPods}
 	// Ignore inactive pods.
 	filteredPods := FilterActivePods(allPods.Items)
+	var failedPods, unreachablePods, crashLoopingPods []*corev1.Pod
+	var failedRequeue, unreachableRequeue, crashLoopRequeue time.Duration
+	var failedErr, unreachableErr, crashLoopErr error
+	if podSet.DeletionTimestamp == nil {
+		// The failed pods kept by the failure policy count as replicas.
+		if failedPods, failedRequeue, failedErr = r.handleFailedPods(ctx, effective, allPods.Items, time.Now()); failedErr != nil {
+			log.Error(failedErr, "error handling failed pods")
+		}
+		filteredPods = append(filteredPods, failedPods...)
+		// The pods on nodes that aren't ready may be replaced right away.
+		if unreachablePods, unreachableRequeue, unreachableErr = r.handleUnreachablePods(ctx, effective, allPods.Items, time.Now()); unreachableErr != nil {
+			log.Error(unreachableErr, "error handling pods on nodes not ready")
+		}
+		filteredPods = remainingPods(filteredPods, unreachablePods)
+		var replacedPod *corev1.Pod
+		if crashLoopingPods, replacedPod, crashLoopRequeue, crashLoopErr = r.handleCrashLoopingPods(ctx, effective, filteredPods, time.Now()); crashLoopErr != nil {
+			log.Error(crashLoopErr, "error handling crash looping pods")
+		}
+		if replacedPod != nil {
+			filteredPods = remainingPods(filteredPods, []*corev1.Pod{replacedPod})
+			crashLoopingPods = remainingPods(crashLoopingPods, []*corev1.Pod{replacedPod})
+		}
+	}
+	// The standby pods don't count as replicas until they are promoted.
+	filteredPods, standbyPods := splitStandbyPods(filteredPods)
+	revisionHash, err := util.ComputeTemplateHash(&effective.Spec.Template)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to compute pod template hash: %v", err)
+	}
+	if len(podSet.Status.UpdateRevision) == 0 {
+		if err = r.labelPodRevisions(ctx, filteredPods, revisionHash); err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to label pod revisions: %v", err)
+		}
+	}
+
+	schedule, scheduleRequeue, err := activeSchedule(podSet, time.Now())
+	if err != nil {
+		log.Error(err, "error evaluating replica schedules")
+	}
+	desired, sourceStatus, sourceRequeue := r.desiredReplicas(ctx, podSet, schedule)
+	sourceRequeue = minRequeue(minRequeue(minRequeue(minRequeue(sourceRequeue, scheduleRequeue), failedRequeue), unreachableRequeue), crashLoopRequeue)
+	desired, clampMsg := clampReplicas(podSet, desired)
+	hibernate, hibernationRequeue, err := hibernating(podSet, time.Now())
+	if err != nil {
+		log.Error(err, "error evaluating hibernation windows")
+	}
+	sourceRequeue = minRequeue(sourceRequeue, hibernationRequeue)
+	if podSet.Spec.Suspend || hibernate {
+		desired = 0
+	}
+	desired, quotaMsg, err := r.applyQuota(ctx, effective, int32(len(filteredPods)), desired)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to apply pod set quotas: %v", err)
+	}
+	if len(quotaMsg) != 0 {
+		sourceRequeue = minRequeue(sourceRequeue, quotaRetryInterval)
+	}
+	if imageErr != nil {
+		if desired > int32(len(filteredPods)) {
+			r.Log.Info("Not scaling up, the images can't be resolved", "podSet", klog.KObj(podSet), "error", imageErr.Error())
+			desired = int32(len(filteredPods))
+		}
+		sourceRequeue = minRequeue(sourceRequeue, imageRetryInterval)
+	}
+	var verifyErr error
+	if podSet.DeletionTimestamp == nil && imageErr == nil {
+		if verifyErr = r.verifyImages(ctx, effective); verifyErr != nil {
+			if desired > int32(len(filteredPods)) {
+				r.Log.Info("Not scaling up, the image signatures can't be verified", "podSet", klog.KObj(podSet), "error", verifyErr.Error())
+				desired = int32(len(filteredPods))
+			}
+			sourceRequeue = minRequeue(sourceRequeue, templateRetryInterval)
+		}
+	}
+	var priorityErr error
+	if podSet.DeletionTimestamp == nil {
+		if priorityErr = r.checkPriorityClass(ctx, effective); priorityErr != nil {
+			if desired > int32(len(filteredPods)) {
+				r.Log.Info("Not scaling up, the priority class is invalid", "podSet", klog.KObj(podSet), "error", priorityErr.Error())
+				desired = int32(len(filteredPods))
+			}
+			sourceRequeue = minRequeue(sourceRequeue, priorityClassRetryInterval)
+		}
+	}
+	var templateErr error
+	if podSet.DeletionTimestamp == nil && imageErr == nil && verifyErr == nil && priorityErr == nil {
+		if templateErr = r.dryRunTemplate(ctx, effective); templateErr != nil && desired > int32(len(filteredPods)) {
+			r.Log.Info("Not scaling up, the pod template is refused", "podSet", klog.KObj(podSet), "error", templateErr.Error())
+			desired = int32(len(filteredPods))
+		}
+		if templateErr != nil {
+			sourceRequeue = minRequeue(sourceRequeue, templateRetryInterval)
+		}
+	}
+	var unschedulableReason, unschedulableMsg string
+	if r.SchedulingPreCheck && desired > int32(len(filteredPods)) {
+		if unschedulableReason, unschedulableMsg, err = r.checkSchedulable(ctx, effective); err != nil {
+			// Don't block scaling up on the pre-check, the scheduler has the final word.
+			log.Error(err, "error checking pod set schedulability")
+		} else if len(unschedulableReason) != 0 {
+			r.Log.Info("Not scaling up, no node can run the pods", "podSet", klog.KObj(podSet), "reason", unschedulableReason)
+			desired = int32(len(filteredPods))
+			sourceRequeue = minRequeue(sourceRequeue, schedulingRetryInterval)
+		}
+	}
 
-	var replicasErr error
+	revision := podSet.Status.Revision
+	var revisionErr error
+	if podSet.DeletionTimestamp == nil {
+		if revision, revisionErr = r.syncRevisions(ctx, podSet); revisionErr != nil {
+			log.Error(revisionErr, "error syncing template revisions")
+		}
+	}
+
+	var replicasErr, standbyErr error
+	var result scaleResult
+	if podSet.DeletionTimestamp == nil && !podSet.Spec.Paused && !r.expectations.Satisfied(client.ObjectKeyFromObject(podSet), time.Now()) {
+		// The pods created or deleted by a previous reconcile aren't all in
+		// the cache yet, their events trigger another reconcile.
+		r.Log.V(2).Info("Waiting for the pod creations and deletions to be observed", "podSet", klog.KObj(podSet))
+		result.requeueAfter = ExpectationsTimeout
+	} else if podSet.DeletionTimestamp == nil && !podSet.Spec.Paused {
+		// Pods are only replaced with a template that passed the checks, and
+		// once its images are pre-pulled.
+		rollout := imageErr == nil && verifyErr == nil && priorityErr == nil && templateErr == nil && prePulled(effective, revisionHash)
+		if missing := int(desired) - len(filteredPods); missing > 0 && len(standbyPods) != 0 {
+			var promotedPods []*corev1.Pod
+			if promotedPods, standbyPods, standbyErr = r.promoteStandbyPods(ctx, effective, standbyPods, missing, revisionHash); standbyErr != nil {
+				log.Error(standbyErr, "error promoting standby pods")
+			}
+			filteredPods = append(filteredPods, promotedPods...)
+		}
+		result, replicasErr = r.manageReplicas(ctx, filteredPods, effective, desired, revisionHash, rollout)
+		if standbyErr == nil {
+			if standbyErr = r.manageStandby(ctx, effective, filteredPods, standbyPods, revisionHash); standbyErr != nil {
+				log.Error(standbyErr, "error managing standby pods")
+			}
+		}
+	}
+	// The standby pods are scheduled and carry the metadata like the replicas.
+	podsWithStandby := append(append([]*corev1.Pod{}, filteredPods...), standbyPods...)
+	var gatesErr error
 	if podSet.DeletionTimestamp == nil {
-		replicasErr = r.manageReplicas(ctx, filteredPods, podSet)
+		if gatesErr = r.liftSchedulingGates(ctx, effective, podsWithStandby); gatesErr != nil {
+			log.Error(gatesErr, "error lifting scheduling gates")
+		}
+	}
+	var metadataErr error
+	if podSet.DeletionTimestamp == nil {
+		if metadataErr = r.syncPropagatedMetadata(ctx, effective, podsWithStandby); metadataErr != nil {
+			log.Error(metadataErr, "error propagating pod set metadata")
+		}
+	}
+	var finishedErr error
+	if podSet.DeletionTimestamp == nil {
+		var finishedRequeue time.Duration
+		if finishedRequeue, finishedErr = r.deleteFinishedPods(ctx, effective, allPods.Items, time.Now()); finishedErr != nil {
+			log.Error(finishedErr, "error deleting finished pods")
+		}
+		result.requeueAfter = minRequeue(result.requeueAfter, finishedRequeue)
+	}
+	result.requeueAfter = minRequeue(result.requeueAfter, sourceRequeue)
+
+	prePull := podSet.Status.PrePull
+	var prePullErr error
+	// Images that can't be trusted aren't pulled on the nodes either.
+	if podSet.DeletionTimestamp == nil && verifyErr == nil {
+		var prePullRequeue time.Duration
+		if prePull, prePullRequeue, prePullErr = r.syncPrePull(ctx, effective, filteredPods); prePullErr != nil {
+			log.Error(prePullErr, "error pre-pulling images")
+		}
+		result.requeueAfter = minRequeue(result.requeueAfter, prePullRequeue)
 	}
 
 	podSet = podSet.DeepCopy()
-	newStatus := r.calculateStatus(podSet, filteredPods, replicasErr)
+	// The pods are counted against the effective spec they are created from.
+	newStatus, availableAfter := r.calculateStatus(effective, filteredPods, revisionHash, replicasErr)
+	result.requeueAfter = minRequeue(result.requeueAfter, availableAfter)
+	newStatus.DesiredReplicas = desired
+	newStatus.Revision = revision
+	newStatus.ReplicaSource = sourceStatus
+	newStatus.ScaledToZeroSince = scaledToZeroSince(podSet, &newStatus, time.Now())
+	newStatus.ActiveSchedule = ""
+	if schedule != nil {
+		newStatus.ActiveSchedule = schedule.Name
+	}
+	newStatus.ScaleDown = result.scaleDown
+	newStatus.StandbyReplicas = int32(len(standbyPods))
+	newStatus.TerminatingReplicas = countTerminatingPods(allPods.Items)
+	newStatus.Hibernation = r.trackHibernation(podSet, hibernate, time.Now())
+	setHibernatingCondition(&newStatus)
+	r.setCreateCircuitCondition(podSet, &newStatus)
+	setReplicasClampedCondition(&newStatus, clampMsg)
+	setPodsFailedCondition(&newStatus, effective, failedPods)
+	setCrashLoopingCondition(&newStatus, effective, crashLoopingPods)
+	setQuotaCondition(&newStatus, quotaMsg)
+	setUnschedulableCondition(&newStatus, unschedulableReason, unschedulableMsg)
+	setTemplateInvalidCondition(&newStatus, templateErr)
+	setPriorityClassCondition(&newStatus, priorityErr)
+	RemovePodSetCondition(&newStatus, pixiuv1alpha1.PodSetInvalidSpec)
+	setImageResolutionCondition(&newStatus, imageErr)
+	setImageVerificationCondition(&newStatus, verifyErr)
+	newStatus.ImageResolution = imageResolution
+	newStatus.PrePull = prePull
+	newStatus.Resources = resourceTotals(&effective.Spec.Template, desired, r.settings().ResourcePrices)
+	if newStatus.Spread, err = r.topologySpread(ctx, effective, filteredPods); err != nil {
+		// Keep the last reported spread rather than clearing it.
+		log.Error(err, "error computing the topology spread")
+		newStatus.Spread = podSet.Status.Spread
+	}
+	var auxiliaryErr error
+	if podSet.DeletionTimestamp == nil {
+		if auxiliaryErr = r.syncAuxiliaryResources(ctx, effective, &newStatus); auxiliaryErr != nil {
+			log.Error(auxiliaryErr, "error syncing auxiliary resources")
+		}
+	}
+	reconcileErr := utilerrors.NewAggregate([]error{claimErr, replicasErr, standbyErr, revisionErr, gatesErr, metadataErr, failedErr, unreachableErr, crashLoopErr, finishedErr, auxiliaryErr, prePullErr})
+	setKStatusConditions(&newStatus, reconcileErr, isRollingUpdate(effective), rollingUpdatePartition(effective))
+	setAvailableCondition(effective, &newStatus)
+	setSuspendedCondition(&newStatus, podSet.Spec.Suspend)
+	setPausedCondition(&newStatus, podSet.Spec.Paused)
+	r.trackRollback(podSet, &newStatus)
+	result.requeueAfter = minRequeue(result.requeueAfter, r.setProgressingCondition(podSet, &newStatus, time.Now()))
+	r.checkDrift(podSet, &newStatus)
+	r.syncSnapshot(ctx, podSet, &newStatus)
 
-	_, err = r.updatePodSetStatus(podSet, newStatus)
-	if err != nil {
-		return reconcile.Result{Requeue: true}, nil
+	newStatus.FailureBackoff = nil
+	if reconcileErr != nil {
+		newStatus.FailureBackoff = r.failureBackoff(podSet, reconcileErr, time.Now())
+	}
+
+	if _, err = r.updatePodSetStatus(podSet, newStatus); err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to update pod set status: %v", err)
 	}
 
-	return ctrl.Result{}, nil
+	if newStatus.FailureBackoff != nil {
+		return ctrl.Result{RequeueAfter: time.Until(newStatus.FailureBackoff.NextRetryTime.Time)}, nil
+	}
+	return ctrl.Result{RequeueAfter: result.requeueAfter}, nil
 }
 
-func (r *PodSetReconciler) manageReplicas(ctx context.Context, filteredPods []*corev1.Pod, podSet *pixiuv1alpha1.PodSet) error {
-	diff := len(filteredPods) - int(*podSet.Spec.Replicas)
+// manageReplicas creates or deletes pods to converge to the desired replicas,
+// and reports the changes postponed by the scale policy. When rollout is set
+// and the podSet is updated with a RollingUpdate, the pods of previous
+// templates are replaced as well.
+func (r *PodSetReconciler) manageReplicas(ctx context.Context, filteredPods []*corev1.Pod, podSet *pixiuv1alpha1.PodSet, replicas int32, revisionHash string, rollout bool) (scaleResult, error) {
+	var result scaleResult
+	now := time.Now()
+
+	if rollout && isRollingUpdate(podSet) {
+		oldPods, updatedPods := splitPodsByRevision(podSet, filteredPods, revisionHash)
+		if pinnedPods, outdatedPods := partitionPods(oldPods, rollingUpdatePartition(podSet)); len(outdatedPods) != 0 {
+			return r.rollOut(ctx, podSet, pinnedPods, outdatedPods, updatedPods, replicas, revisionHash, now)
+		}
+	}
+
+	diff := len(filteredPods) - int(replicas)
+	if diff <= 0 {
+		result.scaleDown = activeScaleDownWindow(podSet, now)
+	}
+	var zones *zonePlan
+	if len(podSet.Spec.ZoneDistribution) != 0 {
+		var err error
+		if zones, err = r.planZones(ctx, podSet, replicas); err != nil {
+			return result, err
+		}
+		// The pods are created in the zone missing the most replicas, one zone
+		// per reconcile, and only deleted once no zone misses any.
+		if zone, missing := zones.mostMissing(podSet, filteredPods); missing > 0 {
+			r.Log.Info("Too few replicas in zone", "podSet", klog.KObj(podSet), "zone", zone, "need", zones.shares[zone], "missing", missing)
+			podSet, diff = zonedPodSet(podSet, zone), -missing
+		}
+	}
 	if diff < 0 {
 		diff *= -1
-		if diff > types.BurstReplicas {
-			diff = types.BurstReplicas
+		if burst := r.burstReplicas(podSet); diff > burst {
+			diff = burst
 		}
-		r.Log.Info("Too few replicas", "podSet", klog.KObj(podSet), "need", *(podSet.Spec.Replicas), "creating", diff)
-		_, err := r.createPodsInBatch(diff, 1, func() error {
-			if err := r.createPod(ctx, podSet.Namespace, &podSet.Spec.Template, podSet, metav1.NewControllerRef(podSet, pixiuv1alpha1.GroupVersionKind)); err != nil {
-				return err
+		if isOrderedReady(podSet) {
+			if !allPodsReady(filteredPods) {
+				r.Log.V(2).Info("Waiting for the pods to be ready before creating the next one", "podSet", klog.KObj(podSet))
+				return result, nil
 			}
-			return nil
-		})
-
-		return err
+			diff = 1
+		}
+		r.Log.Info("Too few replicas", "podSet", klog.KObj(podSet), "need", replicas, "creating", diff)
+		var err error
+		result.requeueAfter, err = r.createReplicas(ctx, podSet, filteredPods, diff, revisionHash, now)
+		return result, err
 
 	} else if diff > 0 {
-		if diff > types.BurstReplicas {
-			diff = types.BurstReplicas
+		pending := diff
+		if burst := r.burstReplicas(podSet); diff > burst {
+			diff = burst
 		}
-		r.Log.Info("Too many replicas", "podSet", klog.KObj(podSet), "need", *(podSet.Spec.Replicas), "deleting", diff)
-		podToDelete := getPodsToDelete(filteredPods, diff)
-
-		errCh := make(chan error, diff)
-		var wg sync.WaitGroup
-		wg.Add(diff)
-		for _, pod := range podToDelete {
-			go func(targetPod *corev1.Pod) {
-				defer wg.Done()
-				if err := r.deletePod(ctx, targetPod.Namespace, targetPod.Name); err != nil {
-					if !apierrors.IsNotFound(err) {
-						errCh <- err
-					}
+		if isOrderedReady(podSet) {
+			if !allPodsReady(filteredPods) {
+				r.Log.V(2).Info("Waiting for the pods to be ready before deleting the next one", "podSet", klog.KObj(podSet))
+				return result, nil
+			}
+			diff = 1
+		}
+		if budget, window, retryAfter := deleteBudget(podSet, now); budget >= 0 {
+			if diff > budget {
+				r.Log.Info("Pod deletion throttled by scale policy", "podSet", klog.KObj(podSet), "need", diff, "allowed", budget, "retryAfter", retryAfter)
+				diff = budget
+			}
+			window.DeletedInWindow += int32(diff)
+			window.PendingDeletions = int32(pending - diff)
+			result.scaleDown = window
+			if window.PendingDeletions > 0 {
+				result.requeueAfter = retryAfter
+			}
+			if diff == 0 {
+				return result, nil
+			}
+		}
+		if threshold := r.settings().LiveVerifyDeleteThreshold; r.APIReader != nil && threshold > 0 && diff > threshold {
+			livePods, liveDiff, err := r.verifyScaleDown(ctx, podSet, replicas, diff)
+			switch {
+			case err == errStaleCache:
+				// The update of the PodSet triggers another reconcile.
+				r.Log.Info("Skipping scale down based on a stale pod set", "podSet", klog.KObj(podSet))
+				liveDiff = 0
+			case err != nil:
+				return result, fmt.Errorf("failed to verify scale down: %v", err)
+			default:
+				filteredPods = livePods
+			}
+			if liveDiff != diff {
+				r.Log.Info("Scale down reduced after live verification", "podSet", klog.KObj(podSet), "cached", diff, "live", liveDiff)
+				if result.scaleDown != nil {
+					result.scaleDown.DeletedInWindow -= int32(diff - liveDiff)
 				}
-			}(pod)
+				diff = liveDiff
+			}
+			if diff == 0 {
+				return result, nil
+			}
 		}
-		wg.Wait()
+		r.Log.Info("Too many replicas", "podSet", klog.KObj(podSet), "need", replicas, "deleting", diff)
+		candidates := filteredPods
+		if zones != nil {
+			// Only the pods above the share of their zone are deleted.
+			candidates = zones.excessPods(podSet, filteredPods)
+		}
+		podToDelete := getPodsToDelete(podSet, candidates, diff)
+		if isOrderedReady(podSet) || hasFixedPodNames(podSet) {
+			// Keep the indexes of the remaining pods contiguous.
+			podToDelete = highestIndexPods(candidates, diff)
+		}
+		return result, r.deletePods(ctx, podSet, podToDelete)
+	}
+
+	return result, nil
+}
+
+// SlowStartInitialBatchSize is the size of the first batch of pods created
+// by createPodsInBatch.
+const SlowStartInitialBatchSize = 1
+
+// createReplicas creates count pods from the template with the revision hash,
+// within the create circuit breaker and the scale policy. It returns when the
+// creations postponed by them may proceed.
+func (r *PodSetReconciler) createReplicas(ctx context.Context, podSet *pixiuv1alpha1.PodSet, filteredPods []*corev1.Pod, count int, revisionHash string, now time.Time) (time.Duration, error) {
+	var requeueAfter time.Duration
+	key := client.ObjectKeyFromObject(podSet)
+	if allowed, remaining := r.createBreaker.Allow(key, now); !allowed {
+		r.Log.V(2).Info("Pod creation suspended by open circuit", "podSet", klog.KObj(podSet), "retryAfter", remaining)
+		return remaining, nil
+	}
+	if budget, retryAfter := createBudget(podSet, filteredPods, now); budget >= 0 && count > budget {
+		r.Log.Info("Pod creation throttled by scale policy", "podSet", klog.KObj(podSet), "need", count, "allowed", budget, "retryAfter", retryAfter)
+		count = budget
+		requeueAfter = retryAfter
+		if count == 0 {
+			return requeueAfter, nil
+		}
+	}
+	indexes := make(chan int, count)
+	for _, index := range freePodIndexes(filteredPods, count) {
+		indexes <- index
+	}
 
-		select {
-		case err := <-errCh:
-			if err != nil {
-				return err
+	successes, err := r.createPodsInBatch(count, SlowStartInitialBatchSize, func() error {
+		template, err := podTemplate(podSet, <-indexes, revisionHash)
+		if err != nil {
+			return err
+		}
+		if err := r.createPod(ctx, podSet.Namespace, template, podSet, podSetOwnerReference(podSet)); err != nil {
+			if len(template.Name) != 0 && apierrors.IsAlreadyExists(err) {
+				// The previous pod of the index is still terminating, its
+				// deletion triggers another reconcile.
+				r.Log.Info("Waiting for the previous pod to be deleted", "podSet", klog.KObj(podSet), "pod", template.Name)
+				return nil
 			}
-		default:
+			return err
 		}
+		return nil
+	})
+	// The batches skipped after a failed one aren't failures.
+	failed := 0
+	if agg, ok := err.(utilerrors.Aggregate); ok {
+		failed = len(agg.Errors())
 	}
+	if now := time.Now(); r.createBreaker.Record(key, successes, failed, err, now) {
+		_, failures, until, _ := r.createBreaker.Open(key)
+		r.Recorder.Eventf(podSet, corev1.EventTypeWarning, "CreateCircuitOpen",
+			"Stopped creating pods for %v after %d consecutive failures: %v", until.Sub(now), failures, err)
+	}
+	return requeueAfter, err
+}
 
-	return nil
+// podDeleteOptions returns the options the pods of the podSet are deleted
+// with: the grace period of spec.terminationGracePeriodSeconds, which
+// overrides the one of the pods, and spec.podDeletionPropagation.
+func podDeleteOptions(podSet *pixiuv1alpha1.PodSet) []client.DeleteOption {
+	var opts []client.DeleteOption
+	if seconds := podSet.Spec.TerminationGracePeriodSeconds; seconds != nil {
+		opts = append(opts, client.GracePeriodSeconds(*seconds))
+	}
+	if policy := podSet.Spec.PodDeletionPropagation; policy != nil {
+		opts = append(opts, client.PropagationPolicy(*policy))
+	}
+	return opts
 }
 
-func (r *PodSetReconciler) createPod(ctx context.Context, namespace string, template *corev1.PodTemplateSpec, object runtime.Object, controllerRef *metav1.OwnerReference) error {
-	if err := validateControllerRef(controllerRef); err != nil {
+// deletePods deletes the pods in parallel, pods already gone are ignored,
+// with the delete options of the podSet.
+func (r *PodSetReconciler) deletePods(ctx context.Context, podSet *pixiuv1alpha1.PodSet, pods []*corev1.Pod) error {
+	opts := podDeleteOptions(podSet)
+	key := client.ObjectKeyFromObject(podSet)
+	r.expectations.ExpectDeletions(key, pods)
+	errCh := make(chan error, len(pods))
+	var wg sync.WaitGroup
+	wg.Add(len(pods))
+	for _, pod := range pods {
+		go func(targetPod *corev1.Pod) {
+			defer wg.Done()
+			if err := r.deletePod(ctx, targetPod, opts...); err != nil {
+				// No event of the pod is coming.
+				r.expectations.DeletionObserved(key, podKey(targetPod))
+				if !apierrors.IsNotFound(err) {
+					r.Recorder.Eventf(podSet, corev1.EventTypeWarning, "FailedDelete", "Error deleting pod %s: %v", targetPod.Name, err)
+					errCh <- err
+				}
+				return
+			}
+			r.Recorder.Eventf(podSet, corev1.EventTypeNormal, "SuccessfulDelete", "Deleted pod: %s", targetPod.Name)
+		}(pod)
+	}
+	wg.Wait()
+	close(errCh)
+
+	errs := make([]error, 0, len(errCh))
+	for err := range errCh {
+		errs = append(errs, err)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (r *PodSetReconciler) createPod(ctx context.Context, namespace string, template *corev1.PodTemplateSpec, object runtime.Object, ownerRef *metav1.OwnerReference) error {
+	pod, err := newPod(namespace, template, object, ownerRef)
+	if err != nil {
 		return err
 	}
-	pod, err := GetPodFromTemplate(template, object, controllerRef)
+	key := client.ObjectKey{Namespace: namespace, Name: ownerRef.Name}
+	r.expectations.ExpectCreations(key, 1)
+	if gates := pod.Annotations[types.SchedulingGatesAnnotation]; len(gates) != 0 {
+		err = r.createGatedPod(ctx, pod, strings.Split(gates, ","))
+	} else {
+		err = r.Create(ctx, pod)
+	}
 	if err != nil {
+		// No event of the pod is coming.
+		r.expectations.CreationObserved(key)
+		// Failing to create pods in a namespace being deleted, or a fixed name
+		// pod while the previous one terminates, is expected.
+		if !apierrors.HasStatusCause(err, corev1.NamespaceTerminatingCause) && !(len(pod.Name) != 0 && apierrors.IsAlreadyExists(err)) {
+			r.Recorder.Eventf(object, corev1.EventTypeWarning, "FailedCreate", "Error creating: %v", err)
+		}
 		return err
 	}
+	r.Recorder.Eventf(object, corev1.EventTypeNormal, "SuccessfulCreate", "Created pod: %s", pod.Name)
+	return nil
+}
+
+// newPod returns the pod to create from the template.
+func newPod(namespace string, template *corev1.PodTemplateSpec, object runtime.Object, ownerRef *metav1.OwnerReference) (*corev1.Pod, error) {
+	if err := validateOwnerRef(ownerRef); err != nil {
+		return nil, err
+	}
+	pod, err := GetPodFromTemplate(template, object, ownerRef)
+	if err != nil {
+		return nil, err
+	}
 
 	if len(labels.Set(pod.Labels)) == 0 {
 		// return fmt.Errorf("failed to create pod, no labels")
 		// TODO: CRD 在存储 spec.template 为空
-		ps := object.(*pixiuv1alpha1.PodSet)
-		pod.Labels = ps.Spec.Selector.MatchLabels
+		pod.Labels = podLabels(object.(*pixiuv1alpha1.PodSet))
 	}
 
 	pod.SetNamespace(namespace)
-	if err = r.Create(ctx, pod); err != nil {
-		if apierrors.HasStatusCause(err, corev1.NamespaceTerminatingCause) {
-			// TODO: 打印个事件
-			r.Recorder.Event(pod, corev1.EventTypeWarning, "create pod fail", err.Error())
-		}
-		return err
+	return pod, nil
+}
+
+// listPods lists pods from the cache, or from the API server when only the
+// metadata of pods is cached.
+func (r *PodSetReconciler) listPods(ctx context.Context, pods *corev1.PodList, opts ...client.ListOption) error {
+	if r.PodMetadataOnly {
+		return r.APIReader.List(ctx, pods, opts...)
 	}
-	r.Recorder.Event(pod, corev1.EventTypeNormal, "create pod successful", "create pod successful -1")
-	return nil
+	return r.List(ctx, pods, opts...)
 }
 
-func (r *PodSetReconciler) deletePod(ctx context.Context, namespace string, name string) error {
+// deletePod deletes the pod observed, the UID precondition keeps a pod
+// recreated with the same name from being deleted in its place.
+func (r *PodSetReconciler) deletePod(ctx context.Context, observed *corev1.Pod, opts ...client.DeleteOption) error {
+	namespace, name := observed.Namespace, observed.Name
 	pod := &corev1.Pod{}
 	pod.SetNamespace(namespace)
 	pod.SetName(name)
-	if err := r.Delete(ctx, pod); err != nil {
+	uid := observed.UID
+	opts = append(opts, client.Preconditions{UID: &uid})
+	if err := r.Delete(ctx, pod, opts...); err != nil {
 		if apierrors.IsNotFound(err) {
 			klog.V(4).Infof("pod %v/%v has already been deleted.", namespace, name)
 			return err
 		}
+		if apierrors.IsConflict(err) {
+			// The pod observed is gone as well.
+			klog.V(4).Infof("pod %v/%v has been replaced, not deleting it.", namespace, name)
+			return apierrors.NewNotFound(corev1.Resource("pods"), name)
+		}
 
-		return fmt.Errorf("failed to delete pod: %v", err)
+		// The API error is kept for the reason of the ReplicaFailure condition.
+		return fmt.Errorf("failed to delete pod: %w", err)
 	}
 
 	return nil
 }
 
+// createPodsInBatch calls fn count times, in batches doubling in size from
+// initialBatchSize, like the slow start of kube-controller-manager. It stops
+// after the first batch with a failure, so that a template refused by the API
+// server, e.g. by a quota, doesn't cost count failed calls. It returns the
+// number of successful calls and the errors of the failed batch.
 func (r *PodSetReconciler) createPodsInBatch(count int, initialBatchSize int, fn func() error) (int, error) {
-	errCh := make(chan error, count)
-	var wg sync.WaitGroup
-	wg.Add(count)
-	for i := 0; i < count; i++ {
-		go func() {
-			defer wg.Done()
-			if err := fn(); err != nil {
-				errCh <- err
+	if initialBatchSize < 1 {
+		initialBatchSize = 1
+	}
+	remaining := count
+	successes := 0
+	for batchSize := minInt(remaining, initialBatchSize); batchSize > 0; batchSize = minInt(2*batchSize, remaining) {
+		errCh := make(chan error, batchSize)
+		var wg sync.WaitGroup
+		wg.Add(batchSize)
+		for i := 0; i < batchSize; i++ {
+			go func() {
+				defer wg.Done()
+				if err := fn(); err != nil {
+					errCh <- err
+				}
+			}()
+		}
+		wg.Wait()
+		close(errCh)
+
+		successes += batchSize - len(errCh)
+		if len(errCh) != 0 {
+			errs := make([]error, 0, len(errCh))
+			for err := range errCh {
+				errs = append(errs, err)
 			}
-		}()
+			return successes, utilerrors.NewAggregate(errs)
+		}
+		remaining -= batchSize
 	}
-	wg.Wait()
+	return successes, nil
+}
 
-	return 0, nil
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
 }
 
-func (r *PodSetReconciler) calculateStatus(podSet *pixiuv1alpha1.PodSet, filteredPods []*corev1.Pod, replicasErr error) pixiuv1alpha1.PodSetStatus {
+// calculateStatus counts the pods of the podSet. It also returns when the
+// next ready pod becomes available, so that the status is updated then.
+func (r *PodSetReconciler) calculateStatus(podSet *pixiuv1alpha1.PodSet, filteredPods []*corev1.Pod, revisionHash string, replicasErr error) (pixiuv1alpha1.PodSetStatus, time.Duration) {
 	newStatus := podSet.Status
 
 	readyReplicasCount := 0
 	availableReplicasCount := 0
-	// TODO: 设置 condition
+	updatedReplicasCount := 0
+	fullyLabeledReplicasCount := 0
+	templateLabels := fullLabelsSelector(podSet)
+	var availableAfter time.Duration
+	now := metav1.Now()
+	minReadySeconds := podSet.Spec.MinReadySeconds
 	for _, pod := range filteredPods {
+		if pod.Labels[types.PodTemplateHashLabel] == podRevision(podSet, pod, revisionHash) {
+			updatedReplicasCount++
+		}
+		if templateLabels.Matches(labels.Set(pod.Labels)) {
+			fullyLabeledReplicasCount++
+		}
 		if IsPodReady(pod) {
 			readyReplicasCount++
-			if IsPodAvailable(pod, 0, metav1.Now()) {
+			if IsPodAvailable(pod, minReadySeconds, now) {
 				availableReplicasCount++
+			} else if c := GetPodReadyCondition(pod.Status); c != nil && !c.LastTransitionTime.IsZero() {
+				remaining := c.LastTransitionTime.Add(time.Duration(minReadySeconds) * time.Second).Sub(now.Time)
+				availableAfter = minRequeue(availableAfter, remaining+time.Second)
 			}
 		}
 	}
@@ -234,38 +909,151 @@ func (r *PodSetReconciler) calculateStatus(podSet *pixiuv1alpha1.PodSet, filtere
 	newStatus.Replicas = int32(len(filteredPods))
 	newStatus.ReadyReplicas = int32(readyReplicasCount)
 	newStatus.AvailableReplicas = int32(availableReplicasCount)
-	return newStatus
+	newStatus.UpdatedReplicas = int32(updatedReplicasCount)
+	newStatus.FullyLabeledReplicas = int32(fullyLabeledReplicasCount)
+	newStatus.UpdateRevision = revisionHash
+	newStatus.ReadinessGates = readinessGateStatus(&podSet.Spec.Template, filteredPods)
+	setReplicaFailureCondition(&newStatus, replicasErr)
+	return newStatus, availableAfter
 }
 
+// fullLabelsSelector selects the pods with all the labels the pods of the
+// podSet are created with, like the fullyLabeledReplicas of ReplicaSets. The
+// labels the controller adds are left out, they are tracked otherwise.
+func fullLabelsSelector(podSet *pixiuv1alpha1.PodSet) labels.Selector {
+	set := labels.Set(podLabels(podSet))
+	delete(set, types.PodTemplateHashLabel)
+	delete(set, types.StandbyLabel)
+	return set.AsSelectorPreValidated()
+}
+
+// replicaSourceConfigMapKey indexes PodSets by the ConfigMap their replicas are read from.
+const replicaSourceConfigMapKey = ".spec.replicaSource.configMapKeyRef.name"
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *PodSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	enqueuePod := handler.EnqueueRequestsFromMapFunc(r.mapToPods)
+	settings := r.settings()
+	r.createBreaker = newCircuitBreaker(settings.CreateFailureThreshold, settings.CreateCircuitCooldown)
+	r.expectations = newExpectations()
+	r.templateChecks = newTemplateChecks()
+	r.imageVerifications = newTemplateChecks()
+	r.crashLoops = newCrashLoops()
+	r.registry = &registry.Resolver{HTTPClient: &http.Client{Timeout: 10 * time.Second}}
+	r.resync = make(chan event.GenericEvent)
+	r.prometheus = &prometheus.Client{HTTPClient: &http.Client{Timeout: 10 * time.Second}}
+	r.hookClient = newHookClient(r.PreDeleteHookHosts)
+
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &pixiuv1alpha1.PodSet{}, replicaSourceConfigMapKey, func(obj client.Object) []string {
+		podSet := obj.(*pixiuv1alpha1.PodSet)
+		if podSet.Spec.ReplicaSource == nil || podSet.Spec.ReplicaSource.ConfigMapKeyRef == nil {
+			return nil
+		}
+		return []string{podSet.Spec.ReplicaSource.ConfigMapKeyRef.Name}
+	}); err != nil {
+		return err
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &pixiuv1alpha1.PodSet{}, classNameKey, func(obj client.Object) []string {
+		podSet := obj.(*pixiuv1alpha1.PodSet)
+		if len(podSet.Spec.ClassName) == 0 {
+			return nil
+		}
+		return []string{podSet.Spec.ClassName}
+	}); err != nil {
+		return err
+	}
+
+	// The index is built by the pod informer, which caches only metadata
+	// when PodMetadataOnly is set.
+	if !r.PodMetadataOnly {
+		if err := mgr.GetFieldIndexer().IndexField(context.Background(), &corev1.Pod{}, podOwnerKey, podOwnerIndex); err != nil {
+			return err
+		}
+	}
+
+	enqueuePod := r.podEventHandler()
+	enqueueConfigMap := handler.EnqueueRequestsFromMapFunc(r.mapConfigMapToPodSets)
+	enqueueQuota := handler.EnqueueRequestsFromMapFunc(r.mapQuotaToPodSets)
+	enqueueClass := handler.EnqueueRequestsFromMapFunc(r.mapClassToPodSets)
+
+	podOpts := []builder.WatchesOption{builder.WithPredicates(podUpdatePredicate())}
+	if r.PodMetadataOnly {
+		podOpts = append(podOpts, builder.OnlyMetadata)
+	}
 
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&pixiuv1alpha1.PodSet{}).
-		Watches(&source.Kind{Type: &corev1.Pod{}}, enqueuePod).
-		Complete(r)
+		Watches(&source.Kind{Type: &corev1.Pod{}}, enqueuePod, podOpts...).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, enqueueConfigMap, builder.OnlyMetadata).
+		Watches(&source.Kind{Type: &pixiuv1alpha1.PodSetQuota{}}, enqueueQuota).
+		Watches(&source.Kind{Type: &pixiuv1alpha1.PodSetClass{}}, enqueueClass).
+		Watches(&source.Channel{Source: r.resync}, &handler.EnqueueRequestForObject{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Complete(recoverPanics("podset", r.Log, r))
 }
 
 func (r *PodSetReconciler) updatePodSetStatus(podSet *pixiuv1alpha1.PodSet, newStatus pixiuv1alpha1.PodSetStatus) (*pixiuv1alpha1.PodSet, error) {
 	if podSet.Status.Replicas == newStatus.Replicas &&
 		podSet.Status.ReadyReplicas == newStatus.ReadyReplicas &&
 		podSet.Status.AvailableReplicas == newStatus.AvailableReplicas &&
-		// TODO: 判断条件
-		//reflect.DeepEqual(podSet.Status.Conditions, newStatus.Conditions) &&
+		podSet.Status.UpdatedReplicas == newStatus.UpdatedReplicas &&
+		podSet.Status.UpdateRevision == newStatus.UpdateRevision &&
+		podSet.Status.Revision == newStatus.Revision &&
+		podSet.Status.SnapshotGeneration == newStatus.SnapshotGeneration &&
+		reflect.DeepEqual(podSet.Status.Inventory, newStatus.Inventory) &&
+		reflect.DeepEqual(podSet.Status.ScaleDown, newStatus.ScaleDown) &&
+		reflect.DeepEqual(podSet.Status.FailureBackoff, newStatus.FailureBackoff) &&
+		podSet.Status.DesiredReplicas == newStatus.DesiredReplicas &&
+		reflect.DeepEqual(podSet.Status.ReplicaSource, newStatus.ReplicaSource) &&
+		reflect.DeepEqual(podSet.Status.ImageResolution, newStatus.ImageResolution) &&
+		reflect.DeepEqual(podSet.Status.PrePull, newStatus.PrePull) &&
+		reflect.DeepEqual(podSet.Status.Rollback, newStatus.Rollback) &&
+		reflect.DeepEqual(podSet.Status.Spread, newStatus.Spread) &&
+		podSet.Status.ActiveSchedule == newStatus.ActiveSchedule &&
+		reflect.DeepEqual(podSet.Status.ScaledToZeroSince, newStatus.ScaledToZeroSince) &&
+		reflect.DeepEqual(podSet.Status.ReadinessGates, newStatus.ReadinessGates) &&
+		podSet.Status.StandbyReplicas == newStatus.StandbyReplicas &&
+		podSet.Status.FullyLabeledReplicas == newStatus.FullyLabeledReplicas &&
+		podSet.Status.TerminatingReplicas == newStatus.TerminatingReplicas &&
+		reflect.DeepEqual(podSet.Status.Hibernation, newStatus.Hibernation) &&
+		equality.Semantic.DeepEqual(podSet.Status.Resources, newStatus.Resources) &&
+		reflect.DeepEqual(podSet.Status.Conditions, newStatus.Conditions) &&
 		podSet.Generation == newStatus.ObservedGeneration {
 		return podSet, nil
 	}
 	newStatus.ObservedGeneration = podSet.Generation
 
-	podSet.Status = newStatus
-	if err := r.Status().Update(context.TODO(), podSet); err != nil {
+	if err := r.applyPodSetStatus(context.TODO(), podSet, newStatus); err != nil {
 		return nil, err
 	}
+	podSet.Status = newStatus
 
 	return podSet, nil
 }
 
-func getPodsToDelete(filteredPods []*corev1.Pod, diff int) []*corev1.Pod {
-	return filteredPods[:diff]
+// PodSetFieldManager is the field manager the controller applies the status
+// of the PodSets with.
+const PodSetFieldManager = "podset-controller"
+
+// applyPodSetStatus applies the status with a server-side apply patch. It
+// needs no resource version, so it doesn't conflict with updates of the spec,
+// and leaves the conditions of other components untouched.
+func (r *PodSetReconciler) applyPodSetStatus(ctx context.Context, podSet *pixiuv1alpha1.PodSet, status pixiuv1alpha1.PodSetStatus) error {
+	applied := status.DeepCopy()
+	applied.Conditions = nil
+	for _, condition := range status.Conditions {
+		if controllerConditions.Has(condition.Type) {
+			applied.Conditions = append(applied.Conditions, condition)
+		}
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(applied)
+	if err != nil {
+		return err
+	}
+
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"status": content}}
+	obj.SetGroupVersionKind(pixiuv1alpha1.GroupVersionKind)
+	obj.SetNamespace(podSet.Namespace)
+	obj.SetName(podSet.Name)
+	return r.Status().Patch(ctx, obj, client.Apply, client.FieldOwner(PodSetFieldManager), client.ForceOwnership)
 }
@@ -0,0 +1,105 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestCreatePodsInBatch(t *testing.T) {
+	tests := []struct {
+		name             string
+		count            int
+		initialBatchSize int
+		// failAt fails the calls from the given one on, 0 never fails.
+		failAt        int
+		wantSuccesses int
+		wantCalls     int
+	}{
+		{
+			name:             "nothing to create",
+			count:            0,
+			initialBatchSize: 1,
+		},
+		{
+			name:             "all created",
+			count:            10,
+			initialBatchSize: 1,
+			wantSuccesses:    10,
+			wantCalls:        10,
+		},
+		{
+			name:             "invalid initial batch size",
+			count:            3,
+			initialBatchSize: 0,
+			wantSuccesses:    3,
+			wantCalls:        3,
+		},
+		{
+			// Batches of 1, 2 and 4: the failure stops after the third one.
+			name:             "stops after the failed batch",
+			count:            10,
+			initialBatchSize: 1,
+			failAt:           5,
+			wantSuccesses:    4,
+			wantCalls:        7,
+		},
+		{
+			name:             "first call failing",
+			count:            10,
+			initialBatchSize: 1,
+			failAt:           1,
+			wantSuccesses:    0,
+			wantCalls:        1,
+		},
+		{
+			name:             "initial batch larger than the count",
+			count:            3,
+			initialBatchSize: 5,
+			wantSuccesses:    3,
+			wantCalls:        3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			calls := 0
+			r := &PodSetReconciler{}
+			successes, err := r.createPodsInBatch(tt.count, tt.initialBatchSize, func() error {
+				mu.Lock()
+				defer mu.Unlock()
+				calls++
+				if tt.failAt != 0 && calls >= tt.failAt {
+					return errors.New("forbidden")
+				}
+				return nil
+			})
+			if successes != tt.wantSuccesses {
+				t.Errorf("createPodsInBatch() = %d, want %d", successes, tt.wantSuccesses)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if wantErr := tt.failAt != 0; (err != nil) != wantErr {
+				t.Errorf("createPodsInBatch() error = %v, want error %v", err, wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+// controllerConditions are the condition types the controller manages. Only
+// these are applied with the status, the conditions of other components are
+// left to them.
+var controllerConditions = sets.NewString(
+	pixiuv1alpha1.PodSetDriftDetected,
+	pixiuv1alpha1.PodSetReconciling,
+	pixiuv1alpha1.PodSetStalled,
+	pixiuv1alpha1.PodSetCreateCircuitOpen,
+	pixiuv1alpha1.PodSetQuotaDenied,
+	pixiuv1alpha1.PodSetUnschedulable,
+	pixiuv1alpha1.PodSetTemplateInvalid,
+	pixiuv1alpha1.PodSetImageResolutionFailed,
+	pixiuv1alpha1.PodSetImageVerificationFailed,
+	pixiuv1alpha1.PodSetAvailable,
+	pixiuv1alpha1.PodSetProgressing,
+	pixiuv1alpha1.PodSetPaused,
+	pixiuv1alpha1.PodSetSuspended,
+	pixiuv1alpha1.PodSetHibernating,
+	pixiuv1alpha1.PodSetReplicasClamped,
+	pixiuv1alpha1.PodSetPodsFailed,
+	pixiuv1alpha1.PodSetPodsCrashLooping,
+	pixiuv1alpha1.PodSetReplicaFailure,
+	pixiuv1alpha1.PodSetInvalidSpec,
+	pixiuv1alpha1.PodSetInvalidPriorityClass,
+	pixiuv1alpha1.PodSetInvalidClass,
+)
+
+// NewPodSetCondition creates a new podset condition.
+func NewPodSetCondition(condType string, status corev1.ConditionStatus, reason, msg string) pixiuv1alpha1.PodSetCondition {
+	now := metav1.Now()
+	return pixiuv1alpha1.PodSetCondition{
+		Type:               condType,
+		Status:             status,
+		LastUpdateTime:     now,
+		LastTransitionTime: now,
+		Reason:             reason,
+		Message:            msg,
+	}
+}
+
+// GetPodSetCondition returns a podset condition with the provided type if it exists.
+func GetPodSetCondition(status pixiuv1alpha1.PodSetStatus, condType string) *pixiuv1alpha1.PodSetCondition {
+	for i := range status.Conditions {
+		c := status.Conditions[i]
+		if c.Type == condType {
+			return &c
+		}
+	}
+	return nil
+}
+
+// SetPodSetCondition adds/replaces the given condition in the podset status. If the condition that we
+// are about to add already exists and has the same status, reason and message then we are not going to update.
+func SetPodSetCondition(status *pixiuv1alpha1.PodSetStatus, condition pixiuv1alpha1.PodSetCondition) {
+	status.Conditions = setCondition(status.Conditions, condition)
+}
+
+// setCondition adds/replaces the given condition in the list, keeping the
+// lastTransitionTime when the status of the condition doesn't change.
+func setCondition(conditions []pixiuv1alpha1.PodSetCondition, condition pixiuv1alpha1.PodSetCondition) []pixiuv1alpha1.PodSetCondition {
+	var currentCond *pixiuv1alpha1.PodSetCondition
+	for i := range conditions {
+		if conditions[i].Type == condition.Type {
+			currentCond = &conditions[i]
+			break
+		}
+	}
+	if currentCond != nil && currentCond.Status == condition.Status && currentCond.Reason == condition.Reason && currentCond.Message == condition.Message {
+		return conditions
+	}
+	// Do not update lastTransitionTime if the status of the condition doesn't change.
+	if currentCond != nil && currentCond.Status == condition.Status {
+		condition.LastTransitionTime = currentCond.LastTransitionTime
+	}
+	newConditions := filterOutCondition(conditions, condition.Type)
+	return append(newConditions, condition)
+}
+
+// RemovePodSetCondition removes the podset condition with the provided type.
+func RemovePodSetCondition(status *pixiuv1alpha1.PodSetStatus, condType string) {
+	status.Conditions = filterOutCondition(status.Conditions, condType)
+}
+
+// filterOutCondition returns a new slice of podset conditions without conditions with the provided type.
+func filterOutCondition(conditions []pixiuv1alpha1.PodSetCondition, condType string) []pixiuv1alpha1.PodSetCondition {
+	var newConditions []pixiuv1alpha1.PodSetCondition
+	for _, c := range conditions {
+		if c.Type == condType {
+			continue
+		}
+		newConditions = append(newConditions, c)
+	}
+	return newConditions
+}
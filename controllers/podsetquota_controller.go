@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+// PodSetQuotaReconciler reports the usage of the PodSets of a namespace in
+// the status of its PodSetQuotas. The quotas are enforced by the PodSet
+// controller.
+type PodSetQuotaReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	Log    logr.Logger
+}
+
+//+kubebuilder:rbac:groups=pixiu.pixiu.io,resources=podsetquotas,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=pixiu.pixiu.io,resources=podsetquotas/status,verbs=get;update;patch
+
+var _ reconcile.Reconciler = &PodSetQuotaReconciler{}
+
+func (r *PodSetQuotaReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+
+	quota := &pixiuv1alpha1.PodSetQuota{}
+	if err := r.Get(ctx, req.NamespacedName, quota); err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, fmt.Errorf("failed to get pod set quota: %v", err)
+	}
+
+	podSets := &pixiuv1alpha1.PodSetList{}
+	if err := r.List(ctx, podSets, client.InNamespace(req.Namespace)); err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to list pod sets: %v", err)
+	}
+	usedReplicas, used := quotaUsage(podSets.Items, "")
+
+	newStatus := pixiuv1alpha1.PodSetQuotaStatus{
+		ObservedGeneration: quota.Generation,
+		UsedReplicas:       usedReplicas,
+	}
+	for name := range quota.Spec.Hard {
+		if newStatus.Used == nil {
+			newStatus.Used = corev1.ResourceList{}
+		}
+		q := used[quotaResourceName(name)]
+		newStatus.Used[name] = q.DeepCopy()
+	}
+
+	// Quantities decoded from the API server cache their string form, compare them semantically.
+	if apiequality.Semantic.DeepEqual(quota.Status, newStatus) {
+		return reconcile.Result{}, nil
+	}
+	quota = quota.DeepCopy()
+	quota.Status = newStatus
+	if err := r.Status().Update(ctx, quota); err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to update pod set quota status: %v", err)
+	}
+	return reconcile.Result{}, nil
+}
+
+// mapPodSetToQuotas enqueues the quotas of the namespace of a PodSet.
+func (r *PodSetQuotaReconciler) mapPodSetToQuotas(obj client.Object) (requests []reconcile.Request) {
+	quotas := &pixiuv1alpha1.PodSetQuotaList{}
+	if err := r.List(context.TODO(), quotas, client.InNamespace(obj.GetNamespace())); err != nil {
+		return
+	}
+	for _, quota := range quotas.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: quota.Namespace, Name: quota.Name},
+		})
+	}
+	return
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PodSetQuotaReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&pixiuv1alpha1.PodSetQuota{}).
+		Watches(&source.Kind{Type: &pixiuv1alpha1.PodSet{}}, handler.EnqueueRequestsFromMapFunc(r.mapPodSetToQuotas)).
+		Complete(recoverPanics("podsetquota", r.Log, r))
+}
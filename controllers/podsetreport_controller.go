@@ -0,0 +1,189 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+// blockingConditions keep a podset from scaling up to its desired replicas.
+var blockingConditions = []string{
+	pixiuv1alpha1.PodSetCreateCircuitOpen,
+	pixiuv1alpha1.PodSetQuotaDenied,
+	pixiuv1alpha1.PodSetUnschedulable,
+	pixiuv1alpha1.PodSetTemplateInvalid,
+	pixiuv1alpha1.PodSetImageResolutionFailed,
+	pixiuv1alpha1.PodSetImageVerificationFailed,
+}
+
+type podSetHealth int
+
+const (
+	podSetHealthy podSetHealth = iota
+	podSetProgressing
+	podSetDegraded
+	podSetStalled
+)
+
+// PodSetReportReconciler maintains the PodSetReport of every namespace with
+// PodSets, and deletes it once the last PodSet of the namespace is gone.
+type PodSetReportReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	Log    logr.Logger
+}
+
+//+kubebuilder:rbac:groups=pixiu.pixiu.io,resources=podsetreports,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=pixiu.pixiu.io,resources=podsetreports/status,verbs=get;update;patch
+
+var _ reconcile.Reconciler = &PodSetReportReconciler{}
+
+func (r *PodSetReportReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	if req.Name != pixiuv1alpha1.PodSetReportName {
+		return reconcile.Result{}, nil
+	}
+
+	podSets := &pixiuv1alpha1.PodSetList{}
+	if err := r.List(ctx, podSets, client.InNamespace(req.Namespace)); err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to list pod sets: %v", err)
+	}
+
+	report := &pixiuv1alpha1.PodSetReport{}
+	err := r.Get(ctx, req.NamespacedName, report)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return reconcile.Result{}, fmt.Errorf("failed to get pod set report: %v", err)
+	}
+	exists := err == nil
+
+	if len(podSets.Items) == 0 {
+		if exists {
+			if err = r.Delete(ctx, report); err != nil && !apierrors.IsNotFound(err) {
+				return reconcile.Result{}, fmt.Errorf("failed to delete pod set report: %v", err)
+			}
+		}
+		return reconcile.Result{}, nil
+	}
+
+	if !exists {
+		report = &pixiuv1alpha1.PodSetReport{}
+		report.Namespace, report.Name = req.Namespace, req.Name
+		if err = r.Create(ctx, report); err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to create pod set report: %v", err)
+		}
+	}
+
+	newStatus := summarizePodSets(podSets.Items)
+	if apiequality.Semantic.DeepEqual(report.Status, newStatus) {
+		return reconcile.Result{}, nil
+	}
+	report = report.DeepCopy()
+	report.Status = newStatus
+	if err = r.Status().Update(ctx, report); err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to update pod set report status: %v", err)
+	}
+	return reconcile.Result{}, nil
+}
+
+// summarizePodSets returns the report of the podSets.
+func summarizePodSets(podSets []pixiuv1alpha1.PodSet) pixiuv1alpha1.PodSetReportStatus {
+	// Keep the names sorted, they end up in the status.
+	sort.Slice(podSets, func(i, j int) bool { return podSets[i].Name < podSets[j].Name })
+
+	status := pixiuv1alpha1.PodSetReportStatus{PodSets: int32(len(podSets))}
+	for i := range podSets {
+		podSet := &podSets[i]
+		status.Replicas += podSet.Status.Replicas
+		status.DesiredReplicas += podSet.Status.DesiredReplicas
+		status.ReadyReplicas += podSet.Status.ReadyReplicas
+		status.AvailableReplicas += podSet.Status.AvailableReplicas
+
+		switch healthOf(podSet) {
+		case podSetHealthy:
+			status.Healthy++
+		case podSetProgressing:
+			status.Progressing++
+			status.ProgressingPodSets = appendReported(status.ProgressingPodSets, podSet.Name)
+		case podSetDegraded:
+			status.Degraded++
+			status.DegradedPodSets = appendReported(status.DegradedPodSets, podSet.Name)
+		case podSetStalled:
+			status.Stalled++
+			status.DegradedPodSets = appendReported(status.DegradedPodSets, podSet.Name)
+		}
+	}
+	return status
+}
+
+func appendReported(names []string, name string) []string {
+	if len(names) >= pixiuv1alpha1.MaxReportedPodSets {
+		return names
+	}
+	return append(names, name)
+}
+
+// healthOf classifies a podSet from its status. A podSet whose pods are all
+// there but not ready, or that is kept from scaling up, is degraded rather
+// than progressing.
+func healthOf(podSet *pixiuv1alpha1.PodSet) podSetHealth {
+	status := podSet.Status
+	if cond := GetPodSetCondition(status, pixiuv1alpha1.PodSetStalled); cond != nil && cond.Status == corev1.ConditionTrue {
+		return podSetStalled
+	}
+	for _, condType := range blockingConditions {
+		if cond := GetPodSetCondition(status, condType); cond != nil && cond.Status == corev1.ConditionTrue {
+			return podSetDegraded
+		}
+	}
+	if status.ObservedGeneration < podSet.Generation || status.Replicas != status.DesiredReplicas {
+		return podSetProgressing
+	}
+	if status.ReadyReplicas < status.DesiredReplicas {
+		return podSetDegraded
+	}
+	return podSetHealthy
+}
+
+// mapPodSetToReport enqueues the report of the namespace of a PodSet.
+func (r *PodSetReportReconciler) mapPodSetToReport(obj client.Object) []reconcile.Request {
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: pixiuv1alpha1.PodSetReportName},
+	}}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PodSetReportReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&pixiuv1alpha1.PodSetReport{}).
+		Watches(&source.Kind{Type: &pixiuv1alpha1.PodSet{}}, handler.EnqueueRequestsFromMapFunc(r.mapPodSetToReport)).
+		Complete(recoverPanics("podsetreport", r.Log, r))
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// podUpdatePredicate drops the pod updates that can't change what the
+// controller does, the periodic resyncs and most status updates, e.g. of the
+// container images or the probe results that don't flip a condition.
+func podUpdatePredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.ObjectOld.GetResourceVersion() == e.ObjectNew.GetResourceVersion() {
+				return false
+			}
+			oldPod, ok := e.ObjectOld.(*corev1.Pod)
+			if !ok {
+				// Only the metadata of the pod is cached, its status is unknown.
+				return true
+			}
+			newPod, ok := e.ObjectNew.(*corev1.Pod)
+			if !ok {
+				return true
+			}
+			return podChanged(oldPod, newPod)
+		},
+	}
+}
+
+// podChanged reports whether the update of the pod is meaningful to its
+// PodSet: a change of its phase, conditions, node, container restarts or
+// deletion, or of the labels, annotations and owner references it is claimed,
+// ranked and indexed with.
+func podChanged(oldPod, newPod *corev1.Pod) bool {
+	if (oldPod.DeletionTimestamp == nil) != (newPod.DeletionTimestamp == nil) ||
+		oldPod.Status.Phase != newPod.Status.Phase ||
+		oldPod.Spec.NodeName != newPod.Spec.NodeName ||
+		maxContainerRestarts(oldPod) != maxContainerRestarts(newPod) {
+		return true
+	}
+	if !apiequality.Semantic.DeepEqual(oldPod.Labels, newPod.Labels) ||
+		!apiequality.Semantic.DeepEqual(oldPod.Annotations, newPod.Annotations) ||
+		!apiequality.Semantic.DeepEqual(oldPod.OwnerReferences, newPod.OwnerReferences) {
+		return true
+	}
+	return !apiequality.Semantic.DeepEqual(podConditionStatuses(oldPod), podConditionStatuses(newPod))
+}
+
+// podConditionStatuses returns the status of the conditions of the pod by
+// type, without the probe and transition times.
+func podConditionStatuses(pod *corev1.Pod) map[corev1.PodConditionType]corev1.ConditionStatus {
+	statuses := make(map[corev1.PodConditionType]corev1.ConditionStatus, len(pod.Status.Conditions))
+	for _, cond := range pod.Status.Conditions {
+		statuses[cond.Type] = cond.Status
+	}
+	return statuses
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/types"
+	"github.com/caoyingjunz/podset-operator/pkg/util"
+)
+
+const (
+	defaultPrePullTimeout = 5 * time.Minute
+	// prePullPollInterval is how often the progress of a pre-pull is checked.
+	prePullPollInterval = 5 * time.Second
+	// prePullCommand doesn't exist in the images: the container fails to start
+	// right after its image is pulled, whatever the entrypoint of the image.
+	prePullCommand = "/podset-prepull-noop"
+)
+
+// prePulled reports whether the images of the template with the revision hash
+// are pre-pulled, or don't need to be.
+func prePulled(podSet *pixiuv1alpha1.PodSet, revisionHash string) bool {
+	if podSet.Spec.PrePull == nil {
+		return true
+	}
+	current := podSet.Status.PrePull
+	return current != nil && current.RevisionHash == revisionHash && current.Completed
+}
+
+// syncPrePull pulls the images of the podSet template on the nodes running
+// its pods when the template changes, with one short-lived pod per node. It
+// returns the progress to record in status and when to check it again.
+func (r *PodSetReconciler) syncPrePull(ctx context.Context, podSet *pixiuv1alpha1.PodSet, pods []*corev1.Pod) (*pixiuv1alpha1.PrePullStatus, time.Duration, error) {
+	current := podSet.Status.PrePull
+	if podSet.Spec.PrePull == nil {
+		if current != nil && !current.Completed {
+			return nil, 0, r.deletePrePullPods(ctx, podSet)
+		}
+		return nil, 0, nil
+	}
+	hash, err := util.ComputeTemplateHash(&podSet.Spec.Template)
+	if err != nil {
+		return current, 0, err
+	}
+
+	if current == nil || current.RevisionHash != hash {
+		// The pods run the images of the template the pre-pull is enabled with.
+		if current == nil {
+			return &pixiuv1alpha1.PrePullStatus{RevisionHash: hash, StartTime: metav1.Now(), Completed: true}, 0, nil
+		}
+		if err = r.deletePrePullPods(ctx, podSet); err != nil {
+			return current, 0, err
+		}
+		nodes := sets.NewString()
+		for _, pod := range pods {
+			if len(pod.Spec.NodeName) != 0 {
+				nodes.Insert(pod.Spec.NodeName)
+			}
+		}
+		r.Log.Info("Pre-pulling the images of a new template", "podSet", klog.KObj(podSet), "revision", hash, "nodes", nodes.Len())
+		for _, node := range nodes.List() {
+			if err = r.Create(ctx, newPrePullPod(podSet, hash, node)); err != nil {
+				return current, 0, fmt.Errorf("failed to create pre-pull pod on node %s: %v", node, err)
+			}
+		}
+		return &pixiuv1alpha1.PrePullStatus{
+			RevisionHash: hash,
+			StartTime:    metav1.Now(),
+			Nodes:        int32(nodes.Len()),
+			Completed:    nodes.Len() == 0,
+		}, prePullPollInterval, nil
+	}
+	if current.Completed {
+		return current, 0, nil
+	}
+
+	prePullPods := &corev1.PodList{}
+	if err = r.listPods(ctx, prePullPods, client.InNamespace(podSet.Namespace),
+		client.MatchingLabels{types.PrePullLabel: podSet.Name, types.PrePullRevisionLabel: hash}); err != nil {
+		return current, 0, err
+	}
+	status := current.DeepCopy()
+	status.PulledNodes = 0
+	for i := range prePullPods.Items {
+		if imagesPulled(&prePullPods.Items[i]) {
+			status.PulledNodes++
+		}
+	}
+
+	timeout := defaultPrePullTimeout
+	if podSet.Spec.PrePull.Timeout != nil {
+		timeout = podSet.Spec.PrePull.Timeout.Duration
+	}
+	expired := time.Since(status.StartTime.Time) >= timeout
+	if status.PulledNodes < status.Nodes && !expired {
+		return status, prePullPollInterval, nil
+	}
+
+	if expired && status.PulledNodes < status.Nodes {
+		r.Log.Info("Pre-pull timed out", "podSet", klog.KObj(podSet), "revision", hash, "pulled", status.PulledNodes, "nodes", status.Nodes)
+	}
+	status.Completed = true
+	return status, 0, r.deletePrePullPods(ctx, podSet)
+}
+
+// newPrePullPod returns a pod pulling the images of the template on the node.
+// Its labels don't match the selector of the podSet, so it isn't a replica.
+func newPrePullPod(podSet *pixiuv1alpha1.PodSet, hash, node string) *corev1.Pod {
+	podSpec := &podSet.Spec.Template.Spec
+	images := sets.NewString()
+	for _, containers := range [][]corev1.Container{podSpec.InitContainers, podSpec.Containers} {
+		for _, c := range containers {
+			images.Insert(c.Image)
+		}
+	}
+
+	var gracePeriod int64
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: fmt.Sprintf("%s-prepull-", podSet.Name),
+			Namespace:    podSet.Namespace,
+			Labels: map[string]string{
+				types.PrePullLabel:         podSet.Name,
+				types.PrePullRevisionLabel: hash,
+			},
+			OwnerReferences: []metav1.OwnerReference{*podSetOwnerReference(podSet)},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:                      node,
+			RestartPolicy:                 corev1.RestartPolicyNever,
+			ImagePullSecrets:              podSpec.ImagePullSecrets,
+			TerminationGracePeriodSeconds: &gracePeriod,
+			// The node is chosen already, its taints must not keep the pod away.
+			Tolerations: []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+		},
+	}
+	for i, image := range images.List() {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{
+			Name:            fmt.Sprintf("prepull-%d", i),
+			Image:           image,
+			Command:         []string{prePullCommand},
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("1m"),
+					corev1.ResourceMemory: resource.MustParse("4Mi"),
+				},
+			},
+		})
+	}
+	return pod
+}
+
+// imagesPulled reports whether the images of all the containers of the pod
+// are on its node: a container past the pull is running or terminated, or
+// waiting for another reason than the pull.
+func imagesPulled(pod *corev1.Pod) bool {
+	if len(pod.Status.ContainerStatuses) < len(pod.Spec.Containers) {
+		return false
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Waiting == nil {
+			continue
+		}
+		switch status.State.Waiting.Reason {
+		case "", "ContainerCreating", "ErrImagePull", "ImagePullBackOff":
+			return false
+		}
+	}
+	return true
+}
+
+// deletePrePullPods deletes the pre-pull pods of every revision of the podSet.
+func (r *PodSetReconciler) deletePrePullPods(ctx context.Context, podSet *pixiuv1alpha1.PodSet) error {
+	pods := &corev1.PodList{}
+	if err := r.listPods(ctx, pods, client.InNamespace(podSet.Namespace), client.MatchingLabels{types.PrePullLabel: podSet.Name}); err != nil {
+		return err
+	}
+	for i := range pods.Items {
+		if err := r.Delete(ctx, &pods.Items[i]); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+// DefaultProgressDeadlineSeconds is the progress deadline of a podSet when
+// spec.progressDeadlineSeconds isn't set, as for Deployments.
+const DefaultProgressDeadlineSeconds = 600
+
+// The reasons of the Progressing condition.
+const (
+	progressingReason      = "ReplicasProgressing"
+	availableReason        = "ReplicasAvailable"
+	deadlineExceededReason = "ProgressDeadlineExceeded"
+)
+
+// The reasons of the Available condition.
+const (
+	minimumReplicasAvailable   = "MinimumReplicasAvailable"
+	minimumReplicasUnavailable = "MinimumReplicasUnavailable"
+)
+
+// setAvailableCondition sets the Available condition of the podSet, true
+// while at least desired-maxUnavailable replicas are available for
+// minReadySeconds.
+func setAvailableCondition(podSet *pixiuv1alpha1.PodSet, newStatus *pixiuv1alpha1.PodSetStatus) {
+	desired := newStatus.DesiredReplicas
+	_, maxUnavailable, err := rollingUpdateBounds(podSet.Spec.UpdateStrategy, desired)
+	if err != nil {
+		maxUnavailable = 0
+	}
+	minAvailable := desired - int32(maxUnavailable)
+	if newStatus.AvailableReplicas >= minAvailable {
+		SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetAvailable, corev1.ConditionTrue, minimumReplicasAvailable,
+			"PodSet has minimum availability"))
+		return
+	}
+	SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetAvailable, corev1.ConditionFalse, minimumReplicasUnavailable,
+		fmt.Sprintf("%d of %d replicas are available, at least %d are required", newStatus.AvailableReplicas, desired, minAvailable)))
+}
+
+// setProgressingCondition sets the Progressing condition of the podSet. The
+// deadline restarts whenever the replicas progress or the spec changes, and
+// a podSet whose deadline expired is Stalled until it progresses again. It
+// returns when the deadline expires.
+func (r *PodSetReconciler) setProgressingCondition(podSet *pixiuv1alpha1.PodSet, newStatus *pixiuv1alpha1.PodSetStatus, now time.Time) time.Duration {
+	if podSet.Spec.Paused {
+		SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetProgressing, corev1.ConditionUnknown, "Paused", "The progress deadline isn't checked while spec.paused is set"))
+		return 0
+	}
+
+	desired := newStatus.DesiredReplicas
+	if newStatus.UpdatedReplicas >= desired && newStatus.Replicas == desired && newStatus.AvailableReplicas >= desired {
+		SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetProgressing, corev1.ConditionTrue, availableReason,
+			fmt.Sprintf("All %d replicas are updated and available", desired)))
+		return 0
+	}
+
+	deadline := time.Duration(DefaultProgressDeadlineSeconds) * time.Second
+	if podSet.Spec.ProgressDeadlineSeconds != nil {
+		deadline = time.Duration(*podSet.Spec.ProgressDeadlineSeconds) * time.Second
+	}
+	current := GetPodSetCondition(*newStatus, pixiuv1alpha1.PodSetProgressing)
+	switch {
+	case current == nil || current.Reason == availableReason || current.Status == corev1.ConditionUnknown ||
+		podSet.Generation != podSet.Status.ObservedGeneration || progressed(&podSet.Status, newStatus):
+		condition := NewPodSetCondition(pixiuv1alpha1.PodSetProgressing, corev1.ConditionTrue, progressingReason,
+			fmt.Sprintf("%d of %d replicas are updated, %d are available", newStatus.UpdatedReplicas, desired, newStatus.AvailableReplicas))
+		if current != nil && current.Status == corev1.ConditionTrue {
+			condition.LastTransitionTime = current.LastTransitionTime
+		}
+		// Replace the condition even when unchanged, its lastUpdateTime is
+		// when the deadline restarted.
+		RemovePodSetCondition(newStatus, pixiuv1alpha1.PodSetProgressing)
+		SetPodSetCondition(newStatus, condition)
+		return deadline
+	case current.Reason == deadlineExceededReason:
+	default:
+		if remaining := current.LastUpdateTime.Add(deadline).Sub(now); remaining > 0 {
+			return remaining
+		}
+		msg := fmt.Sprintf("No progress for %v: %s", deadline, current.Message)
+		SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetProgressing, corev1.ConditionFalse, deadlineExceededReason, msg))
+		r.Recorder.Event(podSet, corev1.EventTypeWarning, deadlineExceededReason, msg)
+	}
+	SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetStalled, corev1.ConditionTrue, deadlineExceededReason,
+		GetPodSetCondition(*newStatus, pixiuv1alpha1.PodSetProgressing).Message))
+	return 0
+}
+
+// progressed reports whether the replicas of the podSet moved since the
+// previous status.
+func progressed(previous, current *pixiuv1alpha1.PodSetStatus) bool {
+	return previous.UpdateRevision != current.UpdateRevision ||
+		previous.Replicas != current.Replicas ||
+		previous.UpdatedReplicas != current.UpdatedReplicas ||
+		previous.ReadyReplicas != current.ReadyReplicas ||
+		previous.AvailableReplicas != current.AvailableReplicas
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/inventory"
+	pixiutypes "github.com/caoyingjunz/podset-operator/pkg/types"
+)
+
+// applyImagePullSecrets returns the podSet with spec.imagePullSecrets added to
+// the image pull secrets of its template. Like applyPodSetClass, it returns
+// the podSet itself when there is nothing to add.
+func applyImagePullSecrets(podSet *pixiuv1alpha1.PodSet) *pixiuv1alpha1.PodSet {
+	if len(podSet.Spec.ImagePullSecrets) == 0 {
+		return podSet
+	}
+
+	effective := podSet.DeepCopy()
+	podSpec := &effective.Spec.Template.Spec
+	for _, secret := range podSet.Spec.ImagePullSecrets {
+		name := imagePullSecretName(podSet, secret)
+		found := false
+		for _, ref := range podSpec.ImagePullSecrets {
+			if ref.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			podSpec.ImagePullSecrets = append(podSpec.ImagePullSecrets, corev1.LocalObjectReference{Name: name})
+		}
+	}
+	return effective
+}
+
+// imagePullSecretName returns the name the pods reference the secret with.
+func imagePullSecretName(podSet *pixiuv1alpha1.PodSet, secret pixiuv1alpha1.ImagePullSecret) string {
+	if len(secret.SourceNamespace) == 0 {
+		return secret.Name
+	}
+	return fmt.Sprintf("%s-%s", podSet.Name, secret.Name)
+}
+
+// copiedImagePullSecrets returns the sources of the image pull secrets copied
+// into the namespace of the podSet, by the name of their copy.
+func copiedImagePullSecrets(podSet *pixiuv1alpha1.PodSet) map[string]types.NamespacedName {
+	sources := map[string]types.NamespacedName{}
+	for _, secret := range podSet.Spec.ImagePullSecrets {
+		if len(secret.SourceNamespace) != 0 {
+			sources[imagePullSecretName(podSet, secret)] = types.NamespacedName{Namespace: secret.SourceNamespace, Name: secret.Name}
+		}
+	}
+	return sources
+}
+
+// applyImagePullSecretCopies declares the copies of the image pull secrets
+// taken from other namespaces.
+func (r *PodSetReconciler) applyImagePullSecretCopies(ctx context.Context, podSet *pixiuv1alpha1.PodSet, tracker *inventory.Tracker) error {
+	for name, source := range copiedImagePullSecrets(podSet) {
+		secret, err := r.sourceImagePullSecret(ctx, podSet.Namespace, source)
+		if err != nil {
+			return err
+		}
+		copied := &corev1.Secret{}
+		copied.Name = name
+		if err = tracker.Apply(ctx, copied, func() error {
+			copied.Type = secret.Type
+			copied.Data = secret.Data
+			return nil
+		}); err != nil {
+			return fmt.Errorf("failed to copy image pull secret %s: %v", source, err)
+		}
+	}
+	return nil
+}
+
+// sourceImagePullSecret reads an image pull secret of another namespace. The
+// secret must be shared with the namespace, otherwise any PodSet author could
+// read the registry credentials of every namespace.
+func (r *PodSetReconciler) sourceImagePullSecret(ctx context.Context, namespace string, source types.NamespacedName) (*corev1.Secret, error) {
+	var reader client.Reader = r.Client
+	if r.APIReader != nil {
+		reader = r.APIReader
+	}
+	secret := &corev1.Secret{}
+	if err := reader.Get(ctx, source, secret); err != nil {
+		return nil, fmt.Errorf("failed to get image pull secret %s: %v", source, err)
+	}
+	if secret.Type != corev1.SecretTypeDockerConfigJson && secret.Type != corev1.SecretTypeDockercfg {
+		return nil, fmt.Errorf("secret %s of type %s isn't an image pull secret", source, secret.Type)
+	}
+	if !sharedWith(secret.Annotations[pixiutypes.ShareToNamespacesAnnotation], namespace) {
+		return nil, fmt.Errorf("image pull secret %s isn't shared with namespace %s, see the %s annotation", source, namespace, pixiutypes.ShareToNamespacesAnnotation)
+	}
+	return secret, nil
+}
+
+func sharedWith(namespaces, namespace string) bool {
+	for _, ns := range strings.Split(namespaces, ",") {
+		if ns = strings.TrimSpace(ns); ns == "*" || ns == namespace {
+			return true
+		}
+	}
+	return false
+}
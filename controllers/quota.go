@@ -0,0 +1,186 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+// quotaRetryInterval is how often a podset denied by a quota checks whether
+// the quota allows it to scale up again.
+const quotaRetryInterval = 30 * time.Second
+
+// quotaResourceName maps a resource of a quota to the pod request it limits:
+// requests.cpu and cpu both limit the requested cpu.
+func quotaResourceName(name corev1.ResourceName) corev1.ResourceName {
+	return corev1.ResourceName(strings.TrimPrefix(string(name), "requests."))
+}
+
+// podRequests returns the resources requested by a single pod of the template.
+func podRequests(template *corev1.PodTemplateSpec) corev1.ResourceList {
+	return podResources(template, func(r corev1.ResourceRequirements) corev1.ResourceList { return r.Requests })
+}
+
+// podResources sums the resources of the containers of the template. Init
+// containers run one at a time, so only the largest of them counts.
+func podResources(template *corev1.PodTemplateSpec, resources func(corev1.ResourceRequirements) corev1.ResourceList) corev1.ResourceList {
+	total := corev1.ResourceList{}
+	for _, c := range template.Spec.Containers {
+		for name, q := range resources(c.Resources) {
+			sum := total[name]
+			sum.Add(q)
+			total[name] = sum
+		}
+	}
+	for _, c := range template.Spec.InitContainers {
+		for name, q := range resources(c.Resources) {
+			if current, ok := total[name]; !ok || q.Cmp(current) > 0 {
+				total[name] = q.DeepCopy()
+			}
+		}
+	}
+	return total
+}
+
+// quotaUsage returns the replicas and requested resources of the podSets,
+// skipping the one with the exclude UID.
+func quotaUsage(podSets []pixiuv1alpha1.PodSet, exclude types.UID) (int32, corev1.ResourceList) {
+	var replicas int32
+	used := corev1.ResourceList{}
+	for i := range podSets {
+		podSet := &podSets[i]
+		if podSet.UID == exclude || podSet.Status.Replicas == 0 {
+			continue
+		}
+		replicas += podSet.Status.Replicas
+		for name, q := range podRequests(&podSet.Spec.Template) {
+			total := used[name]
+			total.Add(*resource.NewMilliQuantity(q.MilliValue()*int64(podSet.Status.Replicas), q.Format))
+			used[name] = total
+		}
+	}
+	return replicas, used
+}
+
+// applyQuota caps the desired replicas of the podSet to what the PodSetQuotas
+// of its namespace allow on top of the other PodSets, and explains why when
+// they are capped. Quotas only refuse scaling up, current pods are kept.
+func (r *PodSetReconciler) applyQuota(ctx context.Context, podSet *pixiuv1alpha1.PodSet, current, desired int32) (int32, string, error) {
+	quotas := &pixiuv1alpha1.PodSetQuotaList{}
+	if err := r.List(ctx, quotas, client.InNamespace(podSet.Namespace)); err != nil {
+		return desired, "", err
+	}
+	if len(quotas.Items) == 0 {
+		return desired, "", nil
+	}
+
+	podSets := &pixiuv1alpha1.PodSetList{}
+	if err := r.List(ctx, podSets, client.InNamespace(podSet.Namespace)); err != nil {
+		return desired, "", err
+	}
+	usedReplicas, used := quotaUsage(podSets.Items, podSet.UID)
+	perPod := podRequests(&podSet.Spec.Template)
+
+	// Keep the order of the reasons stable, they end up in the status.
+	sort.Slice(quotas.Items, func(i, j int) bool { return quotas.Items[i].Name < quotas.Items[j].Name })
+
+	allowed := desired
+	var reasons []string
+	for _, quota := range quotas.Items {
+		if max := quota.Spec.MaxReplicas; max != nil {
+			if limit := *max - usedReplicas; allowed > limit {
+				allowed = limit
+				reasons = append(reasons, fmt.Sprintf("PodSetQuota %s allows %d of %d replicas in the namespace", quota.Name, limit, *max))
+			}
+		}
+		names := make([]string, 0, len(quota.Spec.Hard))
+		for name := range quota.Spec.Hard {
+			names = append(names, string(name))
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			name := corev1.ResourceName(n)
+			hard := quota.Spec.Hard[name]
+			request, ok := perPod[quotaResourceName(name)]
+			if !ok || request.IsZero() {
+				continue
+			}
+			remaining := hard.DeepCopy()
+			remaining.Sub(used[quotaResourceName(name)])
+			limit := int32(0)
+			if remaining.Sign() > 0 {
+				limit = int32(remaining.MilliValue() / request.MilliValue())
+			}
+			if allowed > limit {
+				allowed = limit
+				reasons = append(reasons, fmt.Sprintf("PodSetQuota %s allows %d replicas requesting %s %s, %s of %s remaining",
+					quota.Name, limit, request.String(), name, remaining.String(), hard.String()))
+			}
+		}
+	}
+	if allowed >= desired {
+		return desired, "", nil
+	}
+
+	if floor := minInt32(current, desired); allowed < floor {
+		allowed = floor
+	}
+	return allowed, strings.Join(reasons, "; "), nil
+}
+
+// setQuotaCondition reports in the podSet status whether a quota refused
+// scaling it up.
+func setQuotaCondition(newStatus *pixiuv1alpha1.PodSetStatus, msg string) {
+	if len(msg) == 0 {
+		RemovePodSetCondition(newStatus, pixiuv1alpha1.PodSetQuotaDenied)
+		return
+	}
+	SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetQuotaDenied, corev1.ConditionTrue, "QuotaExceeded", msg))
+}
+
+// mapQuotaToPodSets enqueues the PodSets of the namespace of a quota.
+func (r *PodSetReconciler) mapQuotaToPodSets(obj client.Object) (requests []reconcile.Request) {
+	podSets := &pixiuv1alpha1.PodSetList{}
+	if err := r.List(context.TODO(), podSets, client.InNamespace(obj.GetNamespace())); err != nil {
+		return
+	}
+	for _, podSet := range podSets.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: podSet.Namespace, Name: podSet.Name},
+		})
+	}
+	return
+}
+
+func minInt32(a, b int32) int32 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+func TestPodRequests(t *testing.T) {
+	template := &corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{
+				{Name: "init-1", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")}}},
+				{Name: "init-2", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")}}},
+			},
+			Containers: []corev1.Container{
+				{Name: "web", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("128Mi")}}},
+				{Name: "proxy", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("250m")}}},
+			},
+		},
+	}
+
+	requests := podRequests(template)
+	// The largest init container outweighs the containers for cpu only.
+	for name, want := range map[corev1.ResourceName]string{corev1.ResourceCPU: "2", corev1.ResourceMemory: "128Mi"} {
+		if got := requests[name]; got.Cmp(resource.MustParse(want)) != 0 {
+			t.Errorf("podRequests() %s = %s, want %s", name, got.String(), want)
+		}
+	}
+}
+
+func TestApplyQuota(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = pixiuv1alpha1.AddToScheme(scheme)
+
+	newPodSet := func(name string, cpu string, replicas int32) *pixiuv1alpha1.PodSet {
+		return &pixiuv1alpha1.PodSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, UID: "uid-" + types.UID(name)},
+			Spec: pixiuv1alpha1.PodSetSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{
+							Name:      name,
+							Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu)}},
+						}},
+					},
+				},
+			},
+			Status: pixiuv1alpha1.PodSetStatus{Replicas: replicas},
+		}
+	}
+	newQuota := func(name string, spec pixiuv1alpha1.PodSetQuotaSpec) *pixiuv1alpha1.PodSetQuota {
+		return &pixiuv1alpha1.PodSetQuota{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}, Spec: spec}
+	}
+
+	tests := []struct {
+		name            string
+		quotas          []client.Object
+		current         int32
+		desired         int32
+		wantReplicas    int32
+		wantExplanation string
+	}{
+		{
+			name:         "no quota",
+			current:      1,
+			desired:      5,
+			wantReplicas: 5,
+		},
+		{
+			name:         "within the quota",
+			quotas:       []client.Object{newQuota("replicas", pixiuv1alpha1.PodSetQuotaSpec{MaxReplicas: pointer.Int32(10)})},
+			current:      1,
+			desired:      5,
+			wantReplicas: 5,
+		},
+		{
+			name:            "replicas capped",
+			quotas:          []client.Object{newQuota("replicas", pixiuv1alpha1.PodSetQuotaSpec{MaxReplicas: pointer.Int32(6)})},
+			current:         1,
+			desired:         5,
+			wantReplicas:    4,
+			wantExplanation: "PodSetQuota replicas allows 4 of 6 replicas in the namespace",
+		},
+		{
+			name: "requests capped",
+			quotas: []client.Object{newQuota("cpu", pixiuv1alpha1.PodSetQuotaSpec{
+				Hard: corev1.ResourceList{"requests.cpu": resource.MustParse("4")},
+			})},
+			current:         1,
+			desired:         5,
+			wantReplicas:    4,
+			wantExplanation: "PodSetQuota cpu allows 4 replicas requesting 500m requests.cpu, 2 of 4 remaining",
+		},
+		{
+			name: "lowest of the quotas",
+			quotas: []client.Object{
+				newQuota("replicas", pixiuv1alpha1.PodSetQuotaSpec{MaxReplicas: pointer.Int32(6)}),
+				newQuota("cpu", pixiuv1alpha1.PodSetQuotaSpec{Hard: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("3500m")}}),
+			},
+			current:         1,
+			desired:         5,
+			wantReplicas:    3,
+			wantExplanation: "PodSetQuota cpu allows 3 replicas requesting 500m cpu, 1500m of 3500m remaining",
+		},
+		{
+			name:            "current pods kept",
+			quotas:          []client.Object{newQuota("replicas", pixiuv1alpha1.PodSetQuotaSpec{MaxReplicas: pointer.Int32(3)})},
+			current:         2,
+			desired:         5,
+			wantReplicas:    2,
+			wantExplanation: "PodSetQuota replicas allows 1 of 3 replicas in the namespace",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			podSet := newPodSet("web", "500m", tt.current)
+			// The other PodSet of the namespace uses 2 replicas of 1 cpu.
+			objs := append([]client.Object{podSet, newPodSet("api", "1", 2)}, tt.quotas...)
+			r := &PodSetReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()}
+
+			replicas, explanation, err := r.applyQuota(context.TODO(), podSet, tt.current, tt.desired)
+			if err != nil {
+				t.Fatalf("applyQuota() error = %v", err)
+			}
+			if replicas != tt.wantReplicas || explanation != tt.wantExplanation {
+				t.Errorf("applyQuota() = %d, %q, want %d, %q", replicas, explanation, tt.wantReplicas, tt.wantExplanation)
+			}
+		})
+	}
+}
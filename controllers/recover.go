@@ -0,0 +1,47 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/go-logr/logr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/caoyingjunz/podset-operator/pkg/metrics"
+)
+
+// recoverPanics wraps a reconciler so that a panic, e.g. on a malformed
+// object, fails the reconcile of that object only. The panic is logged with
+// its stack and counted, and the object is retried with the rate limited
+// backoff of the controller while the worker moves on.
+func recoverPanics(controller string, log logr.Logger, r reconcile.Reconciler) reconcile.Reconciler {
+	return reconcile.Func(func(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				metrics.ReconcilePanics.WithLabelValues(controller).Inc()
+				err = fmt.Errorf("panic: %v", p)
+				log.Error(err, "Reconcile panicked", "request", req, "stack", string(debug.Stack()))
+				result = ctrl.Result{}
+			}
+		}()
+		return r.Reconcile(ctx, req)
+	})
+}
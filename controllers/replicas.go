@@ -0,0 +1,198 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+const defaultReplicaSourceInterval = 30 * time.Second
+
+// desiredReplicas resolves the number of replicas the podSet converges to,
+// from the active schedule when there is one. It returns the replica source
+// evaluation to record in status, and when the source is due to be evaluated
+// again.
+func (r *PodSetReconciler) desiredReplicas(ctx context.Context, podSet *pixiuv1alpha1.PodSet, schedule *pixiuv1alpha1.ReplicaSchedule) (int32, *pixiuv1alpha1.ReplicaSourceStatus, time.Duration) {
+	replicas := int32(1)
+	if podSet.Spec.Replicas != nil {
+		replicas = *podSet.Spec.Replicas
+	}
+	if schedule != nil {
+		replicas = schedule.Replicas
+	}
+
+	source := podSet.Spec.ReplicaSource
+	if source == nil {
+		return replicas, nil, 0
+	}
+	if source.Prometheus != nil {
+		return r.evaluatePrometheusSource(ctx, podSet, replicas)
+	}
+	if source.ConfigMapKeyRef != nil {
+		return r.evaluateConfigMapSource(ctx, podSet, replicas)
+	}
+	return replicas, nil, 0
+}
+
+// evaluateConfigMapSource reads the replicas from the referenced ConfigMap key.
+// ConfigMaps are watched, so there is no need to evaluate it periodically.
+// The watch caches only their metadata, the referenced ConfigMap is read live.
+func (r *PodSetReconciler) evaluateConfigMapSource(ctx context.Context, podSet *pixiuv1alpha1.PodSet, fallback int32) (int32, *pixiuv1alpha1.ReplicaSourceStatus, time.Duration) {
+	ref := podSet.Spec.ReplicaSource.ConfigMapKeyRef
+	current := podSet.Status.ReplicaSource
+
+	status := &pixiuv1alpha1.ReplicaSourceStatus{Replicas: fallback}
+	if current != nil {
+		status.Replicas = current.Replicas
+		status.Value = current.Value
+	}
+
+	var reader client.Reader = r.Client
+	if r.APIReader != nil {
+		reader = r.APIReader
+	}
+	cm := &corev1.ConfigMap{}
+	err := reader.Get(ctx, types.NamespacedName{Namespace: podSet.Namespace, Name: ref.Name}, cm)
+	if err == nil {
+		value, ok := cm.Data[ref.Key]
+		if !ok {
+			err = fmt.Errorf("key %q not found in configmap %s", ref.Key, ref.Name)
+		} else {
+			var replicas int64
+			if replicas, err = strconv.ParseInt(strings.TrimSpace(value), 10, 32); err == nil && replicas < 0 {
+				err = fmt.Errorf("negative replicas %d", replicas)
+			}
+			if err == nil {
+				status.Value = value
+				status.Replicas = int32(replicas)
+			}
+		}
+	}
+	if err != nil {
+		status.Error = fmt.Sprintf("failed to read replicas from configmap %s: %v", ref.Name, err)
+	}
+
+	// Only move the evaluation time when the outcome changed, rewriting status on
+	// every reconcile would trigger yet another reconcile.
+	status.LastEvaluationTime = metav1.Now()
+	if current != nil && current.Value == status.Value && current.Replicas == status.Replicas && current.Error == status.Error {
+		status.LastEvaluationTime = current.LastEvaluationTime
+	}
+	return status.Replicas, status, 0
+}
+
+func (r *PodSetReconciler) evaluatePrometheusSource(ctx context.Context, podSet *pixiuv1alpha1.PodSet, fallback int32) (int32, *pixiuv1alpha1.ReplicaSourceStatus, time.Duration) {
+	source := podSet.Spec.ReplicaSource.Prometheus
+	interval := defaultReplicaSourceInterval
+	if source.Interval != nil && source.Interval.Duration > 0 {
+		interval = source.Interval.Duration
+	}
+
+	now := time.Now()
+	current := podSet.Status.ReplicaSource
+	if current != nil {
+		if next := current.LastEvaluationTime.Add(interval); now.Before(next) {
+			return current.Replicas, current.DeepCopy(), next.Sub(now)
+		}
+	}
+
+	status := &pixiuv1alpha1.ReplicaSourceStatus{
+		Replicas:           fallback,
+		LastEvaluationTime: metav1.NewTime(now),
+	}
+	if current != nil {
+		// Keep running the last known replicas while the source is unavailable.
+		status.Replicas = current.Replicas
+		status.Value = current.Value
+	}
+
+	address := source.Address
+	if len(address) == 0 {
+		address = r.settings().PrometheusAddress
+	}
+	if len(address) == 0 {
+		status.Error = "no prometheus address configured"
+		return status.Replicas, status, interval
+	}
+
+	value, err := r.prometheus.Query(ctx, address, source.Query)
+	if err == nil {
+		var replicas int32
+		if replicas, err = replicasFromMetric(source, value); err == nil {
+			status.Value = strconv.FormatFloat(value, 'f', -1, 64)
+			status.Replicas = replicas
+		}
+	}
+	if err != nil {
+		r.Log.Error(err, "failed to evaluate prometheus replica source", "podSet", podSet.Namespace+"/"+podSet.Name)
+		status.Error = err.Error()
+	}
+	return status.Replicas, status, interval
+}
+
+// replicasFromMetric applies the scaling function of the source to the metric
+// value and clamps the result into [minReplicas, maxReplicas].
+func replicasFromMetric(source *pixiuv1alpha1.PrometheusReplicaSource, value float64) (int32, error) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("prometheus query returned %v", value)
+	}
+
+	var replicas float64
+	switch source.ScalingFunction {
+	case pixiuv1alpha1.DirectScaling:
+		replicas = math.Ceil(value)
+	case "", pixiuv1alpha1.ProportionalScaling:
+		if source.TargetValue == nil || source.TargetValue.AsApproximateFloat64() <= 0 {
+			return 0, fmt.Errorf("proportional scaling requires a positive targetValue")
+		}
+		replicas = math.Ceil(value / source.TargetValue.AsApproximateFloat64())
+	default:
+		return 0, fmt.Errorf("unknown scaling function %q", source.ScalingFunction)
+	}
+
+	min := int32(1)
+	if source.MinReplicas != nil {
+		min = *source.MinReplicas
+	}
+	if replicas < float64(min) {
+		return min, nil
+	}
+	if replicas > float64(source.MaxReplicas) {
+		return source.MaxReplicas, nil
+	}
+	return int32(replicas), nil
+}
+
+// minRequeue returns the sooner of two requeue delays, zero meaning no requeue.
+func minRequeue(a, b time.Duration) time.Duration {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+const gibibyte = 1 << 30
+
+// resourceTotals returns the resources of the replicas of the template, and
+// their hourly cost at the prices when there are any.
+func resourceTotals(template *corev1.PodTemplateSpec, replicas int32, prices map[corev1.ResourceName]float64) *pixiuv1alpha1.ResourceTotals {
+	totals := &pixiuv1alpha1.ResourceTotals{
+		Requests: multiplyResources(podRequests(template), replicas),
+		Limits:   multiplyResources(podResources(template, func(r corev1.ResourceRequirements) corev1.ResourceList { return r.Limits }), replicas),
+	}
+	if len(prices) != 0 {
+		var cost float64
+		for name, q := range totals.Requests {
+			units := q.AsApproximateFloat64()
+			if pricedPerGiB(name) {
+				units /= gibibyte
+			}
+			cost += units * prices[name]
+		}
+		totals.EstimatedHourlyCost = strconv.FormatFloat(cost, 'f', 4, 64)
+	}
+	return totals
+}
+
+func multiplyResources(perPod corev1.ResourceList, replicas int32) corev1.ResourceList {
+	if len(perPod) == 0 {
+		return nil
+	}
+	total := make(corev1.ResourceList, len(perPod))
+	for name, q := range perPod {
+		total[name] = *resource.NewMilliQuantity(q.MilliValue()*int64(replicas), q.Format)
+	}
+	return total
+}
+
+// pricedPerGiB reports whether the resource is an amount of bytes.
+func pricedPerGiB(name corev1.ResourceName) bool {
+	switch name {
+	case corev1.ResourceMemory, corev1.ResourceStorage, corev1.ResourceEphemeralStorage:
+		return true
+	}
+	return strings.HasPrefix(string(name), corev1.ResourceHugePagesPrefix)
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/types"
+	"github.com/caoyingjunz/podset-operator/pkg/util"
+)
+
+// DefaultRevisionHistoryLimit is the number of previous templates kept when
+// spec.revisionHistoryLimit isn't set, as for Deployments.
+const DefaultRevisionHistoryLimit = 10
+
+// syncRevisions records the declared template of the podSet as a
+// ControllerRevision named after its hash, and prunes the revisions beyond
+// spec.revisionHistoryLimit. A template seen before, after a rollback, gets
+// its revision renumbered as the latest. It returns the number of the
+// revision of the current template.
+func (r *PodSetReconciler) syncRevisions(ctx context.Context, podSet *pixiuv1alpha1.PodSet) (int64, error) {
+	revisions, err := r.listRevisions(ctx, podSet)
+	if err != nil {
+		return 0, err
+	}
+	hash, err := util.ComputeTemplateHash(&podSet.Spec.Template)
+	if err != nil {
+		return 0, err
+	}
+	name := revisionName(podSet, hash)
+
+	var current *appsv1.ControllerRevision
+	var latest int64
+	for _, revision := range revisions {
+		if revision.Name == name {
+			current = revision
+		}
+		if revision.Revision > latest {
+			latest = revision.Revision
+		}
+	}
+
+	switch {
+	case current == nil:
+		data, err := json.Marshal(&podSet.Spec.Template)
+		if err != nil {
+			return 0, err
+		}
+		current = &appsv1.ControllerRevision{
+			Data:     runtime.RawExtension{Raw: data},
+			Revision: latest + 1,
+		}
+		current.Name = name
+		current.Namespace = podSet.Namespace
+		current.Labels = map[string]string{types.PodSetRevisionLabel: podSet.Name}
+		if err = util.SetOwnerReference(current, *podSetOwnerReference(podSet)); err != nil {
+			return 0, err
+		}
+		if err = r.Create(ctx, current); err != nil && !apierrors.IsAlreadyExists(err) {
+			return 0, fmt.Errorf("failed to create revision %s: %v", name, err)
+		}
+		r.Log.Info("Recorded template revision", "podSet", klog.KObj(podSet), "revision", current.Revision, "hash", hash)
+		revisions = append(revisions, current)
+	case current.Revision < latest:
+		updated := current.DeepCopy()
+		updated.Revision = latest + 1
+		if err = r.Update(ctx, updated); err != nil {
+			return 0, fmt.Errorf("failed to renumber revision %s: %v", name, err)
+		}
+		current.Revision = updated.Revision
+	}
+
+	return current.Revision, r.pruneRevisions(ctx, podSet, revisions, current)
+}
+
+// pruneRevisions deletes the oldest revisions above the history limit, the
+// current revision aside.
+func (r *PodSetReconciler) pruneRevisions(ctx context.Context, podSet *pixiuv1alpha1.PodSet, revisions []*appsv1.ControllerRevision, current *appsv1.ControllerRevision) error {
+	limit := DefaultRevisionHistoryLimit
+	if podSet.Spec.RevisionHistoryLimit != nil {
+		limit = int(*podSet.Spec.RevisionHistoryLimit)
+	}
+
+	history := make([]*appsv1.ControllerRevision, 0, len(revisions))
+	for _, revision := range revisions {
+		if revision.Name != current.Name {
+			history = append(history, revision)
+		}
+	}
+	if len(history) <= limit {
+		return nil
+	}
+	sort.Slice(history, func(i, j int) bool { return history[i].Revision < history[j].Revision })
+	for _, revision := range history[:len(history)-limit] {
+		if err := r.Delete(ctx, revision); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete revision %s: %v", revision.Name, err)
+		}
+	}
+	return nil
+}
+
+// listRevisions returns the ControllerRevisions owned by the podSet.
+func (r *PodSetReconciler) listRevisions(ctx context.Context, podSet *pixiuv1alpha1.PodSet) ([]*appsv1.ControllerRevision, error) {
+	list := &appsv1.ControllerRevisionList{}
+	if err := r.List(ctx, list, client.InNamespace(podSet.Namespace), client.MatchingLabels{types.PodSetRevisionLabel: podSet.Name}); err != nil {
+		return nil, fmt.Errorf("failed to list revisions: %v", err)
+	}
+	revisions := make([]*appsv1.ControllerRevision, 0, len(list.Items))
+	for i := range list.Items {
+		revision := &list.Items[i]
+		if util.IsOwnedBy(revision, podSet.UID) {
+			revisions = append(revisions, revision)
+		}
+	}
+	return revisions, nil
+}
+
+// revisionName returns the name of the ControllerRevision of the template
+// with the hash.
+func revisionName(podSet *pixiuv1alpha1.PodSet, hash string) string {
+	return fmt.Sprintf("%s-%s", podSet.Name, hash)
+}
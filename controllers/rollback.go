@@ -0,0 +1,157 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/types"
+	"github.com/caoyingjunz/podset-operator/pkg/util"
+)
+
+// rollback restores the template of the podSet from the revision requested
+// with the rollback annotation, and reports whether the PodSet was updated.
+// The pods are then replaced following the update strategy, and the progress
+// is tracked in status by trackRollback.
+func (r *PodSetReconciler) rollback(ctx context.Context, podSet *pixiuv1alpha1.PodSet) (bool, error) {
+	value, ok := podSet.Annotations[types.RollbackToAnnotation]
+	if !ok {
+		return false, nil
+	}
+
+	revisions, err := r.listRevisions(ctx, podSet)
+	if err != nil {
+		return false, err
+	}
+	hash, err := util.ComputeTemplateHash(&podSet.Spec.Template)
+	if err != nil {
+		return false, err
+	}
+	target, err := rollbackTarget(revisions, revisionName(podSet, hash), value)
+	if err != nil {
+		r.Recorder.Eventf(podSet, corev1.EventTypeWarning, "FailedRollback", "Failed to roll back to revision %q: %v", value, err)
+		return false, err
+	}
+
+	updated := podSet.DeepCopy()
+	delete(updated.Annotations, types.RollbackToAnnotation)
+	if target == nil {
+		r.Recorder.Eventf(podSet, corev1.EventTypeNormal, "RollbackSkipped", "Revision %s is the current template", value)
+		return true, r.Update(ctx, updated)
+	}
+	if err = json.Unmarshal(target.Data.Raw, &updated.Spec.Template); err != nil {
+		r.Recorder.Eventf(podSet, corev1.EventTypeWarning, "FailedRollback", "Invalid template in revision %d: %v", target.Revision, err)
+		return false, fmt.Errorf("invalid template in revision %s: %v", target.Name, err)
+	}
+	targetHash, err := util.ComputeTemplateHash(&updated.Spec.Template)
+	if err != nil {
+		return false, err
+	}
+	if err = util.SetControllerSpecHash(updated); err != nil {
+		return false, err
+	}
+	if err = r.Update(ctx, updated); err != nil {
+		r.Recorder.Eventf(podSet, corev1.EventTypeWarning, "FailedRollback", "Failed to restore the template of revision %d: %v", target.Revision, err)
+		return false, err
+	}
+
+	r.Log.Info("Rolling back pod set", "podSet", klog.KObj(podSet), "revision", target.Revision)
+	r.Recorder.Eventf(podSet, corev1.EventTypeNormal, "RollingBack", "Rolling back to revision %d", target.Revision)
+	updated.Status.Rollback = &pixiuv1alpha1.RollbackStatus{
+		Revision:     target.Revision,
+		TemplateHash: targetHash,
+		StartTime:    metav1.Now(),
+	}
+	if err = r.applyPodSetStatus(ctx, updated, updated.Status); err != nil {
+		// The template is restored, only the progress events are lost.
+		r.Log.Error(err, "error recording rollback in status", "podSet", klog.KObj(podSet))
+	}
+	return true, nil
+}
+
+// rollbackTarget returns the revision the annotation value refers to, or nil
+// when it is the revision of the current template.
+func rollbackTarget(revisions []*appsv1.ControllerRevision, current, value string) (*appsv1.ControllerRevision, error) {
+	var target *appsv1.ControllerRevision
+	if len(value) == 0 || value == "previous" {
+		for _, revision := range revisions {
+			if revision.Name != current && (target == nil || revision.Revision > target.Revision) {
+				target = revision
+			}
+		}
+		if target == nil {
+			return nil, fmt.Errorf("no previous revision")
+		}
+		return target, nil
+	}
+
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s annotation %q: %v", types.RollbackToAnnotation, value, err)
+	}
+	for _, revision := range revisions {
+		if revision.Revision == number {
+			target = revision
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("revision %d not found", number)
+	}
+	if target.Name == current {
+		return nil, nil
+	}
+	return target, nil
+}
+
+// trackRollback reports the progress of the rollback of the podSet with
+// events, until the pods of the restored template are available or the
+// template is changed again.
+func (r *PodSetReconciler) trackRollback(podSet *pixiuv1alpha1.PodSet, newStatus *pixiuv1alpha1.PodSetStatus) {
+	if newStatus.Rollback == nil {
+		return
+	}
+	rollback := newStatus.Rollback.DeepCopy()
+	newStatus.Rollback = nil
+
+	hash, err := util.ComputeTemplateHash(&podSet.Spec.Template)
+	if err != nil || hash != rollback.TemplateHash {
+		r.Recorder.Eventf(podSet, corev1.EventTypeNormal, "RollbackSuperseded", "Rollback to revision %d superseded by a template change", rollback.Revision)
+		return
+	}
+	if newStatus.UpdatedReplicas == newStatus.Replicas && newStatus.Replicas == newStatus.DesiredReplicas &&
+		newStatus.AvailableReplicas >= newStatus.DesiredReplicas {
+		r.Recorder.Eventf(podSet, corev1.EventTypeNormal, "RolledBack", "Rolled back to revision %d in %v",
+			rollback.Revision, time.Since(rollback.StartTime.Time).Round(time.Second))
+		return
+	}
+	if newStatus.UpdatedReplicas != rollback.UpdatedReplicas {
+		rollback.UpdatedReplicas = newStatus.UpdatedReplicas
+		r.Recorder.Eventf(podSet, corev1.EventTypeNormal, "RollingBack", "Rolled back %d of %d replicas to revision %d",
+			rollback.UpdatedReplicas, newStatus.DesiredReplicas, rollback.Revision)
+	}
+	newStatus.Rollback = rollback
+}
@@ -0,0 +1,218 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/types"
+)
+
+// defaultRollingUpdateBound is the default maxSurge and maxUnavailable.
+var defaultRollingUpdateBound = intstr.FromString("25%")
+
+// isRollingUpdate reports whether the pods of previous templates are replaced
+// by the controller.
+func isRollingUpdate(podSet *pixiuv1alpha1.PodSet) bool {
+	strategy := podSet.Spec.UpdateStrategy
+	return strategy == nil || strategy.Type != pixiuv1alpha1.OnDeletePodSetStrategyType
+}
+
+// rollingUpdateBounds returns the number of pods that may be created above
+// the replicas and the number of replicas that may be unavailable during a
+// rolling update. Like Deployments, the surge is rounded up and the
+// unavailability down, and at least one pod may be unavailable when no pod
+// may be surged, otherwise the update could never progress.
+func rollingUpdateBounds(strategy *pixiuv1alpha1.PodSetUpdateStrategy, replicas int32) (int, int, error) {
+	maxSurge, maxUnavailable := &defaultRollingUpdateBound, &defaultRollingUpdateBound
+	if strategy != nil && strategy.RollingUpdate != nil {
+		if strategy.RollingUpdate.MaxSurge != nil {
+			maxSurge = strategy.RollingUpdate.MaxSurge
+		}
+		if strategy.RollingUpdate.MaxUnavailable != nil {
+			maxUnavailable = strategy.RollingUpdate.MaxUnavailable
+		}
+	}
+
+	surge, err := intstr.GetScaledValueFromIntOrPercent(maxSurge, int(replicas), true)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid maxSurge: %v", err)
+	}
+	unavailable, err := intstr.GetScaledValueFromIntOrPercent(maxUnavailable, int(replicas), false)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid maxUnavailable: %v", err)
+	}
+	if surge == 0 && unavailable == 0 {
+		unavailable = 1
+	}
+	return surge, unavailable, nil
+}
+
+// rollingUpdatePartition returns the lowest pod index updated by a rolling
+// update.
+func rollingUpdatePartition(podSet *pixiuv1alpha1.PodSet) int32 {
+	strategy := podSet.Spec.UpdateStrategy
+	if strategy == nil || strategy.RollingUpdate == nil || strategy.RollingUpdate.Partition == nil {
+		return 0
+	}
+	return *strategy.RollingUpdate.Partition
+}
+
+// partitionPods returns the old pods below the partition, which keep their
+// template, and the old pods to update. Pods without an index are updated.
+func partitionPods(oldPods []*corev1.Pod, partition int32) ([]*corev1.Pod, []*corev1.Pod) {
+	if partition <= 0 {
+		return nil, oldPods
+	}
+	var pinnedPods, outdatedPods []*corev1.Pod
+	for _, pod := range oldPods {
+		if index, err := strconv.Atoi(pod.Annotations[types.PodIndexAnnotation]); err == nil && index < int(partition) {
+			pinnedPods = append(pinnedPods, pod)
+		} else {
+			outdatedPods = append(outdatedPods, pod)
+		}
+	}
+	return pinnedPods, outdatedPods
+}
+
+// splitPodsByRevision returns the pods created from previous templates and
+// the pods created from the template with the revision hash, and the override
+// of their index.
+func splitPodsByRevision(podSet *pixiuv1alpha1.PodSet, pods []*corev1.Pod, revisionHash string) ([]*corev1.Pod, []*corev1.Pod) {
+	var oldPods, updatedPods []*corev1.Pod
+	for _, pod := range pods {
+		if pod.Labels[types.PodTemplateHashLabel] == podRevision(podSet, pod, revisionHash) {
+			updatedPods = append(updatedPods, pod)
+		} else {
+			oldPods = append(oldPods, pod)
+		}
+	}
+	return oldPods, updatedPods
+}
+
+// labelPodRevisions sets the revision hash label on the pods created before
+// the pods carried it. They are taken to run the current template, rather than
+// replacing every pod of the podSet on upgrade.
+func (r *PodSetReconciler) labelPodRevisions(ctx context.Context, pods []*corev1.Pod, revisionHash string) error {
+	for i, pod := range pods {
+		if _, ok := pod.Labels[types.PodTemplateHashLabel]; ok {
+			continue
+		}
+		labelled := pod.DeepCopy()
+		if labelled.Labels == nil {
+			labelled.Labels = make(map[string]string)
+		}
+		labelled.Labels[types.PodTemplateHashLabel] = revisionHash
+		if err := r.Patch(ctx, labelled, client.MergeFrom(pod)); err != nil {
+			return fmt.Errorf("failed to label pod %s with its revision: %v", pod.Name, err)
+		}
+		pods[i] = labelled
+	}
+	return nil
+}
+
+// rollOut replaces the old pods with pods of the current template. New pods
+// are created first, up to maxSurge pods above the replicas, and old pods are
+// deleted once it leaves at least replicas-maxUnavailable pods available,
+// unavailable old pods first. The pinned pods, below the partition, are kept
+// and count as replicas. Each step only acts on the pods observed, the next
+// one runs when the pods change.
+func (r *PodSetReconciler) rollOut(ctx context.Context, podSet *pixiuv1alpha1.PodSet, pinnedPods, oldPods, updatedPods []*corev1.Pod, replicas int32, revisionHash string, now time.Time) (scaleResult, error) {
+	var result scaleResult
+	maxSurge, maxUnavailable, err := rollingUpdateBounds(podSet.Spec.UpdateStrategy, replicas)
+	if err != nil {
+		return result, err
+	}
+	if hasFixedPodNames(podSet) {
+		// A pod can only be replaced once its name is free.
+		if maxSurge = 0; maxUnavailable == 0 {
+			maxUnavailable = 1
+		}
+	}
+
+	desired := int(replicas)
+	allPods := append(append(append([]*corev1.Pod{}, pinnedPods...), oldPods...), updatedPods...)
+	if len(allPods) <= desired {
+		result.scaleDown = activeScaleDownWindow(podSet, now)
+	}
+	// An OrderedReady podSet replaces its pods one at a time, by decreasing
+	// index, once all of them are ready.
+	ordered := isOrderedReady(podSet)
+	if ordered && !allPodsReady(allPods) {
+		return result, nil
+	}
+
+	create := desired - len(pinnedPods) - len(updatedPods)
+	if surge := desired + maxSurge - len(allPods); surge < create {
+		create = surge
+	}
+	if create > 0 {
+		if burst := r.burstReplicas(podSet); create > burst {
+			create = burst
+		}
+		if ordered {
+			create = 1
+		}
+		r.Log.Info("Rolling update creating pods", "podSet", klog.KObj(podSet), "revision", revisionHash, "old", len(oldPods), "updated", len(updatedPods), "creating", create)
+		result.requeueAfter, err = r.createReplicas(ctx, podSet, allPods, create, revisionHash, now)
+		return result, err
+	}
+
+	metaNow := metav1.NewTime(now)
+	minAvailable := desired - maxUnavailable
+	newUnavailable := 0
+	for _, pod := range updatedPods {
+		if !IsPodAvailable(pod, podSet.Spec.MinReadySeconds, metaNow) {
+			newUnavailable++
+		}
+	}
+	maxScaleDown := len(allPods) - minAvailable - newUnavailable
+	if maxScaleDown <= 0 {
+		return result, nil
+	}
+	if burst := r.burstReplicas(podSet); maxScaleDown > burst {
+		maxScaleDown = burst
+	}
+
+	var unavailable, available []*corev1.Pod
+	for _, pod := range oldPods {
+		if IsPodAvailable(pod, podSet.Spec.MinReadySeconds, metaNow) {
+			available = append(available, pod)
+		} else {
+			unavailable = append(unavailable, pod)
+		}
+	}
+	podsToDelete := append(unavailable, available...)
+	if ordered {
+		podsToDelete = highestIndexPods(oldPods, 1)
+	}
+	if len(podsToDelete) > maxScaleDown {
+		podsToDelete = podsToDelete[:maxScaleDown]
+	}
+	r.Log.Info("Rolling update deleting pods", "podSet", klog.KObj(podSet), "revision", revisionHash, "old", len(oldPods), "updated", len(updatedPods), "deleting", len(podsToDelete))
+	return result, r.deletePods(ctx, podSet, podsToDelete)
+}
@@ -0,0 +1,299 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/types"
+)
+
+func newRollingUpdate(maxSurge, maxUnavailable intstr.IntOrString) *pixiuv1alpha1.PodSetUpdateStrategy {
+	return &pixiuv1alpha1.PodSetUpdateStrategy{
+		Type: pixiuv1alpha1.RollingUpdatePodSetStrategyType,
+		RollingUpdate: &pixiuv1alpha1.RollingUpdatePodSet{
+			MaxSurge:       &maxSurge,
+			MaxUnavailable: &maxUnavailable,
+		},
+	}
+}
+
+func TestRollingUpdateBounds(t *testing.T) {
+	tests := []struct {
+		name            string
+		strategy        *pixiuv1alpha1.PodSetUpdateStrategy
+		replicas        int32
+		wantSurge       int
+		wantUnavailable int
+		wantErr         bool
+	}{
+		{
+			name:            "defaults",
+			replicas:        10,
+			wantSurge:       3,
+			wantUnavailable: 2,
+		},
+		{
+			name:            "absolute numbers",
+			strategy:        newRollingUpdate(intstr.FromInt(2), intstr.FromInt(0)),
+			replicas:        10,
+			wantSurge:       2,
+			wantUnavailable: 0,
+		},
+		{
+			name:            "no surge and no unavailability",
+			strategy:        newRollingUpdate(intstr.FromInt(0), intstr.FromInt(0)),
+			replicas:        10,
+			wantSurge:       0,
+			wantUnavailable: 1,
+		},
+		{
+			name:            "percentages",
+			strategy:        newRollingUpdate(intstr.FromString("50%"), intstr.FromString("50%")),
+			replicas:        3,
+			wantSurge:       2,
+			wantUnavailable: 1,
+		},
+		{
+			name:     "invalid percentage",
+			strategy: newRollingUpdate(intstr.FromString("half"), intstr.FromInt(1)),
+			replicas: 3,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			surge, unavailable, err := rollingUpdateBounds(tt.strategy, tt.replicas)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("rollingUpdateBounds() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if surge != tt.wantSurge || unavailable != tt.wantUnavailable {
+				t.Errorf("rollingUpdateBounds() = %d, %d, want %d, %d", surge, unavailable, tt.wantSurge, tt.wantUnavailable)
+			}
+		})
+	}
+}
+
+func newRevisionPod(name, revisionHash string, ready bool) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      name,
+			Labels:    map[string]string{"app": "web", types.PodTemplateHashLabel: revisionHash},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	if ready {
+		pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+	}
+	return pod
+}
+
+func TestSplitPodsByRevision(t *testing.T) {
+	pods := []*corev1.Pod{
+		newRevisionPod("old-1", "v1", true),
+		newRevisionPod("new-1", "v2", true),
+		newRevisionPod("old-2", "v0", true),
+	}
+
+	oldPods, updatedPods := splitPodsByRevision(&pixiuv1alpha1.PodSet{}, pods, "v2")
+	if len(oldPods) != 2 || oldPods[0].Name != "old-1" || oldPods[1].Name != "old-2" {
+		t.Errorf("splitPodsByRevision() old pods = %v, want old-1 and old-2", oldPods)
+	}
+	if len(updatedPods) != 1 || updatedPods[0].Name != "new-1" {
+		t.Errorf("splitPodsByRevision() updated pods = %v, want new-1", updatedPods)
+	}
+}
+
+func TestPartitionPods(t *testing.T) {
+	newIndexedPod := func(name, index string) *corev1.Pod {
+		pod := newRevisionPod(name, "v1", true)
+		if len(index) != 0 {
+			pod.Annotations = map[string]string{types.PodIndexAnnotation: index}
+		}
+		return pod
+	}
+	oldPods := []*corev1.Pod{
+		newIndexedPod("web-0", "0"),
+		newIndexedPod("web-3", "3"),
+		newIndexedPod("web-1", "1"),
+		newIndexedPod("unindexed", ""),
+	}
+
+	tests := []struct {
+		name         string
+		partition    int32
+		wantPinned   []string
+		wantOutdated []string
+	}{
+		{
+			name:         "no partition",
+			wantOutdated: []string{"web-0", "web-3", "web-1", "unindexed"},
+		},
+		{
+			name:         "below the partition",
+			partition:    2,
+			wantPinned:   []string{"web-0", "web-1"},
+			wantOutdated: []string{"web-3", "unindexed"},
+		},
+		{
+			name:       "partition above every index",
+			partition:  5,
+			wantPinned: []string{"web-0", "web-3", "web-1"},
+			// The pods without an index are always updated.
+			wantOutdated: []string{"unindexed"},
+		},
+	}
+
+	names := func(pods []*corev1.Pod) []string {
+		var names []string
+		for _, pod := range pods {
+			names = append(names, pod.Name)
+		}
+		return names
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pinnedPods, outdatedPods := partitionPods(oldPods, tt.partition)
+			if got := names(pinnedPods); !reflect.DeepEqual(got, tt.wantPinned) {
+				t.Errorf("partitionPods() pinned = %v, want %v", got, tt.wantPinned)
+			}
+			if got := names(outdatedPods); !reflect.DeepEqual(got, tt.wantOutdated) {
+				t.Errorf("partitionPods() outdated = %v, want %v", got, tt.wantOutdated)
+			}
+		})
+	}
+}
+
+func TestRollOutDeletions(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = pixiuv1alpha1.AddToScheme(scheme)
+
+	tests := []struct {
+		name        string
+		strategy    *pixiuv1alpha1.PodSetUpdateStrategy
+		pinnedPods  []*corev1.Pod
+		oldPods     []*corev1.Pod
+		updatedPods []*corev1.Pod
+		wantDeleted []string
+	}{
+		{
+			name:     "within maxUnavailable",
+			strategy: newRollingUpdate(intstr.FromInt(0), intstr.FromInt(1)),
+			oldPods: []*corev1.Pod{
+				newRevisionPod("old-1", "v1", true), newRevisionPod("old-2", "v1", true),
+				newRevisionPod("old-3", "v1", true), newRevisionPod("old-4", "v1", true),
+			},
+			wantDeleted: []string{"old-1"},
+		},
+		{
+			name:     "unavailable old pods first",
+			strategy: newRollingUpdate(intstr.FromInt(0), intstr.FromInt(1)),
+			oldPods: []*corev1.Pod{
+				newRevisionPod("old-1", "v1", true), newRevisionPod("old-2", "v1", true),
+				newRevisionPod("old-3", "v1", true), newRevisionPod("old-4", "v1", false),
+			},
+			wantDeleted: []string{"old-4"},
+		},
+		{
+			name:     "updated pods not available yet",
+			strategy: newRollingUpdate(intstr.FromInt(1), intstr.FromInt(0)),
+			oldPods: []*corev1.Pod{
+				newRevisionPod("old-1", "v1", true), newRevisionPod("old-2", "v1", true),
+				newRevisionPod("old-3", "v1", true),
+			},
+			updatedPods: []*corev1.Pod{newRevisionPod("new-1", "v2", false), newRevisionPod("new-2", "v2", false)},
+		},
+		{
+			name:     "surged pod available",
+			strategy: newRollingUpdate(intstr.FromInt(1), intstr.FromInt(0)),
+			oldPods: []*corev1.Pod{
+				newRevisionPod("old-1", "v1", true), newRevisionPod("old-2", "v1", true),
+				newRevisionPod("old-3", "v1", true), newRevisionPod("old-4", "v1", true),
+			},
+			updatedPods: []*corev1.Pod{newRevisionPod("new-1", "v2", true)},
+			wantDeleted: []string{"old-1"},
+		},
+		{
+			name:       "pinned pods kept",
+			strategy:   newRollingUpdate(intstr.FromInt(0), intstr.FromInt(2)),
+			pinnedPods: []*corev1.Pod{newRevisionPod("old-1", "v1", false), newRevisionPod("old-2", "v1", true)},
+			oldPods: []*corev1.Pod{
+				newRevisionPod("old-3", "v1", true), newRevisionPod("old-4", "v1", true),
+			},
+			wantDeleted: []string{"old-3", "old-4"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var objects []client.Object
+			for _, pod := range append(append(append([]*corev1.Pod{}, tt.pinnedPods...), tt.oldPods...), tt.updatedPods...) {
+				objects = append(objects, pod.DeepCopy())
+			}
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+			r := &PodSetReconciler{Client: c, Log: logr.Discard(), Recorder: record.NewFakeRecorder(10), expectations: newExpectations()}
+			podSet := &pixiuv1alpha1.PodSet{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+				Spec:       pixiuv1alpha1.PodSetSpec{UpdateStrategy: tt.strategy},
+			}
+
+			if _, err := r.rollOut(context.TODO(), podSet, tt.pinnedPods, tt.oldPods, tt.updatedPods, 4, "v2", time.Now()); err != nil {
+				t.Fatalf("rollOut() error = %v", err)
+			}
+
+			pods := &corev1.PodList{}
+			if err := c.List(context.TODO(), pods); err != nil {
+				t.Fatalf("failed to list pods: %v", err)
+			}
+			remaining := make(map[string]bool)
+			for _, pod := range pods.Items {
+				remaining[pod.Name] = true
+			}
+			var deleted []string
+			for _, obj := range objects {
+				if !remaining[obj.GetName()] {
+					deleted = append(deleted, obj.GetName())
+				}
+			}
+			sort.Strings(deleted)
+			if !reflect.DeepEqual(deleted, tt.wantDeleted) {
+				t.Errorf("rollOut() deleted %v, want %v", deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
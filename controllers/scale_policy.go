@@ -0,0 +1,105 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+// scaleRateWindow is the period the scale policy rates are measured over.
+const scaleRateWindow = time.Minute
+
+// scaleResult reports what manageReplicas postponed because of the scale policy.
+type scaleResult struct {
+	// requeueAfter is when the postponed changes may proceed.
+	requeueAfter time.Duration
+	// scaleDown is the progress of a rate limited scale down.
+	scaleDown *pixiuv1alpha1.ScaleDownStatus
+}
+
+// createBudget returns how many pods may be created now under the scale
+// policy of the podSet, or -1 if unlimited, and when the budget grows again.
+// Recent creations are derived from the creation timestamps of the active
+// pods, so the budget survives controller restarts.
+func createBudget(podSet *pixiuv1alpha1.PodSet, filteredPods []*corev1.Pod, now time.Time) (int, time.Duration) {
+	if podSet.Spec.ScalePolicy == nil || podSet.Spec.ScalePolicy.MaxCreatePerMinute == nil {
+		return -1, 0
+	}
+
+	var oldest time.Time
+	recent := 0
+	for _, pod := range filteredPods {
+		created := pod.CreationTimestamp.Time
+		if now.Sub(created) >= scaleRateWindow {
+			continue
+		}
+		recent++
+		if oldest.IsZero() || created.Before(oldest) {
+			oldest = created
+		}
+	}
+
+	budget := int(*podSet.Spec.ScalePolicy.MaxCreatePerMinute) - recent
+	if budget < 0 {
+		budget = 0
+	}
+	if oldest.IsZero() {
+		return budget, scaleRateWindow
+	}
+	return budget, oldest.Add(scaleRateWindow).Sub(now)
+}
+
+// deleteBudget returns how many pods may be deleted now under the scale policy
+// of the podSet, or -1 if unlimited, together with the current rate limit
+// window and when the window ends. Deletions are counted in status since the
+// deleted pods are gone from the cache.
+func deleteBudget(podSet *pixiuv1alpha1.PodSet, now time.Time) (int, *pixiuv1alpha1.ScaleDownStatus, time.Duration) {
+	if podSet.Spec.ScalePolicy == nil || podSet.Spec.ScalePolicy.MaxDeletePerMinute == nil {
+		return -1, nil, 0
+	}
+
+	window := activeScaleDownWindow(podSet, now)
+	if window == nil {
+		window = &pixiuv1alpha1.ScaleDownStatus{WindowStart: metav1.NewTime(now)}
+	}
+
+	budget := int(*podSet.Spec.ScalePolicy.MaxDeletePerMinute - window.DeletedInWindow)
+	if budget < 0 {
+		budget = 0
+	}
+	return budget, window, window.WindowStart.Add(scaleRateWindow).Sub(now)
+}
+
+// activeScaleDownWindow returns a copy of the rate limit window recorded in
+// status if it has not expired yet.
+func activeScaleDownWindow(podSet *pixiuv1alpha1.PodSet, now time.Time) *pixiuv1alpha1.ScaleDownStatus {
+	current := podSet.Status.ScaleDown
+	if current == nil || podSet.Spec.ScalePolicy == nil || podSet.Spec.ScalePolicy.MaxDeletePerMinute == nil {
+		return nil
+	}
+	if now.Sub(current.WindowStart.Time) >= scaleRateWindow {
+		return nil
+	}
+	window := current.DeepCopy()
+	window.PendingDeletions = 0
+	return window
+}
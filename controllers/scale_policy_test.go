@@ -0,0 +1,169 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+func TestCreateBudget(t *testing.T) {
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	podCreated := func(ago time.Duration) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(now.Add(-ago))}}
+	}
+
+	tests := []struct {
+		name       string
+		policy     *pixiuv1alpha1.ScalePolicy
+		pods       []*corev1.Pod
+		wantBudget int
+		wantAfter  time.Duration
+	}{
+		{
+			name:       "no policy",
+			wantBudget: -1,
+		},
+		{
+			name:       "no recent creations",
+			policy:     &pixiuv1alpha1.ScalePolicy{MaxCreatePerMinute: pointer.Int32(3)},
+			pods:       []*corev1.Pod{podCreated(time.Hour)},
+			wantBudget: 3,
+			wantAfter:  scaleRateWindow,
+		},
+		{
+			name:       "recent creations",
+			policy:     &pixiuv1alpha1.ScalePolicy{MaxCreatePerMinute: pointer.Int32(3)},
+			pods:       []*corev1.Pod{podCreated(10 * time.Second), podCreated(40 * time.Second), podCreated(time.Hour)},
+			wantBudget: 1,
+			wantAfter:  20 * time.Second,
+		},
+		{
+			name:       "budget exhausted",
+			policy:     &pixiuv1alpha1.ScalePolicy{MaxCreatePerMinute: pointer.Int32(1)},
+			pods:       []*corev1.Pod{podCreated(10 * time.Second), podCreated(30 * time.Second)},
+			wantBudget: 0,
+			wantAfter:  30 * time.Second,
+		},
+		{
+			name:       "no create limit",
+			policy:     &pixiuv1alpha1.ScalePolicy{MaxDeletePerMinute: pointer.Int32(1)},
+			wantBudget: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			podSet := &pixiuv1alpha1.PodSet{Spec: pixiuv1alpha1.PodSetSpec{ScalePolicy: tt.policy}}
+			budget, after := createBudget(podSet, tt.pods, now)
+			if budget != tt.wantBudget || after != tt.wantAfter {
+				t.Errorf("createBudget() = %d, %v, want %d, %v", budget, after, tt.wantBudget, tt.wantAfter)
+			}
+		})
+	}
+}
+
+func TestDeleteBudget(t *testing.T) {
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	limit := &pixiuv1alpha1.ScalePolicy{MaxDeletePerMinute: pointer.Int32(5)}
+
+	tests := []struct {
+		name       string
+		policy     *pixiuv1alpha1.ScalePolicy
+		scaleDown  *pixiuv1alpha1.ScaleDownStatus
+		wantBudget int
+		wantStart  time.Time
+		wantAfter  time.Duration
+	}{
+		{
+			name:       "no policy",
+			wantBudget: -1,
+		},
+		{
+			name:       "no window recorded",
+			policy:     limit,
+			wantBudget: 5,
+			wantStart:  now,
+			wantAfter:  scaleRateWindow,
+		},
+		{
+			name:   "active window",
+			policy: limit,
+			scaleDown: &pixiuv1alpha1.ScaleDownStatus{
+				WindowStart:      metav1.NewTime(now.Add(-20 * time.Second)),
+				DeletedInWindow:  3,
+				PendingDeletions: 4,
+			},
+			wantBudget: 2,
+			wantStart:  now.Add(-20 * time.Second),
+			wantAfter:  40 * time.Second,
+		},
+		{
+			name:   "window exhausted",
+			policy: limit,
+			scaleDown: &pixiuv1alpha1.ScaleDownStatus{
+				WindowStart:     metav1.NewTime(now.Add(-20 * time.Second)),
+				DeletedInWindow: 6,
+			},
+			wantBudget: 0,
+			wantStart:  now.Add(-20 * time.Second),
+			wantAfter:  40 * time.Second,
+		},
+		{
+			name:   "expired window",
+			policy: limit,
+			scaleDown: &pixiuv1alpha1.ScaleDownStatus{
+				WindowStart:     metav1.NewTime(now.Add(-2 * time.Minute)),
+				DeletedInWindow: 5,
+			},
+			wantBudget: 5,
+			wantStart:  now,
+			wantAfter:  scaleRateWindow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			podSet := &pixiuv1alpha1.PodSet{
+				Spec:   pixiuv1alpha1.PodSetSpec{ScalePolicy: tt.policy},
+				Status: pixiuv1alpha1.PodSetStatus{ScaleDown: tt.scaleDown},
+			}
+			budget, window, after := deleteBudget(podSet, now)
+			if budget != tt.wantBudget || after != tt.wantAfter {
+				t.Errorf("deleteBudget() = %d, %v, want %d, %v", budget, after, tt.wantBudget, tt.wantAfter)
+			}
+			if tt.policy == nil {
+				if window != nil {
+					t.Errorf("deleteBudget() window = %v, want nil", window)
+				}
+				return
+			}
+			if !window.WindowStart.Time.Equal(tt.wantStart) {
+				t.Errorf("deleteBudget() window start = %v, want %v", window.WindowStart, tt.wantStart)
+			}
+			if window.PendingDeletions != 0 {
+				t.Errorf("deleteBudget() pending deletions = %d, want 0", window.PendingDeletions)
+			}
+		})
+	}
+}
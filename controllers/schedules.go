@@ -0,0 +1,85 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/cron"
+)
+
+// scheduleLookahead is how far ahead the next change of the active schedule
+// is looked for. A podset whose schedule doesn't change within it is
+// reconciled again after it.
+const scheduleLookahead = 7 * 24 * time.Hour
+
+type parsedSchedule struct {
+	schedule *pixiuv1alpha1.ReplicaSchedule
+	cron     *cron.Schedule
+	location *time.Location
+}
+
+// activeSchedule returns the first schedule of spec.schedules active at now,
+// or nil, and how long until the active schedule changes. Invalid schedules
+// are ignored and returned as an error.
+func activeSchedule(podSet *pixiuv1alpha1.PodSet, now time.Time) (*pixiuv1alpha1.ReplicaSchedule, time.Duration, error) {
+	if len(podSet.Spec.Schedules) == 0 {
+		return nil, 0, nil
+	}
+
+	var errs []error
+	schedules := make([]parsedSchedule, 0, len(podSet.Spec.Schedules))
+	for i := range podSet.Spec.Schedules {
+		schedule := &podSet.Spec.Schedules[i]
+		parsed, err := cron.Parse(schedule.Schedule)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid schedule %s: %v", schedule.Name, err))
+			continue
+		}
+		location := time.UTC
+		if len(schedule.TimeZone) != 0 {
+			if location, err = time.LoadLocation(schedule.TimeZone); err != nil {
+				errs = append(errs, fmt.Errorf("invalid time zone of schedule %s: %v", schedule.Name, err))
+				continue
+			}
+		}
+		schedules = append(schedules, parsedSchedule{schedule: schedule, cron: parsed, location: location})
+	}
+
+	active := func(t time.Time) *pixiuv1alpha1.ReplicaSchedule {
+		for _, s := range schedules {
+			if s.cron.Matches(t.In(s.location)) {
+				return s.schedule
+			}
+		}
+		return nil
+	}
+
+	current := active(now)
+	requeueAfter := scheduleLookahead
+	for t := now.Truncate(time.Minute).Add(time.Minute); t.Sub(now) < scheduleLookahead; t = t.Add(time.Minute) {
+		if active(t) != current {
+			requeueAfter = t.Sub(now)
+			break
+		}
+	}
+	return current, requeueAfter, utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+// schedulingRetryInterval is how often an unschedulable podset checks whether
+// the nodes changed.
+const schedulingRetryInterval = 30 * time.Second
+
+// Reasons a node can't run a pod of the template, in the Unschedulable condition.
+const (
+	reasonNoNodes               = "NoNodes"
+	reasonNodeUnschedulable     = "NodeUnschedulable"
+	reasonNodeSelectorMismatch  = "NodeSelectorMismatch"
+	reasonUntoleratedTaint      = "UntoleratedTaint"
+	reasonInsufficientResources = "InsufficientResources"
+)
+
+// checkSchedulable reports why no node can run a pod of the podSet template,
+// or an empty reason when one can. It doesn't account for the pods already
+// running on the nodes, only whether a pod of the template fits at all, so
+// that a template that can never schedule is reported instead of leaving
+// Pending pods behind.
+func (r *PodSetReconciler) checkSchedulable(ctx context.Context, podSet *pixiuv1alpha1.PodSet) (string, string, error) {
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes); err != nil {
+		return "", "", err
+	}
+	if len(nodes.Items) == 0 {
+		return reasonNoNodes, "No nodes are available", nil
+	}
+
+	podSpec := &podSet.Spec.Template.Spec
+	requests := podRequests(&podSet.Spec.Template)
+	counts := map[string]int{}
+	for i := range nodes.Items {
+		reason := nodeFitReason(&nodes.Items[i], podSpec, requests)
+		if len(reason) == 0 {
+			return "", "", nil
+		}
+		counts[reason]++
+	}
+
+	// The dominant reason is the one excluding the most nodes, ties are broken
+	// by name to keep the condition stable.
+	reasons := make([]string, 0, len(counts))
+	for reason := range counts {
+		reasons = append(reasons, reason)
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if counts[reasons[i]] != counts[reasons[j]] {
+			return counts[reasons[i]] > counts[reasons[j]]
+		}
+		return reasons[i] < reasons[j]
+	})
+	msg := fmt.Sprintf("0/%d nodes can run the pods:", len(nodes.Items))
+	for _, reason := range reasons {
+		msg += fmt.Sprintf(" %d %s,", counts[reason], reason)
+	}
+	return reasons[0], msg[:len(msg)-1], nil
+}
+
+// nodeFitReason returns why the node can't run the pod, or an empty string.
+func nodeFitReason(node *corev1.Node, podSpec *corev1.PodSpec, requests corev1.ResourceList) string {
+	if node.Spec.Unschedulable {
+		return reasonNodeUnschedulable
+	}
+	if !labels.SelectorFromSet(podSpec.NodeSelector).Matches(labels.Set(node.Labels)) || !matchesNodeAffinity(node, podSpec.Affinity) {
+		return reasonNodeSelectorMismatch
+	}
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect != corev1.TaintEffectNoSchedule && taint.Effect != corev1.TaintEffectNoExecute {
+			continue
+		}
+		if !toleratesTaint(podSpec.Tolerations, taint) {
+			return reasonUntoleratedTaint
+		}
+	}
+	for name, request := range requests {
+		if request.IsZero() {
+			continue
+		}
+		if allocatable, ok := node.Status.Allocatable[name]; !ok || request.Cmp(allocatable) > 0 {
+			return reasonInsufficientResources
+		}
+	}
+	return ""
+}
+
+func toleratesTaint(tolerations []corev1.Toleration, taint *corev1.Taint) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesNodeAffinity checks the required node affinity: the node must match
+// one of the terms, and every requirement of that term.
+func matchesNodeAffinity(node *corev1.Node, affinity *corev1.Affinity) bool {
+	if affinity == nil || affinity.NodeAffinity == nil || affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return true
+	}
+	terms := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	for _, term := range terms {
+		if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+			// An empty term matches no objects.
+			continue
+		}
+		if matchesRequirements(term.MatchExpressions, labels.Set(node.Labels)) &&
+			matchesRequirements(term.MatchFields, labels.Set{"metadata.name": node.Name}) {
+			return true
+		}
+	}
+	return false
+}
+
+var nodeSelectorOperators = map[corev1.NodeSelectorOperator]selection.Operator{
+	corev1.NodeSelectorOpIn:           selection.In,
+	corev1.NodeSelectorOpNotIn:        selection.NotIn,
+	corev1.NodeSelectorOpExists:       selection.Exists,
+	corev1.NodeSelectorOpDoesNotExist: selection.DoesNotExist,
+	corev1.NodeSelectorOpGt:           selection.GreaterThan,
+	corev1.NodeSelectorOpLt:           selection.LessThan,
+}
+
+func matchesRequirements(requirements []corev1.NodeSelectorRequirement, set labels.Set) bool {
+	for _, req := range requirements {
+		op, ok := nodeSelectorOperators[req.Operator]
+		if !ok {
+			return false
+		}
+		requirement, err := labels.NewRequirement(req.Key, op, req.Values)
+		if err != nil || !requirement.Matches(set) {
+			return false
+		}
+	}
+	return true
+}
+
+// setUnschedulableCondition reports in the podSet status why its pods can't
+// be scheduled on any node.
+func setUnschedulableCondition(newStatus *pixiuv1alpha1.PodSetStatus, reason, msg string) {
+	if len(reason) == 0 {
+		RemovePodSetCondition(newStatus, pixiuv1alpha1.PodSetUnschedulable)
+		return
+	}
+	SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetUnschedulable, corev1.ConditionTrue, reason, msg))
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	pixiutypes "github.com/caoyingjunz/podset-operator/pkg/types"
+)
+
+// The vendored k8s.io/api predates pod scheduling gates, so the pods are
+// created unstructured to carry them and the gates a pod carries are tracked
+// in its pixiu.pixiu.io/scheduling-gates annotation.
+
+// schedulingGates returns the scheduling gates of the podSet that are not
+// lifted, the ones added to its new pods.
+func schedulingGates(podSet *pixiuv1alpha1.PodSet) []string {
+	lifted := splitGates(podSet.Annotations[pixiutypes.LiftSchedulingGatesAnnotation])
+	var gates []string
+	for _, gate := range podSet.Spec.SchedulingGates {
+		if !lifted.Has(gate) {
+			gates = append(gates, gate)
+		}
+	}
+	return gates
+}
+
+func splitGates(value string) sets.String {
+	gates := sets.NewString()
+	for _, gate := range strings.Split(value, ",") {
+		if gate = strings.TrimSpace(gate); len(gate) != 0 {
+			gates.Insert(gate)
+		}
+	}
+	return gates
+}
+
+// createGatedPod creates the pod with the scheduling gates and updates it with
+// the created pod.
+func (r *PodSetReconciler) createGatedPod(ctx context.Context, pod *corev1.Pod, gates []string) error {
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(pod)
+	if err != nil {
+		return err
+	}
+	schedulingGates := make([]interface{}, 0, len(gates))
+	for _, gate := range gates {
+		schedulingGates = append(schedulingGates, map[string]interface{}{"name": gate})
+	}
+	if err = unstructured.SetNestedSlice(obj, schedulingGates, "spec", "schedulingGates"); err != nil {
+		return err
+	}
+
+	gated := &unstructured.Unstructured{Object: obj}
+	gated.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Pod"))
+	if err = r.Create(ctx, gated); err != nil {
+		return err
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(gated.Object, pod)
+}
+
+// liftSchedulingGates removes the lifted scheduling gates from the pods that
+// are not scheduled yet. Gates removed by others are left alone.
+func (r *PodSetReconciler) liftSchedulingGates(ctx context.Context, podSet *pixiuv1alpha1.PodSet, pods []*corev1.Pod) error {
+	keep := sets.NewString(schedulingGates(podSet)...)
+	for _, pod := range pods {
+		value, ok := pod.Annotations[pixiutypes.SchedulingGatesAnnotation]
+		if !ok || len(pod.Spec.NodeName) != 0 {
+			continue
+		}
+		carried := splitGates(value)
+		lifted := carried.Difference(keep)
+		if lifted.Len() == 0 {
+			continue
+		}
+
+		deletes := make([]interface{}, 0, lifted.Len())
+		for _, gate := range lifted.List() {
+			deletes = append(deletes, map[string]interface{}{"name": gate, "$patch": "delete"})
+		}
+		var annotation interface{}
+		if remaining := carried.Intersection(keep); remaining.Len() != 0 {
+			annotation = strings.Join(remaining.List(), ",")
+		}
+		patch, err := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"annotations": map[string]interface{}{pixiutypes.SchedulingGatesAnnotation: annotation},
+			},
+			"spec": map[string]interface{}{"schedulingGates": deletes},
+		})
+		if err != nil {
+			return err
+		}
+		if err = r.Patch(ctx, pod, client.RawPatch(types.StrategicMergePatchType, patch)); err != nil {
+			return fmt.Errorf("failed to lift the scheduling gates of pod %s: %v", pod.Name, err)
+		}
+		r.Log.Info("Lifted scheduling gates", "podSet", klog.KObj(podSet), "pod", klog.KObj(pod), "gates", lifted.List())
+	}
+	return nil
+}
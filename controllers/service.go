@@ -0,0 +1,93 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/inventory"
+)
+
+// applyHeadlessService declares the headless Service of spec.serviceName,
+// giving the pods the DNS records <hostname>.<serviceName>.
+func (r *PodSetReconciler) applyHeadlessService(ctx context.Context, podSet *pixiuv1alpha1.PodSet, tracker *inventory.Tracker) error {
+	if len(podSet.Spec.ServiceName) == 0 {
+		return nil
+	}
+
+	service := &corev1.Service{}
+	service.Name = podSet.Spec.ServiceName
+	if err := tracker.Apply(ctx, service, func() error {
+		service.Spec.ClusterIP = corev1.ClusterIPNone
+		service.Spec.Selector = podSet.Spec.Selector.MatchLabels
+		service.Spec.Ports = servicePorts(&podSet.Spec.Template)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to apply headless service %s: %v", podSet.Spec.ServiceName, err)
+	}
+	return nil
+}
+
+// applyServiceSubdomain returns the podSet with the pods of its template in
+// the subdomain of spec.serviceName. Like applyImagePullSecrets, it returns
+// the podSet itself when there is nothing to change. The hostnames are set
+// per index by podTemplate.
+func applyServiceSubdomain(podSet *pixiuv1alpha1.PodSet) *pixiuv1alpha1.PodSet {
+	if len(podSet.Spec.ServiceName) == 0 || podSet.Spec.Template.Spec.Subdomain == podSet.Spec.ServiceName {
+		return podSet
+	}
+	effective := podSet.DeepCopy()
+	effective.Spec.Template.Spec.Subdomain = podSet.Spec.ServiceName
+	return effective
+}
+
+// servicePorts returns the ports of the containers of the template, so that
+// they get SRV records. Ports of different containers may share a number.
+func servicePorts(template *corev1.PodTemplateSpec) []corev1.ServicePort {
+	var ports []corev1.ServicePort
+	seen := map[string]bool{}
+	for _, container := range template.Spec.Containers {
+		for _, port := range container.Ports {
+			protocol := port.Protocol
+			if len(protocol) == 0 {
+				protocol = corev1.ProtocolTCP
+			}
+			name := port.Name
+			if len(name) == 0 {
+				name = fmt.Sprintf("%s-%d", strings.ToLower(string(protocol)), port.ContainerPort)
+			}
+			key := fmt.Sprintf("%s/%d", protocol, port.ContainerPort)
+			if seen[key] || seen[name] {
+				continue
+			}
+			seen[key], seen[name] = true, true
+			ports = append(ports, corev1.ServicePort{
+				Name:       name,
+				Protocol:   protocol,
+				Port:       port.ContainerPort,
+				TargetPort: intstr.FromInt(int(port.ContainerPort)),
+			})
+		}
+	}
+	return ports
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/types"
+)
+
+// DefaultReconcileTimeout bounds a reconcile of a PodSet.
+const DefaultReconcileTimeout = 2 * time.Minute
+
+// Settings are the tunables of the PodSet controller that can be changed
+// while it runs, see UpdateSettings.
+type Settings struct {
+	// CreateFailureThreshold is the number of consecutive failed pod creations
+	// after which pod creation stops for CreateCircuitCooldown.
+	CreateFailureThreshold int
+	CreateCircuitCooldown  time.Duration
+
+	// FailureBackoffBase and FailureBackoffMax bound the exponential backoff
+	// applied to PodSets whose reconciles keep failing.
+	FailureBackoffBase time.Duration
+	FailureBackoffMax  time.Duration
+
+	// PrometheusAddress is the default server of Prometheus replica sources.
+	PrometheusAddress string
+
+	// Scale downs of more than LiveVerifyDeleteThreshold pods are verified
+	// through APIReader; zero disables the verification.
+	LiveVerifyDeleteThreshold int
+
+	// ReconcileTimeout is the deadline of a reconcile of a PodSet, unless
+	// overridden by spec.reconcileTimeout; zero disables it.
+	ReconcileTimeout time.Duration
+
+	// BurstReplicas is the maximum number of pods of a PodSet created or
+	// deleted in a single reconcile, unless overridden by spec.burstReplicas.
+	BurstReplicas int
+
+	// IgnoredNamespaces are namespaces whose PodSets are not reconciled.
+	IgnoredNamespaces []string
+
+	// ResourcePrices are the hourly prices of the resources by name, per GiB
+	// of memory and storage and per unit of the others. The estimated cost of
+	// PodSets isn't published without them.
+	ResourcePrices map[corev1.ResourceName]float64
+}
+
+// settings returns the current settings.
+func (r *PodSetReconciler) settings() Settings {
+	r.settingsMu.RLock()
+	defer r.settingsMu.RUnlock()
+	return r.Settings
+}
+
+// reconcileTimeout returns the deadline of the reconciles of the podSet.
+func (r *PodSetReconciler) reconcileTimeout(podSet *pixiuv1alpha1.PodSet) time.Duration {
+	if podSet.Spec.ReconcileTimeout != nil {
+		return podSet.Spec.ReconcileTimeout.Duration
+	}
+	return r.settings().ReconcileTimeout
+}
+
+// burstReplicas returns the maximum number of pods of the podSet created or
+// deleted in a single reconcile.
+func (r *PodSetReconciler) burstReplicas(podSet *pixiuv1alpha1.PodSet) int {
+	if podSet.Spec.BurstReplicas != nil {
+		return int(*podSet.Spec.BurstReplicas)
+	}
+	if burst := r.settings().BurstReplicas; burst > 0 {
+		return burst
+	}
+	return types.BurstReplicas
+}
+
+// ignored reports whether the PodSets of the namespace are left alone.
+func (r *PodSetReconciler) ignored(namespace string) bool {
+	for _, ns := range r.settings().IgnoredNamespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateSettings applies new settings to the running controller. The PodSets
+// of the namespaces that are no longer ignored are reconciled right away.
+func (r *PodSetReconciler) UpdateSettings(s Settings) {
+	r.settingsMu.Lock()
+	previous := r.Settings
+	r.Settings = s
+	r.settingsMu.Unlock()
+
+	if r.createBreaker != nil {
+		r.createBreaker.SetLimits(s.CreateFailureThreshold, s.CreateCircuitCooldown)
+	}
+
+	resumed := sets.NewString(previous.IgnoredNamespaces...).Difference(sets.NewString(s.IgnoredNamespaces...))
+	if resumed.Len() == 0 || r.resync == nil {
+		return
+	}
+	go func() {
+		podSets := &pixiuv1alpha1.PodSetList{}
+		if err := r.List(context.TODO(), podSets); err != nil {
+			r.Log.Error(err, "error listing pod sets of resumed namespaces")
+			return
+		}
+		for i := range podSets.Items {
+			if resumed.Has(podSets.Items[i].Namespace) {
+				r.resync <- event.GenericEvent{Object: &podSets.Items[i]}
+			}
+		}
+	}()
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultShutdownDrainTimeout bounds how long in-flight reconciles may run
+// once the manager is asked to stop.
+const DefaultShutdownDrainTimeout = 25 * time.Second
+
+// drainContext keeps the values of a reconcile context but takes its
+// cancellation from the drain context. The manager cancels the reconcile
+// contexts as soon as it stops, which would abort scale operations and status
+// updates half way.
+type drainContext struct {
+	context.Context
+	drain context.Context
+}
+
+func (c drainContext) Deadline() (time.Time, bool) { return c.drain.Deadline() }
+func (c drainContext) Done() <-chan struct{}       { return c.drain.Done() }
+func (c drainContext) Err() error                  { return c.drain.Err() }
+
+// withDrain returns the context the work of a reconcile runs with. It reports
+// false when the manager is already stopping, so that no new work is started.
+func withDrain(ctx, drain context.Context) (context.Context, bool) {
+	if ctx.Err() != nil {
+		return ctx, false
+	}
+	if drain == nil {
+		return ctx, true
+	}
+	return drainContext{Context: ctx, drain: drain}, true
+}
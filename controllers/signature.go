@@ -0,0 +1,140 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/podtemplate"
+	"github.com/caoyingjunz/podset-operator/pkg/registry"
+	"github.com/caoyingjunz/podset-operator/pkg/signature"
+)
+
+// verifyImages checks the cosign signatures of the images of the podSet
+// template, and returns why they can't be trusted. Verification fails closed:
+// an image that can't be verified, because it is templated or its registry is
+// unavailable, blocks the template like an unsigned one.
+//
+// An image referenced by tag is verified at the digest the tag points to when
+// the template changes. Pin the images with spec.imageResolution Digest to
+// make sure the pods run the verified digests.
+func (r *PodSetReconciler) verifyImages(ctx context.Context, podSet *pixiuv1alpha1.PodSet) error {
+	policy := podSet.Spec.ImageVerification
+	if policy == nil {
+		return nil
+	}
+	hash, err := verificationHash(&podSet.Spec.Template, policy)
+	if err != nil {
+		return err
+	}
+	key := client.ObjectKeyFromObject(podSet)
+	now := time.Now()
+	if ok, err := r.imageVerifications.get(key, hash, now); ok {
+		return err
+	}
+
+	err = r.verifyTemplateImages(ctx, podSet, policy)
+	if err == nil {
+		r.Log.Info("Verified the image signatures of a new template", "podSet", klog.KObj(podSet))
+	}
+	r.imageVerifications.set(key, templateCheck{hash: hash, err: err, checkedAt: now})
+	return err
+}
+
+func (r *PodSetReconciler) verifyTemplateImages(ctx context.Context, podSet *pixiuv1alpha1.PodSet, policy *pixiuv1alpha1.ImageVerification) error {
+	verifier := &signature.Verifier{TrustRoot: r.SignatureTrust}
+	for _, key := range policy.PublicKeys {
+		keys, err := signature.ParsePublicKeys(key)
+		if err != nil {
+			return fmt.Errorf("invalid public key: %v", err)
+		}
+		verifier.Keys = append(verifier.Keys, keys...)
+	}
+	for _, identity := range policy.Identities {
+		verifier.Identities = append(verifier.Identities, signature.Identity{Issuer: identity.Issuer, Subject: identity.Subject})
+	}
+
+	keychain, err := r.imagePullKeychain(ctx, podSet)
+	if err != nil {
+		return err
+	}
+	verified := map[string]bool{}
+	podSpec := &podSet.Spec.Template.Spec
+	for _, containers := range [][]corev1.Container{podSpec.InitContainers, podSpec.Containers} {
+		for _, c := range containers {
+			if verified[c.Image] {
+				continue
+			}
+			if podtemplate.IsTemplated(c.Image) {
+				return fmt.Errorf("image %s of container %s is templated and can't be verified", c.Image, c.Name)
+			}
+			if err = r.verifyImage(ctx, verifier, c.Image, keychain); err != nil {
+				return fmt.Errorf("image %s of container %s: %v", c.Image, c.Name, err)
+			}
+			verified[c.Image] = true
+		}
+	}
+	return nil
+}
+
+func (r *PodSetReconciler) verifyImage(ctx context.Context, verifier *signature.Verifier, image string, keychain registry.Keychain) error {
+	pinned, err := r.registry.Resolve(ctx, image, keychain)
+	if err != nil {
+		return err
+	}
+	signatures, err := r.registry.Signatures(ctx, pinned, keychain)
+	if err != nil {
+		return err
+	}
+	ref, err := registry.ParseReference(pinned)
+	if err != nil {
+		return err
+	}
+	return verifier.Verify(ref.Digest, signatures)
+}
+
+// verificationHash identifies the template together with the policy it is
+// verified with, a new key must verify the template again.
+func verificationHash(template *corev1.PodTemplateSpec, policy *pixiuv1alpha1.ImageVerification) (string, error) {
+	data, err := json.Marshal(struct {
+		Template *corev1.PodTemplateSpec          `json:"template"`
+		Policy   *pixiuv1alpha1.ImageVerification `json:"policy"`
+	}{template, policy})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// setImageVerificationCondition reports in the podSet status why the images
+// of its template can't be trusted.
+func setImageVerificationCondition(newStatus *pixiuv1alpha1.PodSetStatus, err error) {
+	if err == nil {
+		RemovePodSetCondition(newStatus, pixiuv1alpha1.PodSetImageVerificationFailed)
+		return
+	}
+	SetPodSetCondition(newStatus, NewPodSetCondition(pixiuv1alpha1.PodSetImageVerificationFailed, corev1.ConditionTrue, "VerificationFailed", err.Error()))
+}
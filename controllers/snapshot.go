@@ -0,0 +1,77 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/snapshot"
+	"github.com/caoyingjunz/podset-operator/pkg/types"
+)
+
+// syncSnapshot writes the spec of the podSet to the snapshot store when it
+// changed since the last recorded snapshot.
+func (r *PodSetReconciler) syncSnapshot(ctx context.Context, podSet *pixiuv1alpha1.PodSet, newStatus *pixiuv1alpha1.PodSetStatus) {
+	if r.SnapshotStore == nil || newStatus.SnapshotGeneration == podSet.Generation {
+		return
+	}
+
+	if err := snapshot.Take(ctx, r.SnapshotStore, podSet, r.SnapshotHistoryLimit); err != nil {
+		r.Log.Error(err, "failed to take snapshot", "podSet", klog.KObj(podSet))
+		r.Recorder.Eventf(podSet, corev1.EventTypeWarning, "FailedSnapshot", "Failed to take snapshot of generation %d: %v", podSet.Generation, err)
+		return
+	}
+	newStatus.SnapshotGeneration = podSet.Generation
+}
+
+// restoreSnapshot restores the podSet spec from the snapshot store when the
+// restore annotation is set, and reports whether the PodSet was updated.
+func (r *PodSetReconciler) restoreSnapshot(ctx context.Context, podSet *pixiuv1alpha1.PodSet) (bool, error) {
+	value, ok := podSet.Annotations[types.RestoreSnapshotAnnotation]
+	if !ok {
+		return false, nil
+	}
+	if r.SnapshotStore == nil {
+		r.Recorder.Eventf(podSet, corev1.EventTypeWarning, "FailedRestore", "Annotation %s is set but no snapshot store is configured", types.RestoreSnapshotAnnotation)
+		return false, nil
+	}
+
+	var generation int64
+	if len(value) != 0 && value != "latest" {
+		var err error
+		if generation, err = strconv.ParseInt(value, 10, 64); err != nil {
+			r.Recorder.Eventf(podSet, corev1.EventTypeWarning, "FailedRestore", "Invalid %s annotation %q", types.RestoreSnapshotAnnotation, value)
+			return false, fmt.Errorf("invalid %s annotation %q: %v", types.RestoreSnapshotAnnotation, value, err)
+		}
+	}
+
+	if _, err := snapshot.Restore(ctx, r.Client, r.SnapshotStore, client.ObjectKeyFromObject(podSet), generation); err != nil {
+		r.Recorder.Eventf(podSet, corev1.EventTypeWarning, "FailedRestore", "Failed to restore from snapshot: %v", err)
+		return false, err
+	}
+
+	r.Log.Info("Restored pod set from snapshot", "podSet", klog.KObj(podSet), "generation", value)
+	r.Recorder.Eventf(podSet, corev1.EventTypeNormal, "Restored", "Restored spec from snapshot %s", value)
+	return true, nil
+}
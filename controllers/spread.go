@@ -0,0 +1,169 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+// spreadTopologyKeys are the node labels the spread policy spreads the pods
+// over, the widest domain first.
+var spreadTopologyKeys = []string{corev1.LabelTopologyZone, corev1.LabelHostname}
+
+// applySpreadPolicy returns the podSet with the topology spread constraints of
+// spec.spreadPolicy added to the pods of its template. Like
+// applyImagePullSecrets, it returns the podSet itself when there is nothing
+// to add.
+func applySpreadPolicy(podSet *pixiuv1alpha1.PodSet) *pixiuv1alpha1.PodSet {
+	policy := podSet.Spec.SpreadPolicy
+	if policy == nil {
+		return podSet
+	}
+	maxSkew := policy.MaxSkew
+	if maxSkew < 1 {
+		maxSkew = 1
+	}
+	whenUnsatisfiable := policy.WhenUnsatisfiable
+	if len(whenUnsatisfiable) == 0 {
+		whenUnsatisfiable = corev1.ScheduleAnyway
+	}
+
+	var effective *pixiuv1alpha1.PodSet
+	for _, key := range spreadTopologyKeys {
+		if hasSpreadConstraint(podSet.Spec.Template.Spec.TopologySpreadConstraints, key) {
+			continue
+		}
+		if effective == nil {
+			effective = podSet.DeepCopy()
+		}
+		podSpec := &effective.Spec.Template.Spec
+		podSpec.TopologySpreadConstraints = append(podSpec.TopologySpreadConstraints, corev1.TopologySpreadConstraint{
+			MaxSkew:           maxSkew,
+			TopologyKey:       key,
+			WhenUnsatisfiable: whenUnsatisfiable,
+			LabelSelector:     podSet.Spec.Selector.DeepCopy(),
+		})
+	}
+	if effective == nil {
+		return podSet
+	}
+	return effective
+}
+
+// applyNodeAntiAffinity returns the podSet with the pod anti-affinity of
+// spec.spreadAcrossNodes added to the pods of its template. Like
+// applyImagePullSecrets, it returns the podSet itself when there is nothing
+// to add.
+func applyNodeAntiAffinity(podSet *pixiuv1alpha1.PodSet) *pixiuv1alpha1.PodSet {
+	spread := podSet.Spec.SpreadAcrossNodes
+	if spread != pixiuv1alpha1.RequiredNodeSpread && spread != pixiuv1alpha1.PreferredNodeSpread {
+		return podSet
+	}
+
+	effective := podSet.DeepCopy()
+	podSpec := &effective.Spec.Template.Spec
+	if podSpec.Affinity == nil {
+		podSpec.Affinity = &corev1.Affinity{}
+	}
+	if podSpec.Affinity.PodAntiAffinity == nil {
+		podSpec.Affinity.PodAntiAffinity = &corev1.PodAntiAffinity{}
+	}
+	antiAffinity := podSpec.Affinity.PodAntiAffinity
+	term := corev1.PodAffinityTerm{
+		LabelSelector: podSet.Spec.Selector.DeepCopy(),
+		TopologyKey:   corev1.LabelHostname,
+	}
+	if spread == pixiuv1alpha1.RequiredNodeSpread {
+		antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution = append(antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution, term)
+	} else {
+		antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution,
+			corev1.WeightedPodAffinityTerm{Weight: 100, PodAffinityTerm: term})
+	}
+	return effective
+}
+
+func hasSpreadConstraint(constraints []corev1.TopologySpreadConstraint, key string) bool {
+	for _, constraint := range constraints {
+		if constraint.TopologyKey == key {
+			return true
+		}
+	}
+	return false
+}
+
+// topologySpread returns the skew of the scheduled pods over the topology keys
+// of the spread policy. Like the scheduler, the domains are those of the
+// nodes matching the node selector and affinity of the template, including
+// the ones without pods.
+func (r *PodSetReconciler) topologySpread(ctx context.Context, podSet *pixiuv1alpha1.PodSet, pods []*corev1.Pod) ([]pixiuv1alpha1.TopologySpreadStatus, error) {
+	if podSet.Spec.SpreadPolicy == nil {
+		return nil, nil
+	}
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes); err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %v", err)
+	}
+
+	podSpec := &podSet.Spec.Template.Spec
+	nodeSelector := labels.SelectorFromSet(podSpec.NodeSelector)
+	eligible := map[string]*corev1.Node{}
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if nodeSelector.Matches(labels.Set(node.Labels)) && matchesNodeAffinity(node, podSpec.Affinity) {
+			eligible[node.Name] = node
+		}
+	}
+
+	spread := make([]pixiuv1alpha1.TopologySpreadStatus, 0, len(spreadTopologyKeys))
+	for _, key := range spreadTopologyKeys {
+		counts := map[string]int32{}
+		for _, node := range eligible {
+			if domain, ok := node.Labels[key]; ok {
+				counts[domain] = 0
+			}
+		}
+		for _, pod := range pods {
+			if node, ok := eligible[pod.Spec.NodeName]; ok {
+				if domain, ok := node.Labels[key]; ok {
+					counts[domain]++
+				}
+			}
+		}
+
+		status := pixiuv1alpha1.TopologySpreadStatus{TopologyKey: key, Domains: int32(len(counts))}
+		first := true
+		var min, max int32
+		for _, count := range counts {
+			if first || count < min {
+				min = count
+			}
+			if first || count > max {
+				max = count
+			}
+			first = false
+		}
+		status.Skew = max - min
+		spread = append(spread, status)
+	}
+	return spread, nil
+}
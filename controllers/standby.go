@@ -0,0 +1,160 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/types"
+)
+
+// splitStandbyPods returns the pods counted as replicas and the standby pods.
+func splitStandbyPods(pods []*corev1.Pod) ([]*corev1.Pod, []*corev1.Pod) {
+	var replicaPods, standbyPods []*corev1.Pod
+	for _, pod := range pods {
+		if _, ok := pod.Labels[types.StandbyLabel]; ok {
+			standbyPods = append(standbyPods, pod)
+		} else {
+			replicaPods = append(replicaPods, pod)
+		}
+	}
+	return replicaPods, standbyPods
+}
+
+// standbyReplicas returns the number of standby pods the podSet keeps.
+func standbyReplicas(podSet *pixiuv1alpha1.PodSet) int {
+	if podSet.Spec.StandbyReplicas == nil || podSet.Spec.Suspend || hasFixedPodNames(podSet) {
+		return 0
+	}
+	return int(*podSet.Spec.StandbyReplicas)
+}
+
+// promoteStandbyPods promotes up to count standby pods of the current
+// template, the running ones first: their readiness gate is set, and they
+// lose the standby label. It returns the promoted pods and the standby pods
+// left.
+func (r *PodSetReconciler) promoteStandbyPods(ctx context.Context, podSet *pixiuv1alpha1.PodSet, standbyPods []*corev1.Pod, count int, revisionHash string) ([]*corev1.Pod, []*corev1.Pod, error) {
+	var candidates []*corev1.Pod
+	for _, pod := range standbyPods {
+		if pod.Labels[types.PodTemplateHashLabel] == podRevision(podSet, pod, revisionHash) {
+			candidates = append(candidates, pod)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Status.Phase == corev1.PodRunning && candidates[j].Status.Phase != corev1.PodRunning
+	})
+	if len(candidates) > count {
+		candidates = candidates[:count]
+	}
+
+	var promotedPods []*corev1.Pod
+	for _, pod := range candidates {
+		promoted := pod.DeepCopy()
+		if !podConditionTrue(pod, types.PromotedConditionType) {
+			promoted.Status.Conditions = append(promoted.Status.Conditions, corev1.PodCondition{
+				Type:               types.PromotedConditionType,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.Now(),
+			})
+			if err := r.Status().Patch(ctx, promoted, client.StrategicMergeFrom(pod)); err != nil {
+				return promotedPods, remainingPods(standbyPods, promotedPods), fmt.Errorf("failed to promote standby pod %s: %v", pod.Name, err)
+			}
+		}
+		unlabelled := promoted.DeepCopy()
+		delete(unlabelled.Labels, types.StandbyLabel)
+		if err := r.Patch(ctx, unlabelled, client.MergeFrom(promoted)); err != nil {
+			return promotedPods, remainingPods(standbyPods, promotedPods), fmt.Errorf("failed to promote standby pod %s: %v", pod.Name, err)
+		}
+		r.Log.Info("Promoted standby pod", "podSet", klog.KObj(podSet), "pod", pod.Name)
+		r.Recorder.Eventf(podSet, corev1.EventTypeNormal, "PromotedStandbyPod", "Promoted standby pod %s", pod.Name)
+		promotedPods = append(promotedPods, unlabelled)
+	}
+	return promotedPods, remainingPods(standbyPods, promotedPods), nil
+}
+
+// remainingPods returns the pods not removed, by name.
+func remainingPods(pods, removed []*corev1.Pod) []*corev1.Pod {
+	names := sets.NewString()
+	for _, pod := range removed {
+		names.Insert(pod.Name)
+	}
+	var remaining []*corev1.Pod
+	for _, pod := range pods {
+		if !names.Has(pod.Name) {
+			remaining = append(remaining, pod)
+		}
+	}
+	return remaining
+}
+
+// manageStandby keeps spec.standbyReplicas standby pods of the current
+// template, replacing the standby pods of previous templates.
+func (r *PodSetReconciler) manageStandby(ctx context.Context, podSet *pixiuv1alpha1.PodSet, replicaPods, standbyPods []*corev1.Pod, revisionHash string) error {
+	var outdatedPods, currentPods []*corev1.Pod
+	for _, pod := range standbyPods {
+		if pod.Labels[types.PodTemplateHashLabel] == podRevision(podSet, pod, revisionHash) {
+			currentPods = append(currentPods, pod)
+		} else {
+			outdatedPods = append(outdatedPods, pod)
+		}
+	}
+
+	diff := len(currentPods) - standbyReplicas(podSet)
+	if diff > 0 {
+		outdatedPods = append(outdatedPods, getPodsToDelete(podSet, currentPods, diff)...)
+	}
+	if len(outdatedPods) != 0 {
+		r.Log.Info("Deleting standby pods", "podSet", klog.KObj(podSet), "deleting", len(outdatedPods))
+		if err := r.deletePods(ctx, podSet, outdatedPods); err != nil {
+			return err
+		}
+	}
+	if diff >= 0 {
+		return nil
+	}
+
+	count := -diff
+	if burst := r.burstReplicas(podSet); count > burst {
+		count = burst
+	}
+	r.Log.Info("Too few standby pods", "podSet", klog.KObj(podSet), "need", standbyReplicas(podSet), "creating", count)
+	indexes := make(chan int, count)
+	for _, index := range freePodIndexes(append(append([]*corev1.Pod{}, replicaPods...), standbyPods...), count) {
+		indexes <- index
+	}
+	_, err := r.createPodsInBatch(count, SlowStartInitialBatchSize, func() error {
+		template, err := podTemplate(podSet, <-indexes, revisionHash)
+		if err != nil {
+			return err
+		}
+		template.Labels[types.StandbyLabel] = "true"
+		if !hasReadinessGate(template.Spec.ReadinessGates, types.PromotedConditionType) {
+			template.Spec.ReadinessGates = append(template.Spec.ReadinessGates, corev1.PodReadinessGate{ConditionType: types.PromotedConditionType})
+		}
+		return r.createPod(ctx, podSet.Namespace, template, podSet, podSetOwnerReference(podSet))
+	})
+	return err
+}
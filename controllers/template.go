@@ -0,0 +1,131 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/podtemplate"
+	"github.com/caoyingjunz/podset-operator/pkg/types"
+	"github.com/caoyingjunz/podset-operator/pkg/util"
+)
+
+// freePodIndexes returns the count lowest indexes not used by the pods.
+func freePodIndexes(pods []*corev1.Pod, count int) []int {
+	used := sets.NewInt()
+	for _, pod := range pods {
+		if index, err := strconv.Atoi(pod.Annotations[types.PodIndexAnnotation]); err == nil {
+			used.Insert(index)
+		}
+	}
+
+	indexes := make([]int, 0, count)
+	for i := 0; len(indexes) < count; i++ {
+		if !used.Has(i) {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// hasFixedPodNames reports whether the pods are named after their index, with
+// the Ordinal identity policy or a pod name template.
+func hasFixedPodNames(podSet *pixiuv1alpha1.PodSet) bool {
+	return podSet.Spec.IdentityPolicy == pixiuv1alpha1.OrdinalPodIdentity ||
+		(podSet.Spec.PodNamePolicy != nil && len(podSet.Spec.PodNamePolicy.Template) != 0)
+}
+
+// ordinalPodName returns the name of the pod with the given index of a podSet
+// with fixed pod names.
+func ordinalPodName(podSet *pixiuv1alpha1.PodSet, index int) string {
+	if policy := podSet.Spec.PodNamePolicy; policy != nil && len(policy.Template) != 0 {
+		return util.ExpandPodName(policy.Template, podSet.Name, podSet.Namespace, index)
+	}
+	return podHostname(podSet, index)
+}
+
+// podHostname returns the hostname of the pod with the given index.
+func podHostname(podSet *pixiuv1alpha1.PodSet, index int) string {
+	return fmt.Sprintf("%s-%d", podSet.Name, index)
+}
+
+// podLabels returns the labels the pods of the podSet are created with. The
+// CRD schema doesn't preserve the template metadata, so a template without
+// labels falls back to the labels of the selector, like newPod.
+func podLabels(podSet *pixiuv1alpha1.PodSet) map[string]string {
+	source := podSet.Spec.Template.Labels
+	if len(source) == 0 && podSet.Spec.Selector != nil {
+		source = podSet.Spec.Selector.MatchLabels
+	}
+	podLabels := make(map[string]string, len(source))
+	for k, v := range source {
+		podLabels[k] = v
+	}
+	return podLabels
+}
+
+// podTemplate returns the template of the pod with the given index, with the
+// template variables expanded. The template is named after the pod when the
+// pods have fixed names.
+func podTemplate(podSet *pixiuv1alpha1.PodSet, index int, revisionHash string) (*corev1.PodTemplateSpec, error) {
+	template, err := podtemplate.Expand(&podSet.Spec.Template, podtemplate.Values{
+		Index:        index,
+		Name:         podSet.Name,
+		Namespace:    podSet.Namespace,
+		RevisionHash: revisionHash,
+		Parameters:   podSet.Spec.Parameters,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if template.Annotations == nil {
+		template.Annotations = make(map[string]string)
+	}
+	template.Annotations[types.PodIndexAnnotation] = strconv.Itoa(index)
+	if gates := schedulingGates(podSet); len(gates) != 0 {
+		template.Annotations[types.SchedulingGatesAnnotation] = strings.Join(gates, ",")
+	}
+	template.Name = ""
+	template.GenerateName = ""
+	if hasFixedPodNames(podSet) {
+		template.Name = ordinalPodName(podSet, index)
+	} else if policy := podSet.Spec.PodNamePolicy; policy != nil {
+		template.GenerateName = policy.Prefix
+	}
+	if len(podSet.Spec.ServiceName) != 0 {
+		// The hostname follows the index, so the DNS record of a replaced pod
+		// is kept.
+		template.Spec.Hostname = podHostname(podSet, index)
+	}
+	if len(template.Labels) == 0 {
+		template.Labels = podLabels(podSet)
+	}
+	labels, annotations := propagatedMetadata(podSet)
+	setPropagatedMetadata(&template.ObjectMeta, labels, annotations)
+	if override := replicaOverride(podSet, index); override != nil {
+		applyReplicaOverride(&template.Spec, override)
+	}
+	template.Labels[types.PodTemplateHashLabel] = replicaRevision(podSet, index, revisionHash)
+	return template, nil
+}
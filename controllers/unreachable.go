@@ -0,0 +1,111 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/util"
+)
+
+// handleUnreachablePods applies spec.unreachablePodPolicy to the pods of the
+// podSet on nodes that aren't ready. The pods terminating, or whose phase is
+// Unknown, are force deleted once their node has been not ready for
+// forceDeleteAfterSeconds. It returns the active pods to replace right away,
+// and when the next pod is due to be force deleted.
+func (r *PodSetReconciler) handleUnreachablePods(ctx context.Context, podSet *pixiuv1alpha1.PodSet, pods []corev1.Pod, now time.Time) ([]*corev1.Pod, time.Duration, error) {
+	policy := podSet.Spec.UnreachablePodPolicy
+	if policy == nil {
+		return nil, 0, nil
+	}
+
+	notReadySince := map[string]*time.Time{}
+	var unreachablePods []*corev1.Pod
+	var requeueAfter time.Duration
+	var errs []error
+	for i := range pods {
+		pod := &pods[i]
+		if len(pod.Spec.NodeName) == 0 || isPodFinished(pod) || !util.IsOwnedBy(pod, podSet.UID) {
+			continue
+		}
+		since, ok := notReadySince[pod.Spec.NodeName]
+		if !ok {
+			var err error
+			if since, err = r.nodeNotReadySince(ctx, pod.Spec.NodeName); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			notReadySince[pod.Spec.NodeName] = since
+		}
+		if since == nil {
+			continue
+		}
+
+		stuck := pod.DeletionTimestamp != nil || pod.Status.Phase == corev1.PodUnknown
+		if stuck && policy.ForceDeleteAfterSeconds != nil {
+			after := time.Duration(*policy.ForceDeleteAfterSeconds) * time.Second
+			if remaining := since.Add(after).Sub(now); remaining > 0 {
+				requeueAfter = minRequeue(requeueAfter, remaining+time.Second)
+			} else {
+				if err := r.deletePod(ctx, pod, append(podDeleteOptions(podSet), client.GracePeriodSeconds(0))...); err != nil && !apierrors.IsNotFound(err) {
+					r.Recorder.Eventf(podSet, corev1.EventTypeWarning, "FailedDelete", "Error force deleting pod %s: %v", pod.Name, err)
+					errs = append(errs, err)
+					continue
+				}
+				r.Log.Info("Force deleted pod on a node not ready", "podSet", klog.KObj(podSet), "pod", pod.Name, "node", pod.Spec.NodeName)
+				r.Recorder.Eventf(podSet, corev1.EventTypeWarning, "ForceDeletedPod", "Force deleted pod %s, node %s not ready for %v",
+					pod.Name, pod.Spec.NodeName, now.Sub(*since).Round(time.Second))
+				continue
+			}
+		}
+		if policy.ReplaceImmediately && IsPodActive(pod) {
+			unreachablePods = append(unreachablePods, pod)
+		}
+	}
+	return unreachablePods, requeueAfter, utilerrors.NewAggregate(errs)
+}
+
+// nodeNotReadySince returns when the node stopped being ready, or nil when it
+// is ready. A node gone is left to the pod garbage collector.
+func (r *PodSetReconciler) nodeNotReadySince(ctx context.Context, name string) (*time.Time, error) {
+	node := &corev1.Node{}
+	if err := r.Get(ctx, client.ObjectKey{Name: name}, node); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get node %s: %v", name, err)
+	}
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			if cond.Status == corev1.ConditionTrue {
+				return nil, nil
+			}
+			since := cond.LastTransitionTime.Time
+			return &since, nil
+		}
+	}
+	return nil, nil
+}
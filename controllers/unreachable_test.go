@@ -0,0 +1,186 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+func newNode(name string, ready corev1.ConditionStatus, since time.Time) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{
+				Type:               corev1.NodeReady,
+				Status:             ready,
+				LastTransitionTime: metav1.NewTime(since),
+			}},
+		},
+	}
+}
+
+func newNodePod(name, node string, owner k8stypes.UID) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "default",
+			Name:            name,
+			OwnerReferences: []metav1.OwnerReference{{Kind: "PodSet", Name: "web", UID: owner}},
+		},
+		Spec:   corev1.PodSpec{NodeName: node},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+func TestHandleUnreachablePods(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = pixiuv1alpha1.AddToScheme(scheme)
+
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	const uid = k8stypes.UID("web-uid")
+	unknownPod := func(name, node string) *corev1.Pod {
+		pod := newNodePod(name, node, uid)
+		pod.Status.Phase = corev1.PodUnknown
+		return pod
+	}
+
+	tests := []struct {
+		name             string
+		policy           *pixiuv1alpha1.UnreachablePodPolicy
+		nodes            []*corev1.Node
+		pods             []*corev1.Pod
+		wantReplaced     []string
+		wantDeleted      []string
+		wantRequeueAfter time.Duration
+	}{
+		{
+			name:  "no policy",
+			nodes: []*corev1.Node{newNode("n1", corev1.ConditionFalse, now.Add(-time.Hour))},
+			pods:  []*corev1.Pod{newNodePod("web-0", "n1", uid)},
+		},
+		{
+			name:   "node ready",
+			policy: &pixiuv1alpha1.UnreachablePodPolicy{ReplaceImmediately: true},
+			nodes:  []*corev1.Node{newNode("n1", corev1.ConditionTrue, now.Add(-time.Hour))},
+			pods:   []*corev1.Pod{newNodePod("web-0", "n1", uid)},
+		},
+		{
+			name:   "replaced immediately",
+			policy: &pixiuv1alpha1.UnreachablePodPolicy{ReplaceImmediately: true},
+			nodes: []*corev1.Node{
+				newNode("n1", corev1.ConditionTrue, now.Add(-time.Hour)),
+				newNode("n2", corev1.ConditionUnknown, now.Add(-time.Minute)),
+			},
+			pods:         []*corev1.Pod{newNodePod("web-0", "n1", uid), newNodePod("web-1", "n2", uid)},
+			wantReplaced: []string{"web-1"},
+		},
+		{
+			name:   "pods of other owners ignored",
+			policy: &pixiuv1alpha1.UnreachablePodPolicy{ReplaceImmediately: true, ForceDeleteAfterSeconds: pointer.Int32(60)},
+			nodes:  []*corev1.Node{newNode("n1", corev1.ConditionFalse, now.Add(-time.Hour))},
+			pods:   []*corev1.Pod{newNodePod("other-0", "n1", "other-uid")},
+		},
+		{
+			name:        "force deleted",
+			policy:      &pixiuv1alpha1.UnreachablePodPolicy{ReplaceImmediately: true, ForceDeleteAfterSeconds: pointer.Int32(60)},
+			nodes:       []*corev1.Node{newNode("n1", corev1.ConditionFalse, now.Add(-10*time.Minute))},
+			pods:        []*corev1.Pod{unknownPod("web-0", "n1")},
+			wantDeleted: []string{"web-0"},
+		},
+		{
+			name:             "force delete not due",
+			policy:           &pixiuv1alpha1.UnreachablePodPolicy{ForceDeleteAfterSeconds: pointer.Int32(60)},
+			nodes:            []*corev1.Node{newNode("n1", corev1.ConditionFalse, now.Add(-30*time.Second))},
+			pods:             []*corev1.Pod{unknownPod("web-0", "n1")},
+			wantRequeueAfter: 31 * time.Second,
+		},
+		{
+			name:   "node gone",
+			policy: &pixiuv1alpha1.UnreachablePodPolicy{ReplaceImmediately: true, ForceDeleteAfterSeconds: pointer.Int32(0)},
+			pods:   []*corev1.Pod{unknownPod("web-0", "n1")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var objects []client.Object
+			var pods []corev1.Pod
+			for _, node := range tt.nodes {
+				objects = append(objects, node)
+			}
+			for _, pod := range tt.pods {
+				objects = append(objects, pod.DeepCopy())
+				pods = append(pods, *pod)
+			}
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+			r := &PodSetReconciler{Client: c, Log: logr.Discard(), Recorder: record.NewFakeRecorder(10)}
+			podSet := &pixiuv1alpha1.PodSet{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", UID: uid},
+				Spec:       pixiuv1alpha1.PodSetSpec{UnreachablePodPolicy: tt.policy},
+			}
+
+			replaced, requeueAfter, err := r.handleUnreachablePods(context.TODO(), podSet, pods, now)
+			if err != nil {
+				t.Fatalf("handleUnreachablePods() error = %v", err)
+			}
+			var gotReplaced []string
+			for _, pod := range replaced {
+				gotReplaced = append(gotReplaced, pod.Name)
+			}
+			if !reflect.DeepEqual(gotReplaced, tt.wantReplaced) {
+				t.Errorf("handleUnreachablePods() replaced %v, want %v", gotReplaced, tt.wantReplaced)
+			}
+			if requeueAfter != tt.wantRequeueAfter {
+				t.Errorf("handleUnreachablePods() requeueAfter = %v, want %v", requeueAfter, tt.wantRequeueAfter)
+			}
+
+			remaining := &corev1.PodList{}
+			if err = c.List(context.TODO(), remaining); err != nil {
+				t.Fatalf("failed to list pods: %v", err)
+			}
+			left := make(map[string]bool)
+			for _, pod := range remaining.Items {
+				left[pod.Name] = true
+			}
+			var gotDeleted []string
+			for _, pod := range tt.pods {
+				if !left[pod.Name] {
+					gotDeleted = append(gotDeleted, pod.Name)
+				}
+			}
+			if !reflect.DeepEqual(gotDeleted, tt.wantDeleted) {
+				t.Errorf("handleUnreachablePods() deleted %v, want %v", gotDeleted, tt.wantDeleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/util"
+)
+
+// DefaultLiveVerifyDeleteThreshold is the number of pods deleted in a single
+// reconcile above which the decision is verified against the API server.
+const DefaultLiveVerifyDeleteThreshold = 10
+
+// errStaleCache is returned when the cached PodSet is older than the live one.
+var errStaleCache = fmt.Errorf("pod set changed since it was read from the cache")
+
+// verifyScaleDown re-reads the podSet and its pods through the uncached reader
+// before diff pods are deleted, and returns the live active pods owned by the
+// podSet, standby pods aside, and the number of pods that may really be
+// deleted. It fails with errStaleCache when the live PodSet no longer matches
+// the cached one.
+func (r *PodSetReconciler) verifyScaleDown(ctx context.Context, podSet *pixiuv1alpha1.PodSet, replicas int32, diff int) ([]*corev1.Pod, int, error) {
+	live := &pixiuv1alpha1.PodSet{}
+	if err := r.APIReader.Get(ctx, client.ObjectKeyFromObject(podSet), live); err != nil {
+		return nil, 0, err
+	}
+	if live.Generation != podSet.Generation || live.DeletionTimestamp != nil {
+		return nil, 0, errStaleCache
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(live.Spec.Selector)
+	if err != nil {
+		return nil, 0, err
+	}
+	pods := &corev1.PodList{}
+	if err = r.APIReader.List(ctx, pods, &client.ListOptions{Namespace: live.Namespace, LabelSelector: selector}); err != nil {
+		return nil, 0, err
+	}
+	var ownedPods []*corev1.Pod
+	for _, pod := range FilterActivePods(pods.Items) {
+		// The orphans and the pods of other owners matching the selector are
+		// never deleted.
+		if util.IsOwnedBy(pod, podSet.UID) {
+			ownedPods = append(ownedPods, pod)
+		}
+	}
+	// The standby pods are not replicas, they are managed on their own.
+	livePods, _ := splitStandbyPods(ownedPods)
+
+	if liveDiff := len(livePods) - int(replicas); liveDiff < diff {
+		diff = liveDiff
+	}
+	if diff < 0 {
+		diff = 0
+	}
+	return livePods, diff, nil
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	pixiutypes "github.com/caoyingjunz/podset-operator/pkg/types"
+)
+
+func TestVerifyScaleDown(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = pixiuv1alpha1.AddToScheme(scheme)
+
+	podSet := &pixiuv1alpha1.PodSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", UID: "web-uid", Generation: 2},
+		Spec: pixiuv1alpha1.PodSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+		},
+	}
+	newPod := func(name string, owner types.UID) *corev1.Pod {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      name,
+			Labels:    map[string]string{"app": "web"},
+		}}
+		if len(owner) != 0 {
+			pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "v1", Kind: "Owner", Name: "owner", UID: owner}}
+		}
+		return pod
+	}
+	newStandbyPod := func(name string, owner types.UID) *corev1.Pod {
+		pod := newPod(name, owner)
+		pod.Labels[pixiutypes.StandbyLabel] = "true"
+		return pod
+	}
+
+	tests := []struct {
+		name           string
+		liveGeneration int64
+		pods           []client.Object
+		replicas       int32
+		diff           int
+		wantPods       []string
+		wantDiff       int
+		wantErr        error
+	}{
+		{
+			name:     "owned pods",
+			pods:     []client.Object{newPod("web-1", "web-uid"), newPod("web-2", "web-uid"), newPod("web-3", "web-uid")},
+			replicas: 1,
+			diff:     2,
+			wantPods: []string{"web-1", "web-2", "web-3"},
+			wantDiff: 2,
+		},
+		{
+			name: "foreign and orphan pods matching the selector",
+			pods: []client.Object{
+				newPod("web-1", "web-uid"), newPod("web-2", "web-uid"), newPod("web-3", "web-uid"),
+				newPod("other", "other-uid"), newPod("orphan", ""),
+			},
+			replicas: 1,
+			diff:     4,
+			wantPods: []string{"web-1", "web-2", "web-3"},
+			wantDiff: 2,
+		},
+		{
+			name: "standby pods",
+			pods: []client.Object{
+				newPod("web-1", "web-uid"), newPod("web-2", "web-uid"), newStandbyPod("standby", "web-uid"),
+			},
+			replicas: 1,
+			diff:     2,
+			wantPods: []string{"web-1", "web-2"},
+			wantDiff: 1,
+		},
+		{
+			name:     "fewer live pods than cached",
+			pods:     []client.Object{newPod("web-1", "web-uid"), newPod("other", "other-uid")},
+			replicas: 1,
+			diff:     2,
+			wantPods: []string{"web-1"},
+			wantDiff: 0,
+		},
+		{
+			name:           "stale cache",
+			liveGeneration: 3,
+			pods:           []client.Object{newPod("web-1", "web-uid"), newPod("web-2", "web-uid")},
+			replicas:       1,
+			diff:           1,
+			wantErr:        errStaleCache,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			live := podSet.DeepCopy()
+			if tt.liveGeneration != 0 {
+				live.Generation = tt.liveGeneration
+			}
+			r := &PodSetReconciler{
+				APIReader: fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(tt.pods, live)...).Build(),
+			}
+
+			pods, diff, err := r.verifyScaleDown(context.TODO(), podSet, tt.replicas, tt.diff)
+			if err != tt.wantErr {
+				t.Fatalf("verifyScaleDown() error = %v, want %v", err, tt.wantErr)
+			}
+			if diff != tt.wantDiff {
+				t.Errorf("verifyScaleDown() diff = %d, want %d", diff, tt.wantDiff)
+			}
+			var names []string
+			for _, pod := range pods {
+				names = append(names, pod.Name)
+			}
+			if len(names) != len(tt.wantPods) {
+				t.Fatalf("verifyScaleDown() pods = %v, want %v", names, tt.wantPods)
+			}
+			for i := range names {
+				if names[i] != tt.wantPods[i] {
+					t.Errorf("verifyScaleDown() pods = %v, want %v", names, tt.wantPods)
+					break
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sort"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+// podRanker orders the pods in place, the pods deleted first coming first.
+type podRanker func(pods []*corev1.Pod)
+
+// podRankers are the rankers of the scale-down policies.
+var podRankers = map[pixiuv1alpha1.ScaleDownPolicyType]podRanker{
+	pixiuv1alpha1.NewestScaleDownPolicy: func(pods []*corev1.Pod) {
+		sort.SliceStable(pods, func(i, j int) bool { return newerPod(pods[i], pods[j]) })
+	},
+	pixiuv1alpha1.OldestScaleDownPolicy: func(pods []*corev1.Pod) {
+		sort.SliceStable(pods, func(i, j int) bool { return newerPod(pods[j], pods[i]) })
+	},
+	pixiuv1alpha1.RandomScaleDownPolicy: func(pods []*corev1.Pod) {
+		for i := len(pods) - 1; i > 0; i-- {
+			j := rand.Intn(i + 1)
+			pods[i], pods[j] = pods[j], pods[i]
+		}
+	},
+	pixiuv1alpha1.LeastReadyScaleDownPolicy: func(pods []*corev1.Pod) {
+		ranks := colocationRanks(pods)
+		sort.SliceStable(pods, func(i, j int) bool { return lessActive(pods[i], pods[j], ranks) })
+	},
+}
+
+// colocationRanks returns the number of pods on the node of each pod, by pod
+// name, like the ActivePodsWithRanks of ReplicaSets.
+func colocationRanks(pods []*corev1.Pod) map[string]int {
+	podsOnNode := map[string]int{}
+	for _, pod := range pods {
+		if len(pod.Spec.NodeName) != 0 {
+			podsOnNode[pod.Spec.NodeName]++
+		}
+	}
+	ranks := make(map[string]int, len(pods))
+	for _, pod := range pods {
+		ranks[pod.Name] = podsOnNode[pod.Spec.NodeName]
+	}
+	return ranks
+}
+
+// podPhaseOrder orders the phases of the pods from the first deleted.
+var podPhaseOrder = map[corev1.PodPhase]int{corev1.PodPending: 0, corev1.PodUnknown: 1, corev1.PodRunning: 2}
+
+// lessActive reports whether pod a is less active than pod b, following the
+// ActivePodsWithRanks ordering of ReplicaSets: unscheduled first, then
+// pending, then not ready, then sharing a node with more pods of the podSet,
+// then ready for a shorter time, then restarted more often, then newer.
+func lessActive(a, b *corev1.Pod, ranks map[string]int) bool {
+	if (len(a.Spec.NodeName) == 0) != (len(b.Spec.NodeName) == 0) {
+		return len(a.Spec.NodeName) == 0
+	}
+	if podPhaseOrder[a.Status.Phase] != podPhaseOrder[b.Status.Phase] {
+		return podPhaseOrder[a.Status.Phase] < podPhaseOrder[b.Status.Phase]
+	}
+	if aReady, bReady := IsPodReady(a), IsPodReady(b); aReady != bReady {
+		return !aReady
+	}
+	if ranks[a.Name] != ranks[b.Name] {
+		return ranks[a.Name] > ranks[b.Name]
+	}
+	if IsPodReady(a) {
+		aSince, bSince := GetPodReadyCondition(a.Status).LastTransitionTime, GetPodReadyCondition(b.Status).LastTransitionTime
+		if !aSince.Equal(&bSince) {
+			return bSince.Before(&aSince)
+		}
+	}
+	if aRestarts, bRestarts := maxContainerRestarts(a), maxContainerRestarts(b); aRestarts != bRestarts {
+		return aRestarts > bRestarts
+	}
+	return newerPod(a, b)
+}
+
+func maxContainerRestarts(pod *corev1.Pod) int32 {
+	var restarts int32
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.RestartCount > restarts {
+			restarts = status.RestartCount
+		}
+	}
+	return restarts
+}
+
+// getPodsToDelete returns the diff pods to delete first: the pods with the
+// lowest controller.kubernetes.io/pod-deletion-cost, as for ReplicaSets, then
+// following the scale-down policy of the podSet.
+func getPodsToDelete(podSet *pixiuv1alpha1.PodSet, filteredPods []*corev1.Pod, diff int) []*corev1.Pod {
+	ranker, ok := podRankers[podSet.Spec.ScaleDownPolicy]
+	if !ok {
+		ranker = podRankers[pixiuv1alpha1.LeastReadyScaleDownPolicy]
+	}
+	pods := append([]*corev1.Pod{}, filteredPods...)
+	ranker(pods)
+	sort.SliceStable(pods, func(i, j int) bool { return podDeletionCost(pods[i]) < podDeletionCost(pods[j]) })
+	return pods[:diff]
+}
+
+// podDeletionCost returns the deletion cost of the pod, 0 when the annotation
+// is missing or invalid.
+func podDeletionCost(pod *corev1.Pod) int32 {
+	value, ok := pod.Annotations[corev1.PodDeletionCost]
+	if !ok {
+		return 0
+	}
+	cost, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return 0
+	}
+	return int32(cost)
+}
+
+// newerPod reports whether pod a was created after pod b.
+func newerPod(a, b *corev1.Pod) bool {
+	if a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.Name > b.Name
+	}
+	return b.CreationTimestamp.Before(&a.CreationTimestamp)
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+type testPod struct {
+	name       string
+	node       string
+	phase      corev1.PodPhase
+	readySince time.Duration
+	restarts   int32
+	created    time.Duration
+	cost       string
+}
+
+func newTestPod(p testPod, now time.Time) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              p.name,
+			CreationTimestamp: metav1.NewTime(now.Add(-p.created)),
+		},
+		Spec: corev1.PodSpec{NodeName: p.node},
+		Status: corev1.PodStatus{
+			Phase:             p.phase,
+			ContainerStatuses: []corev1.ContainerStatus{{RestartCount: p.restarts}},
+		},
+	}
+	if p.readySince != 0 {
+		pod.Status.Conditions = []corev1.PodCondition{{
+			Type:               corev1.PodReady,
+			Status:             corev1.ConditionTrue,
+			LastTransitionTime: metav1.NewTime(now.Add(-p.readySince)),
+		}}
+	}
+	if len(p.cost) != 0 {
+		pod.Annotations = map[string]string{corev1.PodDeletionCost: p.cost}
+	}
+	return pod
+}
+
+func TestGetPodsToDelete(t *testing.T) {
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		policy pixiuv1alpha1.ScaleDownPolicyType
+		pods   []testPod
+		diff   int
+		want   []string
+	}{
+		{
+			name: "unscheduled before scheduled",
+			pods: []testPod{
+				{name: "running", node: "n1", phase: corev1.PodRunning, readySince: time.Hour},
+				{name: "unscheduled", phase: corev1.PodPending},
+			},
+			diff: 1,
+			want: []string{"unscheduled"},
+		},
+		{
+			name: "pending before running",
+			pods: []testPod{
+				{name: "running", node: "n1", phase: corev1.PodRunning},
+				{name: "pending", node: "n2", phase: corev1.PodPending},
+			},
+			diff: 1,
+			want: []string{"pending"},
+		},
+		{
+			name: "not ready before ready",
+			pods: []testPod{
+				{name: "ready", node: "n1", phase: corev1.PodRunning, readySince: time.Hour},
+				{name: "not-ready", node: "n2", phase: corev1.PodRunning},
+			},
+			diff: 1,
+			want: []string{"not-ready"},
+		},
+		{
+			name: "crowded node first",
+			pods: []testPod{
+				{name: "alone", node: "n1", phase: corev1.PodRunning, readySince: time.Hour},
+				{name: "shared-1", node: "n2", phase: corev1.PodRunning, readySince: time.Hour, created: time.Minute},
+				{name: "shared-2", node: "n2", phase: corev1.PodRunning, readySince: time.Hour, created: 2 * time.Minute},
+			},
+			diff: 2,
+			want: []string{"shared-1", "shared-2"},
+		},
+		{
+			name: "ready for a shorter time first",
+			pods: []testPod{
+				{name: "old", node: "n1", phase: corev1.PodRunning, readySince: time.Hour},
+				{name: "recent", node: "n2", phase: corev1.PodRunning, readySince: time.Minute},
+			},
+			diff: 1,
+			want: []string{"recent"},
+		},
+		{
+			name: "as ready from the newest",
+			pods: []testPod{
+				{name: "old", node: "n1", phase: corev1.PodRunning, readySince: time.Hour, created: 2 * time.Hour},
+				{name: "new", node: "n2", phase: corev1.PodRunning, readySince: time.Hour, created: time.Hour},
+			},
+			diff: 1,
+			want: []string{"new"},
+		},
+		{
+			name: "more restarts first",
+			pods: []testPod{
+				{name: "stable", node: "n1", phase: corev1.PodRunning, readySince: time.Hour},
+				{name: "restarting", node: "n2", phase: corev1.PodRunning, readySince: time.Hour, restarts: 3},
+			},
+			diff: 1,
+			want: []string{"restarting"},
+		},
+		{
+			name: "lowest deletion cost first",
+			pods: []testPod{
+				{name: "unscheduled", phase: corev1.PodPending, cost: "10"},
+				{name: "cheap", node: "n1", phase: corev1.PodRunning, readySince: time.Hour, cost: "-5"},
+				{name: "invalid-cost", node: "n2", phase: corev1.PodRunning, readySince: time.Hour, cost: "high"},
+			},
+			diff: 2,
+			want: []string{"cheap", "invalid-cost"},
+		},
+		{
+			name:   "newest",
+			policy: pixiuv1alpha1.NewestScaleDownPolicy,
+			pods: []testPod{
+				{name: "old", created: time.Hour},
+				{name: "new", created: time.Minute},
+				{name: "middle", created: 10 * time.Minute},
+			},
+			diff: 2,
+			want: []string{"new", "middle"},
+		},
+		{
+			name:   "oldest",
+			policy: pixiuv1alpha1.OldestScaleDownPolicy,
+			pods: []testPod{
+				{name: "old", created: time.Hour},
+				{name: "new", created: time.Minute},
+				{name: "middle", created: 10 * time.Minute},
+			},
+			diff: 2,
+			want: []string{"old", "middle"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pods := make([]*corev1.Pod, 0, len(tt.pods))
+			for _, p := range tt.pods {
+				pods = append(pods, newTestPod(p, now))
+			}
+			podSet := &pixiuv1alpha1.PodSet{Spec: pixiuv1alpha1.PodSetSpec{ScaleDownPolicy: tt.policy}}
+
+			victims := getPodsToDelete(podSet, pods, tt.diff)
+			got := make([]string, 0, len(victims))
+			for _, pod := range victims {
+				got = append(got, pod.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getPodsToDelete() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPodsToDeleteRandom(t *testing.T) {
+	now := time.Now()
+	pods := []*corev1.Pod{
+		newTestPod(testPod{name: "a"}, now),
+		newTestPod(testPod{name: "b"}, now),
+		newTestPod(testPod{name: "c", cost: "-1"}, now),
+	}
+	podSet := &pixiuv1alpha1.PodSet{Spec: pixiuv1alpha1.PodSetSpec{ScaleDownPolicy: pixiuv1alpha1.RandomScaleDownPolicy}}
+
+	// The deletion cost still goes first, and the pods given are left in order.
+	if victims := getPodsToDelete(podSet, pods, 1); victims[0].Name != "c" {
+		t.Errorf("getPodsToDelete() = %s, want c", victims[0].Name)
+	}
+	if pods[0].Name != "a" || pods[1].Name != "b" || pods[2].Name != "c" {
+		t.Errorf("getPodsToDelete() reordered the pods given")
+	}
+}
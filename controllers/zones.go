@@ -0,0 +1,168 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+// distributeReplicas returns the replicas of every zone of the distribution.
+// The zones with a number of replicas get them first, up to the replicas, and
+// the rest is shared by weight, the largest remainders getting the replicas
+// left by the rounding. Without a weight to share them by, the rest is spread
+// over the zones in turn, in their order.
+func distributeReplicas(zones []pixiuv1alpha1.ZoneReplicas, replicas int32) map[string]int {
+	shares := make(map[string]int, len(zones))
+	remaining := int(replicas)
+	var weighted []pixiuv1alpha1.ZoneReplicas
+	totalWeight := 0
+	for _, zone := range zones {
+		if zone.Replicas == nil {
+			weighted = append(weighted, zone)
+			totalWeight += zoneWeight(zone)
+			continue
+		}
+		share := int(*zone.Replicas)
+		if share > remaining {
+			share = remaining
+		}
+		shares[zone.Zone] = share
+		remaining -= share
+	}
+	if totalWeight == 0 {
+		for i := 0; i < remaining && len(zones) != 0; i++ {
+			shares[zones[i%len(zones)].Zone]++
+		}
+		return shares
+	}
+
+	type remainder struct {
+		zone  string
+		value int
+	}
+	remainders := make([]remainder, 0, len(weighted))
+	distributed := 0
+	for _, zone := range weighted {
+		share := remaining * zoneWeight(zone) / totalWeight
+		shares[zone.Zone] = share
+		distributed += share
+		remainders = append(remainders, remainder{zone: zone.Zone, value: remaining * zoneWeight(zone) % totalWeight})
+	}
+	sort.SliceStable(remainders, func(i, j int) bool { return remainders[i].value > remainders[j].value })
+	for i := 0; i < remaining-distributed; i++ {
+		shares[remainders[i].zone]++
+	}
+	return shares
+}
+
+func zoneWeight(zone pixiuv1alpha1.ZoneReplicas) int {
+	if zone.Weight == nil {
+		return 1
+	}
+	return int(*zone.Weight)
+}
+
+// zonePlan is the share of the replicas of every zone of a podSet, and the
+// zone of the nodes to group its pods by.
+type zonePlan struct {
+	shares    map[string]int
+	nodeZones map[string]string
+}
+
+// planZones distributes the replicas of the podSet over its zones.
+func (r *PodSetReconciler) planZones(ctx context.Context, podSet *pixiuv1alpha1.PodSet, replicas int32) (*zonePlan, error) {
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes); err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %v", err)
+	}
+	nodeZones := make(map[string]string, len(nodes.Items))
+	for _, node := range nodes.Items {
+		nodeZones[node.Name] = node.Labels[corev1.LabelTopologyZone]
+	}
+	return &zonePlan{
+		shares:    distributeReplicas(podSet.Spec.ZoneDistribution, replicas),
+		nodeZones: nodeZones,
+	}, nil
+}
+
+// podsByZone groups the pods by zone: the zone of their node once they are
+// scheduled, or the zone of their node selector.
+func (p *zonePlan) podsByZone(pods []*corev1.Pod) map[string][]*corev1.Pod {
+	zones := map[string][]*corev1.Pod{}
+	for _, pod := range pods {
+		zone := pod.Spec.NodeSelector[corev1.LabelTopologyZone]
+		if len(pod.Spec.NodeName) != 0 {
+			zone = p.nodeZones[pod.Spec.NodeName]
+		}
+		zones[zone] = append(zones[zone], pod)
+	}
+	return zones
+}
+
+// mostMissing returns the zone of the distribution missing the most replicas,
+// the first one on a tie, and the number of replicas it misses.
+func (p *zonePlan) mostMissing(podSet *pixiuv1alpha1.PodSet, pods []*corev1.Pod) (string, int) {
+	zones := p.podsByZone(pods)
+	missingZone, missing := "", 0
+	for _, zone := range podSet.Spec.ZoneDistribution {
+		if diff := p.shares[zone.Zone] - len(zones[zone.Zone]); diff > missing {
+			missingZone, missing = zone.Zone, diff
+		}
+	}
+	return missingZone, missing
+}
+
+// excessPods returns the pods above the share of their zone, the pods outside
+// of the distribution included, picked in every zone as they are on scale down.
+func (p *zonePlan) excessPods(podSet *pixiuv1alpha1.PodSet, pods []*corev1.Pod) []*corev1.Pod {
+	zones := p.podsByZone(pods)
+	names := make([]string, 0, len(zones))
+	for zone := range zones {
+		names = append(names, zone)
+	}
+	sort.Strings(names)
+
+	var excessPods []*corev1.Pod
+	for _, zone := range names {
+		zonePods := zones[zone]
+		excess := len(zonePods) - p.shares[zone]
+		switch {
+		case excess <= 0:
+		case isOrderedReady(podSet) || hasFixedPodNames(podSet):
+			excessPods = append(excessPods, highestIndexPods(zonePods, excess)...)
+		default:
+			excessPods = append(excessPods, getPodsToDelete(podSet, zonePods, excess)...)
+		}
+	}
+	return excessPods
+}
+
+// zonedPodSet returns a copy of the podSet creating its pods in the zone.
+func zonedPodSet(podSet *pixiuv1alpha1.PodSet, zone string) *pixiuv1alpha1.PodSet {
+	zoned := podSet.DeepCopy()
+	if zoned.Spec.Template.Spec.NodeSelector == nil {
+		zoned.Spec.Template.Spec.NodeSelector = make(map[string]string)
+	}
+	zoned.Spec.Template.Spec.NodeSelector[corev1.LabelTopologyZone] = zone
+	return zoned
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/types"
+)
+
+func TestDistributeReplicas(t *testing.T) {
+	tests := []struct {
+		name     string
+		zones    []pixiuv1alpha1.ZoneReplicas
+		replicas int32
+		want     map[string]int
+	}{
+		{
+			name:     "even weights",
+			zones:    []pixiuv1alpha1.ZoneReplicas{{Zone: "a"}, {Zone: "b"}, {Zone: "c"}},
+			replicas: 7,
+			want:     map[string]int{"a": 3, "b": 2, "c": 2},
+		},
+		{
+			name: "largest remainders first",
+			zones: []pixiuv1alpha1.ZoneReplicas{
+				{Zone: "a", Weight: pointer.Int32(1)},
+				{Zone: "b", Weight: pointer.Int32(3)},
+			},
+			replicas: 5,
+			want:     map[string]int{"a": 1, "b": 4},
+		},
+		{
+			name: "fixed replicas first",
+			zones: []pixiuv1alpha1.ZoneReplicas{
+				{Zone: "a", Replicas: pointer.Int32(4)},
+				{Zone: "b"},
+				{Zone: "c"},
+			},
+			replicas: 6,
+			want:     map[string]int{"a": 4, "b": 1, "c": 1},
+		},
+		{
+			name: "fixed replicas above the replicas",
+			zones: []pixiuv1alpha1.ZoneReplicas{
+				{Zone: "a", Replicas: pointer.Int32(4)},
+				{Zone: "b", Replicas: pointer.Int32(4)},
+				{Zone: "c"},
+			},
+			replicas: 6,
+			want:     map[string]int{"a": 4, "b": 2, "c": 0},
+		},
+		{
+			name: "fixed replicas below the replicas",
+			zones: []pixiuv1alpha1.ZoneReplicas{
+				{Zone: "a", Replicas: pointer.Int32(1)},
+				{Zone: "b", Replicas: pointer.Int32(1)},
+			},
+			replicas: 5,
+			want:     map[string]int{"a": 3, "b": 2},
+		},
+		{
+			name: "zero weights",
+			zones: []pixiuv1alpha1.ZoneReplicas{
+				{Zone: "a", Replicas: pointer.Int32(1)},
+				{Zone: "b", Weight: pointer.Int32(0)},
+			},
+			replicas: 4,
+			want:     map[string]int{"a": 3, "b": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := distributeReplicas(tt.zones, tt.replicas)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("distributeReplicas() = %v, want %v", got, tt.want)
+			}
+			total := 0
+			for _, share := range got {
+				total += share
+			}
+			if total != int(tt.replicas) {
+				t.Errorf("distributeReplicas() distributed %d replicas, want %d", total, tt.replicas)
+			}
+		})
+	}
+}
+
+func TestZonePlan(t *testing.T) {
+	newZonedPod := func(name, node, zone string) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Annotations: map[string]string{types.PodIndexAnnotation: strings.TrimPrefix(name, "web-")},
+			},
+			Spec: corev1.PodSpec{NodeName: node},
+		}
+		if len(zone) != 0 {
+			pod.Spec.NodeSelector = map[string]string{corev1.LabelTopologyZone: zone}
+		}
+		return pod
+	}
+	plan := &zonePlan{
+		shares:    map[string]int{"a": 1, "b": 2},
+		nodeZones: map[string]string{"node-a": "a", "node-b": "b"},
+	}
+
+	tests := []struct {
+		name        string
+		podSet      *pixiuv1alpha1.PodSet
+		pods        []*corev1.Pod
+		wantZone    string
+		wantMissing int
+		wantExcess  []string
+	}{
+		{
+			name: "zone missing the most",
+			pods: []*corev1.Pod{
+				newZonedPod("web-1", "node-a", ""),
+				newZonedPod("web-2", "node-a", ""),
+			},
+			wantZone:    "b",
+			wantMissing: 2,
+			wantExcess:  []string{"web-2"},
+		},
+		{
+			name: "pending pods in the zone of their node selector",
+			pods: []*corev1.Pod{
+				newZonedPod("web-1", "node-a", ""),
+				newZonedPod("web-2", "", "b"),
+				newZonedPod("web-3", "", "b"),
+			},
+		},
+		{
+			name: "pods outside of the distribution",
+			pods: []*corev1.Pod{
+				newZonedPod("web-1", "node-a", ""),
+				newZonedPod("web-2", "node-b", ""),
+				newZonedPod("web-3", "node-b", ""),
+				newZonedPod("web-4", "", ""),
+				newZonedPod("web-5", "", "c"),
+			},
+			wantExcess: []string{"web-4", "web-5"},
+		},
+		{
+			name: "highest indexes with fixed pod names",
+			podSet: &pixiuv1alpha1.PodSet{Spec: pixiuv1alpha1.PodSetSpec{
+				IdentityPolicy: pixiuv1alpha1.OrdinalPodIdentity,
+			}},
+			pods: []*corev1.Pod{
+				newZonedPod("web-1", "node-b", ""),
+				newZonedPod("web-0", "node-b", ""),
+				newZonedPod("web-2", "node-b", ""),
+				newZonedPod("web-3", "node-a", ""),
+			},
+			wantExcess: []string{"web-2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			podSet := tt.podSet
+			if podSet == nil {
+				podSet = &pixiuv1alpha1.PodSet{}
+			}
+			podSet.Spec.ZoneDistribution = []pixiuv1alpha1.ZoneReplicas{{Zone: "a"}, {Zone: "b"}}
+
+			zone, missing := plan.mostMissing(podSet, tt.pods)
+			if zone != tt.wantZone || missing != tt.wantMissing {
+				t.Errorf("mostMissing() = %q, %d, want %q, %d", zone, missing, tt.wantZone, tt.wantMissing)
+			}
+			var excess []string
+			for _, pod := range plan.excessPods(podSet, tt.pods) {
+				excess = append(excess, pod.Name)
+			}
+			sort.Strings(excess)
+			if !reflect.DeepEqual(excess, tt.wantExcess) {
+				t.Errorf("excessPods() = %v, want %v", excess, tt.wantExcess)
+			}
+		})
+	}
+}
@@ -17,22 +17,41 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	uberzap "go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
 	"github.com/caoyingjunz/podset-operator/controllers"
+	"github.com/caoyingjunz/podset-operator/pkg/config"
+	"github.com/caoyingjunz/podset-operator/pkg/events"
+	"github.com/caoyingjunz/podset-operator/pkg/metrics"
+	"github.com/caoyingjunz/podset-operator/pkg/signature"
+	"github.com/caoyingjunz/podset-operator/pkg/snapshot"
+	"github.com/caoyingjunz/podset-operator/pkg/types"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -50,40 +69,370 @@ func init() {
 
 func main() {
 	var metricsAddr string
+	var metricsSecure, metricsAuth bool
+	var metricsCertFile, metricsKeyFile string
 	var enableLeaderElection bool
+	var leaderElectionID, leaderElectionNamespace string
+	var leaseDuration, renewDeadline, retryPeriod time.Duration
 	var probeAddr string
+	var createFailureThreshold int
+	var createCircuitCooldown time.Duration
+	var failureBackoffBase, failureBackoffMax time.Duration
+	var prometheusAddress string
+	var liveVerifyDeleteThreshold int
+	var reconcileTimeout time.Duration
+	var burstReplicas int
+	var podMetadataOnly bool
+	var maxConcurrentReconciles int
+	var watchNamespaces string
+	var kubeAPIQPS float64
+	var kubeAPIBurst int
+	var kubeAPITimeout time.Duration
+	var eventSink string
+	var eventQPS, eventNormalQPS float64
+	var eventBurst, eventNormalBurst int
+	var configFile string
+	var shutdownDrainTimeout time.Duration
+	var snapshotStore string
+	var snapshotNamespace string
+	var snapshotHistoryLimit int
+	var s3Endpoint, s3Bucket, s3Region, s3Prefix string
+	var fulcioRootsFile, rekorKeysFile string
+	var preDeleteHookHosts string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
+	flag.BoolVar(&metricsSecure, "metrics-secure", false,
+		"Serve the metrics endpoint over TLS instead of plain HTTP.")
+	flag.BoolVar(&metricsAuth, "metrics-auth", true,
+		"Authenticate and authorize metrics requests against the API server when --metrics-secure is set.")
+	flag.StringVar(&metricsCertFile, "metrics-tls-cert-file", "",
+		"The certificate of the secure metrics endpoint. A self-signed certificate is used when empty.")
+	flag.StringVar(&metricsKeyFile, "metrics-tls-key-file", "", "The key of --metrics-tls-cert-file.")
+	flag.StringVar(&configFile, "config", "",
+		"A YAML file with settings that override their flags and are reloaded when the file changes, "+
+			"e.g. a mounted ConfigMap. See config/manager/operator_config.yaml.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&leaderElectionID, "leader-elect-id", "98aadc68.pixiu.io",
+		"The name of the lease the leader holds. Operators sharing it elect a single leader.")
+	flag.StringVar(&leaderElectionNamespace, "leader-elect-namespace", "",
+		"The namespace of the leader lease. Defaults to the namespace the controller runs in.")
+	flag.DurationVar(&leaseDuration, "leader-elect-lease-duration", 15*time.Second,
+		"How long the other replicas wait before taking over the lease of a leader that stopped renewing it.")
+	flag.DurationVar(&renewDeadline, "leader-elect-renew-deadline", 10*time.Second,
+		"How long the leader retries renewing its lease before it steps down. Keep it below the lease duration.")
+	flag.DurationVar(&retryPeriod, "leader-elect-retry-period", 2*time.Second,
+		"How often the replicas try to acquire or renew the lease.")
+	flag.DurationVar(&shutdownDrainTimeout, "shutdown-drain-timeout", controllers.DefaultShutdownDrainTimeout,
+		"How long in-flight reconciles may run after a termination signal before they are cancelled. "+
+			"The leader lease is released once they are done. Keep it below terminationGracePeriodSeconds.")
+	flag.StringVar(&watchNamespaces, "watch-namespaces", "",
+		"A comma separated list of namespaces the controller is restricted to, so that it only needs namespaced "+
+			"RBAC. The ClusterPodSet controller is disabled in this mode. Defaults to all namespaces.")
+	flag.Float64Var(&kubeAPIQPS, "kube-api-qps", 50,
+		"The maximum queries per second from the controller to the API server.")
+	flag.IntVar(&kubeAPIBurst, "kube-api-burst", 100,
+		"The maximum burst of queries from the controller to the API server.")
+	flag.DurationVar(&kubeAPITimeout, "kube-api-timeout", 0,
+		"The timeout of a single request to the API server. 0 means no timeout.")
+	flag.StringVar(&eventSink, "event-sink", events.SinkAPI,
+		"Where events are recorded, one of api, log or none.")
+	flag.Float64Var(&eventQPS, "event-qps", 0,
+		"The events per second recorded for a single object. Defaults to the client-go spam filter.")
+	flag.IntVar(&eventBurst, "event-burst", 0,
+		"The burst of events recorded for a single object. Defaults to the client-go spam filter.")
+	flag.Float64Var(&eventNormalQPS, "event-normal-qps", 0,
+		"The Normal events per second recorded by a controller, the others are dropped. Warning events are never dropped. 0 means no limit.")
+	flag.IntVar(&eventNormalBurst, "event-normal-burst", 100,
+		"The burst of Normal events recorded by a controller when --event-normal-qps is set.")
+	flag.IntVar(&createFailureThreshold, "create-failure-threshold", controllers.DefaultCreateFailureThreshold,
+		"The number of consecutive failed pod creations after which creation is suspended for a PodSet.")
+	flag.DurationVar(&createCircuitCooldown, "create-circuit-cooldown", controllers.DefaultCreateCircuitCooldown,
+		"How long pod creation stays suspended for a PodSet after repeated failures.")
+	flag.DurationVar(&failureBackoffBase, "failure-backoff-base", controllers.DefaultFailureBackoffBase,
+		"The initial retry delay for a PodSet whose reconcile failed.")
+	flag.DurationVar(&failureBackoffMax, "failure-backoff-max", controllers.DefaultFailureBackoffMax,
+		"The maximum retry delay for a PodSet whose reconciles keep failing.")
+	flag.StringVar(&prometheusAddress, "prometheus-address", "",
+		"The default Prometheus server used by PodSets whose replicas come from a Prometheus query.")
+	flag.IntVar(&liveVerifyDeleteThreshold, "live-verify-delete-threshold", controllers.DefaultLiveVerifyDeleteThreshold,
+		"Scale downs deleting more pods than this in one reconcile are verified against the API server first. 0 disables the verification.")
+	flag.DurationVar(&reconcileTimeout, "reconcile-timeout", controllers.DefaultReconcileTimeout,
+		"The deadline of a reconcile of a PodSet, unless overridden by its spec.reconcileTimeout. 0 disables the deadline.")
+	flag.IntVar(&burstReplicas, "burst-replicas", types.BurstReplicas,
+		"The maximum number of pods of a PodSet created or deleted in one reconcile, unless overridden by its spec.burstReplicas.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1,
+		"The number of PodSets reconciled at once. A PodSet is never reconciled by two workers at a time.")
+	flag.BoolVar(&podMetadataOnly, "pod-metadata-only", false,
+		"Cache only the metadata of pods and read the pods of a PodSet from the API server when it is reconciled, "+
+			"to reduce memory in clusters with many pods that don't belong to PodSets.")
+	flag.StringVar(&snapshotStore, "snapshot-store", "",
+		"Where to write PodSet spec snapshots, one of configmap or s3. Snapshots are disabled when empty.")
+	flag.StringVar(&snapshotNamespace, "snapshot-namespace", "",
+		"The namespace snapshot ConfigMaps are written to. Defaults to the namespace of each PodSet.")
+	flag.IntVar(&snapshotHistoryLimit, "snapshot-history-limit", snapshot.DefaultHistoryLimit,
+		"The number of PodSet spec revisions kept in each snapshot.")
+	flag.StringVar(&s3Endpoint, "snapshot-s3-endpoint", "", "The URL of the S3-compatible snapshot service.")
+	flag.StringVar(&s3Bucket, "snapshot-s3-bucket", "", "The bucket snapshots are written to.")
+	flag.StringVar(&s3Region, "snapshot-s3-region", "us-east-1", "The region of the snapshot bucket.")
+	flag.StringVar(&s3Prefix, "snapshot-s3-prefix", "podsets", "The key prefix of snapshot objects.")
+	flag.StringVar(&fulcioRootsFile, "sigstore-fulcio-roots", "",
+		"The PEM file of the Fulcio root certificates keyless image signatures are verified against.")
+	flag.StringVar(&rekorKeysFile, "sigstore-rekor-public-keys", "",
+		"The PEM file of the Rekor public keys the transparency log entries of keyless image signatures are verified against.")
+	flag.StringVar(&preDeleteHookHosts, "pre-delete-hook-allowed-hosts", "",
+		"A comma separated list of the hosts the pre-delete hooks of PodSets may call, a leading dot allowing the "+
+			"subdomains of a domain, e.g. .hooks.svc.cluster.local. Hooks are never called when empty, nor on "+
+			"loopback and link-local addresses.")
 	opts := zap.Options{
 		Development: true,
 	}
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
+	// An atomic level lets the config file change the verbosity of the running logger.
+	logLevel := uberzap.NewAtomicLevelAt(zapcore.InfoLevel)
+	if opts.Development {
+		logLevel.SetLevel(zapcore.DebugLevel)
+	}
+	if level, ok := opts.Level.(uberzap.AtomicLevel); ok {
+		logLevel = level
+	}
+	opts.Level = logLevel
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:                 scheme,
-		MetricsBindAddress:     metricsAddr,
-		Port:                   9443,
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "98aadc68.pixiu.io",
+	flagLevel := logLevel.Level()
+	flagSettings := controllers.Settings{
+		CreateFailureThreshold:    createFailureThreshold,
+		CreateCircuitCooldown:     createCircuitCooldown,
+		FailureBackoffBase:        failureBackoffBase,
+		FailureBackoffMax:         failureBackoffMax,
+		PrometheusAddress:         prometheusAddress,
+		LiveVerifyDeleteThreshold: liveVerifyDeleteThreshold,
+		ReconcileTimeout:          reconcileTimeout,
+		BurstReplicas:             burstReplicas,
+	}
+	settings := flagSettings
+	var configWatcher *config.Watcher
+	if len(configFile) != 0 {
+		configWatcher = &config.Watcher{Path: configFile, Log: ctrl.Log.WithName("config")}
+		c, err := configWatcher.Load()
+		if err != nil {
+			setupLog.Error(err, "unable to load config")
+			os.Exit(1)
+		}
+		settings = applyConfig(c, flagSettings, flagLevel, logLevel)
+	}
+
+	cfg := ctrl.GetConfigOrDie()
+	// The client-go defaults of 5 QPS and 10 burst throttle large create and delete batches.
+	cfg.QPS = float32(kubeAPIQPS)
+	cfg.Burst = kubeAPIBurst
+	cfg.Timeout = kubeAPITimeout
+
+	switch eventSink {
+	case events.SinkAPI, events.SinkLog, events.SinkNone:
+	default:
+		setupLog.Error(fmt.Errorf("unknown event sink %q", eventSink), "unable to set up events")
+		os.Exit(1)
+	}
+	broadcaster := events.NewBroadcaster(float32(eventQPS), eventBurst)
+	// The manager starts writing to the API server with the broadcaster it is given.
+	var apiBroadcaster record.EventBroadcaster
+	if eventSink == events.SinkAPI {
+		apiBroadcaster = broadcaster
+	}
+
+	var newCache cache.NewCacheFunc
+	var namespaces []string
+	for _, ns := range strings.Split(watchNamespaces, ",") {
+		if ns = strings.TrimSpace(ns); len(ns) != 0 {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	if len(namespaces) != 0 {
+		// Informers are started per namespace on first use, and list and watch
+		// only within their namespace.
+		newCache = cache.MultiNamespacedCacheBuilder(namespaces)
+	}
+
+	// The secure metrics server replaces the plain HTTP one of the manager.
+	managerMetricsAddr := metricsAddr
+	if metricsSecure {
+		managerMetricsAddr = "0"
+	}
+
+	// On a termination signal the controllers stop picking up work and the
+	// in-flight reconciles get the drain period to finish their writes. The
+	// manager then releases the lease so that the next leader starts at once,
+	// leaving a few seconds on top of the drain to flush events.
+	ctx := ctrl.SetupSignalHandler()
+	drainCtx, cancelDrain := context.WithCancel(context.Background())
+	go func() {
+		<-ctx.Done()
+		setupLog.Info("Termination signal received, draining in-flight reconciles", "timeout", shutdownDrainTimeout)
+		time.AfterFunc(shutdownDrainTimeout, cancelDrain)
+	}()
+	gracefulShutdownTimeout := shutdownDrainTimeout + 5*time.Second
+
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:                        scheme,
+		MetricsBindAddress:            managerMetricsAddr,
+		Port:                          9443,
+		HealthProbeBindAddress:        probeAddr,
+		LeaderElection:                enableLeaderElection,
+		LeaderElectionID:              leaderElectionID,
+		LeaderElectionNamespace:       leaderElectionNamespace,
+		LeaderElectionReleaseOnCancel: true,
+		LeaseDuration:                 &leaseDuration,
+		RenewDeadline:                 &renewDeadline,
+		RetryPeriod:                   &retryPeriod,
+		GracefulShutdownTimeout:       &gracefulShutdownTimeout,
+		EventBroadcaster:              apiBroadcaster, //nolint:staticcheck
+		NewCache:                      newCache,
+		// Copied image pull secrets are read and written without caching every
+		// Secret of the cluster.
+		ClientDisableCacheFor: []client.Object{&corev1.Secret{}},
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	if err = (&controllers.PodSetReconciler{
+	if metricsSecure {
+		server := &metrics.SecureServer{
+			BindAddress: metricsAddr,
+			CertFile:    metricsCertFile,
+			KeyFile:     metricsKeyFile,
+			Log:         ctrl.Log.WithName("metrics"),
+		}
+		if metricsAuth {
+			if server.Client, err = kubernetes.NewForConfig(cfg); err != nil {
+				setupLog.Error(err, "unable to create metrics auth client")
+				os.Exit(1)
+			}
+		}
+		if err = mgr.Add(server); err != nil {
+			setupLog.Error(err, "unable to set up secure metrics")
+			os.Exit(1)
+		}
+	}
+
+	if eventSink == events.SinkLog {
+		broadcaster.StartStructuredLogging(0)
+	}
+	eventRecorderFor := func(name string) record.EventRecorder {
+		var recorder record.EventRecorder
+		switch eventSink {
+		case events.SinkAPI:
+			recorder = mgr.GetEventRecorderFor(name)
+		case events.SinkLog:
+			recorder = broadcaster.NewRecorder(mgr.GetScheme(), corev1.EventSource{Component: name})
+		case events.SinkNone:
+			recorder = &record.FakeRecorder{}
+		}
+		return events.NewNormalRateLimitedRecorder(recorder, float32(eventNormalQPS), eventNormalBurst)
+	}
+
+	var store snapshot.Store
+	switch snapshotStore {
+	case "":
+	case "configmap":
+		// Snapshot ConfigMaps are read rarely, don't cache every ConfigMap in the cluster for them.
+		c, err := client.New(mgr.GetConfig(), client.Options{Scheme: mgr.GetScheme()})
+		if err != nil {
+			setupLog.Error(err, "unable to create snapshot client")
+			os.Exit(1)
+		}
+		store = &snapshot.ConfigMapStore{Client: c, Namespace: snapshotNamespace}
+	case "s3":
+		store = &snapshot.S3Store{
+			Endpoint:        s3Endpoint,
+			Bucket:          s3Bucket,
+			Region:          s3Region,
+			Prefix:          s3Prefix,
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		}
+	default:
+		setupLog.Error(fmt.Errorf("unknown snapshot store %q", snapshotStore), "unable to set up snapshots")
+		os.Exit(1)
+	}
+
+	signatureTrust, err := signature.LoadTrustRoot(fulcioRootsFile, rekorKeysFile)
+	if err != nil {
+		setupLog.Error(err, "unable to load the sigstore trust root")
+		os.Exit(1)
+	}
+
+	var hookHosts []string
+	for _, host := range strings.Split(preDeleteHookHosts, ",") {
+		if host = strings.TrimSpace(host); len(host) != 0 {
+			hookHosts = append(hookHosts, host)
+		}
+	}
+
+	podSetReconciler := &controllers.PodSetReconciler{
+		Client:               mgr.GetClient(),
+		Scheme:               mgr.GetScheme(),
+		Log:                  ctrl.Log.WithName("pixiu").WithName("controller"),
+		Recorder:             eventRecorderFor("podset-controller"),
+		SnapshotStore:        store,
+		SnapshotHistoryLimit: snapshotHistoryLimit,
+		Settings:             settings,
+
+		APIReader:               mgr.GetAPIReader(),
+		PodMetadataOnly:         podMetadataOnly,
+		MaxConcurrentReconciles: maxConcurrentReconciles,
+		// Nodes are cluster scoped, namespaced permissions can't list them.
+		SchedulingPreCheck: len(namespaces) == 0,
+		DrainContext:       drainCtx,
+		SignatureTrust:     signatureTrust,
+		PreDeleteHookHosts: hookHosts,
+	}
+	if err = podSetReconciler.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PodSet")
+		os.Exit(1)
+	}
+	if configWatcher != nil {
+		configWatcher.OnChange = func(c *config.Config) {
+			podSetReconciler.UpdateSettings(applyConfig(c, flagSettings, flagLevel, logLevel))
+		}
+		if err = mgr.Add(configWatcher); err != nil {
+			setupLog.Error(err, "unable to set up config reload")
+			os.Exit(1)
+		}
+	}
+	if len(namespaces) == 0 {
+		if err = (&controllers.ClusterPodSetReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Log:      ctrl.Log.WithName("pixiu").WithName("clusterpodset-controller"),
+			Recorder: eventRecorderFor("clusterpodset-controller"),
+
+			DrainContext: drainCtx,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ClusterPodSet")
+			os.Exit(1)
+		}
+	} else {
+		setupLog.Info("ClusterPodSet controller disabled, the controller is restricted to namespaces", "namespaces", namespaces)
+	}
+	if err = (&controllers.PodSetQuotaReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
-		Log:    ctrl.Log.WithName("pixiu").WithName("controller"),
+		Log:    ctrl.Log.WithName("pixiu").WithName("podsetquota-controller"),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "PodSet")
+		setupLog.Error(err, "unable to create controller", "controller", "PodSetQuota")
+		os.Exit(1)
+	}
+	if err = (&controllers.PodSetReportReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		Log:    ctrl.Log.WithName("pixiu").WithName("podsetreport-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PodSetReport")
 		os.Exit(1)
 	}
 	//+kubebuilder:scaffold:builder
@@ -92,14 +441,70 @@ func main() {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
 	}
-	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+	// Stop receiving traffic, e.g. metrics scrapes, as soon as the drain starts.
+	if err := mgr.AddReadyzCheck("readyz", func(_ *http.Request) error {
+		if ctx.Err() != nil {
+			return errors.New("shutting down")
+		}
+		return nil
+	}); err != nil {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	err = mgr.Start(ctx)
+	cancelDrain()
+	if eventSink == events.SinkLog {
+		broadcaster.Shutdown()
+	}
+	if err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
 }
+
+// applyConfig sets the log level of the config file and returns the
+// controller settings with the values of the file overriding the flags.
+func applyConfig(c *config.Config, settings controllers.Settings, flagLevel zapcore.Level, logLevel uberzap.AtomicLevel) controllers.Settings {
+	level := flagLevel
+	if len(c.LogLevel) != 0 {
+		// The level has been validated when the file was parsed.
+		level, _ = config.ParseLevel(c.LogLevel)
+	}
+	logLevel.SetLevel(level)
+
+	if c.CreateFailureThreshold != nil {
+		settings.CreateFailureThreshold = *c.CreateFailureThreshold
+	}
+	if c.CreateCircuitCooldown != nil {
+		settings.CreateCircuitCooldown = c.CreateCircuitCooldown.Duration
+	}
+	if c.FailureBackoffBase != nil {
+		settings.FailureBackoffBase = c.FailureBackoffBase.Duration
+	}
+	if c.FailureBackoffMax != nil {
+		settings.FailureBackoffMax = c.FailureBackoffMax.Duration
+	}
+	if c.PrometheusAddress != nil {
+		settings.PrometheusAddress = *c.PrometheusAddress
+	}
+	if c.LiveVerifyDeleteThreshold != nil {
+		settings.LiveVerifyDeleteThreshold = *c.LiveVerifyDeleteThreshold
+	}
+	if c.ReconcileTimeout != nil {
+		settings.ReconcileTimeout = c.ReconcileTimeout.Duration
+	}
+	if c.BurstReplicas != nil {
+		settings.BurstReplicas = *c.BurstReplicas
+	}
+	settings.IgnoredNamespaces = c.IgnoredNamespaces
+	settings.ResourcePrices = nil
+	for name, price := range c.Prices() {
+		if settings.ResourcePrices == nil {
+			settings.ResourcePrices = map[corev1.ResourceName]float64{}
+		}
+		settings.ResourcePrices[corev1.ResourceName(name)] = price
+	}
+	return settings
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package admissionpolicy expresses the PodSet validation rules as a
+// ValidatingAdmissionPolicy, for clusters that validate PodSets at admission
+// time without running a webhook. The rules mirror pkg/validation.
+//
+// The API types declared here are not CRDs.
+// +kubebuilder:skip
+package admissionpolicy
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+// DefaultAPIVersion is the admissionregistration version the policy is
+// generated for, GA since Kubernetes 1.30. Older clusters serve v1beta1.
+const DefaultAPIVersion = "admissionregistration.k8s.io/v1"
+
+// The types below are the subset of the admissionregistration API used by
+// the generated policy. The vendored k8s.io/api predates it.
+
+type ValidatingAdmissionPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              ValidatingAdmissionPolicySpec `json:"spec"`
+}
+
+type ValidatingAdmissionPolicySpec struct {
+	FailurePolicy    string         `json:"failurePolicy"`
+	MatchConstraints MatchResources `json:"matchConstraints"`
+	Validations      []Validation   `json:"validations"`
+}
+
+type MatchResources struct {
+	ResourceRules []NamedRuleWithOperations `json:"resourceRules"`
+}
+
+type NamedRuleWithOperations struct {
+	APIGroups   []string `json:"apiGroups"`
+	APIVersions []string `json:"apiVersions"`
+	Operations  []string `json:"operations"`
+	Resources   []string `json:"resources"`
+}
+
+type Validation struct {
+	Expression string `json:"expression"`
+	Message    string `json:"message"`
+}
+
+type ValidatingAdmissionPolicyBinding struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              ValidatingAdmissionPolicyBindingSpec `json:"spec"`
+}
+
+type ValidatingAdmissionPolicyBindingSpec struct {
+	PolicyName        string   `json:"policyName"`
+	ValidationActions []string `json:"validationActions"`
+}
+
+// Validations returns the PodSet validation rules as CEL expressions over the
+// admitted object.
+func Validations() []Validation {
+	const (
+		spec     = "object.spec"
+		template = spec + ".template"
+		pod      = template + ".spec"
+		source   = spec + ".replicaSource"
+		prom     = source + ".prometheus"
+		selector = spec + ".selector"
+	)
+
+	// The match labels, or an empty map when the selector has none.
+	matchLabels := "(has(" + selector + ".matchLabels) ? " + selector + ".matchLabels : {})"
+
+	// The labels of the template are not checked against the selector: the CRD
+	// schema doesn't preserve the template metadata, so it is pruned before
+	// admission and the controller falls back to the selector labels. Those
+	// must then match the selector expressions, or the controller would never
+	// find the pods it creates.
+	return []Validation{
+		{
+			Expression: "!has(" + spec + ".replicas) || " + spec + ".replicas >= 0",
+			Message:    "spec.replicas must be greater than or equal to 0",
+		},
+		{
+			Expression: "has(" + spec + ".selector) && " +
+				"((has(" + spec + ".selector.matchLabels) && size(" + spec + ".selector.matchLabels) > 0) || " +
+				"(has(" + spec + ".selector.matchExpressions) && size(" + spec + ".selector.matchExpressions) > 0))",
+			Message: "spec.selector: empty selector is invalid for podset",
+		},
+		{
+			Expression: "!has(" + spec + ".minReplicas) || !has(" + spec + ".maxReplicas) || " + spec + ".maxReplicas >= " + spec + ".minReplicas",
+			Message:    "spec.maxReplicas: must be greater than or equal to minReplicas",
+		},
+		{
+			Expression: "oldObject == null || " + spec + ".selector == oldObject.spec.selector",
+			Message:    "spec.selector: field is immutable",
+		},
+		{
+			Expression: "!has(" + selector + ".matchExpressions) || " + selector + ".matchExpressions.all(e, " +
+				"e.operator == 'In' ? (e.key in " + matchLabels + " && " + matchLabels + "[e.key] in e.values) : " +
+				"e.operator == 'NotIn' ? !(e.key in " + matchLabels + ") || !(" + matchLabels + "[e.key] in e.values) : " +
+				"e.operator == 'Exists' ? e.key in " + matchLabels + " : " +
+				"!(e.key in " + matchLabels + "))",
+			Message: "spec.selector.matchExpressions: must match the matchLabels the pods are labelled with",
+		},
+		{
+			Expression: "has(" + pod + ") && has(" + pod + ".containers) && size(" + pod + ".containers) > 0",
+			Message:    "spec.template.spec.containers: Required value",
+		},
+		{
+			Expression: "!has(" + pod + ".containers) || " + pod + ".containers.all(c, has(c.image) && size(c.image) > 0)",
+			Message:    "spec.template.spec.containers: image is required",
+		},
+		{
+			Expression: "!has(" + pod + ".containers) || " + pod + ".containers.all(c, " + pod + ".containers.exists_one(d, d.name == c.name))",
+			Message:    "spec.template.spec.containers: container names must be unique",
+		},
+		{
+			Expression: "!has(" + pod + ".initContainers) || " + pod + ".initContainers.all(c, " + pod + ".initContainers.exists_one(d, d.name == c.name))",
+			Message:    "spec.template.spec.initContainers: container names must be unique",
+		},
+		{
+			Expression: "!has(" + pod + ".restartPolicy) || " + pod + ".restartPolicy == 'Always'",
+			Message:    "spec.template.spec.restartPolicy: Unsupported value, supported values: \"Always\"",
+		},
+		{
+			Expression: "!has(" + source + ") || [has(" + prom + "), has(" + source + ".configMapKeyRef)].filter(x, x).size() == 1",
+			Message:    "spec.replicaSource: exactly 1 replica source must be specified",
+		},
+		{
+			Expression: "!has(" + source + ") || !has(" + prom + ") || " +
+				prom + ".maxReplicas >= (has(" + prom + ".minReplicas) ? " + prom + ".minReplicas : 1)",
+			Message: "spec.replicaSource.prometheus.maxReplicas: must be greater than or equal to minReplicas",
+		},
+		{
+			Expression: "!has(" + source + ") || !has(" + prom + ") || has(" + prom + ".targetValue) || " +
+				"(has(" + prom + ".scalingFunction) && " + prom + ".scalingFunction == 'Direct')",
+			Message: "spec.replicaSource.prometheus.targetValue: required by the Proportional scaling function",
+		},
+	}
+}
+
+// Generate returns the policy validating PodSets and its binding, which
+// denies the invalid requests cluster wide.
+func Generate(name, apiVersion string) (*ValidatingAdmissionPolicy, *ValidatingAdmissionPolicyBinding) {
+	policy := &ValidatingAdmissionPolicy{
+		TypeMeta:   metav1.TypeMeta{APIVersion: apiVersion, Kind: "ValidatingAdmissionPolicy"},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: ValidatingAdmissionPolicySpec{
+			FailurePolicy: "Fail",
+			MatchConstraints: MatchResources{
+				ResourceRules: []NamedRuleWithOperations{{
+					APIGroups:   []string{pixiuv1alpha1.GroupVersion.Group},
+					APIVersions: []string{pixiuv1alpha1.GroupVersion.Version},
+					Operations:  []string{"CREATE", "UPDATE"},
+					Resources:   []string{"podsets"},
+				}},
+			},
+			Validations: Validations(),
+		},
+	}
+	binding := &ValidatingAdmissionPolicyBinding{
+		TypeMeta:   metav1.TypeMeta{APIVersion: apiVersion, Kind: "ValidatingAdmissionPolicyBinding"},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: ValidatingAdmissionPolicyBindingSpec{
+			PolicyName:        name,
+			ValidationActions: []string{"Deny"},
+		},
+	}
+	return policy, binding
+}
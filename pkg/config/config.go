@@ -0,0 +1,186 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package config loads the operator settings that can change while it runs
+// and reloads them when their file changes, e.g. a mounted ConfigMap.
+//
+// The settings override the matching command line flags. A setting removed
+// from the file falls back to its flag again.
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	"go.uber.org/zap/zapcore"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultInterval is how often the config file is checked for changes.
+// Kubelet takes up to a minute to update a mounted ConfigMap anyway.
+const DefaultInterval = 10 * time.Second
+
+// Config holds the settings that are safe to change without restarting the
+// manager. Unset fields keep the value of their flag.
+type Config struct {
+	// LogLevel is debug, info, error or an integer verbosity, as --zap-log-level.
+	LogLevel string `json:"logLevel,omitempty"`
+
+	CreateFailureThreshold    *int             `json:"createFailureThreshold,omitempty"`
+	CreateCircuitCooldown     *metav1.Duration `json:"createCircuitCooldown,omitempty"`
+	FailureBackoffBase        *metav1.Duration `json:"failureBackoffBase,omitempty"`
+	FailureBackoffMax         *metav1.Duration `json:"failureBackoffMax,omitempty"`
+	PrometheusAddress         *string          `json:"prometheusAddress,omitempty"`
+	LiveVerifyDeleteThreshold *int             `json:"liveVerifyDeleteThreshold,omitempty"`
+	ReconcileTimeout          *metav1.Duration `json:"reconcileTimeout,omitempty"`
+	BurstReplicas             *int             `json:"burstReplicas,omitempty"`
+
+	// IgnoredNamespaces are namespaces whose PodSets are not reconciled.
+	// Unlike --watch-namespaces they are still cached, so that the list can
+	// change without restarting the informers.
+	IgnoredNamespaces []string `json:"ignoredNamespaces,omitempty"`
+
+	// ResourcePrices are the hourly prices of one unit of the resources, the
+	// estimated cost of PodSets is published in their status. Memory and
+	// storage resources are priced per GiB, the others per unit, e.g. per CPU.
+	ResourcePrices map[string]string `json:"resourcePrices,omitempty"`
+}
+
+// Prices returns the parsed resource prices.
+func (c *Config) Prices() map[string]float64 {
+	if len(c.ResourcePrices) == 0 {
+		return nil
+	}
+	prices := make(map[string]float64, len(c.ResourcePrices))
+	for name, price := range c.ResourcePrices {
+		// The prices have been validated when the file was parsed.
+		prices[name], _ = strconv.ParseFloat(price, 64)
+	}
+	return prices
+}
+
+// Parse decodes a config file, rejecting unknown fields so that typos don't
+// go unnoticed.
+func Parse(data []byte) (*Config, error) {
+	c := &Config{}
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, err
+	}
+	if len(c.LogLevel) != 0 {
+		if _, err := ParseLevel(c.LogLevel); err != nil {
+			return nil, err
+		}
+	}
+	if c.BurstReplicas != nil && *c.BurstReplicas < 1 {
+		return nil, fmt.Errorf("invalid burstReplicas %d, it must be at least 1", *c.BurstReplicas)
+	}
+	for name, price := range c.ResourcePrices {
+		if v, err := strconv.ParseFloat(price, 64); err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid price %q of resource %s", price, name)
+		}
+	}
+	return c, nil
+}
+
+// ParseLevel parses a log level the way --zap-log-level does.
+func ParseLevel(s string) (zapcore.Level, error) {
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(s)); err == nil {
+		return level, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid log level %q", s)
+	}
+	return zapcore.Level(-v), nil
+}
+
+// Watcher reloads a config file when its content changes.
+type Watcher struct {
+	// Path is the config file.
+	Path string
+	// Interval between checks, DefaultInterval when zero.
+	Interval time.Duration
+	// OnChange is called with every new valid config. An invalid file is
+	// logged and the current config is kept.
+	OnChange func(*Config)
+
+	Log logr.Logger
+
+	last []byte
+}
+
+var _ manager.Runnable = &Watcher{}
+var _ manager.LeaderElectionRunnable = &Watcher{}
+
+// NeedLeaderElection is false, standby replicas keep their config current.
+func (w *Watcher) NeedLeaderElection() bool {
+	return false
+}
+
+// Load reads the config file. It is called once before the manager starts so
+// that the first reconciles already use the file.
+func (w *Watcher) Load() (*Config, error) {
+	data, err := os.ReadFile(w.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %v", w.Path, err)
+	}
+	c, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %v", w.Path, err)
+	}
+	w.last = data
+	return c, nil
+}
+
+// Start checks the config file for changes until the context is done.
+func (w *Watcher) Start(ctx context.Context) error {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	wait.UntilWithContext(ctx, w.reload, interval)
+	return nil
+}
+
+func (w *Watcher) reload(_ context.Context) {
+	data, err := os.ReadFile(w.Path)
+	if err != nil {
+		w.Log.Error(err, "error reading config, keeping the current one", "path", w.Path)
+		return
+	}
+	if bytes.Equal(data, w.last) {
+		return
+	}
+	// Remember the content even when invalid, so it is only reported once.
+	w.last = data
+
+	c, err := Parse(data)
+	if err != nil {
+		w.Log.Error(err, "invalid config, keeping the current one", "path", w.Path)
+		return
+	}
+	w.Log.Info("Config changed, applying it", "path", w.Path)
+	w.OnChange(c)
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{spec: "* * * * *"},
+		{spec: "0 9 * * 1-5"},
+		{spec: "*/15 0-6,22-23 1,15 */2 0,7"},
+		{spec: "30 8 * * 5/1"},
+		{spec: "* * * *", wantErr: true},
+		{spec: "* * * * * *", wantErr: true},
+		{spec: "60 * * * *", wantErr: true},
+		{spec: "* 24 * * *", wantErr: true},
+		{spec: "* * 0 * *", wantErr: true},
+		{spec: "* * * 13 *", wantErr: true},
+		{spec: "* * * * 8", wantErr: true},
+		{spec: "5-1 * * * *", wantErr: true},
+		{spec: "*/0 * * * *", wantErr: true},
+		{spec: "a * * * *", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			if _, err := Parse(tt.spec); (err != nil) != tt.wantErr {
+				t.Errorf("Parse(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	// 2021-01-04 is a Monday.
+	monday := time.Date(2021, 1, 4, 9, 0, 0, 0, time.UTC)
+	sunday := time.Date(2021, 1, 3, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		spec string
+		time time.Time
+		want bool
+	}{
+		{name: "every minute", spec: "* * * * *", time: monday, want: true},
+		{name: "minute and hour", spec: "0 9 * * *", time: monday, want: true},
+		{name: "other minute", spec: "30 9 * * *", time: monday, want: false},
+		{name: "other hour", spec: "0 10 * * *", time: monday, want: false},
+		{name: "weekday range", spec: "0 9 * * 1-5", time: monday, want: true},
+		{name: "weekday range on sunday", spec: "0 9 * * 1-5", time: sunday, want: false},
+		{name: "sunday as 0", spec: "0 9 * * 0", time: sunday, want: true},
+		{name: "sunday as 7", spec: "0 9 * * 7", time: sunday, want: true},
+		{name: "step", spec: "*/15 * * * *", time: monday.Add(45 * time.Minute), want: true},
+		{name: "step miss", spec: "*/15 * * * *", time: monday.Add(50 * time.Minute), want: false},
+		{name: "value with step", spec: "10/20 * * * *", time: monday.Add(50 * time.Minute), want: true},
+		{name: "list", spec: "0 8,9 * * *", time: monday, want: true},
+		{name: "month", spec: "0 9 * 2 *", time: monday, want: false},
+		// Both days of month and week restricted: either one matches.
+		{name: "day of month or week, by day of month", spec: "0 9 4 * 5", time: monday, want: true},
+		{name: "day of month or week, by day of week", spec: "0 9 15 * 1", time: monday, want: true},
+		{name: "day of month or week, neither", spec: "0 9 15 * 5", time: monday, want: false},
+		// Only one restricted: it has to match.
+		{name: "day of month only", spec: "0 9 15 * *", time: monday, want: false},
+		{name: "day of week only", spec: "0 9 * * 5", time: monday, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.spec, err)
+			}
+			if got := s.Matches(tt.time); got != tt.want {
+				t.Errorf("Parse(%q).Matches(%v) = %v, want %v", tt.spec, tt.time, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package events tunes the throughput of the events recorded by the controllers.
+package events
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+const (
+	// SinkAPI writes events to the API server.
+	SinkAPI = "api"
+	// SinkLog only writes events to the controller log.
+	SinkLog = "log"
+	// SinkNone drops all events.
+	SinkNone = "none"
+)
+
+// NewBroadcaster returns a broadcaster whose per object spam filter allows qps
+// events per second with bursts of burst events. Zero values keep the
+// client-go defaults.
+func NewBroadcaster(qps float32, burst int) record.EventBroadcaster {
+	return record.NewBroadcasterWithCorrelatorOptions(record.CorrelatorOptions{
+		QPS:       qps,
+		BurstSize: burst,
+	})
+}
+
+// normalRateLimitedRecorder drops Normal events beyond a rate shared by all
+// objects. Warning events are always recorded.
+type normalRateLimitedRecorder struct {
+	record.EventRecorder
+	limiter flowcontrol.RateLimiter
+}
+
+// NewNormalRateLimitedRecorder wraps recorder to record at most qps Normal
+// events per second, so that large scale operations don't get the important
+// Warning events rate limited. A qps of zero records every event.
+func NewNormalRateLimitedRecorder(recorder record.EventRecorder, qps float32, burst int) record.EventRecorder {
+	if qps <= 0 {
+		return recorder
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	return &normalRateLimitedRecorder{
+		EventRecorder: recorder,
+		limiter:       flowcontrol.NewTokenBucketRateLimiter(qps, burst),
+	}
+}
+
+func (r *normalRateLimitedRecorder) drop(eventtype string) bool {
+	return eventtype == corev1.EventTypeNormal && !r.limiter.TryAccept()
+}
+
+func (r *normalRateLimitedRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	if r.drop(eventtype) {
+		return
+	}
+	r.EventRecorder.Event(object, eventtype, reason, message)
+}
+
+func (r *normalRateLimitedRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	if r.drop(eventtype) {
+		return
+	}
+	r.EventRecorder.Eventf(object, eventtype, reason, messageFmt, args...)
+}
+
+func (r *normalRateLimitedRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	if r.drop(eventtype) {
+		return
+	}
+	r.EventRecorder.AnnotatedEventf(object, annotations, eventtype, reason, messageFmt, args...)
+}
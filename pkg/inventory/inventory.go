@@ -0,0 +1,158 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/util"
+)
+
+// Tracker applies the auxiliary objects declared for an owner during one
+// reconcile and prunes the ones that were applied before but are no longer
+// declared.
+type Tracker struct {
+	client client.Client
+	scheme *runtime.Scheme
+	owner  client.Object
+	ref    metav1.OwnerReference
+
+	applied []pixiuv1alpha1.ResourceRef
+}
+
+// NewTracker returns a tracker setting ref, the reference to owner, on every
+// applied object.
+func NewTracker(c client.Client, scheme *runtime.Scheme, owner client.Object, ref metav1.OwnerReference) *Tracker {
+	return &Tracker{
+		client: c,
+		scheme: scheme,
+		owner:  owner,
+		ref:    ref,
+	}
+}
+
+// Apply creates or updates obj in the namespace of the owner, referencing the
+// owner from it, and records it in the inventory.
+func (t *Tracker) Apply(ctx context.Context, obj client.Object, mutate controllerutil.MutateFn) error {
+	obj.SetNamespace(t.owner.GetNamespace())
+	if _, err := controllerutil.CreateOrUpdate(ctx, t.client, obj, func() error {
+		if mutate != nil {
+			if err := mutate(); err != nil {
+				return err
+			}
+		}
+		return util.SetOwnerReference(obj, t.ref)
+	}); err != nil {
+		return err
+	}
+
+	gvk, err := apiutil.GVKForObject(obj, t.scheme)
+	if err != nil {
+		return err
+	}
+	t.applied = append(t.applied, pixiuv1alpha1.ResourceRef{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		Name:       obj.GetName(),
+		UID:        obj.GetUID(),
+	})
+	return nil
+}
+
+// Inventory returns the objects applied so far.
+func (t *Tracker) Inventory() []pixiuv1alpha1.ResourceRef {
+	return t.applied
+}
+
+// Prune deletes the objects of the previous inventory that were not applied
+// by this tracker. Deletes are guarded by the recorded UID, so an object that
+// was recreated by someone else is never removed.
+func (t *Tracker) Prune(ctx context.Context, previous []pixiuv1alpha1.ResourceRef) error {
+	var errs []error
+	for _, ref := range previous {
+		if contains(t.applied, ref) {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind))
+		obj.SetNamespace(t.owner.GetNamespace())
+		obj.SetName(ref.Name)
+
+		opts := []client.DeleteOption{client.PropagationPolicy(metav1.DeletePropagationBackground)}
+		if len(ref.UID) != 0 {
+			uid := ref.UID
+			opts = append(opts, client.Preconditions{UID: &uid})
+		}
+		if err := t.client.Delete(ctx, obj, opts...); err != nil && !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// Release removes the reference to the owner from the objects of the
+// inventory, which are then kept after the owner is deleted.
+func (t *Tracker) Release(ctx context.Context, previous []pixiuv1alpha1.ResourceRef) error {
+	var errs []error
+	for _, ref := range previous {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind))
+		if err := t.client.Get(ctx, client.ObjectKey{Namespace: t.owner.GetNamespace(), Name: ref.Name}, obj); err != nil {
+			if !apierrors.IsNotFound(err) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if len(ref.UID) != 0 && obj.GetUID() != ref.UID {
+			continue
+		}
+
+		released := obj.DeepCopy()
+		refs := make([]metav1.OwnerReference, 0, len(obj.GetOwnerReferences()))
+		for _, owner := range obj.GetOwnerReferences() {
+			if owner.UID != t.owner.GetUID() {
+				refs = append(refs, owner)
+			}
+		}
+		released.SetOwnerReferences(refs)
+		if err := t.client.Update(ctx, released); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func contains(refs []pixiuv1alpha1.ResourceRef, ref pixiuv1alpha1.ResourceRef) bool {
+	for _, r := range refs {
+		if r.APIVersion == ref.APIVersion && r.Kind == ref.Kind && r.Name == ref.Name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const namespace = "podset"
+
+var (
+	// DriftDetected is 1 when the live spec of a PodSet differs from its declared source.
+	DriftDetected = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "drift_detected",
+		Help:      "Whether the live spec of the PodSet differs from the spec declared in its source (1) or not (0).",
+	}, []string{"namespace", "name"})
+
+	// ReconcilePanics counts the reconciles that panicked, by controller.
+	ReconcilePanics = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconcile_panics_total",
+		Help:      "The number of reconciles that panicked and were recovered.",
+	}, []string{"controller"})
+
+	// ReconcileTimeouts counts the reconciles that hit their deadline, by controller.
+	ReconcileTimeouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconcile_timeouts_total",
+		Help:      "The number of reconciles that were aborted at their deadline.",
+	}, []string{"controller"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		DriftDetected,
+		ReconcilePanics,
+		ReconcileTimeouts,
+	)
+}
+
+// Forget drops all the per PodSet series of a deleted PodSet.
+func Forget(namespace, name string) {
+	DriftDetected.DeleteLabelValues(namespace, name)
+}
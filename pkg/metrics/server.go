@@ -0,0 +1,183 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/cert"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+//+kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// SecureServer serves the controller metrics over TLS, replacing the plain
+// HTTP endpoint of the manager and the kube-rbac-proxy sidecar.
+type SecureServer struct {
+	// BindAddress is the address the server listens on.
+	BindAddress string
+	// CertFile and KeyFile are the serving certificate. A self-signed
+	// certificate is generated when they are empty.
+	CertFile string
+	KeyFile  string
+
+	// Client, when set, authenticates every request with a TokenReview and
+	// authorizes it with a SubjectAccessReview for the non-resource URL.
+	Client kubernetes.Interface
+
+	Log logr.Logger
+}
+
+var _ manager.Runnable = &SecureServer{}
+var _ manager.LeaderElectionRunnable = &SecureServer{}
+
+// NeedLeaderElection is false, every replica serves its own metrics.
+func (s *SecureServer) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves the metrics until the context is done.
+func (s *SecureServer) Start(ctx context.Context) error {
+	tlsConfig, err := s.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	var handler http.Handler = promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{
+		ErrorHandling: promhttp.HTTPErrorOnError,
+	})
+	if s.Client != nil {
+		handler = s.authorize(handler)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+
+	listener, err := net.Listen("tcp", s.BindAddress)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", s.BindAddress, err)
+	}
+	server := &http.Server{
+		Handler:           mux,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			s.Log.Error(err, "error shutting down the metrics server")
+		}
+	}()
+
+	s.Log.Info("Serving metrics over TLS", "address", s.BindAddress, "authorization", s.Client != nil)
+	if err = server.ServeTLS(listener, "", ""); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (s *SecureServer) tlsConfig() (*tls.Config, error) {
+	var certificate tls.Certificate
+	var err error
+	if len(s.CertFile) != 0 || len(s.KeyFile) != 0 {
+		certificate, err = tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+	} else {
+		var certPEM, keyPEM []byte
+		if certPEM, keyPEM, err = cert.GenerateSelfSignedCertKey("podset-operator-metrics", nil, nil); err != nil {
+			return nil, fmt.Errorf("failed to generate the metrics certificate: %v", err)
+		}
+		certificate, err = tls.X509KeyPair(certPEM, keyPEM)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the metrics certificate: %v", err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// authorize only lets through requests whose bearer token belongs to a user
+// allowed to get the requested path.
+func (s *SecureServer) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if len(token) == 0 || token == req.Header.Get("Authorization") {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		review, err := s.Client.AuthenticationV1().TokenReviews().Create(req.Context(), &authenticationv1.TokenReview{
+			Spec: authenticationv1.TokenReviewSpec{Token: token},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			s.Log.Error(err, "error reviewing metrics token")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if !review.Status.Authenticated {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		user := review.Status.User
+		extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+		for k, v := range user.Extra {
+			extra[k] = authorizationv1.ExtraValue(v)
+		}
+		sar, err := s.Client.AuthorizationV1().SubjectAccessReviews().Create(req.Context(), &authorizationv1.SubjectAccessReview{
+			Spec: authorizationv1.SubjectAccessReviewSpec{
+				User:   user.Username,
+				UID:    user.UID,
+				Groups: user.Groups,
+				Extra:  extra,
+				NonResourceAttributes: &authorizationv1.NonResourceAttributes{
+					Path: req.URL.Path,
+					Verb: strings.ToLower(req.Method),
+				},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			s.Log.Error(err, "error authorizing metrics request")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if !sar.Status.Allowed {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
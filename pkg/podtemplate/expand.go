@@ -0,0 +1,168 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package podtemplate expands the variables of a PodSet pod template.
+//
+// Any string of the template may reference the variables with the go-template
+// syntax, e.g. "{{ .Name }}-{{ .Index }}" or "{{ .Parameters.region }}". The
+// syntax is restricted to plain field references: functions, pipelines and
+// control structures are rejected.
+package podtemplate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Values are the variables available to a pod template.
+type Values struct {
+	// Index is the index of the pod within the PodSet.
+	Index int
+	// Name and Namespace of the PodSet.
+	Name      string
+	Namespace string
+	// RevisionHash is the hash of the unexpanded pod template.
+	RevisionHash string
+	// Parameters are the user supplied spec.parameters.
+	Parameters map[string]string
+}
+
+// IsTemplated reports whether the string contains template actions.
+func IsTemplated(s string) bool {
+	return strings.Contains(s, "{{")
+}
+
+// Validate checks that every templated string of the pod template only uses
+// the restricted syntax.
+func Validate(podTemplate *corev1.PodTemplateSpec) error {
+	return walk(podTemplate, func(s string) (string, error) {
+		_, err := parseString(s)
+		return s, err
+	})
+}
+
+// Expand returns a copy of the pod template with every templated string
+// expanded with the values.
+func Expand(podTemplate *corev1.PodTemplateSpec, values Values) (*corev1.PodTemplateSpec, error) {
+	expanded := podTemplate.DeepCopy()
+	err := walk(expanded, func(s string) (string, error) {
+		tmpl, err := parseString(s)
+		if err != nil {
+			return "", err
+		}
+		var buf bytes.Buffer
+		if err = tmpl.Execute(&buf, values); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return expanded, nil
+}
+
+// walk replaces, in place, every templated string value of the pod template
+// with the result of fn. Going through the JSON form keeps the expanded values
+// from changing the structure of the template.
+func walk(podTemplate *corev1.PodTemplateSpec, fn func(string) (string, error)) error {
+	data, err := json.Marshal(podTemplate)
+	if err != nil {
+		return err
+	}
+	if !bytes.Contains(data, []byte("{{")) {
+		return nil
+	}
+
+	var obj interface{}
+	if err = json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	if obj, err = walkValue(obj, fn); err != nil {
+		return err
+	}
+	if data, err = json.Marshal(obj); err != nil {
+		return err
+	}
+
+	*podTemplate = corev1.PodTemplateSpec{}
+	return json.Unmarshal(data, podTemplate)
+}
+
+func walkValue(obj interface{}, fn func(string) (string, error)) (interface{}, error) {
+	var err error
+	switch v := obj.(type) {
+	case string:
+		if !IsTemplated(v) {
+			return v, nil
+		}
+		out, err := fn(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template %q: %v", v, err)
+		}
+		return out, nil
+	case map[string]interface{}:
+		for key, value := range v {
+			if v[key], err = walkValue(value, fn); err != nil {
+				return nil, err
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			if v[i], err = walkValue(v[i], fn); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return obj, nil
+}
+
+func parseString(s string) (*template.Template, error) {
+	tmpl, err := template.New("").Option("missingkey=error").Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range tmpl.Tree.Root.Nodes {
+		if err = checkNode(node); err != nil {
+			return nil, err
+		}
+	}
+	return tmpl, nil
+}
+
+// checkNode only accepts text and actions made of a single field reference.
+func checkNode(node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.TextNode:
+		return nil
+	case *parse.ActionNode:
+		pipe := n.Pipe
+		if len(pipe.Decl) == 0 && len(pipe.Cmds) == 1 && len(pipe.Cmds[0].Args) == 1 {
+			if _, ok := pipe.Cmds[0].Args[0].(*parse.FieldNode); ok {
+				return nil
+			}
+		}
+		return fmt.Errorf("unsupported action %s, only field references such as {{ .Index }} are allowed", n)
+	default:
+		return fmt.Errorf("unsupported template construct %s", n)
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podtemplate
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func testTemplate(image string, args ...string) *corev1.PodTemplateSpec {
+	return &corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Image: image, Args: args}},
+		},
+	}
+}
+
+func TestExpand(t *testing.T) {
+	values := Values{
+		Index:        3,
+		Name:         "web",
+		Namespace:    "prod",
+		RevisionHash: "abc",
+		Parameters:   map[string]string{"region": "eu"},
+	}
+
+	tests := []struct {
+		name      string
+		template  *corev1.PodTemplateSpec
+		wantImage string
+		wantArgs  []string
+		// wantInvalid is whether the syntax is rejected by Validate, the
+		// missing values are only caught when expanding.
+		wantInvalid bool
+		wantErr     bool
+	}{
+		{
+			name:      "not templated",
+			template:  testTemplate("nginx", "--port=80"),
+			wantImage: "nginx",
+			wantArgs:  []string{"--port=80"},
+		},
+		{
+			name:      "fields",
+			template:  testTemplate("nginx", "--id={{ .Name }}-{{ .Index }}", "--ns={{.Namespace}}", "--rev={{ .RevisionHash }}"),
+			wantImage: "nginx",
+			wantArgs:  []string{"--id=web-3", "--ns=prod", "--rev=abc"},
+		},
+		{
+			name:      "parameters",
+			template:  testTemplate("registry.{{ .Parameters.region }}.example.com/app"),
+			wantImage: "registry.eu.example.com/app",
+		},
+		{
+			name:     "missing parameter",
+			template: testTemplate("nginx", "{{ .Parameters.zone }}"),
+			wantErr:  true,
+		},
+		{
+			name:     "unknown field",
+			template: testTemplate("nginx", "{{ .Node }}"),
+			wantErr:  true,
+		},
+		{
+			name:        "function",
+			template:    testTemplate("nginx", `{{ printf "%d" .Index }}`),
+			wantInvalid: true,
+			wantErr:     true,
+		},
+		{
+			name:        "pipeline",
+			template:    testTemplate("nginx", "{{ .Name | html }}"),
+			wantInvalid: true,
+			wantErr:     true,
+		},
+		{
+			name:        "control structure",
+			template:    testTemplate("nginx", "{{ if .Index }}x{{ end }}"),
+			wantInvalid: true,
+			wantErr:     true,
+		},
+		{
+			name:        "syntax error",
+			template:    testTemplate("nginx", "{{ .Name"),
+			wantInvalid: true,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.template); (err != nil) != tt.wantInvalid {
+				t.Errorf("Validate() error = %v, want error %v", err, tt.wantInvalid)
+			}
+			expanded, err := Expand(tt.template, values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expand() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			container := expanded.Spec.Containers[0]
+			if container.Image != tt.wantImage {
+				t.Errorf("Expand() image = %q, want %q", container.Image, tt.wantImage)
+			}
+			if len(container.Args) != len(tt.wantArgs) {
+				t.Fatalf("Expand() args = %q, want %q", container.Args, tt.wantArgs)
+			}
+			for i := range tt.wantArgs {
+				if container.Args[i] != tt.wantArgs[i] {
+					t.Errorf("Expand() args = %q, want %q", container.Args, tt.wantArgs)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestExpandKeepsTemplate(t *testing.T) {
+	template := testTemplate("nginx", "{{ .Name }}")
+	if _, err := Expand(template, Values{Name: "web"}); err != nil {
+		t.Fatalf("Expand() error = %v", err)
+	}
+	if template.Spec.Containers[0].Args[0] != "{{ .Name }}" {
+		t.Errorf("Expand() modified the template given: %q", template.Spec.Containers[0].Args[0])
+	}
+}
+
+func TestExpandKeepsStructure(t *testing.T) {
+	// An expanded value looking like JSON stays a string.
+	template := testTemplate("nginx", `{{ .Parameters.arg }}`)
+	expanded, err := Expand(template, Values{Parameters: map[string]string{"arg": `", "injected": "`}})
+	if err != nil {
+		t.Fatalf("Expand() error = %v", err)
+	}
+	if got := expanded.Spec.Containers[0].Args; len(got) != 1 || got[0] != `", "injected": "` {
+		t.Errorf("Expand() args = %q", got)
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Client runs instant queries against the Prometheus HTTP API.
+type Client struct {
+	HTTPClient *http.Client
+}
+
+type queryResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+type sample struct {
+	Value [2]interface{} `json:"value"`
+}
+
+// Query evaluates query on the Prometheus server at address and returns its
+// value. The query must evaluate to a scalar or to a vector with exactly one
+// sample.
+func (c *Client) Query(ctx context.Context, address, query string) (float64, error) {
+	u, err := url.Parse(strings.TrimSuffix(address, "/") + "/api/v1/query")
+	if err != nil {
+		return 0, fmt.Errorf("invalid prometheus address %q: %v", address, err)
+	}
+	u.RawQuery = url.Values{"query": []string{query}}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	qr := &queryResponse{}
+	if err = json.Unmarshal(body, qr); err != nil {
+		return 0, fmt.Errorf("failed to decode prometheus response (%s): %v", resp.Status, err)
+	}
+	if qr.Status != "success" {
+		return 0, fmt.Errorf("prometheus query failed: %s: %s", qr.ErrorType, qr.Error)
+	}
+
+	var value interface{}
+	switch qr.Data.ResultType {
+	case "scalar":
+		var pair [2]interface{}
+		if err = json.Unmarshal(qr.Data.Result, &pair); err != nil {
+			return 0, err
+		}
+		value = pair[1]
+	case "vector":
+		var samples []sample
+		if err = json.Unmarshal(qr.Data.Result, &samples); err != nil {
+			return 0, err
+		}
+		if len(samples) != 1 {
+			return 0, fmt.Errorf("prometheus query returned %d samples, expected 1", len(samples))
+		}
+		value = samples[0].Value[1]
+	default:
+		return 0, fmt.Errorf("unsupported prometheus result type %q", qr.Data.ResultType)
+	}
+
+	str, ok := value.(string)
+	if !ok {
+		return 0, fmt.Errorf("unexpected prometheus sample value %v", value)
+	}
+	return strconv.ParseFloat(str, 64)
+}
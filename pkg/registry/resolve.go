@@ -0,0 +1,347 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package registry resolves image tags to digests with the registry HTTP API.
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	dockerHub         = "docker.io"
+	dockerHubRegistry = "registry-1.docker.io"
+)
+
+// manifestTypes are the manifests the digest of an image may point to, image
+// indexes first so that multi-arch images keep working on every node.
+var manifestTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Reference is a parsed image reference.
+type Reference struct {
+	// Registry is the host of the registry, docker.io when the image has none.
+	Registry string
+	// Repository is the path of the image within the registry.
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference parses an image the way the container runtimes do.
+func ParseReference(image string) (Reference, error) {
+	ref := Reference{}
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, ref.Digest = name[:i], name[i+1:]
+	}
+	if i := strings.LastIndex(name, ":"); i >= 0 && !strings.Contains(name[i:], "/") {
+		name, ref.Tag = name[:i], name[i+1:]
+	}
+	if len(name) == 0 {
+		return ref, fmt.Errorf("invalid image reference %q", image)
+	}
+
+	// The first component is a registry when it looks like a host.
+	if i := strings.Index(name, "/"); i >= 0 && (strings.ContainsAny(name[:i], ".:") || name[:i] == "localhost") {
+		ref.Registry, ref.Repository = name[:i], name[i+1:]
+	} else {
+		ref.Registry, ref.Repository = dockerHub, name
+	}
+	if ref.Registry == dockerHub && !strings.Contains(ref.Repository, "/") {
+		ref.Repository = "library/" + ref.Repository
+	}
+	if len(ref.Tag) == 0 && len(ref.Digest) == 0 {
+		ref.Tag = "latest"
+	}
+	return ref, nil
+}
+
+// Credentials authenticate to a registry.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Keychain returns the credentials of a registry host, if any.
+type Keychain map[string]Credentials
+
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth     string `json:"auth"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	} `json:"auths"`
+}
+
+// KeychainFromSecrets reads the credentials of image pull secrets.
+func KeychainFromSecrets(secrets []corev1.Secret) (Keychain, error) {
+	keychain := Keychain{}
+	for _, secret := range secrets {
+		var config dockerConfig
+		switch secret.Type {
+		case corev1.SecretTypeDockerConfigJson:
+			if err := json.Unmarshal(secret.Data[corev1.DockerConfigJsonKey], &config); err != nil {
+				return nil, fmt.Errorf("invalid image pull secret %s: %v", secret.Name, err)
+			}
+		case corev1.SecretTypeDockercfg:
+			if err := json.Unmarshal(secret.Data[corev1.DockerConfigKey], &config.Auths); err != nil {
+				return nil, fmt.Errorf("invalid image pull secret %s: %v", secret.Name, err)
+			}
+		default:
+			continue
+		}
+		for server, auth := range config.Auths {
+			creds := Credentials{Username: auth.Username, Password: auth.Password}
+			if len(auth.Auth) != 0 {
+				decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+				if err != nil {
+					return nil, fmt.Errorf("invalid auth of %s in image pull secret %s: %v", server, secret.Name, err)
+				}
+				creds.Username, creds.Password, _ = cut(string(decoded), ":")
+			}
+			host := registryHost(server)
+			if _, ok := keychain[host]; !ok {
+				keychain[host] = creds
+			}
+		}
+	}
+	return keychain, nil
+}
+
+// registryHost returns the host of a server of a docker config, which may be
+// a URL such as https://index.docker.io/v1/.
+func registryHost(server string) string {
+	if u, err := url.Parse(server); err == nil && len(u.Host) != 0 {
+		server = u.Host
+	}
+	server = strings.TrimSuffix(server, "/")
+	switch server {
+	case "index.docker.io", dockerHubRegistry:
+		return dockerHub
+	}
+	return server
+}
+
+// Resolver resolves image tags to digests.
+type Resolver struct {
+	HTTPClient *http.Client
+}
+
+// Resolve returns the image pinned to the digest its tag currently points to,
+// e.g. nginx:1.21@sha256:... Images already pinned are returned as is.
+func (r *Resolver) Resolve(ctx context.Context, image string, keychain Keychain) (string, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	if len(ref.Digest) != 0 {
+		return image, nil
+	}
+
+	// HEAD requests don't count against the pull rate limits of Docker Hub.
+	s := r.session(ref, keychain)
+	resp, err := s.do(ctx, http.MethodHead, "manifests/"+ref.Tag, manifestTypes)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get the manifest of %s: %s", image, resp.Status)
+	}
+	if digest := resp.Header.Get("Docker-Content-Digest"); len(digest) != 0 {
+		return image + "@" + digest, nil
+	}
+
+	// The header is optional, the digest is then the hash of the manifest.
+	body, err := s.get(ctx, "manifests/"+ref.Tag, manifestTypes)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the manifest of %s: %v", image, err)
+	}
+	return fmt.Sprintf("%s@sha256:%x", image, sha256.Sum256(body)), nil
+}
+
+// session sends the requests of a repository, authenticating once when the
+// registry asks for it.
+type session struct {
+	resolver      *Resolver
+	baseURL       string
+	creds         Credentials
+	hasCreds      bool
+	authorization string
+}
+
+func (r *Resolver) session(ref Reference, keychain Keychain) *session {
+	host := ref.Registry
+	if host == dockerHub {
+		host = dockerHubRegistry
+	}
+	creds, hasCreds := keychain[ref.Registry]
+	return &session{
+		resolver: r,
+		baseURL:  fmt.Sprintf("https://%s/v2/%s/", host, ref.Repository),
+		creds:    creds,
+		hasCreds: hasCreds,
+	}
+}
+
+func (s *session) do(ctx context.Context, method, path string, accept []string) (*http.Response, error) {
+	resp, err := s.send(ctx, method, path, accept)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || len(s.authorization) != 0 {
+		return resp, err
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+	if s.authorization, err = s.resolver.authorize(ctx, challenge, s.creds, s.hasCreds); err != nil {
+		return nil, fmt.Errorf("failed to authenticate to %s: %v", s.baseURL, err)
+	}
+	return s.send(ctx, method, path, accept)
+}
+
+// get returns the body of a successful GET.
+func (s *session) get(ctx context.Context, path string, accept []string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, path, accept)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (s *session) send(ctx context.Context, method, path string, accept []string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(accept) != 0 {
+		req.Header.Set("Accept", strings.Join(accept, ", "))
+	}
+	if len(s.authorization) != 0 {
+		req.Header.Set("Authorization", s.authorization)
+	}
+	return s.resolver.HTTPClient.Do(req)
+}
+
+// StatusError is returned when the registry answers with an error status.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return e.Status
+}
+
+// authorize answers the challenge of a registry with the Authorization header
+// to retry with: the credentials themselves for Basic, or a token for Bearer.
+func (r *Resolver) authorize(ctx context.Context, challenge string, creds Credentials, hasCreds bool) (string, error) {
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if !hasCreds {
+			return "", fmt.Errorf("credentials required")
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(creds.Username+":"+creds.Password)), nil
+	case "bearer":
+	default:
+		return "", fmt.Errorf("unsupported authentication challenge %q", challenge)
+	}
+
+	tokenURL, err := url.Parse(params["realm"])
+	if err != nil || len(tokenURL.Host) == 0 {
+		return "", fmt.Errorf("invalid token realm %q", params["realm"])
+	}
+	query := tokenURL.Query()
+	for _, key := range []string{"service", "scope"} {
+		if len(params[key]) != 0 {
+			query.Set(key, params[key])
+		}
+	}
+	tokenURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if hasCreds {
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+	resp, err := r.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request failed: %s", resp.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("invalid token response: %v", err)
+	}
+	if len(token.Token) == 0 {
+		token.Token = token.AccessToken
+	}
+	return "Bearer " + token.Token, nil
+}
+
+// parseChallenge parses a WWW-Authenticate header such as
+// Bearer realm="https://auth.docker.io/token",service="registry.docker.io".
+func parseChallenge(challenge string) (string, map[string]string) {
+	scheme, rest, _ := cut(strings.TrimSpace(challenge), " ")
+	params := map[string]string{}
+	for len(rest) != 0 {
+		var key, value string
+		key, rest, _ = cut(strings.TrimLeft(rest, " ,"), "=")
+		if strings.HasPrefix(rest, `"`) {
+			value, rest, _ = cut(rest[1:], `"`)
+		} else {
+			value, rest, _ = cut(rest, ",")
+		}
+		if len(key) != 0 {
+			params[strings.ToLower(strings.TrimSpace(key))] = value
+		}
+	}
+	return scheme, params
+}
+
+// cut slices s around the first instance of sep, as strings.Cut of Go 1.18.
+func cut(s, sep string) (string, string, bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,264 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		image   string
+		want    Reference
+		wantErr bool
+	}{
+		{
+			image: "nginx",
+			want:  Reference{Registry: "docker.io", Repository: "library/nginx", Tag: "latest"},
+		},
+		{
+			image: "nginx:1.21",
+			want:  Reference{Registry: "docker.io", Repository: "library/nginx", Tag: "1.21"},
+		},
+		{
+			image: "bitnami/redis:7.0",
+			want:  Reference{Registry: "docker.io", Repository: "bitnami/redis", Tag: "7.0"},
+		},
+		{
+			image: "quay.io/prometheus/prometheus",
+			want:  Reference{Registry: "quay.io", Repository: "prometheus/prometheus", Tag: "latest"},
+		},
+		{
+			image: "localhost:5000/web:v1",
+			want:  Reference{Registry: "localhost:5000", Repository: "web", Tag: "v1"},
+		},
+		{
+			image: "localhost/web",
+			want:  Reference{Registry: "localhost", Repository: "web", Tag: "latest"},
+		},
+		{
+			image: "nginx:1.21@sha256:abc",
+			want:  Reference{Registry: "docker.io", Repository: "library/nginx", Tag: "1.21", Digest: "sha256:abc"},
+		},
+		{
+			image: "registry.example.com/team/web@sha256:abc",
+			want:  Reference{Registry: "registry.example.com", Repository: "team/web", Digest: "sha256:abc"},
+		},
+		{
+			image:   ":latest",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			got, err := ParseReference(tt.image)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseReference() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseReference() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeychainFromSecrets(t *testing.T) {
+	secrets := []corev1.Secret{
+		{
+			Type: corev1.SecretTypeDockerConfigJson,
+			Data: map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths": {
+				"https://index.docker.io/v1/": {"auth": "dXNlcjpwYXNzOndvcmQ="},
+				"registry.example.com": {"username": "robot", "password": "secret"}
+			}}`)},
+		},
+		{
+			Type: corev1.SecretTypeDockercfg,
+			Data: map[string][]byte{corev1.DockerConfigKey: []byte(`{
+				"registry.example.com": {"username": "other", "password": "other"},
+				"quay.io": {"username": "quay", "password": "quay"}
+			}`)},
+		},
+		{
+			Type: corev1.SecretTypeOpaque,
+			Data: map[string][]byte{"token": []byte("ignored")},
+		},
+	}
+
+	keychain, err := KeychainFromSecrets(secrets)
+	if err != nil {
+		t.Fatalf("KeychainFromSecrets() error = %v", err)
+	}
+	want := Keychain{
+		// The password may contain colons.
+		"docker.io": {Username: "user", Password: "pass:word"},
+		// The first secret wins.
+		"registry.example.com": {Username: "robot", Password: "secret"},
+		"quay.io":              {Username: "quay", Password: "quay"},
+	}
+	if len(keychain) != len(want) {
+		t.Fatalf("KeychainFromSecrets() = %v, want %v", keychain, want)
+	}
+	for host, creds := range want {
+		if keychain[host] != creds {
+			t.Errorf("KeychainFromSecrets() %s = %+v, want %+v", host, keychain[host], creds)
+		}
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"`)
+	if scheme != "Bearer" {
+		t.Errorf("parseChallenge() scheme = %q, want Bearer", scheme)
+	}
+	want := map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:library/nginx:pull",
+	}
+	if len(params) != len(want) {
+		t.Fatalf("parseChallenge() params = %v, want %v", params, want)
+	}
+	for key, value := range want {
+		if params[key] != value {
+			t.Errorf("parseChallenge() %s = %q, want %q", key, params[key], value)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	manifest := []byte(`{"schemaVersion": 2}`)
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(manifest))
+
+	tests := []struct {
+		name string
+		// auth is the authentication the registry requires, none, basic or bearer.
+		auth       string
+		noDigest   bool
+		image      string
+		keychain   bool
+		wantDigest string
+		wantErr    bool
+	}{
+		{
+			name:       "digest header",
+			image:      "web:v1",
+			wantDigest: "sha256:0123",
+		},
+		{
+			name:       "digest of the manifest",
+			noDigest:   true,
+			image:      "web:v1",
+			wantDigest: digest,
+		},
+		{
+			name:       "bearer token",
+			auth:       "bearer",
+			image:      "team/web:v1",
+			wantDigest: "sha256:0123",
+		},
+		{
+			name:       "basic credentials",
+			auth:       "basic",
+			keychain:   true,
+			image:      "web:v1",
+			wantDigest: "sha256:0123",
+		},
+		{
+			name:    "basic without credentials",
+			auth:    "basic",
+			image:   "web:v1",
+			wantErr: true,
+		},
+		{
+			name:    "unknown tag",
+			image:   "web:missing",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var server *httptest.Server
+			server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if req.URL.Path == "/token" {
+					if req.URL.Query().Get("scope") != "repository:team/web:pull" {
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+					fmt.Fprint(w, `{"token": "t0ken"}`)
+					return
+				}
+
+				authorized := true
+				switch tt.auth {
+				case "basic":
+					user, password, ok := req.BasicAuth()
+					authorized = ok && user == "robot" && password == "secret"
+					w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+				case "bearer":
+					authorized = req.Header.Get("Authorization") == "Bearer t0ken"
+					w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",scope="repository:team/web:pull"`, server.URL))
+				}
+				if !authorized {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				if !strings.HasSuffix(req.URL.Path, "/manifests/v1") {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				if !tt.noDigest {
+					w.Header().Set("Docker-Content-Digest", "sha256:0123")
+				}
+				w.Write(manifest)
+			}))
+			defer server.Close()
+
+			host := strings.TrimPrefix(server.URL, "https://")
+			var keychain Keychain
+			if tt.keychain {
+				keychain = Keychain{host: {Username: "robot", Password: "secret"}}
+			}
+			r := &Resolver{HTTPClient: server.Client()}
+			image := host + "/" + tt.image
+			got, err := r.Resolve(context.TODO(), image, keychain)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resolve() error = %v, want error %v", err, tt.wantErr)
+			}
+			if want := image + "@" + tt.wantDigest; !tt.wantErr && got != want {
+				t.Errorf("Resolve() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestResolvePinned(t *testing.T) {
+	r := &Resolver{}
+	image := "nginx:1.21@sha256:0123"
+	if got, err := r.Resolve(context.TODO(), image, nil); err != nil || got != image {
+		t.Errorf("Resolve() = %q, %v, want %q", got, err, image)
+	}
+}
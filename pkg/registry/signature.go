@@ -0,0 +1,117 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The annotations of the layers of a cosign signature manifest.
+const (
+	signatureAnnotation   = "dev.cosignproject.cosign/signature"
+	certificateAnnotation = "dev.sigstore.cosign/certificate"
+	chainAnnotation       = "dev.sigstore.cosign/chain"
+	bundleAnnotation      = "dev.sigstore.cosign/bundle"
+)
+
+// signatureManifestTypes are the manifests signatures are stored as.
+var signatureManifestTypes = []string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Signature is a cosign signature of an image.
+type Signature struct {
+	// Payload is the signed simple signing document, naming the image digest.
+	Payload []byte
+	// Signature is the signature of the payload.
+	Signature []byte
+	// Certificate and Chain are the PEM encoded signing certificate and its
+	// intermediates, set for keyless signatures.
+	Certificate []byte
+	Chain       []byte
+	// Bundle is the transparency log entry of a keyless signature.
+	Bundle []byte
+}
+
+// Signatures returns the cosign signatures of the image, which must be pinned
+// to a digest. cosign stores them in the repository of the image, under the
+// tag sha256-<digest>.sig. An image without signatures has none.
+func (r *Resolver) Signatures(ctx context.Context, image string, keychain Keychain) ([]Signature, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return nil, err
+	}
+	if len(ref.Digest) == 0 {
+		return nil, fmt.Errorf("image %s is not pinned to a digest", image)
+	}
+
+	s := r.session(ref, keychain)
+	tag := strings.Replace(ref.Digest, ":", "-", 1) + ".sig"
+	body, err := s.get(ctx, "manifests/"+tag, signatureManifestTypes)
+	if err != nil {
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get the signatures of %s: %v", image, err)
+	}
+
+	var manifest struct {
+		Layers []struct {
+			Digest      string            `json:"digest"`
+			Annotations map[string]string `json:"annotations"`
+		} `json:"layers"`
+	}
+	if err = json.Unmarshal(body, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid signature manifest of %s: %v", image, err)
+	}
+
+	signatures := make([]Signature, 0, len(manifest.Layers))
+	for _, layer := range manifest.Layers {
+		encoded, ok := layer.Annotations[signatureAnnotation]
+		if !ok {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signature of %s: %v", image, err)
+		}
+		payload, err := s.get(ctx, "blobs/"+layer.Digest, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the signature payload of %s: %v", image, err)
+		}
+		if digest := fmt.Sprintf("sha256:%x", sha256.Sum256(payload)); digest != layer.Digest {
+			return nil, fmt.Errorf("signature payload of %s doesn't match its digest %s", image, layer.Digest)
+		}
+		signatures = append(signatures, Signature{
+			Payload:     payload,
+			Signature:   sig,
+			Certificate: []byte(layer.Annotations[certificateAnnotation]),
+			Chain:       []byte(layer.Annotations[chainAnnotation]),
+			Bundle:      []byte(layer.Annotations[bundleAnnotation]),
+		})
+	}
+	return signatures, nil
+}
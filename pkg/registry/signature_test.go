@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSignatures(t *testing.T) {
+	payload := []byte(`{"critical":{"image":{"docker-manifest-digest":"sha256:0123"}}}`)
+	payloadDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(payload))
+	sig := base64.StdEncoding.EncodeToString([]byte("signature"))
+
+	tests := []struct {
+		name string
+		// manifest is the signature manifest served, none when empty.
+		manifest string
+		image    string
+		want     int
+		wantErr  bool
+	}{
+		{
+			name: "signed",
+			manifest: fmt.Sprintf(`{"layers": [
+				{"digest": %q, "annotations": {"dev.cosignproject.cosign/signature": %q, "dev.sigstore.cosign/certificate": "cert"}},
+				{"digest": "sha256:ffff", "annotations": {}}
+			]}`, payloadDigest, sig),
+			image: "web@sha256:0123",
+			want:  1,
+		},
+		{
+			name:  "unsigned",
+			image: "web@sha256:0123",
+		},
+		{
+			name: "payload not matching its digest",
+			manifest: fmt.Sprintf(`{"layers": [
+				{"digest": "sha256:ffff", "annotations": {"dev.cosignproject.cosign/signature": %q}}
+			]}`, sig),
+			image:   "web@sha256:0123",
+			wantErr: true,
+		},
+		{
+			name:    "not pinned",
+			image:   "web:v1",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				switch {
+				case req.URL.Path == "/v2/web/manifests/sha256-0123.sig" && len(tt.manifest) != 0:
+					fmt.Fprint(w, tt.manifest)
+				case strings.HasPrefix(req.URL.Path, "/v2/web/blobs/"):
+					w.Write(payload)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			r := &Resolver{HTTPClient: server.Client()}
+			image := strings.TrimPrefix(server.URL, "https://") + "/" + tt.image
+			signatures, err := r.Signatures(context.TODO(), image, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Signatures() error = %v, want error %v", err, tt.wantErr)
+			}
+			if len(signatures) != tt.want {
+				t.Fatalf("Signatures() = %d signatures, want %d", len(signatures), tt.want)
+			}
+			for _, s := range signatures {
+				if string(s.Payload) != string(payload) || string(s.Signature) != "signature" || string(s.Certificate) != "cert" {
+					t.Errorf("Signatures() = %+v, want the served signature", s)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,352 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package signature verifies cosign image signatures.
+//
+// Signatures made with a key are verified against the configured public keys.
+// Keyless signatures are verified against the Fulcio roots and the Rekor keys
+// of the TrustRoot: the signing certificate must chain to a Fulcio root and be
+// valid when the Rekor entry was logged, the signed entry timestamp must be
+// signed by Rekor and match the signature, and the certificate must belong to
+// one of the accepted identities.
+package signature
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/caoyingjunz/podset-operator/pkg/registry"
+)
+
+// The extensions of Fulcio certificates holding the OIDC issuer of the signer.
+var (
+	oidIssuerV1 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// Identity is a keyless signer: the OIDC issuer of its certificate and the
+// email or URI it was issued to.
+type Identity struct {
+	Issuer  string
+	Subject string
+}
+
+// TrustRoot holds the roots keyless signatures are verified against.
+type TrustRoot struct {
+	FulcioRoots *x509.CertPool
+	RekorKeys   []crypto.PublicKey
+}
+
+// LoadTrustRoot reads the PEM encoded Fulcio root certificates and Rekor
+// public keys. It returns nil when neither is set, keyless signatures are
+// then refused.
+func LoadTrustRoot(fulcioRootsFile, rekorKeysFile string) (*TrustRoot, error) {
+	if len(fulcioRootsFile) == 0 && len(rekorKeysFile) == 0 {
+		return nil, nil
+	}
+	if len(fulcioRootsFile) == 0 || len(rekorKeysFile) == 0 {
+		return nil, errors.New("both the Fulcio roots and the Rekor keys are required to verify keyless signatures")
+	}
+
+	data, err := os.ReadFile(fulcioRootsFile)
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates in %s", fulcioRootsFile)
+	}
+	if data, err = os.ReadFile(rekorKeysFile); err != nil {
+		return nil, err
+	}
+	keys, err := ParsePublicKeys(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Rekor keys in %s: %v", rekorKeysFile, err)
+	}
+	return &TrustRoot{FulcioRoots: roots, RekorKeys: keys}, nil
+}
+
+// ParsePublicKeys parses the PEM encoded public keys.
+func ParsePublicKeys(data string) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	rest := []byte(data)
+	for {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no PEM encoded public key")
+	}
+	return keys, nil
+}
+
+// Verifier accepts images signed by one of its keys or identities.
+type Verifier struct {
+	Keys       []crypto.PublicKey
+	Identities []Identity
+	TrustRoot  *TrustRoot
+}
+
+// Verify returns nil when one of the signatures is valid for the digest.
+func (v *Verifier) Verify(digest string, signatures []registry.Signature) error {
+	if len(signatures) == 0 {
+		return errors.New("no signatures")
+	}
+	var errs []string
+	for i := range signatures {
+		err := v.verify(digest, &signatures[i])
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return fmt.Errorf("no valid signature: %s", joinUnique(errs))
+}
+
+func (v *Verifier) verify(digest string, sig *registry.Signature) error {
+	var payload struct {
+		Critical struct {
+			Image struct {
+				DockerManifestDigest string `json:"docker-manifest-digest"`
+			} `json:"image"`
+			Type string `json:"type"`
+		} `json:"critical"`
+	}
+	if err := json.Unmarshal(sig.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid payload: %v", err)
+	}
+	if payload.Critical.Image.DockerManifestDigest != digest {
+		return fmt.Errorf("signature is for digest %s", payload.Critical.Image.DockerManifestDigest)
+	}
+
+	if len(sig.Certificate) == 0 {
+		for _, key := range v.Keys {
+			if verifySignature(key, sig.Payload, sig.Signature) == nil {
+				return nil
+			}
+		}
+		return errors.New("not signed by an accepted key")
+	}
+	return v.verifyKeyless(sig)
+}
+
+func (v *Verifier) verifyKeyless(sig *registry.Signature) error {
+	if len(v.Identities) == 0 {
+		return errors.New("keyless signatures are not accepted")
+	}
+	if v.TrustRoot == nil {
+		return errors.New("keyless signatures can't be verified, no Fulcio roots and Rekor keys are configured")
+	}
+
+	block, _ := pem.Decode(sig.Certificate)
+	if block == nil {
+		return errors.New("invalid signing certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("invalid signing certificate: %v", err)
+	}
+
+	// Fulcio certificates expire minutes after they are issued, they must be
+	// valid when the signature was logged.
+	integratedTime, err := v.verifyBundle(sig)
+	if err != nil {
+		return err
+	}
+	intermediates := x509.NewCertPool()
+	intermediates.AppendCertsFromPEM(sig.Chain)
+	if _, err = cert.Verify(x509.VerifyOptions{
+		Roots:         v.TrustRoot.FulcioRoots,
+		Intermediates: intermediates,
+		CurrentTime:   integratedTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		return fmt.Errorf("untrusted signing certificate: %v", err)
+	}
+
+	if err = v.verifyIdentity(cert); err != nil {
+		return err
+	}
+	return verifySignature(cert.PublicKey, sig.Payload, sig.Signature)
+}
+
+// verifyBundle checks the Rekor entry of the signature and returns when it
+// was logged.
+func (v *Verifier) verifyBundle(sig *registry.Signature) (time.Time, error) {
+	if len(sig.Bundle) == 0 {
+		return time.Time{}, errors.New("keyless signature has no transparency log entry")
+	}
+	var bundle struct {
+		SignedEntryTimestamp []byte `json:"SignedEntryTimestamp"`
+		Payload              struct {
+			Body           string `json:"body"`
+			IntegratedTime int64  `json:"integratedTime"`
+			LogIndex       int64  `json:"logIndex"`
+			LogID          string `json:"logID"`
+		} `json:"Payload"`
+	}
+	if err := json.Unmarshal(sig.Bundle, &bundle); err != nil {
+		return time.Time{}, fmt.Errorf("invalid transparency log entry: %v", err)
+	}
+
+	// The timestamp signs the canonical JSON of the payload, whose keys are sorted.
+	var canonical bytes.Buffer
+	encoder := json.NewEncoder(&canonical)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(map[string]interface{}{
+		"body":           bundle.Payload.Body,
+		"integratedTime": bundle.Payload.IntegratedTime,
+		"logIndex":       bundle.Payload.LogIndex,
+		"logID":          bundle.Payload.LogID,
+	}); err != nil {
+		return time.Time{}, err
+	}
+	signed := bytes.TrimSuffix(canonical.Bytes(), []byte("\n"))
+	verified := false
+	for _, key := range v.TrustRoot.RekorKeys {
+		if verifySignature(key, signed, bundle.SignedEntryTimestamp) == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return time.Time{}, errors.New("transparency log entry is not signed by a trusted Rekor key")
+	}
+
+	// The entry must be the one of this signature.
+	body, err := base64.StdEncoding.DecodeString(bundle.Payload.Body)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid transparency log entry body: %v", err)
+	}
+	var entry struct {
+		Spec struct {
+			Signature struct {
+				Content string `json:"content"`
+			} `json:"signature"`
+			Data struct {
+				Hash struct {
+					Algorithm string `json:"algorithm"`
+					Value     string `json:"value"`
+				} `json:"hash"`
+			} `json:"data"`
+		} `json:"spec"`
+	}
+	if err = json.Unmarshal(body, &entry); err != nil {
+		return time.Time{}, fmt.Errorf("invalid transparency log entry body: %v", err)
+	}
+	payloadHash := sha256.Sum256(sig.Payload)
+	if entry.Spec.Signature.Content != base64.StdEncoding.EncodeToString(sig.Signature) ||
+		entry.Spec.Data.Hash.Algorithm != "sha256" || entry.Spec.Data.Hash.Value != hex.EncodeToString(payloadHash[:]) {
+		return time.Time{}, errors.New("transparency log entry doesn't match the signature")
+	}
+	return time.Unix(bundle.Payload.IntegratedTime, 0), nil
+}
+
+func (v *Verifier) verifyIdentity(cert *x509.Certificate) error {
+	issuer := certificateIssuer(cert)
+	subjects := append([]string{}, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		subjects = append(subjects, uri.String())
+	}
+	for _, identity := range v.Identities {
+		if identity.Issuer != issuer {
+			continue
+		}
+		for _, subject := range subjects {
+			if subject == identity.Subject {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("signer %v of issuer %s is not accepted", subjects, issuer)
+}
+
+// certificateIssuer returns the OIDC issuer of a Fulcio certificate.
+func certificateIssuer(cert *x509.Certificate) string {
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidIssuerV2):
+			var issuer string
+			if _, err := asn1.Unmarshal(ext.Value, &issuer); err == nil {
+				return issuer
+			}
+		case ext.Id.Equal(oidIssuerV1):
+			return string(ext.Value)
+		}
+	}
+	return ""
+}
+
+func verifySignature(key crypto.PublicKey, payload, sig []byte) error {
+	digest := sha256.Sum256(payload)
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if ecdsa.VerifyASN1(k, digest[:], sig) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if ed25519.Verify(k, payload, sig) {
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+	return errors.New("invalid signature")
+}
+
+func joinUnique(errs []string) string {
+	seen := map[string]bool{}
+	var out []string
+	for _, err := range errs {
+		if !seen[err] {
+			seen[err] = true
+			out = append(out, err)
+		}
+	}
+	var buf bytes.Buffer
+	for i, err := range out {
+		if i > 0 {
+			buf.WriteString("; ")
+		}
+		buf.WriteString(err)
+	}
+	return buf.String()
+}
@@ -0,0 +1,293 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/caoyingjunz/podset-operator/pkg/registry"
+)
+
+const testDigest = "sha256:0123456789abcdef"
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func sign(t *testing.T, key *ecdsa.PrivateKey, data []byte) []byte {
+	digest := sha256.Sum256(data)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+func payloadFor(digest string) []byte {
+	return []byte(`{"critical":{"identity":{"docker-reference":"registry.example.com/web"},` +
+		`"image":{"docker-manifest-digest":"` + digest + `"},"type":"cosign container image signature"},"optional":null}`)
+}
+
+func TestVerifyKey(t *testing.T) {
+	key, otherKey := newKey(t), newKey(t)
+	payload := payloadFor(testDigest)
+
+	tests := []struct {
+		name       string
+		signatures []registry.Signature
+		wantErr    bool
+	}{
+		{
+			name:       "signed by an accepted key",
+			signatures: []registry.Signature{{Payload: payload, Signature: sign(t, key, payload)}},
+		},
+		{
+			name: "one valid signature of several",
+			signatures: []registry.Signature{
+				{Payload: payload, Signature: sign(t, otherKey, payload)},
+				{Payload: payload, Signature: sign(t, key, payload)},
+			},
+		},
+		{
+			name:       "signed by another key",
+			signatures: []registry.Signature{{Payload: payload, Signature: sign(t, otherKey, payload)}},
+			wantErr:    true,
+		},
+		{
+			name:       "signature of another image",
+			signatures: []registry.Signature{{Payload: payloadFor("sha256:fedcba"), Signature: sign(t, key, payloadFor("sha256:fedcba"))}},
+			wantErr:    true,
+		},
+		{
+			name:       "tampered payload",
+			signatures: []registry.Signature{{Payload: payload, Signature: sign(t, key, payloadFor("sha256:fedcba"))}},
+			wantErr:    true,
+		},
+		{
+			name:    "no signatures",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Verifier{Keys: []crypto.PublicKey{&key.PublicKey}}
+			if err := v.Verify(testDigest, tt.signatures); (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// keyless signs payloads the way cosign does without a key: with a short-lived
+// certificate issued by a Fulcio root and an entry logged by Rekor.
+type keyless struct {
+	root     *x509.Certificate
+	rootKey  *ecdsa.PrivateKey
+	rekorKey *ecdsa.PrivateKey
+	issued   time.Time
+}
+
+func newKeyless(t *testing.T) *keyless {
+	rootKey := newKey(t)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fulcio"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &keyless{root: root, rootKey: rootKey, rekorKey: newKey(t), issued: time.Now().Add(-30 * time.Minute)}
+}
+
+func (k *keyless) trustRoot() *TrustRoot {
+	roots := x509.NewCertPool()
+	roots.AddCert(k.root)
+	return &TrustRoot{FulcioRoots: roots, RekorKeys: []crypto.PublicKey{&k.rekorKey.PublicKey}}
+}
+
+func (k *keyless) sign(t *testing.T, email string, logged time.Time, rekorKey *ecdsa.PrivateKey) registry.Signature {
+	key := newKey(t)
+	issuer, err := asn1.Marshal("https://accounts.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       k.issued,
+		NotAfter:        k.issued.Add(10 * time.Minute),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		EmailAddresses:  []string{email},
+		ExtraExtensions: []pkix.Extension{{Id: oidIssuerV2, Value: issuer}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, k.root, &key.PublicKey, k.rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := payloadFor(testDigest)
+	sig := sign(t, key, payload)
+	payloadHash := sha256.Sum256(payload)
+	body, _ := json.Marshal(map[string]interface{}{
+		"kind": "hashedrekord",
+		"spec": map[string]interface{}{
+			"signature": map[string]interface{}{"content": base64.StdEncoding.EncodeToString(sig)},
+			"data":      map[string]interface{}{"hash": map[string]string{"algorithm": "sha256", "value": hex.EncodeToString(payloadHash[:])}},
+		},
+	})
+	entry := map[string]interface{}{
+		"body":           base64.StdEncoding.EncodeToString(body),
+		"integratedTime": logged.Unix(),
+		"logIndex":       int64(42),
+		"logID":          "rekor",
+	}
+	// The keys of the canonical JSON signed by Rekor are sorted, as json.Marshal
+	// sorts the keys of maps.
+	signed, _ := json.Marshal(entry)
+	bundle, _ := json.Marshal(map[string]interface{}{
+		"SignedEntryTimestamp": sign(t, rekorKey, signed),
+		"Payload":              entry,
+	})
+
+	return registry.Signature{
+		Payload:     payload,
+		Signature:   sig,
+		Certificate: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		Bundle:      bundle,
+	}
+}
+
+func TestVerifyKeyless(t *testing.T) {
+	k := newKeyless(t)
+	identity := Identity{Issuer: "https://accounts.example.com", Subject: "dev@example.com"}
+	inTime := k.issued.Add(time.Minute)
+
+	tests := []struct {
+		name       string
+		signature  registry.Signature
+		identities []Identity
+		trustRoot  *TrustRoot
+		wantErr    bool
+	}{
+		{
+			name:       "accepted identity",
+			signature:  k.sign(t, "dev@example.com", inTime, k.rekorKey),
+			identities: []Identity{identity},
+			trustRoot:  k.trustRoot(),
+		},
+		{
+			name:       "other identity",
+			signature:  k.sign(t, "someone@example.com", inTime, k.rekorKey),
+			identities: []Identity{identity},
+			trustRoot:  k.trustRoot(),
+			wantErr:    true,
+		},
+		{
+			name:       "other issuer",
+			signature:  k.sign(t, "dev@example.com", inTime, k.rekorKey),
+			identities: []Identity{{Issuer: "https://issuer.example.com", Subject: "dev@example.com"}},
+			trustRoot:  k.trustRoot(),
+			wantErr:    true,
+		},
+		{
+			name:       "logged after the certificate expired",
+			signature:  k.sign(t, "dev@example.com", k.issued.Add(time.Hour), k.rekorKey),
+			identities: []Identity{identity},
+			trustRoot:  k.trustRoot(),
+			wantErr:    true,
+		},
+		{
+			name:       "entry not signed by Rekor",
+			signature:  k.sign(t, "dev@example.com", inTime, newKey(t)),
+			identities: []Identity{identity},
+			trustRoot:  k.trustRoot(),
+			wantErr:    true,
+		},
+		{
+			name:       "untrusted root",
+			signature:  k.sign(t, "dev@example.com", inTime, k.rekorKey),
+			identities: []Identity{identity},
+			trustRoot:  newKeyless(t).trustRoot(),
+			wantErr:    true,
+		},
+		{
+			name:       "no trust root",
+			signature:  k.sign(t, "dev@example.com", inTime, k.rekorKey),
+			identities: []Identity{identity},
+			wantErr:    true,
+		},
+		{
+			name:      "keyless not accepted",
+			signature: k.sign(t, "dev@example.com", inTime, k.rekorKey),
+			trustRoot: k.trustRoot(),
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Verifier{Identities: tt.identities, TrustRoot: tt.trustRoot}
+			if err := v.Verify(testDigest, []registry.Signature{tt.signature}); (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyKeylessTamperedSignature(t *testing.T) {
+	k := newKeyless(t)
+	sig := k.sign(t, "dev@example.com", k.issued.Add(time.Minute), k.rekorKey)
+	// The signature no longer matches the logged entry.
+	sig.Signature = sign(t, newKey(t), sig.Payload)
+
+	v := &Verifier{
+		Identities: []Identity{{Issuer: "https://accounts.example.com", Subject: "dev@example.com"}},
+		TrustRoot:  k.trustRoot(),
+	}
+	if err := v.Verify(testDigest, []registry.Signature{sig}); err == nil {
+		t.Error("Verify() error = nil, want an error")
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	configMapDataKey = "snapshot.json"
+
+	snapshotLabel = "pixiu.pixiu.io/podset-snapshot"
+)
+
+// ConfigMapStore keeps snapshots in ConfigMaps. When Namespace is empty the
+// ConfigMap lives next to the PodSet, otherwise all snapshots are written to
+// the given namespace.
+type ConfigMapStore struct {
+	Client    client.Client
+	Namespace string
+}
+
+var _ Store = &ConfigMapStore{}
+
+func (s *ConfigMapStore) configMapKey(key types.NamespacedName) types.NamespacedName {
+	if len(s.Namespace) == 0 {
+		return types.NamespacedName{Namespace: key.Namespace, Name: key.Name + "-snapshot"}
+	}
+	return types.NamespacedName{Namespace: s.Namespace, Name: key.Namespace + "." + key.Name + "-snapshot"}
+}
+
+func (s *ConfigMapStore) Save(ctx context.Context, snap *Snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	key := s.configMapKey(types.NamespacedName{Namespace: snap.Namespace, Name: snap.Name})
+	cm := &corev1.ConfigMap{}
+	if err = s.Client.Get(ctx, key, cm); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		cm.Namespace = key.Namespace
+		cm.Name = key.Name
+		cm.Labels = map[string]string{snapshotLabel: snap.Name}
+		cm.Data = map[string]string{configMapDataKey: string(data)}
+		return s.Client.Create(ctx, cm)
+	}
+	// Never overwrite a ConfigMap that merely has the name of a snapshot.
+	if _, ok := cm.Labels[snapshotLabel]; !ok {
+		return fmt.Errorf("configmap %s/%s already exists and is not a snapshot", cm.Namespace, cm.Name)
+	}
+
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[configMapDataKey] = string(data)
+	return s.Client.Update(ctx, cm)
+}
+
+func (s *ConfigMapStore) Load(ctx context.Context, key types.NamespacedName) (*Snapshot, error) {
+	cm := &corev1.ConfigMap{}
+	if err := s.Client.Get(ctx, s.configMapKey(key), cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	data, ok := cm.Data[configMapDataKey]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	snap := &Snapshot{}
+	if err := json.Unmarshal([]byte(data), snap); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot configmap %s/%s: %v", cm.Namespace, cm.Name, err)
+	}
+	return snap, nil
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	pixiutypes "github.com/caoyingjunz/podset-operator/pkg/types"
+	"github.com/caoyingjunz/podset-operator/pkg/util"
+)
+
+// DefaultHistoryLimit is the number of revisions kept in a snapshot when
+// no explicit limit is configured.
+const DefaultHistoryLimit = 10
+
+// ErrNotFound is returned by a Store when no snapshot exists for a PodSet.
+var ErrNotFound = errors.New("snapshot not found")
+
+// Revision is a single recorded version of a PodSet spec.
+type Revision struct {
+	Generation int64                    `json:"generation"`
+	Timestamp  metav1.Time              `json:"timestamp"`
+	Spec       pixiuv1alpha1.PodSetSpec `json:"spec"`
+}
+
+// Snapshot is the document persisted to external storage for a PodSet.
+// Revisions are ordered from the oldest to the newest one.
+type Snapshot struct {
+	Namespace string     `json:"namespace"`
+	Name      string     `json:"name"`
+	UID       types.UID  `json:"uid,omitempty"`
+	Revisions []Revision `json:"revisions"`
+}
+
+// Store persists and loads PodSet snapshots.
+type Store interface {
+	// Save writes the snapshot, overwriting any previous one.
+	Save(ctx context.Context, s *Snapshot) error
+	// Load returns the snapshot of the given PodSet, or ErrNotFound.
+	Load(ctx context.Context, key types.NamespacedName) (*Snapshot, error)
+}
+
+// Latest returns the newest revision of the snapshot, or nil if it is empty.
+func (s *Snapshot) Latest() *Revision {
+	if len(s.Revisions) == 0 {
+		return nil
+	}
+	return &s.Revisions[len(s.Revisions)-1]
+}
+
+// Revision returns the revision recorded for the given generation; a
+// generation of 0 selects the latest revision.
+func (s *Snapshot) Revision(generation int64) *Revision {
+	if generation == 0 {
+		return s.Latest()
+	}
+	for i := range s.Revisions {
+		if s.Revisions[i].Generation == generation {
+			return &s.Revisions[i]
+		}
+	}
+	return nil
+}
+
+// Record appends the current spec of podSet to the snapshot history, keeping
+// at most historyLimit revisions. It is a no-op if the generation is already
+// recorded as the latest revision.
+func (s *Snapshot) Record(podSet *pixiuv1alpha1.PodSet, historyLimit int) {
+	s.Namespace = podSet.Namespace
+	s.Name = podSet.Name
+	s.UID = podSet.UID
+
+	if latest := s.Latest(); latest != nil && latest.Generation == podSet.Generation {
+		return
+	}
+	s.Revisions = append(s.Revisions, Revision{
+		Generation: podSet.Generation,
+		Timestamp:  metav1.Now(),
+		Spec:       *podSet.Spec.DeepCopy(),
+	})
+
+	if historyLimit <= 0 {
+		historyLimit = DefaultHistoryLimit
+	}
+	if diff := len(s.Revisions) - historyLimit; diff > 0 {
+		s.Revisions = s.Revisions[diff:]
+	}
+}
+
+// Take records the current spec of podSet into the store.
+func Take(ctx context.Context, store Store, podSet *pixiuv1alpha1.PodSet, historyLimit int) error {
+	key := types.NamespacedName{Namespace: podSet.Namespace, Name: podSet.Name}
+	s, err := store.Load(ctx, key)
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		s = &Snapshot{}
+	}
+	// A snapshot left behind by a deleted PodSet with the same name starts a new history.
+	if len(s.UID) != 0 && s.UID != podSet.UID {
+		s = &Snapshot{}
+	}
+
+	s.Record(podSet, historyLimit)
+	return store.Save(ctx, s)
+}
+
+// Restore sets the spec of the PodSet, including its replica count, from the
+// snapshot revision with the given generation (0 for the latest). The PodSet is
+// created if it no longer exists.
+func Restore(ctx context.Context, c client.Client, store Store, key types.NamespacedName, generation int64) (*pixiuv1alpha1.PodSet, error) {
+	s, err := store.Load(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	rev := s.Revision(generation)
+	if rev == nil {
+		return nil, fmt.Errorf("snapshot of %s has no revision for generation %d", key, generation)
+	}
+
+	podSet := &pixiuv1alpha1.PodSet{}
+	if err = c.Get(ctx, key, podSet); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		podSet = &pixiuv1alpha1.PodSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+			Spec:       *rev.Spec.DeepCopy(),
+		}
+		if err = c.Create(ctx, podSet); err != nil {
+			return nil, err
+		}
+		return podSet, nil
+	}
+
+	podSet.Spec = *rev.Spec.DeepCopy()
+	delete(podSet.Annotations, pixiutypes.RestoreSnapshotAnnotation)
+	if err = util.SetControllerSpecHash(podSet); err != nil {
+		return nil, err
+	}
+	if err = c.Update(ctx, podSet); err != nil {
+		return nil, err
+	}
+	return podSet, nil
+}
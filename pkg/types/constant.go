@@ -17,7 +17,88 @@ limitations under the License.
 package types
 
 const (
-	PodSetKind = "PodSet"
+	PodSetKind        = "PodSet"
+	ClusterPodSetKind = "ClusterPodSet"
 
 	BurstReplicas = 500
 )
+
+const (
+	// ClusterPodSetLabel is set on the PodSets maintained by a ClusterPodSet to its name.
+	ClusterPodSetLabel = "pixiu.pixiu.io/cluster-podset"
+
+	// PrePullLabel and PrePullRevisionLabel are set on the pods pulling the
+	// images of a new template to the name of the PodSet and the template hash.
+	PrePullLabel         = "pixiu.pixiu.io/prepull"
+	PrePullRevisionLabel = "pixiu.pixiu.io/prepull-revision"
+
+	// PodTemplateHashLabel is set on every pod to the hash of the template it
+	// was created from, telling the pods of previous templates apart.
+	PodTemplateHashLabel = "pixiu.pixiu.io/pod-template-hash"
+
+	// PodSetRevisionLabel is set on the ControllerRevisions of a PodSet to its
+	// name.
+	PodSetRevisionLabel = "pixiu.pixiu.io/podset"
+
+	// StandbyLabel is set on the standby pods of a PodSet, which don't count
+	// as replicas until they are promoted.
+	StandbyLabel = "pixiu.pixiu.io/standby"
+)
+
+// PromotedConditionType is the readiness gate of the standby pods, its
+// condition is set when they are promoted.
+const PromotedConditionType = "pixiu.pixiu.io/promoted"
+
+// PodSetFinalizer holds the deletion of a PodSet until its pods are deleted or
+// orphaned, following its deletionPolicy.
+const PodSetFinalizer = "pixiu.pixiu.io/podset-pods"
+
+const (
+	// PodIndexAnnotation is set on every pod to its index within the PodSet,
+	// the lowest index not used by another active pod.
+	PodIndexAnnotation = "pixiu.pixiu.io/pod-index"
+
+	// RestoreSnapshotAnnotation requests the controller to restore the PodSet
+	// spec from its snapshot. The value is the generation to restore, or
+	// "latest" for the most recent one.
+	RestoreSnapshotAnnotation = "pixiu.pixiu.io/restore-snapshot"
+
+	// RollbackToAnnotation requests the controller to restore the template of
+	// the PodSet from one of its ControllerRevisions. The value is the number
+	// of the revision, or "previous" for the one before the current template.
+	RollbackToAnnotation = "pixiu.pixiu.io/rollback-to"
+
+	// ActivateAnnotation requests the controller to scale the PodSet up to
+	// its spec.activationReplicas, when it has fewer replicas. The value is
+	// ignored, the annotation is removed once handled.
+	ActivateAnnotation = "pixiu.pixiu.io/activate"
+
+	// PreDeleteHookCompletedAnnotation is set on a deleted PodSet once its
+	// pre-delete hook succeeded, so that it isn't called again.
+	PreDeleteHookCompletedAnnotation = "pixiu.pixiu.io/pre-delete-hook-completed"
+
+	// SourceURLAnnotation, SourceRevisionAnnotation and SourceSpecHashAnnotation
+	// declare where a PodSet is managed from: the git repository, its ref or
+	// commit, and the content hash of the spec declared there.
+	SourceURLAnnotation      = "pixiu.pixiu.io/source-url"
+	SourceRevisionAnnotation = "pixiu.pixiu.io/source-revision"
+	SourceSpecHashAnnotation = "pixiu.pixiu.io/source-spec-hash"
+	// ControllerSpecHashAnnotation is the spec hash of the last spec the
+	// controller wrote itself, e.g. on a snapshot restore. Drift detection
+	// doesn't report that spec as drift.
+	ControllerSpecHashAnnotation = "pixiu.pixiu.io/controller-spec-hash"
+
+	// ShareToNamespacesAnnotation lets PodSets copy an image pull secret into
+	// other namespaces. The value is a comma separated list of namespaces, or
+	// "*" for all of them.
+	ShareToNamespacesAnnotation = "pixiu.pixiu.io/share-to-namespaces"
+
+	// LiftSchedulingGatesAnnotation lifts scheduling gates of the PodSet from
+	// its pods without editing its spec. The value is a comma separated list
+	// of gates.
+	LiftSchedulingGatesAnnotation = "pixiu.pixiu.io/lift-scheduling-gates"
+
+	// SchedulingGatesAnnotation is set on the pods to the comma separated
+	// scheduling gates of the PodSet they still carry.
+	SchedulingGatesAnnotation = "pixiu.pixiu.io/scheduling-gates"
+)
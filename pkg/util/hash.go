@@ -0,0 +1,84 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/types"
+)
+
+// ComputeSpecHash returns the canonical content hash of the spec, the hash
+// GitOps tooling declares for a PodSet with the source-spec-hash annotation.
+//
+// It is the hex encoded sha256 sum of the JSON encoding of the spec, as
+// encoded by the Go types of this API, after normalizing it the way the API
+// server stores it: the metadata of the pod template is dropped, since the CRD
+// schema doesn't preserve it, and the defaults of the CRD schema are filled in.
+// The same manifest thus hashes the same before it is applied and once it is
+// live; `podsetctl hash` computes it from manifests.
+func ComputeSpecHash(spec *pixiuv1alpha1.PodSetSpec) (string, error) {
+	data, err := json.Marshal(canonicalSpec(spec))
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// canonicalSpec returns a copy of the spec as the API server stores it.
+func canonicalSpec(spec *pixiuv1alpha1.PodSetSpec) *pixiuv1alpha1.PodSetSpec {
+	canonical := spec.DeepCopy()
+	canonical.Template.ObjectMeta = metav1.ObjectMeta{}
+	if canonical.SpreadPolicy != nil && canonical.SpreadPolicy.MaxSkew == 0 {
+		canonical.SpreadPolicy.MaxSkew = 1
+	}
+	return canonical
+}
+
+// SetControllerSpecHash records the hash of the spec of the podSet in the
+// controller-spec-hash annotation before the controller writes the spec, so
+// that the change isn't reported as drift from the declared source. PodSets
+// without a declared source are left alone.
+func SetControllerSpecHash(podSet *pixiuv1alpha1.PodSet) error {
+	if _, ok := podSet.Annotations[types.SourceSpecHashAnnotation]; !ok {
+		return nil
+	}
+	hash, err := ComputeSpecHash(&podSet.Spec)
+	if err != nil {
+		return err
+	}
+	podSet.Annotations[types.ControllerSpecHashAnnotation] = hash
+	return nil
+}
+
+// ComputeTemplateHash returns a short hash of the pod template, identifying the
+// revision of the pods created from it.
+func ComputeTemplateHash(template *corev1.PodTemplateSpec) (string, error) {
+	data, err := json.Marshal(template)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:10], nil
+}
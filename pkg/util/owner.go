@@ -0,0 +1,71 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+// NewOwnerReference returns the reference to owner following the policy. A
+// nil policy gives a blocking controller reference.
+func NewOwnerReference(owner metav1.Object, gvk schema.GroupVersionKind, policy *pixiuv1alpha1.OwnerReferencePolicy) *metav1.OwnerReference {
+	blockOwnerDeletion, isController := true, true
+	if policy != nil {
+		if policy.BlockOwnerDeletion != nil {
+			blockOwnerDeletion = *policy.BlockOwnerDeletion
+		}
+		if policy.Controller != nil {
+			isController = *policy.Controller
+		}
+	}
+
+	return &metav1.OwnerReference{
+		APIVersion:         gvk.GroupVersion().String(),
+		Kind:               gvk.Kind,
+		Name:               owner.GetName(),
+		UID:                owner.GetUID(),
+		BlockOwnerDeletion: &blockOwnerDeletion,
+		Controller:         &isController,
+	}
+}
+
+// SetOwnerReference adds ref to the owner references of object, replacing the
+// existing reference to the same owner. It fails when ref is a controller
+// reference and the object is already controlled by another owner.
+func SetOwnerReference(object metav1.Object, ref metav1.OwnerReference) error {
+	if ref.Controller != nil && *ref.Controller {
+		if existing := metav1.GetControllerOf(object); existing != nil && existing.UID != ref.UID {
+			return fmt.Errorf("object %s/%s is already controlled by %s %s", object.GetNamespace(), object.GetName(), existing.Kind, existing.Name)
+		}
+	}
+
+	refs := object.GetOwnerReferences()
+	for i := range refs {
+		if refs[i].UID == ref.UID {
+			refs[i] = ref
+			object.SetOwnerReferences(refs)
+			return nil
+		}
+	}
+	object.SetOwnerReferences(append(refs, ref))
+	return nil
+}
@@ -17,7 +17,11 @@ limitations under the License.
 package util
 
 import (
+	"strconv"
+	"strings"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -31,6 +35,17 @@ func IsOwnedByKind(object client.Object, kind string) bool {
 	return false
 }
 
+// IsOwnedBy reports whether the object has an owner reference to the owner with the UID.
+func IsOwnedBy(object client.Object, uid types.UID) bool {
+	for _, ref := range object.GetOwnerReferences() {
+		if ref.UID == uid {
+			return true
+		}
+	}
+
+	return false
+}
+
 func GetOwnerByKind(object client.Object, kind string) *metav1.OwnerReference {
 	for _, ref := range object.GetOwnerReferences() {
 		if ref.Kind == kind {
@@ -40,3 +55,9 @@ func GetOwnerByKind(object client.Object, kind string) *metav1.OwnerReference {
 
 	return nil
 }
+
+// ExpandPodName returns the pod name template with its {podset}, {namespace}
+// and {index} placeholders replaced.
+func ExpandPodName(template, podSet, namespace string, index int) string {
+	return strings.NewReplacer("{podset}", podSet, "{namespace}", namespace, "{index}", strconv.Itoa(index)).Replace(template)
+}
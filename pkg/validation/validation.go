@@ -0,0 +1,533 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package validation checks PodSet objects without access to a cluster, so
+// the same rules can be applied by the controller and by CI pipelines.
+package validation
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apimachineryvalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+	"github.com/caoyingjunz/podset-operator/pkg/cron"
+	"github.com/caoyingjunz/podset-operator/pkg/podtemplate"
+	"github.com/caoyingjunz/podset-operator/pkg/util"
+)
+
+// ValidatePodSet tests if required fields in the PodSet are set.
+func ValidatePodSet(podSet *pixiuv1alpha1.PodSet) field.ErrorList {
+	allErrs := apimachineryvalidation.ValidateObjectMeta(&podSet.ObjectMeta, true, apimachineryvalidation.NameIsDNSSubdomain, field.NewPath("metadata"))
+	allErrs = append(allErrs, ValidatePodSetSpec(&podSet.Spec, field.NewPath("spec"))...)
+	if policy := podSet.Spec.PodNamePolicy; policy != nil {
+		allErrs = append(allErrs, validatePodNamePolicy(podSet, policy, field.NewPath("spec", "podNamePolicy"))...)
+	}
+	// The pods of a headless service are given the hostname <name>-<index>,
+	// which leaves room for 5 digits in a DNS label.
+	if len(podSet.Spec.ServiceName) != 0 && len(podSet.Name) > validation.DNS1123LabelMaxLength-6 {
+		allErrs = append(allErrs, field.TooLong(field.NewPath("metadata", "name"), podSet.Name, validation.DNS1123LabelMaxLength-6))
+	}
+	return allErrs
+}
+
+// ValidatePodSetUpdate tests if an update to the PodSet is valid. The selector
+// is immutable: the pods selected by the previous one would be leaked, left
+// running but no longer counted or deleted by the controller. The CRD schema
+// enforces the same with a transition rule on spec.selector.
+func ValidatePodSetUpdate(podSet, oldPodSet *pixiuv1alpha1.PodSet) field.ErrorList {
+	allErrs := ValidatePodSet(podSet)
+	allErrs = append(allErrs, apimachineryvalidation.ValidateImmutableField(podSet.Spec.Selector, oldPodSet.Spec.Selector, field.NewPath("spec", "selector"))...)
+	return allErrs
+}
+
+// ValidatePodSetSpec tests if required fields in the PodSet spec are set.
+func ValidatePodSetSpec(spec *pixiuv1alpha1.PodSetSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if spec.Replicas != nil {
+		allErrs = append(allErrs, apimachineryvalidation.ValidateNonnegativeField(int64(*spec.Replicas), fldPath.Child("replicas"))...)
+	}
+
+	if spec.Selector == nil {
+		allErrs = append(allErrs, field.Required(fldPath.Child("selector"), ""))
+	} else {
+		allErrs = append(allErrs, metav1validation.ValidateLabelSelector(spec.Selector, fldPath.Child("selector"))...)
+		if len(spec.Selector.MatchLabels)+len(spec.Selector.MatchExpressions) == 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("selector"), spec.Selector, "empty selector is invalid for podset"))
+		}
+	}
+
+	if spec.ScalePolicy != nil {
+		allErrs = append(allErrs, ValidateScalePolicy(spec.ScalePolicy, fldPath.Child("scalePolicy"))...)
+	}
+
+	if spec.ReplicaSource != nil {
+		allErrs = append(allErrs, ValidateReplicaSource(spec.ReplicaSource, fldPath.Child("replicaSource"))...)
+	}
+
+	if spec.UpdateStrategy != nil {
+		allErrs = append(allErrs, ValidateUpdateStrategy(spec.UpdateStrategy, fldPath.Child("updateStrategy"))...)
+	}
+
+	if spec.Scheduling != nil {
+		allErrs = append(allErrs, metav1validation.ValidateLabels(spec.Scheduling.NodeSelector, fldPath.Child("scheduling", "nodeSelector"))...)
+	}
+
+	if len(spec.PriorityClassName) != 0 {
+		for _, msg := range validation.IsDNS1123Subdomain(spec.PriorityClassName) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("priorityClassName"), spec.PriorityClassName, msg))
+		}
+	}
+
+	for i, gate := range spec.SchedulingGates {
+		for _, msg := range validation.IsQualifiedName(gate) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("schedulingGates").Index(i), gate, msg))
+		}
+	}
+
+	if spec.EnvOverrides != nil {
+		names := sets.NewString()
+		for i, env := range spec.EnvOverrides.Env {
+			envPath := fldPath.Child("envOverrides", "env").Index(i)
+			if len(env.Name) == 0 {
+				allErrs = append(allErrs, field.Required(envPath.Child("name"), ""))
+			} else if names.Has(env.Name) {
+				allErrs = append(allErrs, field.Duplicate(envPath.Child("name"), env.Name))
+			}
+			names.Insert(env.Name)
+		}
+	}
+
+	if len(spec.Sidecars) != 0 {
+		allErrs = append(allErrs, validateSidecars(spec.Sidecars, fldPath.Child("sidecars"))...)
+	}
+
+	if len(spec.Schedules) != 0 {
+		allErrs = append(allErrs, validateSchedules(spec.Schedules, fldPath.Child("schedules"))...)
+	}
+
+	if hook := spec.PreDeleteHook; hook != nil {
+		urlPath := fldPath.Child("preDeleteHook", "url")
+		if u, err := url.Parse(hook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			allErrs = append(allErrs, field.Invalid(urlPath, hook.URL, "must be an absolute http or https URL"))
+		} else if isLocalHost(u.Hostname()) {
+			allErrs = append(allErrs, field.Invalid(urlPath, hook.URL, "must not be a loopback or link-local address"))
+		}
+	}
+
+	if hibernation := spec.Hibernation; hibernation != nil {
+		hibernationPath := fldPath.Child("hibernation")
+		if len(hibernation.Windows) == 0 {
+			allErrs = append(allErrs, field.Required(hibernationPath.Child("windows"), ""))
+		}
+		for i, window := range hibernation.Windows {
+			if _, err := cron.Parse(window); err != nil {
+				allErrs = append(allErrs, field.Invalid(hibernationPath.Child("windows").Index(i), window, err.Error()))
+			}
+		}
+		if len(hibernation.TimeZone) != 0 {
+			if _, err := time.LoadLocation(hibernation.TimeZone); err != nil {
+				allErrs = append(allErrs, field.Invalid(hibernationPath.Child("timeZone"), hibernation.TimeZone, err.Error()))
+			}
+		}
+	}
+
+	zones := sets.NewString()
+	for i, zone := range spec.ZoneDistribution {
+		zonePath := fldPath.Child("zoneDistribution").Index(i)
+		if len(zone.Zone) == 0 {
+			allErrs = append(allErrs, field.Required(zonePath.Child("zone"), ""))
+		} else if zones.Has(zone.Zone) {
+			allErrs = append(allErrs, field.Duplicate(zonePath.Child("zone"), zone.Zone))
+		}
+		zones.Insert(zone.Zone)
+		if zone.Replicas != nil && zone.Weight != nil {
+			allErrs = append(allErrs, field.Invalid(zonePath, zone, "replicas and weight are mutually exclusive"))
+		}
+	}
+
+	if spec.PropagateMetadata != nil {
+		metadataPath := fldPath.Child("propagateMetadata")
+		for i, key := range spec.PropagateMetadata.Labels {
+			for _, msg := range validation.IsQualifiedName(key) {
+				allErrs = append(allErrs, field.Invalid(metadataPath.Child("labels").Index(i), key, msg))
+			}
+			// Propagating a selector label would let the PodSet labels
+			// change which pods it selects.
+			if spec.Selector != nil {
+				if _, ok := spec.Selector.MatchLabels[key]; ok {
+					allErrs = append(allErrs, field.Invalid(metadataPath.Child("labels").Index(i), key, "must not be a label of the selector"))
+				}
+			}
+		}
+		for i, key := range spec.PropagateMetadata.Annotations {
+			for _, msg := range validation.IsQualifiedName(key) {
+				allErrs = append(allErrs, field.Invalid(metadataPath.Child("annotations").Index(i), key, msg))
+			}
+		}
+	}
+
+	if len(spec.Overrides) != 0 {
+		allErrs = append(allErrs, validateOverrides(spec, fldPath.Child("overrides"))...)
+	}
+
+	if spec.MinReplicas != nil {
+		allErrs = append(allErrs, apimachineryvalidation.ValidateNonnegativeField(int64(*spec.MinReplicas), fldPath.Child("minReplicas"))...)
+	}
+	if spec.MaxReplicas != nil {
+		allErrs = append(allErrs, apimachineryvalidation.ValidateNonnegativeField(int64(*spec.MaxReplicas), fldPath.Child("maxReplicas"))...)
+		if spec.MinReplicas != nil && *spec.MaxReplicas < *spec.MinReplicas {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("maxReplicas"), *spec.MaxReplicas, "must be greater than or equal to minReplicas"))
+		}
+	}
+
+	if spec.StandbyReplicas != nil {
+		allErrs = append(allErrs, apimachineryvalidation.ValidateNonnegativeField(int64(*spec.StandbyReplicas), fldPath.Child("standbyReplicas"))...)
+		if spec.IdentityPolicy == pixiuv1alpha1.OrdinalPodIdentity || (spec.PodNamePolicy != nil && len(spec.PodNamePolicy.Template) != 0) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("standbyReplicas"), *spec.StandbyReplicas, "requires the Random identity policy and no pod name template"))
+		}
+	}
+
+	if spec.FinishedPodTTLSeconds != nil {
+		allErrs = append(allErrs, apimachineryvalidation.ValidateNonnegativeField(int64(*spec.FinishedPodTTLSeconds), fldPath.Child("finishedPodTTLSeconds"))...)
+	}
+
+	if len(spec.ServiceName) != 0 {
+		for _, msg := range validation.IsDNS1035Label(spec.ServiceName) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("serviceName"), spec.ServiceName, msg))
+		}
+		if spec.Selector != nil && len(spec.Selector.MatchLabels) == 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("selector", "matchLabels"), spec.Selector.MatchLabels, "must be set to select the pods of the headless service"))
+		}
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(spec.Selector)
+	if err != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("selector"), spec.Selector, "invalid label selector"))
+	} else {
+		template := &spec.Template
+		if len(template.Labels) == 0 && spec.Selector != nil {
+			// The CRD schema doesn't preserve the template metadata, the pods
+			// of a template without labels get the labels of the selector.
+			template = template.DeepCopy()
+			template.Labels = spec.Selector.MatchLabels
+		}
+		allErrs = append(allErrs, ValidatePodTemplateSpec(template, selector, fldPath.Child("template"))...)
+	}
+
+	return allErrs
+}
+
+// ValidateUpdateStrategy validates the bounds of a rolling update.
+func ValidateUpdateStrategy(strategy *pixiuv1alpha1.PodSetUpdateStrategy, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	rollingUpdate := strategy.RollingUpdate
+	if rollingUpdate == nil {
+		return allErrs
+	}
+	if strategy.Type == pixiuv1alpha1.OnDeletePodSetStrategyType {
+		return append(allErrs, field.Forbidden(fldPath.Child("rollingUpdate"), "may not be specified when type is OnDelete"))
+	}
+
+	rollingPath := fldPath.Child("rollingUpdate")
+	allErrs = append(allErrs, validateIntOrPercent(rollingUpdate.MaxSurge, rollingPath.Child("maxSurge"))...)
+	allErrs = append(allErrs, validateIntOrPercent(rollingUpdate.MaxUnavailable, rollingPath.Child("maxUnavailable"))...)
+	if isZero(rollingUpdate.MaxSurge) && isZero(rollingUpdate.MaxUnavailable) {
+		allErrs = append(allErrs, field.Invalid(rollingPath.Child("maxUnavailable"), rollingUpdate.MaxUnavailable, "may not be 0 when maxSurge is 0"))
+	}
+	return allErrs
+}
+
+func validateIntOrPercent(value *intstr.IntOrString, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if value == nil {
+		return allErrs
+	}
+	if value.Type == intstr.String {
+		percent, err := strconv.Atoi(strings.TrimSuffix(value.StrVal, "%"))
+		if err != nil || !strings.HasSuffix(value.StrVal, "%") {
+			return append(allErrs, field.Invalid(fldPath, value.StrVal, "must be an integer or a percentage, e.g. 25%"))
+		}
+		if percent < 0 || percent > 100 {
+			allErrs = append(allErrs, field.Invalid(fldPath, value.StrVal, "must be between 0% and 100%"))
+		}
+		return allErrs
+	}
+	return append(allErrs, apimachineryvalidation.ValidateNonnegativeField(int64(value.IntVal), fldPath)...)
+}
+
+// isZero reports whether the value is set to 0 or 0%.
+func isZero(value *intstr.IntOrString) bool {
+	if value == nil {
+		return false
+	}
+	if value.Type == intstr.String {
+		return value.StrVal == "0%"
+	}
+	return value.IntVal == 0
+}
+
+// ValidateScalePolicy validates the scale rate limits of a PodSet.
+func ValidateScalePolicy(policy *pixiuv1alpha1.ScalePolicy, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if policy.MaxCreatePerMinute != nil && *policy.MaxCreatePerMinute <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("maxCreatePerMinute"), *policy.MaxCreatePerMinute, "must be greater than 0"))
+	}
+	if policy.MaxDeletePerMinute != nil && *policy.MaxDeletePerMinute <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("maxDeletePerMinute"), *policy.MaxDeletePerMinute, "must be greater than 0"))
+	}
+	return allErrs
+}
+
+// ValidateReplicaSource validates the external replica source of a PodSet.
+func ValidateReplicaSource(source *pixiuv1alpha1.ReplicaSource, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	sources := 0
+	if source.Prometheus != nil {
+		sources++
+	}
+	if source.ConfigMapKeyRef != nil {
+		sources++
+		refPath := fldPath.Child("configMapKeyRef")
+		if len(source.ConfigMapKeyRef.Name) == 0 {
+			allErrs = append(allErrs, field.Required(refPath.Child("name"), ""))
+		}
+		if len(source.ConfigMapKeyRef.Key) == 0 {
+			allErrs = append(allErrs, field.Required(refPath.Child("key"), ""))
+		}
+	}
+	switch {
+	case sources == 0:
+		return append(allErrs, field.Required(fldPath, "a replica source must be specified"))
+	case sources > 1:
+		return append(allErrs, field.Forbidden(fldPath, "may not specify more than 1 replica source"))
+	}
+	if source.Prometheus == nil {
+		return allErrs
+	}
+
+	promPath := fldPath.Child("prometheus")
+	prom := source.Prometheus
+	if len(prom.Query) == 0 {
+		allErrs = append(allErrs, field.Required(promPath.Child("query"), ""))
+	}
+	if prom.Interval != nil && prom.Interval.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(promPath.Child("interval"), prom.Interval.Duration.String(), "must be greater than 0"))
+	}
+	minReplicas := int32(1)
+	if prom.MinReplicas != nil {
+		minReplicas = *prom.MinReplicas
+		allErrs = append(allErrs, apimachineryvalidation.ValidateNonnegativeField(int64(minReplicas), promPath.Child("minReplicas"))...)
+	}
+	if prom.MaxReplicas < 1 {
+		// An unset maxReplicas would clamp the replicas to 0.
+		allErrs = append(allErrs, field.Invalid(promPath.Child("maxReplicas"), prom.MaxReplicas, "must be greater than 0"))
+	} else if prom.MaxReplicas < minReplicas {
+		allErrs = append(allErrs, field.Invalid(promPath.Child("maxReplicas"), prom.MaxReplicas, "must be greater than or equal to minReplicas"))
+	}
+	switch prom.ScalingFunction {
+	case "", pixiuv1alpha1.ProportionalScaling:
+		if prom.TargetValue == nil {
+			allErrs = append(allErrs, field.Required(promPath.Child("targetValue"), "required by the Proportional scaling function"))
+		} else if prom.TargetValue.Sign() <= 0 {
+			allErrs = append(allErrs, field.Invalid(promPath.Child("targetValue"), prom.TargetValue.String(), "must be greater than 0"))
+		}
+	case pixiuv1alpha1.DirectScaling:
+	default:
+		allErrs = append(allErrs, field.NotSupported(promPath.Child("scalingFunction"), prom.ScalingFunction,
+			[]string{string(pixiuv1alpha1.ProportionalScaling), string(pixiuv1alpha1.DirectScaling)}))
+	}
+	return allErrs
+}
+
+// ValidatePodTemplateSpec validates the pod template of a PodSet against its selector.
+func ValidatePodTemplateSpec(template *corev1.PodTemplateSpec, selector labels.Selector, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	metaPath := fldPath.Child("metadata")
+	allErrs = append(allErrs, metav1validation.ValidateLabels(template.Labels, metaPath.Child("labels"))...)
+	allErrs = append(allErrs, apimachineryvalidation.ValidateAnnotations(template.Annotations, metaPath.Child("annotations"))...)
+	if !selector.Empty() && !selector.Matches(labels.Set(template.Labels)) {
+		allErrs = append(allErrs, field.Invalid(metaPath.Child("labels"), template.Labels, "`selector` does not match template `labels`"))
+	}
+
+	allErrs = append(allErrs, validateContainers(template.Spec.InitContainers, fldPath.Child("spec", "initContainers"), false)...)
+	allErrs = append(allErrs, validateContainers(template.Spec.Containers, fldPath.Child("spec", "containers"), true)...)
+
+	switch template.Spec.RestartPolicy {
+	case "", corev1.RestartPolicyAlways:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("spec", "restartPolicy"), template.Spec.RestartPolicy, []string{string(corev1.RestartPolicyAlways)}))
+	}
+
+	if err := podtemplate.Validate(template); err != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath, "", err.Error()))
+	}
+
+	return allErrs
+}
+
+// isLocalHost reports whether the host is localhost or a loopback, link-local
+// or unspecified address, which reach the controller itself or the metadata
+// services of its node.
+func isLocalHost(host string) bool {
+	if host = strings.ToLower(host); host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified())
+}
+
+// validatePodNamePolicy checks that the pod names are valid, the template
+// being checked with the largest index of 5 digits.
+func validatePodNamePolicy(podSet *pixiuv1alpha1.PodSet, policy *pixiuv1alpha1.PodNamePolicy, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if len(policy.Template) != 0 {
+		if !strings.Contains(policy.Template, "{index}") {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("template"), policy.Template, "must contain {index}"))
+		}
+		name := util.ExpandPodName(policy.Template, podSet.Name, podSet.Namespace, 99999)
+		for _, msg := range apimachineryvalidation.NameIsDNSSubdomain(name, false) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("template"), policy.Template, msg))
+		}
+	} else if len(policy.Prefix) != 0 {
+		for _, msg := range apimachineryvalidation.NameIsDNSSubdomain(policy.Prefix, true) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("prefix"), policy.Prefix, msg))
+		}
+	}
+	return allErrs
+}
+
+func validateSchedules(schedules []pixiuv1alpha1.ReplicaSchedule, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	names := sets.NewString()
+	for i, schedule := range schedules {
+		idxPath := fldPath.Index(i)
+		if len(schedule.Name) == 0 {
+			allErrs = append(allErrs, field.Required(idxPath.Child("name"), ""))
+		} else if names.Has(schedule.Name) {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), schedule.Name))
+		}
+		names.Insert(schedule.Name)
+		if _, err := cron.Parse(schedule.Schedule); err != nil {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("schedule"), schedule.Schedule, err.Error()))
+		}
+		allErrs = append(allErrs, apimachineryvalidation.ValidateNonnegativeField(int64(schedule.Replicas), idxPath.Child("replicas"))...)
+		if len(schedule.TimeZone) != 0 {
+			if _, err := time.LoadLocation(schedule.TimeZone); err != nil {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("timeZone"), schedule.TimeZone, err.Error()))
+			}
+		}
+	}
+	return allErrs
+}
+
+func validateOverrides(spec *pixiuv1alpha1.PodSetSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if spec.IdentityPolicy != pixiuv1alpha1.OrdinalPodIdentity {
+		allErrs = append(allErrs, field.Invalid(fldPath, len(spec.Overrides), "require the Ordinal identity policy"))
+	}
+	containers := sets.NewString()
+	for _, container := range spec.Template.Spec.Containers {
+		containers.Insert(container.Name)
+	}
+	indexes := sets.NewInt32()
+	for i, override := range spec.Overrides {
+		idxPath := fldPath.Index(i)
+		allErrs = append(allErrs, apimachineryvalidation.ValidateNonnegativeField(int64(override.Index), idxPath.Child("index"))...)
+		if indexes.Has(override.Index) {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("index"), override.Index))
+		}
+		indexes.Insert(override.Index)
+		for j, container := range override.Containers {
+			if !containers.Has(container.Name) {
+				allErrs = append(allErrs, field.NotFound(idxPath.Child("containers").Index(j).Child("name"), container.Name))
+			}
+		}
+		allErrs = append(allErrs, metav1validation.ValidateLabels(override.NodeSelector, idxPath.Child("nodeSelector"))...)
+	}
+	return allErrs
+}
+
+func validateSidecars(sidecars []pixiuv1alpha1.Sidecar, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	names := sets.NewString()
+	for i, sidecar := range sidecars {
+		containerPath := fldPath.Index(i).Child("container")
+		c := sidecar.Container
+		if len(c.Name) == 0 {
+			allErrs = append(allErrs, field.Required(containerPath.Child("name"), ""))
+		} else {
+			for _, msg := range validation.IsDNS1123Label(c.Name) {
+				allErrs = append(allErrs, field.Invalid(containerPath.Child("name"), c.Name, msg))
+			}
+			if names.Has(c.Name) {
+				allErrs = append(allErrs, field.Duplicate(containerPath.Child("name"), c.Name))
+			}
+			names.Insert(c.Name)
+		}
+		if len(c.Image) == 0 {
+			allErrs = append(allErrs, field.Required(containerPath.Child("image"), ""))
+		}
+	}
+	return allErrs
+}
+
+func validateContainers(containers []corev1.Container, fldPath *field.Path, required bool) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if required && len(containers) == 0 {
+		return append(allErrs, field.Required(fldPath, ""))
+	}
+
+	names := sets.NewString()
+	for i, c := range containers {
+		idxPath := fldPath.Index(i)
+		if len(c.Name) == 0 {
+			allErrs = append(allErrs, field.Required(idxPath.Child("name"), ""))
+		} else {
+			for _, msg := range validation.IsDNS1123Label(c.Name) {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), c.Name, msg))
+			}
+			if names.Has(c.Name) {
+				allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), c.Name))
+			}
+			names.Insert(c.Name)
+		}
+		if len(c.Image) == 0 {
+			allErrs = append(allErrs, field.Required(idxPath.Child("image"), ""))
+		}
+	}
+	return allErrs
+}
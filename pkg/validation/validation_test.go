@@ -0,0 +1,279 @@
+/*
+Copyright 2021 The Pixiu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package validation
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
+
+	pixiuv1alpha1 "github.com/caoyingjunz/podset-operator/api/v1alpha1"
+)
+
+func validPodSet() *pixiuv1alpha1.PodSet {
+	return &pixiuv1alpha1.PodSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec: pixiuv1alpha1.PodSetSpec{
+			Replicas: pointer.Int32(3),
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web"}},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "web", Image: "nginx"}},
+				},
+			},
+		},
+	}
+}
+
+func TestValidatePodSet(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(podSet *pixiuv1alpha1.PodSet)
+		// wantFields are the fields of the errors, none for a valid PodSet.
+		wantFields []string
+	}{
+		{
+			name:   "valid",
+			mutate: func(podSet *pixiuv1alpha1.PodSet) {},
+		},
+		{
+			name:   "template labels left to the selector",
+			mutate: func(podSet *pixiuv1alpha1.PodSet) { podSet.Spec.Template.Labels = nil },
+		},
+		{
+			name:       "missing namespace",
+			mutate:     func(podSet *pixiuv1alpha1.PodSet) { podSet.Namespace = "" },
+			wantFields: []string{"metadata.namespace"},
+		},
+		{
+			name:       "negative replicas",
+			mutate:     func(podSet *pixiuv1alpha1.PodSet) { podSet.Spec.Replicas = pointer.Int32(-1) },
+			wantFields: []string{"spec.replicas"},
+		},
+		{
+			name:       "missing selector",
+			mutate:     func(podSet *pixiuv1alpha1.PodSet) { podSet.Spec.Selector = nil },
+			wantFields: []string{"spec.selector", "spec.template.metadata.labels"},
+		},
+		{
+			name: "empty selector",
+			mutate: func(podSet *pixiuv1alpha1.PodSet) {
+				podSet.Spec.Selector = &metav1.LabelSelector{}
+			},
+			wantFields: []string{"spec.selector"},
+		},
+		{
+			name: "selector not matching the template",
+			mutate: func(podSet *pixiuv1alpha1.PodSet) {
+				podSet.Spec.Template.Labels = map[string]string{"app": "api"}
+			},
+			wantFields: []string{"spec.template.metadata.labels"},
+		},
+		{
+			name: "selector expressions not matching its labels",
+			mutate: func(podSet *pixiuv1alpha1.PodSet) {
+				podSet.Spec.Template.Labels = nil
+				podSet.Spec.Selector.MatchExpressions = []metav1.LabelSelectorRequirement{{
+					Key:      "tier",
+					Operator: metav1.LabelSelectorOpExists,
+				}}
+			},
+			wantFields: []string{"spec.template.metadata.labels"},
+		},
+		{
+			name:       "no containers",
+			mutate:     func(podSet *pixiuv1alpha1.PodSet) { podSet.Spec.Template.Spec.Containers = nil },
+			wantFields: []string{"spec.template.spec.containers"},
+		},
+		{
+			name: "duplicate container",
+			mutate: func(podSet *pixiuv1alpha1.PodSet) {
+				podSet.Spec.Template.Spec.Containers = append(podSet.Spec.Template.Spec.Containers, corev1.Container{Name: "web", Image: "nginx"})
+			},
+			wantFields: []string{"spec.template.spec.containers[1].name"},
+		},
+		{
+			name: "container without image",
+			mutate: func(podSet *pixiuv1alpha1.PodSet) {
+				podSet.Spec.Template.Spec.Containers[0].Image = ""
+			},
+			wantFields: []string{"spec.template.spec.containers[0].image"},
+		},
+		{
+			name: "unsupported restart policy",
+			mutate: func(podSet *pixiuv1alpha1.PodSet) {
+				podSet.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyNever
+			},
+			wantFields: []string{"spec.template.spec.restartPolicy"},
+		},
+		{
+			name: "zero create rate",
+			mutate: func(podSet *pixiuv1alpha1.PodSet) {
+				podSet.Spec.ScalePolicy = &pixiuv1alpha1.ScalePolicy{MaxCreatePerMinute: pointer.Int32(0)}
+			},
+			wantFields: []string{"spec.scalePolicy.maxCreatePerMinute"},
+		},
+		{
+			name: "no replica source",
+			mutate: func(podSet *pixiuv1alpha1.PodSet) {
+				podSet.Spec.ReplicaSource = &pixiuv1alpha1.ReplicaSource{}
+			},
+			wantFields: []string{"spec.replicaSource"},
+		},
+		{
+			name: "prometheus source without maxReplicas",
+			mutate: func(podSet *pixiuv1alpha1.PodSet) {
+				target := resource.MustParse("10")
+				podSet.Spec.ReplicaSource = &pixiuv1alpha1.ReplicaSource{
+					Prometheus: &pixiuv1alpha1.PrometheusReplicaSource{Query: "up", TargetValue: &target},
+				}
+			},
+			wantFields: []string{"spec.replicaSource.prometheus.maxReplicas"},
+		},
+		{
+			name: "prometheus source",
+			mutate: func(podSet *pixiuv1alpha1.PodSet) {
+				target := resource.MustParse("10")
+				podSet.Spec.ReplicaSource = &pixiuv1alpha1.ReplicaSource{
+					Prometheus: &pixiuv1alpha1.PrometheusReplicaSource{Query: "up", MaxReplicas: 10, TargetValue: &target},
+				}
+			},
+		},
+		{
+			name: "invalid template syntax",
+			mutate: func(podSet *pixiuv1alpha1.PodSet) {
+				podSet.Spec.Template.Spec.Containers[0].Args = []string{"{{ if .Index }}x{{ end }}"}
+			},
+			wantFields: []string{"spec.template"},
+		},
+		{
+			name: "zero maxSurge and maxUnavailable",
+			mutate: func(podSet *pixiuv1alpha1.PodSet) {
+				zero := intstr.FromInt(0)
+				podSet.Spec.UpdateStrategy = &pixiuv1alpha1.PodSetUpdateStrategy{
+					RollingUpdate: &pixiuv1alpha1.RollingUpdatePodSet{MaxSurge: &zero, MaxUnavailable: &zero},
+				}
+			},
+			wantFields: []string{"spec.updateStrategy.rollingUpdate.maxUnavailable"},
+		},
+		{
+			name: "percentage above 100%",
+			mutate: func(podSet *pixiuv1alpha1.PodSet) {
+				surge := intstr.FromString("150%")
+				podSet.Spec.UpdateStrategy = &pixiuv1alpha1.PodSetUpdateStrategy{
+					RollingUpdate: &pixiuv1alpha1.RollingUpdatePodSet{MaxSurge: &surge},
+				}
+			},
+			wantFields: []string{"spec.updateStrategy.rollingUpdate.maxSurge"},
+		},
+		{
+			name: "pod name template without index",
+			mutate: func(podSet *pixiuv1alpha1.PodSet) {
+				podSet.Spec.PodNamePolicy = &pixiuv1alpha1.PodNamePolicy{Template: "{podset}"}
+			},
+			wantFields: []string{"spec.podNamePolicy.template"},
+		},
+		{
+			name: "maxReplicas below minReplicas",
+			mutate: func(podSet *pixiuv1alpha1.PodSet) {
+				podSet.Spec.MinReplicas = pointer.Int32(3)
+				podSet.Spec.MaxReplicas = pointer.Int32(2)
+			},
+			wantFields: []string{"spec.maxReplicas"},
+		},
+		{
+			name: "relative pre-delete hook",
+			mutate: func(podSet *pixiuv1alpha1.PodSet) {
+				podSet.Spec.PreDeleteHook = &pixiuv1alpha1.PreDeleteHook{URL: "/hooks/pre-delete"}
+			},
+			wantFields: []string{"spec.preDeleteHook.url"},
+		},
+		{
+			name: "loopback pre-delete hook",
+			mutate: func(podSet *pixiuv1alpha1.PodSet) {
+				podSet.Spec.PreDeleteHook = &pixiuv1alpha1.PreDeleteHook{URL: "http://localhost:8081/healthz"}
+			},
+			wantFields: []string{"spec.preDeleteHook.url"},
+		},
+		{
+			name: "link-local pre-delete hook",
+			mutate: func(podSet *pixiuv1alpha1.PodSet) {
+				podSet.Spec.PreDeleteHook = &pixiuv1alpha1.PreDeleteHook{URL: "http://169.254.169.254/latest/meta-data"}
+			},
+			wantFields: []string{"spec.preDeleteHook.url"},
+		},
+		{
+			name: "pre-delete hook",
+			mutate: func(podSet *pixiuv1alpha1.PodSet) {
+				podSet.Spec.PreDeleteHook = &pixiuv1alpha1.PreDeleteHook{URL: "https://hooks.default.svc/pre-delete"}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			podSet := validPodSet()
+			tt.mutate(podSet)
+			errs := ValidatePodSet(podSet)
+			if len(errs) != len(tt.wantFields) {
+				t.Fatalf("ValidatePodSet() = %v, want errors for %v", errs, tt.wantFields)
+			}
+			for i := range errs {
+				if errs[i].Field != tt.wantFields[i] {
+					t.Errorf("ValidatePodSet() error %d = %v, want an error for %s", i, errs[i], tt.wantFields[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidatePodSetUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(podSet *pixiuv1alpha1.PodSet)
+		wantErr bool
+	}{
+		{
+			name:   "replicas changed",
+			mutate: func(podSet *pixiuv1alpha1.PodSet) { podSet.Spec.Replicas = pointer.Int32(5) },
+		},
+		{
+			name: "selector changed",
+			mutate: func(podSet *pixiuv1alpha1.PodSet) {
+				podSet.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web", "tier": "front"}}
+				podSet.Spec.Template.Labels = map[string]string{"app": "web", "tier": "front"}
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldPodSet := validPodSet()
+			podSet := validPodSet()
+			tt.mutate(podSet)
+			if errs := ValidatePodSetUpdate(podSet, oldPodSet); (len(errs) != 0) != tt.wantErr {
+				t.Errorf("ValidatePodSetUpdate() = %v, want error %v", errs, tt.wantErr)
+			}
+		})
+	}
+}